@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/config"
+	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/internal/db/sqlitedb"
+	"github.com/siraj18/balance-service-new/internal/utils"
+	"github.com/siraj18/balance-service-new/pkg/postgres"
+	"os"
+	"strings"
+)
+
+const usage = "available commands: audit verify, import <file.csv> [--dry-run], config print"
+
+func runCommand(cfg *config.Config, args []string) error {
+	switch {
+	case strings.Join(args, " ") == "config print":
+		return printConfig(cfg)
+	case strings.Join(args, " ") == "audit verify":
+		return verifyAuditLog(cfg)
+	case args[0] == "import" && len(args) == 2:
+		return importBalances(cfg, args[1], false)
+	case args[0] == "import" && len(args) == 3 && args[2] == "--dry-run":
+		return importBalances(cfg, args[1], true)
+	default:
+		return fmt.Errorf("unknown command %q, %s", strings.Join(args, " "), usage)
+	}
+}
+
+// printConfig prints the effective config with the secrets redacted, then
+// reports whether it is valid.
+func printConfig(cfg *config.Config) error {
+	if err := cfg.Print(os.Stdout); err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+func verifyAuditLog(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	db, err := connect(cfg.Database, cfg.Database.Url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var log interface {
+		VerifyAuditLog(ctx context.Context) (int, error)
+	} = postgresdb.NewAuditRepository(db)
+	if cfg.Database.Storage == "sqlite" {
+		log = sqlitedb.NewAuditRepository(db)
+	}
+
+	count, err := log.VerifyAuditLog(context.Background())
+	if err != nil {
+		return fmt.Errorf("verified %d entries: %w", count, err)
+	}
+
+	fmt.Printf("audit log is intact, %d entries verified\n", count)
+
+	return nil
+}
+
+func importBalances(cfg *config.Config, path string, dryRun bool) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	db, err := connect(cfg.Database, cfg.Database.Url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "cli"})
+	ctx = audit.WithSource(ctx, audit.Source{Endpoint: "CLI import", PayloadHash: audit.HashPayload(payload)})
+
+	var importer utils.BalanceImporter = postgresdb.ConnectSqlRepository(db)
+	if cfg.Database.Storage == "sqlite" {
+		importer = sqlitedb.ConnectSqlRepository(db)
+	} else if cfg.Database.Driver == "pgx" {
+		pool, err := postgres.NewPool(cfg.Database.Url, cfg.Database.ConnectRetries, poolOptions(cfg.Database)...)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+
+		importer = postgresdb.NewPgxRepository(pool, postgresdb.ConnectSqlRepository(db))
+	}
+
+	result, err := utils.ImportCsv(ctx, importer, bytes.NewReader(payload), dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(result); err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d of %d lines are invalid, nothing was imported", len(result.Errors), result.Rows)
+	}
+
+	return nil
+}
@@ -1,12 +1,31 @@
 package main
 
 import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/config"
 	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/internal/events"
+	"github.com/siraj18/balance-service-new/internal/grpcapi"
 	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/health"
+	"github.com/siraj18/balance-service-new/internal/lifecycle"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/ratelimit"
+	"github.com/siraj18/balance-service-new/internal/scheduler"
 	"github.com/siraj18/balance-service-new/internal/server"
-	"github.com/siraj18/balance-service-new/pkg/postgres"
+	"github.com/siraj18/balance-service-new/internal/stream"
+	"github.com/siraj18/balance-service-new/internal/tracing"
+	"github.com/siraj18/balance-service-new/internal/webhooks"
 	"github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -14,24 +33,212 @@ import (
 // @version 1.0
 // @description api for balance service
 // @BasePath /
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
-	conStr := os.Getenv("connection_string_postgres")
-	address := os.Getenv("address")
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if len(args) > 0 {
+		if err := runCommand(cfg, args); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
+	if err = cfg.Validate(); err != nil {
+		logrus.Fatal(err)
+	}
+
+	if err = logging.Configure(logrus.StandardLogger(), cfg.Log.Format, cfg.Log.Level); err != nil {
+		logrus.Fatal(err)
+	}
+
+	db, err := connect(cfg.Database, cfg.Database.Url)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if err = metrics.RegisterDB(db.DB, "balance"); err != nil {
+		logrus.Fatal(err)
+	}
 
-	db, err := postgres.NewDb(conStr, 10)
+	store, err := newStorage(cfg.Database, db)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	rep, err := postgresdb.NewSqlRepository(db)
+	// components are stopped in the reverse order: the servers first, so that
+	// no new work comes in, then the workers, the relay and the database
+	manager := lifecycle.NewManager()
+
+	if kind := cfg.Tracing.Exporter; kind != "" {
+		exporter, err := tracing.NewExporter(context.Background(), kind)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		provider := tracing.Install(sdktrace.WithBatcher(exporter))
+		manager.Add(lifecycle.Component{Name: "tracing", Stop: provider.Shutdown, Timeout: cfg.Shutdown.DatabaseTimeout})
+	}
+
+	manager.Add(lifecycle.Closer("database", db.Close, cfg.Shutdown.DatabaseTimeout))
+	if store.replica != nil {
+		manager.Add(lifecycle.Closer("database replica", store.replica.Close, cfg.Shutdown.DatabaseTimeout))
+	}
+	if store.pool != nil {
+		manager.Add(lifecycle.Closer("database pool", func() error { store.pool.Close(); return nil }, cfg.Shutdown.DatabaseTimeout))
+	}
+
+	var opts []handlers.Option
+	var grpcOpts []grpcapi.Option
+
+	authenticator, err := newAuthenticator(cfg.Auth)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	handler := handlers.NewHandler(rep)
+	if authenticator != nil {
+		opts = append(opts, handlers.WithAuthenticator(authenticator))
+		grpcOpts = append(grpcOpts, grpcapi.WithAuthenticator(authenticator))
+	} else {
+		logrus.Warn("no authentication configured, all routes are open")
+	}
 
-	server := server.NewServer(address, handler.InitRoutes(), time.Second*10)
-	if err := server.Run(); err != nil {
+	publisher, err := newPublisher(cfg.Events)
+	if err != nil {
 		logrus.Fatal(err)
 	}
+
+	if publisher != nil {
+		manager.Add(lifecycle.Closer("events publisher", publisher.Close, cfg.Shutdown.WorkersTimeout))
+		manager.Add(lifecycle.Worker("event relay", events.NewRelay(store.rep, publisher, time.Second, 100).Run, cfg.Shutdown.WorkersTimeout))
+	} else {
+		logrus.Warn("no events publisher configured, events stay in the outbox")
+	}
+
+	serverOpts := []server.Option{}
+
+	if store.streams {
+		hub := stream.NewHub(cfg.Database.Url, postgresdb.BalanceChangesChannel)
+		manager.Add(lifecycle.Worker("balance stream", hub.Run, cfg.Shutdown.WorkersTimeout))
+		opts = append(opts, handlers.WithBalanceStream(hub))
+		serverOpts = append(serverOpts, server.WithOnShutdown(hub.CloseSubscriptions))
+	} else {
+		logrus.Warnf("balance streams are not available with %s storage", cfg.Database.Storage)
+	}
+
+	manager.Add(lifecycle.Worker("scheduler", scheduler.NewScheduler(store.rep, time.Second*10, 100).Run, cfg.Shutdown.WorkersTimeout))
+	manager.Add(lifecycle.Worker("webhook dispatcher", webhooks.NewDispatcher(store.rep, time.Second*5, 50).Run, cfg.Shutdown.WorkersTimeout))
+
+	auditLog := store.auditLog
+	opts = append(opts, handlers.WithAuditLog(auditLog), handlers.WithIdempotency(store.idempotency),
+		handlers.WithReportsFolder(cfg.Reports.Folder), handlers.WithTimeout(cfg.Http.RequestTimeout))
+
+	if err = os.MkdirAll(cfg.Reports.Folder, 0o755); err != nil {
+		logrus.Fatal(err)
+	}
+
+	checker := health.NewChecker(cfg.Health.Timeout,
+		health.Database(db),
+		health.Check{Name: "schema", Run: store.rep.CheckSchema},
+		health.Writable("reports", cfg.Reports.Folder),
+	)
+	opts = append(opts, handlers.WithHealth(checker))
+
+	// the memory store limits the clients of this instance only
+	var limits ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "redis" {
+		redisOpts, err := redis.ParseURL(cfg.RateLimit.RedisUrl)
+		if err != nil {
+			logrus.Fatalf("invalid rate_limit.redis_url: %s", err)
+		}
+
+		client := redis.NewClient(redisOpts)
+		manager.Add(lifecycle.Closer("rate limit store", client.Close, cfg.Shutdown.DatabaseTimeout))
+		limits = ratelimit.NewRedisStore(client)
+	}
+	opts = append(opts, handlers.WithRateLimits(limits,
+		ratelimit.Limit{Rate: cfg.RateLimit.AddressRate, Burst: cfg.RateLimit.AddressBurst},
+		ratelimit.Limit{Rate: cfg.RateLimit.ReadRate, Burst: cfg.RateLimit.ReadBurst},
+		ratelimit.Limit{Rate: cfg.RateLimit.WriteRate, Burst: cfg.RateLimit.WriteBurst}))
+	grpcOpts = append(grpcOpts, grpcapi.WithAuditLog(auditLog))
+
+	grpcServer := grpcapi.NewServer(cfg.Grpc.Address, store.service, grpcOpts...)
+	manager.Add(lifecycle.Component{
+		Name:    "grpc server",
+		Run:     func(context.Context) error { return grpcServer.Run() },
+		Stop:    grpcServer.Shutdown,
+		Timeout: cfg.Http.ShutdownTimeout,
+	})
+
+	handler := handlers.NewHandler(store.service, opts...)
+	serverOpts = append(serverOpts, server.WithDrain(checker.Drain, cfg.Http.DrainDelay))
+	httpServer := server.NewServer(cfg.Http.Address, handler.InitRoutes(), cfg.Http.RequestTimeout, serverOpts...)
+	manager.Add(lifecycle.Component{
+		Name:    "http server",
+		Run:     func(context.Context) error { return httpServer.Run() },
+		Stop:    httpServer.Shutdown,
+		Timeout: cfg.Http.DrainDelay + cfg.Http.ShutdownTimeout,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = manager.Run(ctx); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func newAuthenticator(cfg config.Auth) (auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if cfg.ApiKeysFile != "" {
+		keys, err := auth.LoadApiKeys(cfg.ApiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+
+		authenticators = append(authenticators, auth.NewApiKeyAuthenticator(keys))
+	}
+
+	if cfg.JwtSecret != "" || cfg.JwksFile != "" {
+		var err error
+		rsaKeys := map[string]*rsa.PublicKey{}
+
+		if cfg.JwksFile != "" {
+			rsaKeys, err = auth.LoadJwks(cfg.JwksFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		authenticators = append(authenticators,
+			auth.NewJwtAuthenticator([]byte(cfg.JwtSecret), rsaKeys, cfg.JwtIssuer, cfg.JwtAudience))
+	}
+
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+
+	return auth.NewChain(authenticators...), nil
+}
+
+func newPublisher(cfg config.Events) (events.EventPublisher, error) {
+	switch cfg.Publisher {
+	case "":
+		return nil, nil
+	case "kafka":
+		return events.NewKafkaPublisher(cfg.KafkaBrokers, cfg.KafkaTopic), nil
+	case "nats":
+		return events.NewNatsPublisher(cfg.NatsUrl, cfg.NatsSubject)
+	default:
+		return nil, fmt.Errorf("unknown events publisher %q, expected kafka or nats", cfg.Publisher)
+	}
 }
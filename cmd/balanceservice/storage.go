@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/config"
+	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/internal/db/sqlitedb"
+	"github.com/siraj18/balance-service-new/internal/events"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/idempotency"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/scheduler"
+	"github.com/siraj18/balance-service-new/internal/tracing"
+	"github.com/siraj18/balance-service-new/internal/webhooks"
+	"github.com/siraj18/balance-service-new/pkg/postgres"
+	"github.com/siraj18/balance-service-new/pkg/sqlite"
+)
+
+// repository is what the workers and the health checks need of a storage.
+type repository interface {
+	handlers.Repository
+	events.Outbox
+	scheduler.Executor
+	webhooks.Store
+	CheckSchema(ctx context.Context) error
+}
+
+// storage is the repository of the configured database together with the
+// stores kept in the same database.
+type storage struct {
+	rep repository
+	// service serves the api, it is rep unless the pgx driver is configured
+	service     handlers.Repository
+	auditLog    audit.Log
+	idempotency idempotency.Store
+	// replica and pool are nil unless configured
+	replica *sqlx.DB
+	pool    *pgxpool.Pool
+	// streams is false when the storage cannot notify balance changes
+	streams bool
+}
+
+// connect opens the database at url with the pool settings of cfg.
+func connect(cfg config.Database, url string) (*sqlx.DB, error) {
+	if cfg.Storage == "sqlite" {
+		return sqlite.NewDb(url, sqlite.WithMaxOpenConns(cfg.MaxOpenConns))
+	}
+
+	return postgres.NewDb(url, cfg.ConnectRetries, poolOptions(cfg)...)
+}
+
+func poolOptions(cfg config.Database) []postgres.Option {
+	return []postgres.Option{
+		postgres.WithMaxOpenConns(cfg.MaxOpenConns),
+		postgres.WithMaxIdleConns(cfg.MaxIdleConns),
+		postgres.WithConnMaxLifetime(cfg.ConnMaxLifetime),
+		postgres.WithStatementTimeout(cfg.StatementTimeout),
+		postgres.WithBackoff(cfg.ConnectBackoff, cfg.ConnectMaxBackoff),
+		// the statements are traced within traced requests
+		postgres.WithConnector(tracing.WrapConnector),
+		postgres.WithOnRetry(metrics.RecordConnectRetry),
+	}
+}
+
+// newStorage initializes the schema in db and builds the repositories of the
+// configured storage on it.
+func newStorage(cfg config.Database, db *sqlx.DB) (*storage, error) {
+	if cfg.Storage == "sqlite" {
+		rep, err := sqlitedb.NewSqlRepository(db)
+		if err != nil {
+			return nil, err
+		}
+
+		return &storage{
+			rep:         rep,
+			service:     rep,
+			auditLog:    sqlitedb.NewAuditRepository(db),
+			idempotency: sqlitedb.NewIdempotencyRepository(db),
+		}, nil
+	}
+
+	s := &storage{auditLog: postgresdb.NewAuditRepository(db), idempotency: postgresdb.NewIdempotencyRepository(db), streams: true}
+
+	var repOpts []postgresdb.Option
+	var err error
+
+	if cfg.ReplicaUrl != "" {
+		if s.replica, err = connect(cfg, cfg.ReplicaUrl); err != nil {
+			return nil, err
+		}
+
+		if err = metrics.RegisterDB(s.replica.DB, "balance_replica"); err != nil {
+			return nil, err
+		}
+
+		repOpts = append(repOpts, postgresdb.WithReplica(s.replica, cfg.ReplicaMaxLag))
+	}
+
+	rep, err := postgresdb.NewSqlRepository(db, repOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.rep, s.service = rep, rep
+
+	// the pgx repository serves the hot paths of the api on its own pool, the
+	// workers and the rest of the api stay on db
+	if cfg.Driver == "pgx" {
+		if s.pool, err = postgres.NewPool(cfg.Url, cfg.ConnectRetries, poolOptions(cfg)...); err != nil {
+			return nil, err
+		}
+
+		err = metrics.RegisterPool("balance_pgx", func() metrics.PoolStats {
+			stat := s.pool.Stat()
+
+			return metrics.PoolStats{
+				MaxConns:        stat.MaxConns(),
+				TotalConns:      stat.TotalConns(),
+				AcquiredConns:   stat.AcquiredConns(),
+				IdleConns:       stat.IdleConns(),
+				AcquireCount:    stat.AcquireCount(),
+				AcquireDuration: stat.AcquireDuration(),
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		s.service = postgresdb.NewPgxRepository(s.pool, rep)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+// Source is the call changes are audited as. The workers and the commands,
+// which serve no call, set a source of their own.
+type Source struct {
+	Endpoint    string
+	PayloadHash string
+	// Status is recorded with the changes, which commit only when the call
+	// succeeds
+	Status int
+}
+
+type sourceKey struct{}
+
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// ScheduleSource is the source of the transfers run by the schedule with id.
+func ScheduleSource(id string) Source {
+	return Source{Endpoint: "SCHEDULE " + id}
+}
+
+// Change is the balance change of an account.
+type Change struct {
+	AccountId     string
+	Before, After float64
+}
+
+// NewEntry returns the entry auditing changes made on behalf of ctx. The
+// repositories append it in the transaction of the changes, so that a change
+// never commits without its entry.
+func NewEntry(ctx context.Context, changes ...Change) *models.AuditEntry {
+	source, _ := ctx.Value(sourceKey{}).(Source)
+
+	before := make(map[string]float64)
+	after := make(map[string]float64)
+
+	for _, change := range changes {
+		if _, ok := before[change.AccountId]; !ok {
+			before[change.AccountId] = change.Before
+		}
+		after[change.AccountId] = change.After
+	}
+
+	beforeJson, _ := json.Marshal(before)
+	afterJson, _ := json.Marshal(after)
+
+	return &models.AuditEntry{
+		Actor:          auth.Actor(ctx),
+		Endpoint:       source.Endpoint,
+		PayloadHash:    source.PayloadHash,
+		BalancesBefore: string(beforeJson),
+		BalancesAfter:  string(afterJson),
+		Status:         source.Status,
+		Outcome:        OutcomeSuccess,
+		CreatedAt:      time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+// Recorder notes whether the call it serves committed changes audited with
+// their transaction.
+type Recorder struct {
+	committed atomic.Bool
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Committed reports whether the entry of the call was appended with its
+// changes.
+func (r *Recorder) Committed() bool {
+	return r.committed.Load()
+}
+
+type recorderKey struct{}
+
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// RecordCommitted tells the middleware serving ctx that the entry of its call
+// was committed with the changes, so that it does not append another one. It
+// is a no-op outside audited calls.
+func RecordCommitted(ctx context.Context) {
+	if r, ok := ctx.Value(recorderKey{}).(*Recorder); ok {
+		r.committed.Store(true)
+	}
+}
+
+func HashPayload(payload []byte) string {
+	sum := sha256.Sum256(payload)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash chains entry to the hash of the previous entry of the log.
+func Hash(prevHash string, entry *models.AuditEntry) string {
+	h := sha256.New()
+
+	for _, field := range []string{
+		prevHash,
+		entry.Actor,
+		entry.Endpoint,
+		entry.PayloadHash,
+		entry.BalancesBefore,
+		entry.BalancesAfter,
+		strconv.Itoa(entry.Status),
+		entry.Outcome,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
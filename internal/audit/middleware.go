@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/response"
+	"github.com/sirupsen/logrus"
+)
+
+type Log interface {
+	AppendAuditEntry(context.Context, *models.AuditEntry) error
+}
+
+// outcome classifies the response, a rejection together with its message.
+func outcome(w *response.Writer) string {
+	switch {
+	case w.Failed():
+		return OutcomeFailed
+	case w.Rejected():
+		return OutcomeRejected + ": " + strings.TrimSpace(string(w.Body()))
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// NoBalances is recorded by the entries of calls that changed no balance.
+const NoBalances = "{}"
+
+// Middleware audits every request it wraps. The repositories append the
+// entry of a request that changes money together with the change, otherwise
+// it is appended after the request has been served.
+func Middleware(log Log, logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "invalid post data", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(payload))
+
+			source := Source{
+				Endpoint:    r.Method + " " + chi.RouteContext(r.Context()).RoutePattern(),
+				PayloadHash: HashPayload(payload),
+				Status:      http.StatusOK,
+			}
+
+			recorder := NewRecorder()
+			ww := response.NewBodyWriter(w)
+
+			next.ServeHTTP(ww, r.WithContext(WithRecorder(WithSource(r.Context(), source), recorder)))
+
+			if recorder.Committed() {
+				return
+			}
+
+			entry := &models.AuditEntry{
+				Actor:          auth.Actor(r.Context()),
+				Endpoint:       source.Endpoint,
+				PayloadHash:    source.PayloadHash,
+				BalancesBefore: NoBalances,
+				BalancesAfter:  NoBalances,
+				Status:         ww.Status(),
+				Outcome:        outcome(ww),
+				CreatedAt:      time.Now().UTC().Truncate(time.Microsecond),
+			}
+
+			if err := log.AppendAuditEntry(context.Background(), entry); err != nil {
+				logger.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+	"os"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type ApiKey struct {
+	Key     string   `json:"key"`
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes"`
+}
+
+type apiKeyAuthenticator struct {
+	keys []ApiKey
+}
+
+func NewApiKeyAuthenticator(keys []ApiKey) *apiKeyAuthenticator {
+	return &apiKeyAuthenticator{keys: keys}
+}
+
+// LoadApiKeys reads a JSON array of ApiKey from path.
+func LoadApiKeys(path string) ([]ApiKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []ApiKey
+	if err := json.NewDecoder(file).Decode(&keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		return nil, api.ErrorNoCredentials
+	}
+
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return &Identity{Subject: k.Subject, Scopes: k.Scopes}, nil
+		}
+	}
+
+	return nil, api.ErrorInvalidCredentials
+}
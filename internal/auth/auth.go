@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+)
+
+const (
+	ScopeReadBalance  = "balance:read"
+	ScopeWriteBalance = "balance:write"
+	ScopeTransfer     = "transfer"
+	ScopeReserve      = "reserve"
+	ScopeReports      = "reports"
+	ScopeAccounts     = "accounts"
+	ScopeAdmin        = "admin"
+)
+
+const anonymousActor = "anonymous"
+
+type Identity struct {
+	Subject string
+	Scopes  []string
+}
+
+func (i *Identity) HasScope(scope string) bool {
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator resolves the caller of a request. Implementations return
+// api.ErrorNoCredentials when the request carries no credentials of their kind,
+// so that several authenticators can be chained.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type chain []Authenticator
+
+func NewChain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+func (c chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, a := range c {
+		identity, err := a.Authenticate(r)
+		if err == api.ErrorNoCredentials {
+			continue
+		}
+
+		return identity, err
+	}
+
+	return nil, api.ErrorNoCredentials
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+
+	return identity
+}
+
+// Actor returns the subject that should be recorded as the author of changes
+// made within ctx.
+func Actor(ctx context.Context) string {
+	if identity := IdentityFromContext(ctx); identity != nil && identity.Subject != "" {
+		return identity.Subject
+	}
+
+	return anonymousActor
+}
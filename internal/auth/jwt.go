@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const bearerPrefix = "Bearer "
+
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+type jwtAuthenticator struct {
+	secret   []byte
+	rsaKeys  map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+}
+
+// NewJwtAuthenticator accepts HS256 tokens signed with secret and RS256 tokens
+// signed by one of rsaKeys, which are looked up by the "kid" header.
+// Either may be empty to disable that algorithm. Tokens must expire, and
+// their "iss" and "aud" claims must match issuer and audience unless these
+// are empty.
+func NewJwtAuthenticator(secret []byte, rsaKeys map[string]*rsa.PublicKey, issuer, audience string) *jwtAuthenticator {
+	return &jwtAuthenticator{
+		secret:   secret,
+		rsaKeys:  rsaKeys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return nil, api.ErrorNoCredentials
+	}
+
+	claims := jwtClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(header, bearerPrefix), &claims, a.keyFunc)
+	if err != nil {
+		return nil, api.ErrorInvalidCredentials
+	}
+
+	if claims.Subject == "" {
+		return nil, api.ErrorInvalidCredentials
+	}
+
+	// the parser only checks the expiry of tokens that have one
+	if !claims.VerifyExpiresAt(time.Now(), true) {
+		return nil, api.ErrorInvalidCredentials
+	}
+
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return nil, api.ErrorInvalidCredentials
+	}
+
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return nil, api.ErrorInvalidCredentials
+	}
+
+	return &Identity{Subject: claims.Subject, Scopes: strings.Fields(claims.Scope)}, nil
+}
+
+func (a *jwtAuthenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		if len(a.secret) == 0 {
+			return nil, fmt.Errorf("HS256 tokens are not accepted")
+		}
+
+		return a.secret, nil
+	case jwt.SigningMethodRS256:
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := a.rsaKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// LoadJwks reads the RSA keys of a JSON Web Key Set file.
+func LoadJwks(path string) (map[string]*rsa.PublicKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var set jwks
+	if err := json.NewDecoder(file).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,180 @@
+// Package config loads the configuration of the service from defaults, a
+// YAML file, the environment and flags, in increasing order of precedence.
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Every field is named by its yaml path, which is also its flag, e.g.
+// -http.address, and may be set by the env variable of its env tag. The
+// env names predate the config file and are kept as they are.
+type Config struct {
+	Http      Http      `yaml:"http"`
+	Grpc      Grpc      `yaml:"grpc"`
+	Database  Database  `yaml:"database"`
+	Log       Log       `yaml:"log"`
+	Auth      Auth      `yaml:"auth"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Events    Events    `yaml:"events"`
+	Reports   Reports   `yaml:"reports"`
+	Health    Health    `yaml:"health"`
+	Shutdown  Shutdown  `yaml:"shutdown"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+}
+
+type Http struct {
+	Address         string        `yaml:"address" env:"address" default:":8080"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" env:"http_request_timeout" default:"10s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"http_shutdown_timeout" default:"5s"`
+	DrainDelay      time.Duration `yaml:"drain_delay" env:"http_drain_delay" default:"0s"`
+}
+
+type Grpc struct {
+	Address string `yaml:"address" env:"grpc_address" default:":9090"`
+}
+
+// Database is the primary and its optional read replica, which share the
+// pool settings. Connecting is retried with a backoff doubling from
+// connect_backoff up to connect_max_backoff. With the sqlite storage url is
+// the path of the database file.
+type Database struct {
+	Storage           string        `yaml:"storage" env:"db_storage" default:"postgres"`
+	Url               string        `yaml:"url" env:"connection_string_postgres" secret:"true"`
+	Driver            string        `yaml:"driver" env:"db_driver" default:"sqlx"`
+	ConnectRetries    int           `yaml:"connect_retries" env:"db_connect_retries" default:"10"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff" env:"db_connect_backoff" default:"500ms"`
+	ConnectMaxBackoff time.Duration `yaml:"connect_max_backoff" env:"db_connect_max_backoff" default:"10s"`
+	MaxOpenConns      int           `yaml:"max_open_conns" env:"db_max_open_conns" default:"10"`
+	MaxIdleConns      int           `yaml:"max_idle_conns" env:"db_max_idle_conns" default:"10"`
+	ConnMaxLifetime   time.Duration `yaml:"conn_max_lifetime" env:"db_conn_max_lifetime" default:"30m"`
+	StatementTimeout  time.Duration `yaml:"statement_timeout" env:"db_statement_timeout" default:"30s"`
+	ReplicaUrl        string        `yaml:"replica_url" env:"connection_string_postgres_replica" secret:"true"`
+	ReplicaMaxLag     time.Duration `yaml:"replica_max_lag" env:"db_replica_max_lag" default:"5s"`
+}
+
+type Log struct {
+	Format string `yaml:"format" env:"log_format" default:"text"`
+	Level  string `yaml:"level" env:"log_level" default:"info"`
+}
+
+type Auth struct {
+	ApiKeysFile string `yaml:"api_keys_file" env:"api_keys_file"`
+	JwtSecret   string `yaml:"jwt_secret" env:"jwt_secret" secret:"true"`
+	JwksFile    string `yaml:"jwks_file" env:"jwt_jwks_file"`
+	JwtIssuer   string `yaml:"jwt_issuer" env:"jwt_issuer"`
+	JwtAudience string `yaml:"jwt_audience" env:"jwt_audience"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"tracing_exporter"`
+}
+
+type Events struct {
+	Publisher    string   `yaml:"publisher" env:"events_publisher"`
+	KafkaBrokers []string `yaml:"kafka_brokers" env:"kafka_brokers"`
+	KafkaTopic   string   `yaml:"kafka_topic" env:"kafka_topic" default:"balance-events"`
+	NatsUrl      string   `yaml:"nats_url" env:"nats_url" default:"nats://localhost:4222"`
+	NatsSubject  string   `yaml:"nats_subject" env:"nats_subject" default:"balance"`
+}
+
+type Reports struct {
+	Folder string `yaml:"folder" env:"reports_folder" default:"./files/reports/"`
+}
+
+// Shutdown bounds stopping the components after the HTTP and gRPC servers,
+// which are bounded by http.shutdown_timeout.
+type Shutdown struct {
+	WorkersTimeout  time.Duration `yaml:"workers_timeout" env:"shutdown_workers_timeout" default:"30s"`
+	DatabaseTimeout time.Duration `yaml:"database_timeout" env:"shutdown_database_timeout" default:"5s"`
+}
+
+// RateLimit limits the requests of every client, the rates are in requests
+// per second and zero disables the limit. The address budget is taken by
+// every api request before it is authenticated. The redis store shares the
+// limits between the instances of the service.
+type RateLimit struct {
+	Store        string  `yaml:"store" env:"rate_limit_store" default:"memory"`
+	RedisUrl     string  `yaml:"redis_url" env:"rate_limit_redis_url" secret:"true"`
+	AddressRate  float64 `yaml:"address_rate" env:"rate_limit_address_rate" default:"100"`
+	AddressBurst int     `yaml:"address_burst" env:"rate_limit_address_burst" default:"200"`
+	ReadRate     float64 `yaml:"read_rate" env:"rate_limit_read_rate" default:"20"`
+	ReadBurst    int     `yaml:"read_burst" env:"rate_limit_read_burst" default:"40"`
+	WriteRate    float64 `yaml:"write_rate" env:"rate_limit_write_rate" default:"10"`
+	WriteBurst   int     `yaml:"write_burst" env:"rate_limit_write_burst" default:"20"`
+}
+
+type Health struct {
+	Timeout time.Duration `yaml:"timeout" env:"health_timeout" default:"2s"`
+}
+
+// Validate reports every invalid setting of c at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Http.Address != "", "http.address is required")
+	check(c.Http.RequestTimeout > 0, "http.request_timeout must be positive")
+	check(c.Http.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+	check(c.Http.DrainDelay >= 0, "http.drain_delay must not be negative")
+	check(c.Grpc.Address != "", "grpc.address is required")
+
+	check(c.Database.Url != "", "database.url is required")
+	check(oneOf(c.Database.Storage, "postgres", "sqlite"), "database.storage must be postgres or sqlite")
+	check(oneOf(c.Database.Driver, "sqlx", "pgx"), "database.driver must be sqlx or pgx")
+	check(c.Database.Storage == "postgres" || c.Database.Driver == "sqlx", "database.driver pgx requires postgres storage")
+	check(c.Database.Storage == "postgres" || c.Database.ReplicaUrl == "", "database.replica_url requires postgres storage")
+	check(c.Database.ConnectRetries > 0, "database.connect_retries must be positive")
+	check(c.Database.ConnectBackoff > 0, "database.connect_backoff must be positive")
+	check(c.Database.ConnectMaxBackoff >= c.Database.ConnectBackoff, "database.connect_max_backoff must not be below database.connect_backoff")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	check(c.Database.ReplicaMaxLag > 0, "database.replica_max_lag must be positive")
+
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format must be text or json")
+	_, err := logrus.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level %q is unknown", c.Log.Level)
+
+	check(oneOf(c.Tracing.Exporter, "", "otlp", "stdout"), "tracing.exporter must be otlp or stdout")
+	check(oneOf(c.Events.Publisher, "", "kafka", "nats"), "events.publisher must be kafka or nats")
+	check(c.Events.Publisher != "kafka" || len(c.Events.KafkaBrokers) > 0, "events.kafka_brokers is required for kafka")
+
+	check(c.Reports.Folder != "", "reports.folder is required")
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+	check(c.Shutdown.WorkersTimeout > 0, "shutdown.workers_timeout must be positive")
+	check(c.Shutdown.DatabaseTimeout > 0, "shutdown.database_timeout must be positive")
+
+	check(oneOf(c.RateLimit.Store, "memory", "redis"), "rate_limit.store must be memory or redis")
+	check(c.RateLimit.Store != "redis" || c.RateLimit.RedisUrl != "", "rate_limit.redis_url is required for redis")
+	check(c.RateLimit.AddressRate >= 0 && c.RateLimit.ReadRate >= 0 && c.RateLimit.WriteRate >= 0,
+		"rate_limit rates must not be negative")
+	check(c.RateLimit.AddressRate == 0 || c.RateLimit.AddressBurst > 0, "rate_limit.address_burst must be positive")
+	check(c.RateLimit.ReadRate == 0 || c.RateLimit.ReadBurst > 0, "rate_limit.read_burst must be positive")
+	check(c.RateLimit.WriteRate == 0 || c.RateLimit.WriteBurst > 0, "rate_limit.write_burst must be positive")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+
+	return false
+}
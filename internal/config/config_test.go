@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestDefaults(t *testing.T) {
+	c, args, err := Load(nil)
+	require.NoError(t, err)
+	require.Empty(t, args)
+
+	require.Equal(t, ":8080", c.Http.Address)
+	require.Equal(t, 10*time.Second, c.Http.RequestTimeout)
+	require.Equal(t, 5*time.Second, c.Http.ShutdownTimeout)
+	require.Equal(t, 10, c.Database.ConnectRetries)
+	require.Equal(t, "postgres", c.Database.Storage)
+	require.Equal(t, "sqlx", c.Database.Driver)
+	require.Equal(t, "./files/reports/", c.Reports.Folder)
+
+	require.ErrorContains(t, c.Validate(), "database.url is required")
+
+	c.Database.Url = "postgres://localhost/balance"
+	require.NoError(t, c.Validate())
+
+	c.Database.Storage = "sqlite"
+	c.Database.Driver = "pgx"
+	require.ErrorContains(t, c.Validate(), "database.driver pgx requires postgres storage")
+}
+
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+http:
+  address: ":7000"
+  request_timeout: 3s
+  shutdown_timeout: 7s
+database:
+  url: postgres://file/balance
+  connect_retries: 3
+`)
+
+	t.Setenv("config_file", file)
+	t.Setenv("http_request_timeout", "4s")
+	t.Setenv("db_connect_retries", "4")
+	t.Setenv("kafka_brokers", "a:9092, b:9092")
+	t.Setenv("rate_limit_read_rate", "2.5")
+
+	c, args, err := Load([]string{"-database.connect_retries", "5", "import", "balances.csv", "--dry-run"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"import", "balances.csv", "--dry-run"}, args)
+
+	require.Equal(t, ":7000", c.Http.Address)
+	require.Equal(t, 4*time.Second, c.Http.RequestTimeout)
+	require.Equal(t, 7*time.Second, c.Http.ShutdownTimeout)
+	require.Equal(t, "postgres://file/balance", c.Database.Url)
+	require.Equal(t, 5, c.Database.ConnectRetries)
+	require.Equal(t, []string{"a:9092", "b:9092"}, c.Events.KafkaBrokers)
+	require.Equal(t, 2.5, c.RateLimit.ReadRate)
+}
+
+func TestInvalidSources(t *testing.T) {
+	_, _, err := Load([]string{"-config", writeFile(t, "config.yaml", "http:\n  adress: :7000\n")})
+	require.ErrorContains(t, err, "adress")
+
+	t.Setenv("db_connect_retries", "ten")
+	_, _, err = Load(nil)
+	require.ErrorContains(t, err, "db_connect_retries")
+
+	_, _, err = Load([]string{"-http.unknown", "1"})
+	require.Error(t, err)
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c, _, err := Load([]string{"-http.request_timeout", "0s", "-log.format", "xml", "-events.publisher", "kafka", "-rate_limit.store", "redis", "-database.driver", "pq"})
+	require.NoError(t, err)
+
+	err = c.Validate()
+	require.ErrorContains(t, err, "database.url is required")
+	require.ErrorContains(t, err, "http.request_timeout must be positive")
+	require.ErrorContains(t, err, "log.format must be text or json")
+	require.ErrorContains(t, err, "events.kafka_brokers is required for kafka")
+	require.ErrorContains(t, err, "rate_limit.redis_url is required for redis")
+	require.ErrorContains(t, err, "database.driver must be sqlx or pgx")
+}
+
+func TestSecrets(t *testing.T) {
+	secret := writeFile(t, "jwt", "s3cret\n")
+	t.Setenv("jwt_secret", SecretFilePrefix+secret)
+	t.Setenv("connection_string_postgres", "postgres://user:password@db/balance")
+
+	c, _, err := Load(nil)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", c.Auth.JwtSecret)
+
+	var out bytes.Buffer
+	require.NoError(t, c.Print(&out))
+
+	require.NotContains(t, out.String(), "s3cret")
+	require.NotContains(t, out.String(), "password")
+	require.Contains(t, out.String(), "jwt_secret: <redacted>")
+	require.Contains(t, out.String(), "request_timeout: 10s")
+	require.Contains(t, out.String(), `api_keys_file: ""`)
+
+	require.Equal(t, "s3cret", c.Auth.JwtSecret, "printing must not redact the config itself")
+
+	t.Setenv("jwt_secret", SecretFilePrefix+filepath.Join(t.TempDir(), "missing"))
+	_, _, err = Load(nil)
+	require.ErrorContains(t, err, "auth.jwt_secret")
+}
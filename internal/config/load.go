@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretFilePrefix marks the value of a secret setting as the path of a file
+// holding the secret, e.g. database.url: file:/run/secrets/postgres.
+const SecretFilePrefix = "file:"
+
+const redacted = "<redacted>"
+
+// field is a setting of the config, reached by reflection.
+type field struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+	def    string
+}
+
+func fields(c *Config) []field {
+	var result []field
+
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			path := prefix + f.Tag.Get("yaml")
+
+			if f.Type.Kind() == reflect.Struct {
+				walk(path+".", v.Field(i))
+				continue
+			}
+
+			result = append(result, field{
+				path:   path,
+				env:    f.Tag.Get("env"),
+				secret: f.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+				def:    f.Tag.Get("default"),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+
+	return result
+}
+
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		f.value.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", f.path, s)
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", f.path, s)
+		}
+		f.value.SetFloat(n)
+	case []string:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("%s: unsupported type %s", f.path, f.value.Type())
+	}
+
+	return nil
+}
+
+// flagValue records the flags given on the command line, they are applied
+// after the file and the environment.
+type flagValue struct {
+	path string
+	set  map[string]string
+}
+
+func (v *flagValue) String() string { return "" }
+
+func (v *flagValue) Set(s string) error {
+	v.set[v.path] = s
+	return nil
+}
+
+// Load returns the config built from the defaults, the YAML file named by the
+// -config flag or the config_file env variable, the env variables and the
+// flags in args, each overriding the previous ones. Secrets given as
+// file:<path> are read from the file. The arguments left after the flags are
+// returned as the command to run. Load does not validate the config.
+func Load(args []string) (*Config, []string, error) {
+	c := &Config{}
+	settings := fields(c)
+
+	flags := flag.NewFlagSet("balanceservice", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("config_file"), "YAML config file")
+
+	given := map[string]string{}
+	for _, f := range settings {
+		usage := "default " + strconv.Quote(f.def)
+		if f.env != "" {
+			usage += ", env " + f.env
+		}
+		flags.Var(&flagValue{path: f.path, set: given}, f.path, usage)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range settings {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if *file != "" {
+		if err := loadFile(c, *file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range settings {
+		if value := os.Getenv(f.env); f.env != "" && value != "" {
+			if err := f.set(value); err != nil {
+				return nil, nil, fmt.Errorf("env %s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, f := range settings {
+		if value, ok := given[f.path]; ok {
+			if err := f.set(value); err != nil {
+				return nil, nil, fmt.Errorf("flag %w", err)
+			}
+		}
+	}
+
+	for _, f := range settings {
+		if err := f.readSecret(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return c, flags.Args(), nil
+}
+
+func loadFile(c *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (f field) readSecret() error {
+	value, ok := f.value.Interface().(string)
+	if !f.secret || !ok || !strings.HasPrefix(value, SecretFilePrefix) {
+		return nil
+	}
+
+	data, err := os.ReadFile(strings.TrimPrefix(value, SecretFilePrefix))
+	if err != nil {
+		return fmt.Errorf("%s: error reading secret: %w", f.path, err)
+	}
+	f.value.SetString(strings.TrimSpace(string(data)))
+
+	return nil
+}
+
+// Redacted returns a copy of c with the secrets that are set replaced.
+func (c *Config) Redacted() *Config {
+	copied := *c
+
+	for _, f := range fields(&copied) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString(redacted)
+		}
+	}
+
+	return &copied
+}
+
+// Print writes c as YAML to w, with the secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
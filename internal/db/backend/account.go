@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// CanDebit returns why money cannot be taken off the account, if it cannot.
+func CanDebit(account *models.Account) error {
+	if account.Status == models.AccountClosed {
+		return api.ErrorAccountClosed
+	}
+
+	if account.DebitsBlocked {
+		return api.ErrorAccountFrozen
+	}
+
+	return nil
+}
+
+// CanCredit returns why money cannot be put on the account, if it cannot.
+func CanCredit(account *models.Account) error {
+	if account.Status == models.AccountClosed {
+		return api.ErrorAccountClosed
+	}
+
+	if account.CreditsBlocked {
+		return api.ErrorAccountFrozen
+	}
+
+	return nil
+}
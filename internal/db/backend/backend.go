@@ -0,0 +1,16 @@
+// Package backend holds what the storage backends share: the rules of the
+// money operations, limits, schedules and webhooks, and the errors that are
+// not part of the api. It keeps the backends independent of each other.
+package backend
+
+import "fmt"
+
+// SchemaVersion is the version of the schemas of the sql backends. It is
+// stored with the schema and must be raised whenever a schema changes, so
+// that services built for another schema report themselves not ready.
+const SchemaVersion = 2
+
+var (
+	ErrorSchemaOutdated   = fmt.Errorf("database schema is outdated")
+	ErrorAuditChainBroken = fmt.Errorf("audit log chain is broken")
+)
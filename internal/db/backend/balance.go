@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"math"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+)
+
+// RoundCents rounds money like the DECIMAL(10, 2) columns of Postgres do.
+func RoundCents(money float64) float64 {
+	return math.Round(money*100) / 100
+}
+
+// RecordBalanceChange counts a committed deposit or withdrawal of money.
+func RecordBalanceChange(money float64) {
+	if money >= 0 {
+		metrics.RecordOperation(metrics.OperationDeposit, money)
+	} else {
+		metrics.RecordOperation(metrics.OperationWithdrawal, -money)
+	}
+}
+
+// TransferChanges returns the balance changes of a transfer of money that
+// left the accounts with the given balances.
+func TransferChanges(fromUid, toUid string, money, fromBalance, toBalance float64) []audit.Change {
+	return []audit.Change{
+		{AccountId: fromUid, Before: fromBalance + money, After: fromBalance},
+		{AccountId: toUid, Before: toBalance - money, After: toBalance},
+	}
+}
@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// NewEvent returns an event made by the actor of ctx, without its sequence,
+// which the outbox assigns.
+func NewEvent(ctx context.Context, eventType, accountId string, data models.AccountEventData) (*models.Event, error) {
+	data.Actor = auth.Actor(ctx)
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Event{
+		Id:         uuid.New().String(),
+		Type:       eventType,
+		Version:    models.EventSchemaVersion,
+		AccountId:  accountId,
+		OccurredAt: time.Now(),
+		Data:       payload,
+	}, nil
+}
@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const GlobalLimitsScope = "global"
+
+const (
+	limitMaxOperation    = "max_operation"
+	limitDailyDebit      = "daily_debit"
+	limitMonthlyDebit    = "monthly_debit"
+	limitHourlyTransfers = "hourly_transfers"
+)
+
+// LimitExceededError tells which limit rejected an operation and when it
+// allows operations again. ResetsAt is nil for limits on a single operation.
+type LimitExceededError struct {
+	Limit    string
+	ResetsAt *time.Time
+}
+
+func (e *LimitExceededError) Error() string {
+	if e.ResetsAt == nil {
+		return fmt.Sprintf("%s: %s", api.ErrorLimitExceeded, e.Limit)
+	}
+
+	return fmt.Sprintf("%s: %s, resets at %s", api.ErrorLimitExceeded, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *LimitExceededError) Unwrap() error {
+	return api.ErrorLimitExceeded
+}
+
+// ValidateLimitsScope accepts the global scope and account ids.
+func ValidateLimitsScope(scope string) error {
+	if scope == GlobalLimitsScope {
+		return nil
+	}
+
+	if _, err := uuid.Parse(scope); err != nil {
+		return api.ErrorInvalidInput
+	}
+
+	return nil
+}
+
+// ValidateLimits rejects negative limits.
+func ValidateLimits(limits models.Limits) error {
+	for _, limit := range []*float64{limits.MaxOperation, limits.DailyDebit, limits.MonthlyDebit} {
+		if limit != nil && *limit < 0 {
+			return api.ErrorInvalidLimits
+		}
+	}
+
+	if limits.HourlyTransfers != nil && *limits.HourlyTransfers < 0 {
+		return api.ErrorInvalidLimits
+	}
+
+	return nil
+}
+
+// MergeLimits merges the limits of the account scope over the global ones.
+func MergeLimits(scopes []models.Limits) *models.Limits {
+	var global, account models.Limits
+
+	for _, limits := range scopes {
+		if limits.Scope == GlobalLimitsScope {
+			global = limits
+		} else {
+			account = limits
+		}
+	}
+
+	if account.MaxOperation == nil {
+		account.MaxOperation = global.MaxOperation
+	}
+	if account.DailyDebit == nil {
+		account.DailyDebit = global.DailyDebit
+	}
+	if account.MonthlyDebit == nil {
+		account.MonthlyDebit = global.MonthlyDebit
+	}
+	if account.HourlyTransfers == nil {
+		account.HourlyTransfers = global.HourlyTransfers
+	}
+
+	return &account
+}
+
+// DebitTotals reads the past debits of an account: Debited returns the
+// amount debited since a time, Transfers the number of transfers since a time
+// and the oldest of them. They are only read for the limits that are set.
+type DebitTotals struct {
+	Debited   func(since time.Time) (float64, error)
+	Transfers func(since time.Time) (int, *time.Time, error)
+}
+
+// CheckLimits checks a debit of amount at now against limits.
+func CheckLimits(limits *models.Limits, amount float64, transfer bool, now time.Time, totals DebitTotals) error {
+	if limits.MaxOperation != nil && amount > *limits.MaxOperation {
+		return &LimitExceededError{Limit: limitMaxOperation}
+	}
+
+	if limits.DailyDebit != nil {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+		if err := checkDebitTotal(totals, amount, *limits.DailyDebit, start, start.AddDate(0, 0, 1), limitDailyDebit); err != nil {
+			return err
+		}
+	}
+
+	if limits.MonthlyDebit != nil {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+		if err := checkDebitTotal(totals, amount, *limits.MonthlyDebit, start, start.AddDate(0, 1, 0), limitMonthlyDebit); err != nil {
+			return err
+		}
+	}
+
+	if transfer && limits.HourlyTransfers != nil {
+		count, oldest, err := totals.Transfers(now.Add(-time.Hour))
+		if err != nil {
+			return err
+		}
+
+		if count >= *limits.HourlyTransfers {
+			resetsAt := now
+			if oldest != nil {
+				resetsAt = oldest.Add(time.Hour)
+			}
+
+			return &LimitExceededError{Limit: limitHourlyTransfers, ResetsAt: &resetsAt}
+		}
+	}
+
+	return nil
+}
+
+func checkDebitTotal(totals DebitTotals, amount, limit float64, start, resetsAt time.Time, name string) error {
+	total, err := totals.Debited(start)
+	if err != nil {
+		return err
+	}
+
+	if total+amount > limit {
+		return &LimitExceededError{Limit: name, ResetsAt: &resetsAt}
+	}
+
+	return nil
+}
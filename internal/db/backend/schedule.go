@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const (
+	defaultScheduleRetries = 3
+	maxScheduleBackoff     = time.Hour
+)
+
+// ScheduleOwner returns the actor whose schedules the caller of ctx may see
+// and change, or "" when an admin may reach every schedule. The schedules of
+// other actors are reported as not found.
+func ScheduleOwner(ctx context.Context) string {
+	if identity := auth.IdentityFromContext(ctx); identity != nil && identity.HasScope(auth.ScopeAdmin) {
+		return ""
+	}
+
+	return auth.Actor(ctx)
+}
+
+// Recurring reports whether the schedule runs more than once.
+func Recurring(schedule *models.Schedule) bool {
+	return schedule.Cron != nil || schedule.IntervalSeconds != nil
+}
+
+// NextRun returns the first run of a recurring schedule after the given time.
+// An interval schedule keeps to the runs counted from its SlotAt, skipping the
+// ones already missed, so that late or retried runs do not shift it.
+func NextRun(schedule *models.Schedule, after time.Time) (*time.Time, error) {
+	var next time.Time
+
+	switch {
+	case schedule.Cron != nil:
+		spec, err := cron.ParseStandard(*schedule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", api.ErrorInvalidSchedule, err)
+		}
+
+		next = spec.Next(after.UTC())
+	case schedule.IntervalSeconds != nil:
+		interval := time.Duration(*schedule.IntervalSeconds) * time.Second
+
+		next = after.Add(interval)
+		if schedule.SlotAt != nil {
+			next = *schedule.SlotAt
+			if !next.After(after) {
+				next = next.Add((after.Sub(next)/interval + 1) * interval)
+			}
+		}
+	default:
+		return nil, nil
+	}
+
+	return &next, nil
+}
+
+// RetryBackoff doubles the delay with every failed attempt, starting at one
+// minute.
+func RetryBackoff(attempt int) time.Duration {
+	backoff := time.Minute << (attempt - 1)
+	if backoff <= 0 || backoff > maxScheduleBackoff {
+		return maxScheduleBackoff
+	}
+
+	return backoff
+}
+
+// NewSchedule validates query and returns the schedule it creates, with the
+// first run of a recurring schedule computed from now.
+func NewSchedule(query models.CreateScheduleQuery, now time.Time) (*models.Schedule, error) {
+	if query.Money <= 0 || query.FromId == query.ToId {
+		return nil, api.ErrorInvalidSchedule
+	}
+
+	schedule := models.Schedule{MaxRetries: defaultScheduleRetries}
+	if query.MaxRetries != nil {
+		if *query.MaxRetries < 0 {
+			return nil, api.ErrorInvalidSchedule
+		}
+
+		schedule.MaxRetries = *query.MaxRetries
+	}
+
+	given := 0
+	if query.RunAt != nil {
+		given++
+	}
+	if query.Cron != "" {
+		schedule.Cron = &query.Cron
+		given++
+	}
+	if query.IntervalSeconds != 0 {
+		if query.IntervalSeconds < 0 {
+			return nil, api.ErrorInvalidSchedule
+		}
+
+		schedule.IntervalSeconds = &query.IntervalSeconds
+		given++
+	}
+	if given != 1 {
+		return nil, api.ErrorInvalidSchedule
+	}
+
+	schedule.NextRunAt = query.RunAt
+	if Recurring(&schedule) {
+		next, err := NextRun(&schedule, now)
+		if err != nil {
+			return nil, err
+		}
+
+		schedule.NextRunAt = next
+	}
+	schedule.SlotAt = schedule.NextRunAt
+
+	return &schedule, nil
+}
+
+// AdvanceSchedule moves schedule past the run finished at now with transferErr:
+// a failed run is retried with a backoff until its retries are used up, a
+// recurring schedule moves to the first of its runs after now and a one-off
+// one is done. The retries keep SlotAt, so the runs after them stay on time.
+func AdvanceSchedule(schedule *models.Schedule, transferErr error, now time.Time) error {
+	attempt := schedule.Attempt + 1
+
+	if schedule.SlotAt == nil {
+		schedule.SlotAt = schedule.NextRunAt
+	}
+
+	switch {
+	case transferErr != nil && attempt <= schedule.MaxRetries:
+		retryAt := now.Add(RetryBackoff(attempt))
+		schedule.NextRunAt = &retryAt
+		schedule.Attempt = attempt
+	case Recurring(schedule):
+		next, err := NextRun(schedule, now)
+		if err != nil {
+			return err
+		}
+
+		schedule.NextRunAt = next
+		schedule.SlotAt = next
+		schedule.Attempt = 0
+	case transferErr != nil:
+		schedule.Status = models.ScheduleFailed
+		schedule.NextRunAt = nil
+		schedule.SlotAt = nil
+		schedule.Attempt = attempt
+	default:
+		schedule.Status = models.ScheduleCompleted
+		schedule.NextRunAt = nil
+		schedule.SlotAt = nil
+		schedule.Attempt = 0
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func hourly(t *testing.T, created time.Time) *models.Schedule {
+	schedule, err := NewSchedule(models.CreateScheduleQuery{
+		FromId:          "from",
+		ToId:            "to",
+		Money:           10,
+		IntervalSeconds: int(time.Hour / time.Second),
+	}, created)
+	require.NoError(t, err)
+	require.Equal(t, created.Add(time.Hour), *schedule.NextRunAt)
+
+	return schedule
+}
+
+func Test_advanceLateInterval(t *testing.T) {
+	created := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	schedule := hourly(t, created)
+
+	// the run starts a few seconds after its slot
+	require.NoError(t, AdvanceSchedule(schedule, nil, created.Add(time.Hour+8*time.Second)))
+	require.Equal(t, created.Add(2*time.Hour), *schedule.NextRunAt)
+
+	// the runs missed while the executor was down are skipped
+	require.NoError(t, AdvanceSchedule(schedule, nil, created.Add(4*time.Hour+30*time.Minute)))
+	require.Equal(t, created.Add(5*time.Hour), *schedule.NextRunAt)
+	require.Equal(t, 0, schedule.Attempt)
+}
+
+func Test_advanceRetriedInterval(t *testing.T) {
+	created := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	schedule := hourly(t, created)
+
+	failedAt := created.Add(time.Hour + 5*time.Second)
+	require.NoError(t, AdvanceSchedule(schedule, fmt.Errorf("insufficient funds"), failedAt))
+	require.Equal(t, failedAt.Add(time.Minute), *schedule.NextRunAt)
+	require.Equal(t, 1, schedule.Attempt)
+
+	// the next run keeps to the slot of the retried one
+	require.NoError(t, AdvanceSchedule(schedule, nil, failedAt.Add(time.Minute+3*time.Second)))
+	require.Equal(t, created.Add(2*time.Hour), *schedule.NextRunAt)
+	require.Equal(t, 0, schedule.Attempt)
+}
+
+func Test_advanceRetriedCron(t *testing.T) {
+	created := time.Date(2022, 11, 1, 10, 30, 0, 0, time.UTC)
+	spec := "0 * * * *"
+
+	schedule, err := NewSchedule(models.CreateScheduleQuery{FromId: "from", ToId: "to", Money: 10, Cron: spec}, created)
+	require.NoError(t, err)
+	require.Equal(t, created.Add(30*time.Minute), *schedule.NextRunAt)
+
+	failedAt := created.Add(30*time.Minute + 2*time.Second)
+	require.NoError(t, AdvanceSchedule(schedule, fmt.Errorf("insufficient funds"), failedAt))
+	require.NoError(t, AdvanceSchedule(schedule, nil, failedAt.Add(time.Minute+time.Second)))
+	require.Equal(t, created.Add(90*time.Minute), *schedule.NextRunAt)
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+var webhookEventTypes = map[string]bool{
+	models.AllEvents:              true,
+	models.EventDeposited:         true,
+	models.EventWithdrawn:         true,
+	models.EventTransferDebited:   true,
+	models.EventTransferCredited:  true,
+	models.EventReserveCreated:    true,
+	models.EventReserveRecognized: true,
+	models.EventReserveReleased:   true,
+}
+
+// ValidateWebhook checks the url and the event types of a subscription.
+func ValidateWebhook(query models.CreateWebhookQuery) error {
+	target, err := url.Parse(query.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", api.ErrorInvalidWebhook)
+	}
+
+	if len(query.EventTypes) == 0 {
+		return fmt.Errorf("%w: event_types is empty", api.ErrorInvalidWebhook)
+	}
+
+	for _, eventType := range query.EventTypes {
+		if !webhookEventTypes[eventType] {
+			return fmt.Errorf("%w: unknown event type %q", api.ErrorInvalidWebhook, eventType)
+		}
+	}
+
+	return nil
+}
+
+// GenerateSecret returns a random secret for the signatures of a webhook.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
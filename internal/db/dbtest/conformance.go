@@ -0,0 +1,915 @@
+// Package dbtest holds the conformance suite every handlers.Repository has to
+// pass, so that the storage backends stay interchangeable.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/scheduler"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/stretchr/testify/suite"
+)
+
+// Suite checks that a repository behaves like postgresdb.BalanceRepository
+// and returns its error values. Every test works on accounts of its own and
+// leaves no active webhooks or global limits behind, so the suite can run on
+// a database shared with other tests.
+type Suite struct {
+	suite.Suite
+	Repository handlers.Repository
+	// AuditLog is the log the repository appends the entries of its changes to
+	AuditLog AuditLog
+	ctx      context.Context
+}
+
+type AuditLog interface {
+	VerifyAuditLog(ctx context.Context) (int, error)
+}
+
+// Run runs the suite against rep, which keeps its audit log in log.
+func Run(t *testing.T, rep handlers.Repository, log AuditLog) {
+	suite.Run(t, &Suite{Repository: rep, AuditLog: log})
+}
+
+func (s *Suite) SetupTest() {
+	s.ctx = context.Background()
+}
+
+// account opens an account holding balance and returns its id.
+func (s *Suite) account(balance float64) string {
+	uid := uuid.New().String()
+
+	_, err := s.Repository.ChangeBalance(s.ctx, uid, balance)
+	s.Require().NoError(err)
+
+	return uid
+}
+
+func (s *Suite) balance(uid string) float64 {
+	user, err := s.Repository.GetBalance(s.ctx, uid)
+	s.Require().NoError(err)
+
+	return user.Balance
+}
+
+func (s *Suite) TestDepositCreatesAccount() {
+	uid := uuid.New().String()
+
+	user, err := s.Repository.ChangeBalance(s.ctx, uid, 100.5)
+	s.Require().NoError(err)
+	s.Assert().Equal(uid, user.Id)
+	s.Assert().Equal(100.5, user.Balance)
+
+	user, err = s.Repository.ChangeBalance(s.ctx, uid, 20)
+	s.Require().NoError(err)
+	s.Assert().Equal(120.5, user.Balance)
+
+	user, err = s.Repository.GetBalance(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(120.5, user.Balance)
+	s.Assert().Equal(120.5, user.Available)
+	s.Assert().Equal(0.0, user.Held)
+	s.Assert().Equal(120.5, user.Total)
+	s.Assert().NotNil(user.Reserves)
+	s.Assert().Empty(user.Reserves)
+
+	account, err := s.Repository.GetAccount(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.AccountActive, account.Status)
+	s.Assert().Equal(120.5, account.Balance)
+}
+
+func (s *Suite) TestWithdrawalDoesNotCreateAccount() {
+	uid := uuid.New().String()
+
+	_, err := s.Repository.ChangeBalance(s.ctx, uid, -10)
+	s.Assert().Equal(api.ErrorNotEnoughMoney, err)
+
+	_, err = s.Repository.GetBalance(s.ctx, uid)
+	s.Assert().Equal(api.ErrorUserNotFound, err)
+}
+
+func (s *Suite) TestInvalidIds() {
+	uid := s.account(10)
+
+	_, err := s.Repository.GetBalance(s.ctx, "not-a-uuid")
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, "not-a-uuid", 10)
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	s.Assert().Equal(api.ErrorInvalidInput, s.Repository.TransferBalance(s.ctx, uid, "not-a-uuid", 1))
+
+	_, err = s.Repository.GetAllTransactions(s.ctx, "not-a-uuid", models.SortDateAsc, 10, 1)
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	_, err = s.Repository.GetAccount(s.ctx, "not-a-uuid")
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	_, err = s.Repository.GetAccount(s.ctx, uuid.New().String())
+	s.Assert().Equal(api.ErrorUserNotFound, err)
+}
+
+func (s *Suite) TestInsufficientFunds() {
+	uid := s.account(50)
+
+	_, err := s.Repository.ChangeBalance(s.ctx, uid, -60)
+	s.Assert().Equal(api.ErrorNotEnoughMoney, err)
+	s.Assert().Equal(50.0, s.balance(uid))
+
+	user, err := s.Repository.ChangeBalance(s.ctx, uid, -50)
+	s.Require().NoError(err)
+	s.Assert().Equal(0.0, user.Balance)
+}
+
+func (s *Suite) TestTransfer() {
+	from, to := s.account(100), s.account(0)
+
+	s.Require().NoError(s.Repository.TransferBalance(s.ctx, from, to, 40))
+	s.Assert().Equal(60.0, s.balance(from))
+	s.Assert().Equal(40.0, s.balance(to))
+
+	s.Assert().Equal(api.ErrorNegativeAmount, s.Repository.TransferBalance(s.ctx, from, to, -1))
+	s.Assert().Equal(api.ErrorUserNotFound, s.Repository.TransferBalance(s.ctx, from, uuid.New().String(), 1))
+	s.Assert().Equal(api.ErrorUserNotFound, s.Repository.TransferBalance(s.ctx, uuid.New().String(), to, 1))
+
+	// the receiver is credited before the sender is checked, the failed
+	// transfer must undo both
+	s.Assert().Equal(api.ErrorNotEnoughMoney, s.Repository.TransferBalance(s.ctx, from, to, 61))
+	s.Assert().Equal(60.0, s.balance(from))
+	s.Assert().Equal(40.0, s.balance(to))
+
+	transactions, err := s.Repository.GetAllTransactions(s.ctx, to, models.SortDateAsc, 10, 1)
+	s.Require().NoError(err)
+	s.Require().Len(*transactions, 2)
+
+	transfer := (*transactions)[1]
+	s.Assert().Equal(models.OperationTransferMoney, transfer.Operation)
+	s.Assert().Equal(40.0, transfer.Money)
+	s.Require().NotNil(transfer.FromId)
+	s.Require().NotNil(transfer.ToId)
+	s.Assert().Equal(from, *transfer.FromId)
+	s.Assert().Equal(to, *transfer.ToId)
+}
+
+func (s *Suite) TestConcurrentTransfers() {
+	first, second := s.account(100), s.account(100)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+
+	for i := 0; i < 40; i++ {
+		from, to := first, second
+		if i%2 == 1 {
+			from, to = second, first
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := s.Repository.TransferBalance(s.ctx, from, to, 7); err != nil && err != api.ErrorNotEnoughMoney {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.Assert().NoError(err)
+	}
+
+	firstBalance, secondBalance := s.balance(first), s.balance(second)
+	s.Assert().Equal(200.0, firstBalance+secondBalance)
+	s.Assert().GreaterOrEqual(firstBalance, 0.0)
+	s.Assert().GreaterOrEqual(secondBalance, 0.0)
+}
+
+func (s *Suite) TestReserves() {
+	uid := s.account(100)
+	service, order := uuid.New().String(), uuid.New().String()
+
+	s.Assert().Equal(api.ErrorNegativeAmount, s.Repository.ReserveMoney(s.ctx, uid, service, order, -1))
+	s.Assert().Equal(api.ErrorNotEnoughMoney, s.Repository.ReserveMoney(s.ctx, uid, service, order, 101))
+	s.Assert().Equal(api.ErrorUserNotFound, s.Repository.ReserveMoney(s.ctx, uuid.New().String(), service, order, 1))
+
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, service, order, 30))
+
+	user, err := s.Repository.GetBalance(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(70.0, user.Balance)
+	s.Assert().Equal(30.0, user.Held)
+	s.Assert().Equal(100.0, user.Total)
+	s.Require().Len(user.Reserves, 1)
+	s.Assert().Equal(order, user.Reserves[0].OrderId)
+	s.Assert().Equal(models.ReserveReserved, user.Reserves[0].Status)
+
+	s.Assert().Equal(api.ErrorReserveNotFound, s.Repository.RecognizedMoney(s.ctx, uid, service, order, 31))
+	s.Assert().Equal(api.ErrorInvalidInput, s.Repository.RecognizedMoney(s.ctx, "not-a-uuid", service, order, 30))
+
+	s.Require().NoError(s.Repository.RecognizedMoney(s.ctx, uid, service, order, 30))
+	s.Assert().Equal(api.ErrorReserveAlreadyRecognized, s.Repository.RecognizedMoney(s.ctx, uid, service, order, 30))
+	s.Assert().Equal(api.ErrorReserveAlreadyRecognized, s.Repository.DeReserveMoney(s.ctx, uid, service, order, 30))
+
+	user, err = s.Repository.GetBalance(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(70.0, user.Balance)
+	s.Assert().Equal(0.0, user.Held)
+	s.Assert().Empty(user.Reserves)
+
+	released := uuid.New().String()
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, service, released, 20))
+	s.Assert().Equal(50.0, s.balance(uid))
+
+	s.Require().NoError(s.Repository.DeReserveMoney(s.ctx, uid, service, released, 20))
+	s.Assert().Equal(70.0, s.balance(uid))
+	s.Assert().Equal(api.ErrorReserveAlreadyDeReserved, s.Repository.DeReserveMoney(s.ctx, uid, service, released, 20))
+	s.Assert().Equal(api.ErrorReserveAlreadyDeReserved, s.Repository.RecognizedMoney(s.ctx, uid, service, released, 20))
+
+	transactions, err := s.Repository.GetAllTransactions(s.ctx, uid, models.SortDateAsc, 10, 1)
+	s.Require().NoError(err)
+
+	var operations []string
+	for _, transaction := range *transactions {
+		operations = append(operations, transaction.Operation)
+	}
+	s.Assert().Equal([]string{models.OperationAddMoney, models.OperationReserveMoney, models.OperationReserveMoney,
+		models.OperationReturnReserveMoney}, operations)
+}
+
+// TestConcurrentReleases races de-reserves and recognitions of one reserve,
+// only one of them may take its money.
+func (s *Suite) TestConcurrentReleases() {
+	uid := s.account(100)
+	service, order := uuid.New().String(), uuid.New().String()
+
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, service, order, 10))
+
+	var wg sync.WaitGroup
+	var succeeded, recognized int32
+	errs := make(chan error, 20)
+
+	for i := 0; i < 20; i++ {
+		recognize := i%4 == 3
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release := s.Repository.DeReserveMoney
+			if recognize {
+				release = s.Repository.RecognizedMoney
+			}
+
+			switch err := release(s.ctx, uid, service, order, 10); err {
+			case nil:
+				atomic.AddInt32(&succeeded, 1)
+				if recognize {
+					atomic.AddInt32(&recognized, 1)
+				}
+			case api.ErrorReserveAlreadyDeReserved, api.ErrorReserveAlreadyRecognized:
+			default:
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.Assert().NoError(err)
+	}
+
+	s.Assert().Equal(int32(1), succeeded)
+
+	user, err := s.Repository.GetBalance(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(0.0, user.Held)
+
+	if recognized == 1 {
+		s.Assert().Equal(90.0, user.Balance)
+	} else {
+		s.Assert().Equal(100.0, user.Balance)
+	}
+}
+
+func (s *Suite) TestMonthlyReport() {
+	uid := s.account(100)
+	service, order := uuid.New().String(), uuid.New().String()
+
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, service, order, 25))
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, service, uuid.New().String(), 5))
+	s.Require().NoError(s.Repository.RecognizedMoney(s.ctx, uid, service, order, 25))
+
+	now := time.Now()
+
+	find := func(year, month int) []models.Reserve {
+		reserves, err := s.Repository.GetReserves(s.ctx, year, month)
+		s.Require().NoError(err)
+
+		var found []models.Reserve
+		for _, reserve := range *reserves {
+			if reserve.UserId == uid {
+				found = append(found, reserve)
+			}
+		}
+
+		return found
+	}
+
+	reserves := find(now.Year(), int(now.Month()))
+	s.Require().Len(reserves, 1)
+	s.Assert().Equal(order, reserves[0].OrderId)
+	s.Assert().Equal(25.0, reserves[0].Amount)
+	s.Assert().Equal(models.ReserveRecognized, reserves[0].Status)
+	s.Assert().NotNil(reserves[0].RecognizedAt)
+
+	s.Assert().Empty(find(now.Year()-1, int(now.Month())))
+	s.Assert().Empty(find(now.AddDate(0, 1, 0).Year(), int(now.AddDate(0, 1, 0).Month())))
+}
+
+func (s *Suite) TestTransactionsSortingAndPagination() {
+	uid := s.account(10)
+
+	for _, money := range []float64{30, 20, -5} {
+		_, err := s.Repository.ChangeBalance(s.ctx, uid, money)
+		s.Require().NoError(err)
+	}
+
+	list := func(sortType string, limit, page int) []float64 {
+		transactions, err := s.Repository.GetAllTransactions(s.ctx, uid, sortType, limit, page)
+		s.Require().NoError(err)
+
+		amounts := []float64{}
+		for _, transaction := range *transactions {
+			amounts = append(amounts, transaction.Money)
+		}
+
+		return amounts
+	}
+
+	s.Assert().Equal([]float64{10, 30, 20, -5}, list(models.SortDateAsc, 10, 1))
+	s.Assert().Equal([]float64{-5, 20, 30, 10}, list(models.SortDateDesc, 10, 1))
+	s.Assert().Equal([]float64{-5, 10, 20, 30}, list(models.SortMoneyAsc, 10, 1))
+	s.Assert().Equal([]float64{30, 20, 10, -5}, list("MONEY_DESC", 10, 1))
+	s.Assert().Equal([]float64{20, 30}, list(models.SortMoneyAsc, 2, 2))
+	s.Assert().Equal([]float64{}, list(models.SortMoneyAsc, 2, 3))
+	s.Assert().Equal([]float64{}, list(models.SortMoneyAsc, 0, 1))
+
+	_, err := s.Repository.GetAllTransactions(s.ctx, uid, models.SortDateAsc, -1, 1)
+	s.Assert().Equal(api.ErrorInvalidSortParameters, err)
+
+	_, err = s.Repository.GetAllTransactions(s.ctx, uid, models.SortDateAsc, 10, -1)
+	s.Assert().Equal(api.ErrorInvalidSortParameters, err)
+
+	transactions, err := s.Repository.GetAllTransactions(s.ctx, uid, models.SortDateAsc, 10, 1)
+	s.Require().NoError(err)
+	s.Require().Len(*transactions, 4)
+
+	deposit, withdrawal := (*transactions)[0], (*transactions)[3]
+	s.Assert().Equal(models.OperationAddMoney, deposit.Operation)
+	s.Assert().Nil(deposit.FromId)
+	s.Require().NotNil(deposit.ToId)
+	s.Assert().Equal(uid, *deposit.ToId)
+	s.Assert().Equal(models.OperationWithdrawMoney, withdrawal.Operation)
+	s.Assert().Nil(withdrawal.ToId)
+	s.Require().NotNil(withdrawal.FromId)
+	s.Assert().Equal(uid, *withdrawal.FromId)
+}
+
+func (s *Suite) TestAccountLifecycle() {
+	uid := uuid.New().String()
+
+	account, err := s.Repository.CreateAccount(s.ctx, uid, "ACME", models.Metadata{"tier": "gold"})
+	s.Require().NoError(err)
+	s.Assert().Equal(uid, account.Id)
+	s.Assert().Equal("ACME", account.Owner)
+	s.Assert().Equal(models.Metadata{"tier": "gold"}, account.Metadata)
+	s.Assert().Equal(models.AccountActive, account.Status)
+
+	_, err = s.Repository.CreateAccount(s.ctx, uid, "ACME", nil)
+	s.Assert().Equal(api.ErrorAccountAlreadyExists, err)
+
+	_, err = s.Repository.CreateAccount(s.ctx, "not-a-uuid", "ACME", nil)
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	generated, err := s.Repository.CreateAccount(s.ctx, "", "", nil)
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(generated.Id)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, 50)
+	s.Require().NoError(err)
+
+	account, err = s.Repository.FreezeAccount(s.ctx, uid, true, false)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.AccountFrozen, account.Status)
+	s.Assert().True(account.DebitsBlocked)
+	s.Assert().False(account.CreditsBlocked)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -10)
+	s.Assert().Equal(api.ErrorAccountFrozen, err)
+	s.Assert().Equal(api.ErrorAccountFrozen, s.Repository.TransferBalance(s.ctx, uid, s.account(0), 10))
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, 10)
+	s.Assert().NoError(err)
+
+	account, err = s.Repository.FreezeAccount(s.ctx, uid, false, false)
+	s.Require().NoError(err)
+	s.Assert().True(account.CreditsBlocked)
+	s.Assert().Equal(api.ErrorAccountFrozen, s.Repository.TransferBalance(s.ctx, s.account(10), uid, 10))
+
+	account, err = s.Repository.UnfreezeAccount(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.AccountActive, account.Status)
+
+	_, err = s.Repository.CloseAccount(s.ctx, uid)
+	s.Assert().Equal(api.ErrorAccountNotEmpty, err)
+
+	service, order := uuid.New().String(), uuid.New().String()
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, service, order, 60))
+
+	_, err = s.Repository.CloseAccount(s.ctx, uid)
+	s.Assert().Equal(api.ErrorAccountHasReserves, err)
+
+	s.Require().NoError(s.Repository.RecognizedMoney(s.ctx, uid, service, order, 60))
+
+	account, err = s.Repository.CloseAccount(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.AccountClosed, account.Status)
+	s.Assert().NotNil(account.ClosedAt)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, 10)
+	s.Assert().Equal(api.ErrorAccountClosed, err)
+
+	_, err = s.Repository.UnfreezeAccount(s.ctx, uid)
+	s.Assert().Equal(api.ErrorAccountClosed, err)
+
+	_, err = s.Repository.SetCreditLimit(s.ctx, uid, 10)
+	s.Assert().Equal(api.ErrorAccountClosed, err)
+}
+
+func (s *Suite) TestCreditLine() {
+	uid := s.account(40)
+
+	_, err := s.Repository.SetCreditLimit(s.ctx, uid, -1)
+	s.Assert().Equal(api.ErrorNegativeAmount, err)
+
+	account, err := s.Repository.SetCreditLimit(s.ctx, uid, 50)
+	s.Require().NoError(err)
+	s.Assert().Equal(50.0, account.CreditLimit)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -100)
+	s.Assert().Equal(api.ErrorNotEnoughMoney, err)
+
+	user, err := s.Repository.ChangeBalance(s.ctx, uid, -80)
+	s.Require().NoError(err)
+	s.Assert().Equal(-40.0, user.Balance)
+	s.Assert().Equal(10.0, user.Available)
+
+	s.Assert().Equal(api.ErrorNotEnoughMoney, s.Repository.TransferBalance(s.ctx, uid, s.account(0), 11))
+
+	_, err = s.Repository.SetCreditLimit(s.ctx, uid, 30)
+	s.Assert().Equal(api.ErrorCreditLimitInUse, err)
+
+	accounts, err := s.Repository.GetAccountsUsingCredit(s.ctx)
+	s.Require().NoError(err)
+
+	var found bool
+	for i, account := range *accounts {
+		if i > 0 {
+			s.Assert().LessOrEqual((*accounts)[i-1].Balance, account.Balance)
+		}
+
+		if account.Id == uid {
+			found = true
+			s.Assert().Equal(-40.0, account.Balance)
+		}
+	}
+	s.Assert().True(found)
+}
+
+func (s *Suite) TestLimits() {
+	uid := s.account(500)
+	maxOperation, dailyDebit, hourlyTransfers := 50.0, 100.0, 1
+
+	_, err := s.Repository.GetLimits(s.ctx, uid)
+	s.Assert().Equal(api.ErrorLimitsNotFound, err)
+
+	_, err = s.Repository.GetLimits(s.ctx, "not-a-scope")
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	negative := -1.0
+	_, err = s.Repository.SetLimits(s.ctx, uid, models.Limits{MaxOperation: &negative})
+	s.Assert().Equal(api.ErrorInvalidLimits, err)
+
+	limits, err := s.Repository.SetLimits(s.ctx, uid, models.Limits{MaxOperation: &maxOperation, DailyDebit: &dailyDebit})
+	s.Require().NoError(err)
+	s.Assert().Equal(uid, limits.Scope)
+	s.Require().NotNil(limits.MaxOperation)
+	s.Assert().Equal(maxOperation, *limits.MaxOperation)
+	s.Assert().Nil(limits.HourlyTransfers)
+
+	limits, err = s.Repository.GetLimits(s.ctx, uid)
+	s.Require().NoError(err)
+	s.Require().NotNil(limits.DailyDebit)
+	s.Assert().Equal(dailyDebit, *limits.DailyDebit)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -60)
+	s.Assert().True(errors.Is(err, api.ErrorLimitExceeded))
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -45)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, uuid.New().String(), uuid.New().String(), 45))
+
+	// reserves count towards the daily debits
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -20)
+	s.Assert().True(errors.Is(err, api.ErrorLimitExceeded))
+
+	var exceeded *backend.LimitExceededError
+	s.Require().True(errors.As(err, &exceeded))
+	s.Assert().NotNil(exceeded.ResetsAt)
+
+	_, err = s.Repository.SetLimits(s.ctx, uid, models.Limits{HourlyTransfers: &hourlyTransfers})
+	s.Require().NoError(err)
+
+	to := s.account(0)
+	s.Require().NoError(s.Repository.TransferBalance(s.ctx, uid, to, 100))
+	s.Assert().True(errors.Is(s.Repository.TransferBalance(s.ctx, uid, to, 1), api.ErrorLimitExceeded))
+
+	s.Require().NoError(s.Repository.DeleteLimits(s.ctx, uid))
+	s.Assert().Equal(api.ErrorLimitsNotFound, s.Repository.DeleteLimits(s.ctx, uid))
+	s.Assert().NoError(s.Repository.TransferBalance(s.ctx, uid, to, 1))
+}
+
+func (s *Suite) TestLimitsReleasedReserve() {
+	uid := s.account(500)
+	dailyDebit := 100.0
+
+	_, err := s.Repository.SetLimits(s.ctx, uid, models.Limits{DailyDebit: &dailyDebit})
+	s.Require().NoError(err)
+
+	serviceId, orderId := uuid.New().String(), uuid.New().String()
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, uid, serviceId, orderId, 80))
+	s.Require().NoError(s.Repository.DeReserveMoney(s.ctx, uid, serviceId, orderId, 80))
+
+	// a released reserve does not count towards the daily debits
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -100)
+	s.Require().NoError(err)
+
+	_, err = s.Repository.ChangeBalance(s.ctx, uid, -1)
+	s.Assert().True(errors.Is(err, api.ErrorLimitExceeded))
+}
+
+func (s *Suite) TestBatch() {
+	first, second := s.account(100), s.account(0)
+
+	operations := []models.BatchOperation{
+		{Type: models.BatchTransfer, FromId: first, ToId: second, Money: 30},
+		{Type: models.BatchWithdraw, Id: second, Money: 50},
+		{Type: models.BatchDeposit, Id: first, Money: 5},
+	}
+
+	result, err := s.Repository.ExecuteBatch(s.ctx, true, operations)
+	s.Require().NoError(err)
+	s.Assert().False(result.Committed)
+	s.Require().Len(result.Results, 3)
+	s.Assert().Equal(models.BatchItemRolledBack, result.Results[0].Status)
+	s.Assert().Equal(models.BatchItemFailed, result.Results[1].Status)
+	s.Assert().Equal(api.ErrorNotEnoughMoney.Error(), result.Results[1].Error)
+	s.Assert().Equal(models.BatchItemSkipped, result.Results[2].Status)
+	s.Assert().Equal(100.0, s.balance(first))
+	s.Assert().Equal(0.0, s.balance(second))
+
+	result, err = s.Repository.ExecuteBatch(s.ctx, false, operations)
+	s.Require().NoError(err)
+	s.Assert().True(result.Committed)
+	s.Assert().Equal(models.BatchItemSuccess, result.Results[0].Status)
+	s.Assert().Equal(models.BatchItemFailed, result.Results[1].Status)
+	s.Assert().Equal(models.BatchItemSuccess, result.Results[2].Status)
+	s.Assert().Equal(75.0, s.balance(first))
+	s.Assert().Equal(30.0, s.balance(second))
+
+	result, err = s.Repository.ExecuteBatch(s.ctx, true, []models.BatchOperation{
+		{Type: models.BatchReserve, Id: first, ServiceId: "service", OrderId: uuid.New().String(), Money: 25},
+		{Type: "unknown", Id: first, Money: 1},
+	})
+	s.Require().NoError(err)
+	s.Assert().False(result.Committed)
+	s.Assert().Equal(api.ErrorInvalidInput.Error(), result.Results[1].Error)
+
+	user, err := s.Repository.GetBalance(s.ctx, first)
+	s.Require().NoError(err)
+	s.Assert().Equal(75.0, user.Balance)
+	s.Assert().Empty(user.Reserves)
+}
+
+func (s *Suite) TestImport() {
+	first, second := s.account(10), s.account(10)
+
+	rows := []models.ImportRow{
+		{Line: 1, UserId: first, Amount: 15.5, Comment: "bonus"},
+		{Line: 2, UserId: second, Amount: -20, Comment: "correction"},
+		{Line: 3, UserId: uuid.New().String(), Amount: 5, Comment: "unknown"},
+		{Line: 4, UserId: "not-a-uuid", Amount: 5},
+	}
+
+	result, err := s.Repository.ImportBalances(s.ctx, rows, false)
+	s.Require().NoError(err)
+	s.Assert().False(result.Applied)
+	s.Assert().Equal(4, result.Rows)
+	s.Assert().Equal([]models.ImportLineError{
+		{Line: 2, Error: api.ErrorNotEnoughMoney.Error()},
+		{Line: 3, Error: api.ErrorUserNotFound.Error()},
+		{Line: 4, Error: api.ErrorInvalidInput.Error()},
+	}, result.Errors)
+	s.Assert().Equal(10.0, s.balance(first))
+
+	// the rows see the ones before them
+	rows = []models.ImportRow{
+		{Line: 1, UserId: first, Amount: 15.5, Comment: "bonus"},
+		{Line: 2, UserId: first, Amount: -20, Comment: "correction"},
+	}
+
+	result, err = s.Repository.ImportBalances(s.ctx, rows, true)
+	s.Require().NoError(err)
+	s.Assert().True(result.DryRun)
+	s.Assert().False(result.Applied)
+	s.Assert().Empty(result.Errors)
+	s.Assert().Equal(10.0, s.balance(first))
+
+	result, err = s.Repository.ImportBalances(s.ctx, rows, false)
+	s.Require().NoError(err)
+	s.Require().True(result.Applied)
+	s.Assert().NotEmpty(result.Id)
+	s.Assert().Equal(5.5, s.balance(first))
+
+	transactions, err := s.Repository.GetAllTransactions(s.ctx, first, models.SortDateAsc, 10, 1)
+	s.Require().NoError(err)
+	s.Require().Len(*transactions, 3)
+	s.Assert().Nil((*transactions)[0].ImportId)
+
+	imported := (*transactions)[2]
+	s.Require().NotNil(imported.ImportId)
+	s.Assert().Equal(result.Id, *imported.ImportId)
+	s.Require().NotNil(imported.Comment)
+	s.Assert().Equal("correction", *imported.Comment)
+}
+
+func (s *Suite) TestSchedules() {
+	from, to := s.account(100), s.account(0)
+	past := time.Now().Add(-time.Minute)
+
+	_, err := s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: from, Money: 10, RunAt: &past})
+	s.Assert().Equal(api.ErrorInvalidSchedule, err)
+
+	_, err = s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 10})
+	s.Assert().Equal(api.ErrorInvalidSchedule, err)
+
+	_, err = s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: uuid.New().String(), Money: 10, RunAt: &past})
+	s.Assert().Equal(api.ErrorUserNotFound, err)
+
+	_, err = s.Repository.GetSchedule(s.ctx, uuid.New().String())
+	s.Assert().Equal(api.ErrorScheduleNotFound, err)
+
+	recurring, err := s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 10, IntervalSeconds: 3600})
+	s.Require().NoError(err)
+	s.Assert().Equal(models.ScheduleActive, recurring.Status)
+	s.Require().NotNil(recurring.NextRunAt)
+	s.Assert().True(recurring.NextRunAt.After(time.Now()))
+
+	schedule, err := s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 25, RunAt: &past})
+	s.Require().NoError(err)
+	s.Assert().Equal(from, schedule.FromId)
+
+	schedule, err = s.Repository.PauseSchedule(s.ctx, schedule.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.SchedulePaused, schedule.Status)
+
+	_, err = s.Repository.PauseSchedule(s.ctx, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleState, err)
+
+	schedule, err = s.Repository.ResumeSchedule(s.ctx, schedule.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.ScheduleActive, schedule.Status)
+
+	recurring, err = s.Repository.CancelSchedule(s.ctx, recurring.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.ScheduleCancelled, recurring.Status)
+	s.Assert().Nil(recurring.NextRunAt)
+
+	executor, ok := s.Repository.(scheduler.Executor)
+	if !ok {
+		return
+	}
+
+	// other schedules of a shared database may run as well
+	_, err = executor.ExecuteDueSchedules(s.ctx, 100)
+	s.Require().NoError(err)
+
+	schedule, err = s.Repository.GetSchedule(s.ctx, schedule.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.ScheduleCompleted, schedule.Status)
+	s.Assert().Nil(schedule.NextRunAt)
+	s.Assert().Equal(75.0, s.balance(from))
+	s.Assert().Equal(25.0, s.balance(to))
+
+	runs, err := s.Repository.GetScheduleRuns(s.ctx, schedule.Id)
+	s.Require().NoError(err)
+	s.Require().Len(*runs, 1)
+	s.Assert().Equal(models.ScheduleRunSuccess, (*runs)[0].Outcome)
+	s.Assert().Equal(1, (*runs)[0].Attempt)
+
+	_, err = s.Repository.CancelSchedule(s.ctx, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleState, err)
+
+	failing, err := s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 1000, RunAt: &past})
+	s.Require().NoError(err)
+
+	_, err = executor.ExecuteDueSchedules(s.ctx, 100)
+	s.Require().NoError(err)
+
+	failing, err = s.Repository.GetSchedule(s.ctx, failing.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.ScheduleActive, failing.Status)
+	s.Assert().Equal(1, failing.Attempt)
+	s.Require().NotNil(failing.NextRunAt)
+	s.Assert().True(failing.NextRunAt.After(time.Now()))
+
+	runs, err = s.Repository.GetScheduleRuns(s.ctx, failing.Id)
+	s.Require().NoError(err)
+	s.Require().Len(*runs, 1)
+	s.Assert().Equal(models.ScheduleRunFailed, (*runs)[0].Outcome)
+	s.Require().NotNil((*runs)[0].Error)
+	s.Assert().Equal(api.ErrorNotEnoughMoney.Error(), *(*runs)[0].Error)
+	s.Assert().Equal(75.0, s.balance(from))
+
+	_, err = s.Repository.CancelSchedule(s.ctx, failing.Id)
+	s.Require().NoError(err)
+}
+
+// TestAudit checks that every committed change appends an entry with it,
+// scheduled and imported changes included, and that rejected ones do not.
+func (s *Suite) TestAudit() {
+	entries := func() int {
+		count, err := s.AuditLog.VerifyAuditLog(s.ctx)
+		s.Require().NoError(err)
+
+		return count
+	}
+
+	from, to := s.account(100), s.account(0)
+	count := entries()
+
+	_, err := s.Repository.ChangeBalance(s.ctx, from, -1000)
+	s.Assert().Equal(api.ErrorNotEnoughMoney, err)
+	s.Assert().Equal(count, entries())
+
+	s.Require().NoError(s.Repository.TransferBalance(s.ctx, from, to, 10))
+	s.Assert().Equal(count+1, entries())
+
+	s.Require().NoError(s.Repository.ReserveMoney(s.ctx, from, "service", "order", 5))
+	s.Require().NoError(s.Repository.DeReserveMoney(s.ctx, from, "service", "order", 5))
+	s.Assert().Equal(count+3, entries())
+
+	result, err := s.Repository.ImportBalances(s.ctx, []models.ImportRow{
+		{Line: 1, UserId: from, Amount: 5},
+		{Line: 2, UserId: to, Amount: -5},
+	}, false)
+	s.Require().NoError(err)
+	s.Require().True(result.Applied)
+	s.Assert().Equal(count+4, entries())
+
+	executor, ok := s.Repository.(scheduler.Executor)
+	if !ok {
+		return
+	}
+
+	past := time.Now().Add(-time.Minute)
+	_, err = s.Repository.CreateSchedule(s.ctx, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 10, RunAt: &past})
+	s.Require().NoError(err)
+
+	executed, err := executor.ExecuteDueSchedules(s.ctx, 100)
+	s.Require().NoError(err)
+	s.Require().Equal(1, executed)
+	s.Assert().Equal(count+5, entries())
+	s.Assert().Equal(85.0, s.balance(from))
+}
+
+// TestScheduleOwners checks that only the creator of a schedule and admins
+// may reach it.
+func (s *Suite) TestScheduleOwners() {
+	from, to := s.account(100), s.account(0)
+	owner := auth.WithIdentity(s.ctx, &auth.Identity{Subject: "owner", Scopes: []string{auth.ScopeTransfer}})
+	other := auth.WithIdentity(s.ctx, &auth.Identity{Subject: "other", Scopes: []string{auth.ScopeTransfer}})
+	admin := auth.WithIdentity(s.ctx, &auth.Identity{Subject: "admin", Scopes: []string{auth.ScopeAdmin}})
+
+	schedule, err := s.Repository.CreateSchedule(owner, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 10, IntervalSeconds: 3600})
+	s.Require().NoError(err)
+
+	_, err = s.Repository.GetSchedule(other, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleNotFound, err)
+	_, err = s.Repository.GetScheduleRuns(other, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleNotFound, err)
+	_, err = s.Repository.PauseSchedule(other, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleNotFound, err)
+	_, err = s.Repository.CancelSchedule(other, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleNotFound, err)
+
+	paused, err := s.Repository.PauseSchedule(owner, schedule.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.SchedulePaused, paused.Status)
+
+	_, err = s.Repository.ResumeSchedule(other, schedule.Id)
+	s.Assert().Equal(api.ErrorScheduleNotFound, err)
+
+	_, err = s.Repository.GetSchedule(admin, schedule.Id)
+	s.Assert().NoError(err)
+
+	cancelled, err := s.Repository.CancelSchedule(admin, schedule.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(models.ScheduleCancelled, cancelled.Status)
+}
+
+func (s *Suite) TestWebhooks() {
+	_, err := s.Repository.CreateWebhook(s.ctx, models.CreateWebhookQuery{Url: "ftp://example.com", EventTypes: []string{models.AllEvents}})
+	s.Assert().True(errors.Is(err, api.ErrorInvalidWebhook))
+
+	_, err = s.Repository.CreateWebhook(s.ctx, models.CreateWebhookQuery{Url: "https://example.com", EventTypes: []string{"unknown"}})
+	s.Assert().True(errors.Is(err, api.ErrorInvalidWebhook))
+
+	webhook, err := s.Repository.CreateWebhook(s.ctx, models.CreateWebhookQuery{
+		Url:        "https://example.com/hooks/balance",
+		EventTypes: []string{models.EventTransferCredited},
+	})
+	s.Require().NoError(err)
+	s.Assert().True(webhook.Active)
+	s.Assert().NotEmpty(webhook.Secret)
+	s.Assert().Equal(models.EventTypes{models.EventTransferCredited}, webhook.EventTypes)
+
+	// the subscription must not outlive the test on a shared database
+	defer s.Repository.DeleteWebhook(s.ctx, webhook.Id)
+
+	stored, err := s.Repository.GetWebhook(s.ctx, webhook.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(webhook.Url, stored.Url)
+	s.Assert().Empty(stored.Secret)
+
+	_, err = s.Repository.GetWebhook(s.ctx, uuid.New().String())
+	s.Assert().Equal(api.ErrorWebhookNotFound, err)
+
+	_, err = s.Repository.GetWebhookDeliveries(s.ctx, "not-a-uuid", "")
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	from, to := s.account(100), s.account(0)
+	s.Require().NoError(s.Repository.TransferBalance(s.ctx, from, to, 10))
+
+	deliveries, err := s.Repository.GetWebhookDeliveries(s.ctx, webhook.Id, models.WebhookPending)
+	s.Require().NoError(err)
+	s.Require().Len(*deliveries, 1)
+	s.Assert().Equal(models.EventTransferCredited, (*deliveries)[0].EventType)
+	s.Assert().Equal(webhook.Id, (*deliveries)[0].SubscriptionId)
+
+	deliveries, err = s.Repository.GetWebhookDeliveries(s.ctx, webhook.Id, models.WebhookDead)
+	s.Require().NoError(err)
+	s.Assert().Empty(*deliveries)
+
+	// a failed change queues no deliveries
+	s.Require().Error(s.Repository.TransferBalance(s.ctx, to, from, 1000))
+
+	deliveries, err = s.Repository.GetWebhookDeliveries(s.ctx, webhook.Id, "")
+	s.Require().NoError(err)
+	s.Require().Len(*deliveries, 1)
+
+	delivery := (*deliveries)[0]
+
+	_, err = s.Repository.RetryWebhookDelivery(s.ctx, webhook.Id, delivery.Id)
+	s.Assert().Equal(api.ErrorWebhookDeliveryNotDead, err)
+
+	_, err = s.Repository.RetryWebhookDelivery(s.ctx, webhook.Id, uuid.New().String())
+	s.Assert().Equal(api.ErrorWebhookDeliveryNotFound, err)
+
+	deleted, err := s.Repository.DeleteWebhook(s.ctx, webhook.Id)
+	s.Require().NoError(err)
+	s.Assert().False(deleted.Active)
+	s.Assert().Empty(deleted.Secret)
+
+	s.Require().NoError(s.Repository.TransferBalance(s.ctx, from, to, 10))
+
+	deliveries, err = s.Repository.GetWebhookDeliveries(s.ctx, webhook.Id, "")
+	s.Require().NoError(err)
+	s.Assert().Len(*deliveries, 1)
+}
@@ -0,0 +1,206 @@
+package memorydb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// copyAccount returns a copy of account that the caller may change.
+func copyAccount(account *models.Account) *models.Account {
+	copied := *account
+
+	copied.Metadata = make(models.Metadata, len(account.Metadata))
+	for key, value := range account.Metadata {
+		copied.Metadata[key] = value
+	}
+
+	return &copied
+}
+
+// account returns the stored account of uid.
+func (rep *Repository) account(uid string) (*models.Account, error) {
+	id, err := parseId(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	account, ok := rep.accounts[id]
+	if !ok {
+		return nil, api.ErrorUserNotFound
+	}
+
+	return account, nil
+}
+
+// addAccount opens an account with zero balance within tx.
+func (tx *tx) addAccount(id, owner string, metadata models.Metadata) *models.Account {
+	account := &models.Account{
+		Id:        id,
+		Owner:     owner,
+		Metadata:  models.Metadata{},
+		Status:    models.AccountActive,
+		CreatedAt: time.Now(),
+	}
+	for key, value := range metadata {
+		account.Metadata[key] = value
+	}
+
+	tx.rep.accounts[id] = account
+	tx.undo = append(tx.undo, func() { delete(tx.rep.accounts, id) })
+
+	return account
+}
+
+func (rep *Repository) CreateAccount(ctx context.Context, uid, owner string, metadata models.Metadata) (*models.Account, error) {
+	if uid == "" {
+		uid = uuid.New().String()
+	}
+
+	id, err := parseId(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	if _, ok := rep.accounts[id]; ok {
+		return nil, api.ErrorAccountAlreadyExists
+	}
+
+	return copyAccount(rep.begin().addAccount(id, owner, metadata)), nil
+}
+
+func (rep *Repository) GetAccount(ctx context.Context, uid string) (*models.Account, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	account, err := rep.account(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyAccount(account), nil
+}
+
+func (rep *Repository) updateAccountStatus(uid string, update func(*models.Account) error) (*models.Account, error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	account, err := rep.account(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == models.AccountClosed {
+		return nil, api.ErrorAccountClosed
+	}
+
+	updated := copyAccount(account)
+	if err = update(updated); err != nil {
+		return nil, err
+	}
+
+	*account = *copyAccount(updated)
+
+	return updated, nil
+}
+
+func (rep *Repository) FreezeAccount(ctx context.Context, uid string, debits, credits bool) (*models.Account, error) {
+	if !debits && !credits {
+		debits, credits = true, true
+	}
+
+	return rep.updateAccountStatus(uid, func(account *models.Account) error {
+		account.Status = models.AccountFrozen
+		account.DebitsBlocked = debits
+		account.CreditsBlocked = credits
+
+		return nil
+	})
+}
+
+func (rep *Repository) UnfreezeAccount(ctx context.Context, uid string) (*models.Account, error) {
+	return rep.updateAccountStatus(uid, func(account *models.Account) error {
+		account.Status = models.AccountActive
+		account.DebitsBlocked = false
+		account.CreditsBlocked = false
+
+		return nil
+	})
+}
+
+func (rep *Repository) CloseAccount(ctx context.Context, uid string) (*models.Account, error) {
+	return rep.updateAccountStatus(uid, func(account *models.Account) error {
+		if account.Balance != 0 {
+			return api.ErrorAccountNotEmpty
+		}
+
+		if len(rep.openReserves(account.Id)) > 0 {
+			return api.ErrorAccountHasReserves
+		}
+
+		now := time.Now()
+		account.Status = models.AccountClosed
+		account.DebitsBlocked = true
+		account.CreditsBlocked = true
+		account.ClosedAt = &now
+
+		return nil
+	})
+}
+
+func (rep *Repository) SetCreditLimit(ctx context.Context, uid string, limit float64) (*models.Account, error) {
+	if limit < 0 {
+		return nil, api.ErrorNegativeAmount
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	account, err := rep.account(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == models.AccountClosed {
+		return nil, api.ErrorAccountClosed
+	}
+
+	if account.Balance < -limit {
+		return nil, api.ErrorCreditLimitInUse
+	}
+
+	account.CreditLimit = backend.RoundCents(limit)
+
+	return copyAccount(account), nil
+}
+
+func (rep *Repository) GetAccountsUsingCredit(ctx context.Context) (*[]models.Account, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	accounts := []models.Account{}
+
+	for _, account := range rep.accounts {
+		if account.Balance < 0 {
+			accounts = append(accounts, *copyAccount(account))
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Balance != accounts[j].Balance {
+			return accounts[i].Balance < accounts[j].Balance
+		}
+
+		return accounts[i].Id < accounts[j].Id
+	})
+
+	return &accounts, nil
+}
@@ -0,0 +1,61 @@
+package memorydb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// AppendAuditEntry implements audit.Log, so that the repository keeps the
+// audit log of the changes it makes.
+func (rep *Repository) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	rep.appendAuditEntry(entry)
+
+	return nil
+}
+
+func (rep *Repository) appendAuditEntry(entry *models.AuditEntry) {
+	entry.PrevHash = ""
+	if n := len(rep.auditLog); n > 0 {
+		entry.PrevHash = rep.auditLog[n-1].Hash
+	}
+
+	entry.Hash = audit.Hash(entry.PrevHash, entry)
+	entry.Id = int64(len(rep.auditLog) + 1)
+
+	rep.auditLog = append(rep.auditLog, *entry)
+}
+
+// auditChanges appends the entry of the changes made on behalf of ctx. It is
+// called under the lock once the changes can no longer fail, so the entry is
+// kept exactly when they are.
+func (tx *tx) auditChanges(ctx context.Context, changes ...audit.Change) {
+	tx.rep.appendAuditEntry(audit.NewEntry(ctx, changes...))
+}
+
+// VerifyAuditLog walks the log under the read lock, so that no entry is
+// appended while the chain is checked.
+func (rep *Repository) VerifyAuditLog(ctx context.Context) (int, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	prevHash := ""
+
+	for i := range rep.auditLog {
+		entry := &rep.auditLog[i]
+
+		if entry.PrevHash != prevHash || audit.Hash(prevHash, entry) != entry.Hash {
+			return i, fmt.Errorf("%w at entry %d", backend.ErrorAuditChainBroken, entry.Id)
+		}
+
+		prevHash = entry.Hash
+	}
+
+	return len(rep.auditLog), nil
+}
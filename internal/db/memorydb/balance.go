@@ -0,0 +1,228 @@
+package memorydb
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (rep *Repository) ChangeBalance(ctx context.Context, uid string, money float64) (*models.User, error) {
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	before, user, err := tx.changeBalance(ctx, uid, money)
+	if err != nil {
+		tx.rollback()
+	} else {
+		tx.auditChanges(ctx, audit.Change{AccountId: uid, Before: before, After: user.Balance})
+	}
+
+	rep.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	audit.RecordCommitted(ctx)
+	backend.RecordBalanceChange(money)
+
+	return user, nil
+}
+
+// changeBalance applies money to the stored account, which keep restores
+// when tx rolls back.
+func (tx *tx) changeBalance(ctx context.Context, uid string, money float64) (float64, *models.User, error) {
+	account, err := tx.rep.account(uid)
+	if err == api.ErrorUserNotFound {
+		id, _ := parseId(uid)
+		account = tx.addAccount(id, "", nil)
+	} else if err != nil {
+		return 0, nil, err
+	} else {
+		if money < 0 {
+			err = backend.CanDebit(account)
+		} else {
+			err = backend.CanCredit(account)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if money < 0 {
+		if account.Balance+account.CreditLimit < math.Abs(money) {
+			metrics.RecordInsufficientFunds()
+			return 0, nil, api.ErrorNotEnoughMoney
+		}
+
+		if err = tx.rep.checkDebitLimits(uid, account.Id, math.Abs(money), false); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	before := account.Balance
+
+	keep(tx, &account.Balance)
+	account.Balance = backend.RoundCents(account.Balance + money)
+
+	eventType := models.EventDeposited
+	if money >= 0 {
+		tx.addTransaction(ctx, &account.Id, nil, models.OperationAddMoney, money)
+	} else {
+		eventType = models.EventWithdrawn
+		tx.addTransaction(ctx, nil, &account.Id, models.OperationWithdrawMoney, money)
+	}
+
+	user := tx.rep.user(account)
+
+	err = tx.addEvent(ctx, eventType, account.Id, models.AccountEventData{Amount: math.Abs(money), Balance: &user.Balance})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return before, user, nil
+}
+
+// user returns the balance view of account, without its reserves.
+func (rep *Repository) user(account *models.Account) *models.User {
+	var held float64
+	for _, reserve := range rep.openReserves(account.Id) {
+		held += reserve.Amount
+	}
+
+	return &models.User{
+		Id:          account.Id,
+		Balance:     account.Balance,
+		CreditLimit: account.CreditLimit,
+		Available:   backend.RoundCents(account.Balance + account.CreditLimit),
+		Held:        backend.RoundCents(held),
+		Total:       backend.RoundCents(account.Balance + held),
+	}
+}
+
+func (rep *Repository) GetBalance(ctx context.Context, uid string) (*models.User, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	account, err := rep.account(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	user := rep.user(account)
+	user.Reserves = []models.Reserve{}
+
+	for _, reserve := range rep.openReserves(account.Id) {
+		user.Reserves = append(user.Reserves, *reserve)
+	}
+
+	sort.SliceStable(user.Reserves, func(i, j int) bool {
+		return user.Reserves[i].CreatedAt.Before(user.Reserves[j].CreatedAt)
+	})
+
+	return user, nil
+}
+
+func (rep *Repository) TransferBalance(ctx context.Context, fromUid string, toUid string, money float64) error {
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	fromBalance, toBalance, err := tx.transfer(ctx, fromUid, toUid, money)
+	if err != nil {
+		tx.rollback()
+	} else {
+		tx.auditChanges(ctx, backend.TransferChanges(fromUid, toUid, money, fromBalance, toBalance)...)
+	}
+
+	rep.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationTransfer, money)
+
+	return nil
+}
+
+// transfer is the part of TransferBalance that schedules run under the lock
+// they already hold.
+func (tx *tx) transfer(ctx context.Context, fromUid string, toUid string, money float64) (float64, float64, error) {
+	if money < 0 {
+		return 0, 0, api.ErrorNegativeAmount
+	}
+
+	from, to, err := tx.rep.transferAccounts(fromUid, toUid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = tx.rep.checkDebitLimits(fromUid, from.Id, money, true); err != nil {
+		return 0, 0, err
+	}
+
+	keep(tx, &to.Balance)
+	to.Balance = backend.RoundCents(to.Balance + money)
+	toBalance := to.Balance
+
+	keep(tx, &from.Balance)
+	from.Balance = backend.RoundCents(from.Balance - money)
+	fromBalance := from.Balance
+
+	if from.Balance < -from.CreditLimit {
+		metrics.RecordInsufficientFunds()
+		return 0, 0, api.ErrorNotEnoughMoney
+	}
+
+	tx.addTransaction(ctx, &to.Id, &from.Id, models.OperationTransferMoney, money)
+
+	err = tx.addEvent(ctx, models.EventTransferDebited, from.Id,
+		models.AccountEventData{Amount: money, Balance: &fromBalance, CounterpartyId: to.Id})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = tx.addEvent(ctx, models.EventTransferCredited, to.Id,
+		models.AccountEventData{Amount: money, Balance: &toBalance, CounterpartyId: from.Id})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fromBalance, toBalance, nil
+}
+
+// transferAccounts looks both accounts up in the order postgresdb locks
+// them, so that the same error is returned when both are wrong, and checks
+// their status.
+func (rep *Repository) transferAccounts(fromUid, toUid string) (*models.Account, *models.Account, error) {
+	uids := []string{fromUid, toUid}
+	if toUid < fromUid {
+		uids = []string{toUid, fromUid}
+	}
+
+	for _, uid := range uids {
+		if _, err := rep.account(uid); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	from, _ := rep.account(fromUid)
+	to, _ := rep.account(toUid)
+
+	if err := backend.CanDebit(from); err != nil {
+		return nil, nil, err
+	}
+
+	if err := backend.CanCredit(to); err != nil {
+		return nil, nil, err
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,122 @@
+package memorydb
+
+import (
+	"context"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// ExecuteBatch runs operations as a single change, rolling back every failed
+// item alone. In atomic mode the first failure rolls back the whole batch
+// and the remaining items are skipped, otherwise the rest is kept.
+func (rep *Repository) ExecuteBatch(ctx context.Context, atomic bool, operations []models.BatchOperation) (*models.BatchResult, error) {
+	result := &models.BatchResult{Atomic: atomic, Results: make([]models.BatchItemResult, len(operations))}
+
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	var changes []audit.Change
+	failed := -1
+
+	for i, operation := range operations {
+		result.Results[i].Index = i
+
+		if failed >= 0 {
+			result.Results[i].Status = models.BatchItemSkipped
+			continue
+		}
+
+		savepoint := tx.savepoint()
+
+		itemChanges, itemErr := tx.executeBatchOperation(ctx, operation)
+		if itemErr != nil {
+			tx.rollbackTo(savepoint)
+
+			result.Results[i].Status = models.BatchItemFailed
+			result.Results[i].Error = itemErr.Error()
+
+			if atomic {
+				failed = i
+			}
+			continue
+		}
+
+		result.Results[i].Status = models.BatchItemSuccess
+		changes = append(changes, itemChanges...)
+	}
+
+	if failed >= 0 {
+		tx.rollback()
+		rep.mu.Unlock()
+
+		for i := 0; i < failed; i++ {
+			result.Results[i].Status = models.BatchItemRolledBack
+		}
+
+		return result, nil
+	}
+
+	tx.auditChanges(ctx, changes...)
+	rep.mu.Unlock()
+
+	result.Committed = true
+	audit.RecordCommitted(ctx)
+
+	for i, operation := range operations {
+		if result.Results[i].Status == models.BatchItemSuccess {
+			recordBatchOperation(operation)
+		}
+	}
+
+	return result, nil
+}
+
+func recordBatchOperation(operation models.BatchOperation) {
+	switch operation.Type {
+	case models.BatchDeposit:
+		metrics.RecordOperation(metrics.OperationDeposit, operation.Money)
+	case models.BatchWithdraw:
+		metrics.RecordOperation(metrics.OperationWithdrawal, operation.Money)
+	case models.BatchTransfer:
+		metrics.RecordOperation(metrics.OperationTransfer, operation.Money)
+	case models.BatchReserve:
+		metrics.RecordOperation(metrics.OperationReserve, operation.Money)
+	}
+}
+
+func (tx *tx) executeBatchOperation(ctx context.Context, operation models.BatchOperation) ([]audit.Change, error) {
+	switch operation.Type {
+	case models.BatchDeposit, models.BatchWithdraw:
+		money := operation.Money
+		if operation.Type == models.BatchWithdraw {
+			money = -money
+		}
+
+		before, user, err := tx.changeBalance(ctx, operation.Id, money)
+		if err != nil {
+			return nil, err
+		}
+
+		return []audit.Change{{AccountId: operation.Id, Before: before, After: user.Balance}}, nil
+	case models.BatchTransfer:
+		fromBalance, toBalance, err := tx.transfer(ctx, operation.FromId, operation.ToId, operation.Money)
+		if err != nil {
+			return nil, err
+		}
+
+		return backend.TransferChanges(operation.FromId, operation.ToId, operation.Money, fromBalance, toBalance), nil
+	case models.BatchReserve:
+		before, after, err := tx.reserveMoney(ctx, operation.Id, operation.ServiceId, operation.OrderId, operation.Money)
+		if err != nil {
+			return nil, err
+		}
+
+		return []audit.Change{{AccountId: operation.Id, Before: before, After: after}}, nil
+	default:
+		return nil, api.ErrorInvalidInput
+	}
+}
@@ -0,0 +1,65 @@
+package memorydb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// ImportBalances tries every row under one lock and undoes them all unless
+// the import is kept.
+func (rep *Repository) ImportBalances(ctx context.Context, rows []models.ImportRow, dryRun bool) (*models.ImportResult, error) {
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(rows), Errors: make([]models.ImportLineError, 0)}
+	importId := uuid.New().String()
+
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	var changes []audit.Change
+
+	for _, row := range rows {
+		rowCtx := context.WithValue(ctx, importNoteKey{}, importNote{importId, row.Comment})
+		savepoint := tx.savepoint()
+
+		// unlike ChangeBalance an import never opens new accounts
+		_, rowErr := rep.account(row.UserId)
+
+		var before float64
+		var user *models.User
+		if rowErr == nil {
+			before, user, rowErr = tx.changeBalance(rowCtx, row.UserId, row.Amount)
+		}
+
+		if rowErr != nil {
+			tx.rollbackTo(savepoint)
+
+			result.Errors = append(result.Errors, models.ImportLineError{Line: row.Line, Error: rowErr.Error()})
+			continue
+		}
+
+		changes = append(changes, audit.Change{AccountId: row.UserId, Before: before, After: user.Balance})
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		tx.rollback()
+		rep.mu.Unlock()
+
+		return result, nil
+	}
+
+	tx.auditChanges(ctx, changes...)
+	rep.mu.Unlock()
+
+	result.Id = importId
+	result.Applied = true
+	audit.RecordCommitted(ctx)
+
+	for _, row := range rows {
+		backend.RecordBalanceChange(row.Amount)
+	}
+
+	return result, nil
+}
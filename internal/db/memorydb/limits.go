@@ -0,0 +1,146 @@
+package memorydb
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (rep *Repository) GetLimits(ctx context.Context, scope string) (*models.Limits, error) {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return nil, err
+	}
+
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	limits, ok := rep.limits[scope]
+	if !ok {
+		return nil, api.ErrorLimitsNotFound
+	}
+
+	copied := *limits
+
+	return &copied, nil
+}
+
+func (rep *Repository) SetLimits(ctx context.Context, scope string, limits models.Limits) (*models.Limits, error) {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return nil, err
+	}
+
+	if err := backend.ValidateLimits(limits); err != nil {
+		return nil, err
+	}
+
+	updated := models.Limits{
+		Scope:           scope,
+		MaxOperation:    roundLimit(limits.MaxOperation),
+		DailyDebit:      roundLimit(limits.DailyDebit),
+		MonthlyDebit:    roundLimit(limits.MonthlyDebit),
+		HourlyTransfers: limits.HourlyTransfers,
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	stored := updated
+	rep.limits[scope] = &stored
+
+	return &updated, nil
+}
+
+func roundLimit(limit *float64) *float64 {
+	if limit == nil {
+		return nil
+	}
+
+	rounded := backend.RoundCents(*limit)
+
+	return &rounded
+}
+
+func (rep *Repository) DeleteLimits(ctx context.Context, scope string) error {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return err
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	if _, ok := rep.limits[scope]; !ok {
+		return api.ErrorLimitsNotFound
+	}
+
+	delete(rep.limits, scope)
+
+	return nil
+}
+
+// checkDebitLimits checks a debit of amount from the account id against the
+// limits of scope, the account id as the caller gave it.
+func (rep *Repository) checkDebitLimits(scope, id string, amount float64, transfer bool) error {
+	var scopes []models.Limits
+	for _, key := range []string{backend.GlobalLimitsScope, scope} {
+		if limits, ok := rep.limits[key]; ok {
+			scopes = append(scopes, *limits)
+		}
+	}
+
+	return backend.CheckLimits(backend.MergeLimits(scopes), amount, transfer, time.Now(), backend.DebitTotals{
+		Debited: func(since time.Time) (float64, error) {
+			var total float64
+			for _, transaction := range rep.transactions {
+				if debit(transaction, id) && !transaction.CreatedAt.Before(since) {
+					total += math.Abs(transaction.Money)
+				}
+			}
+
+			// released reserves do not use up the limits
+			for _, reserve := range rep.reserves {
+				if reserve.UserId == id && reserve.Status != models.ReserveDeReserved && !reserve.CreatedAt.Before(since) {
+					total += reserve.Amount
+				}
+			}
+
+			return total, nil
+		},
+		Transfers: func(since time.Time) (int, *time.Time, error) {
+			var count int
+			var oldest *time.Time
+			for _, transaction := range rep.transactions {
+				if transaction.Operation != models.OperationTransferMoney || transaction.FromId == nil ||
+					*transaction.FromId != id || !transaction.CreatedAt.After(since) {
+					continue
+				}
+
+				count++
+				if oldest == nil || transaction.CreatedAt.Before(*oldest) {
+					createdAt := transaction.CreatedAt
+					oldest = &createdAt
+				}
+			}
+
+			return count, oldest, nil
+		},
+	})
+}
+
+// debit reports whether transaction took money off the account id for good,
+// reserves are counted on their own.
+func debit(transaction models.Transaction, id string) bool {
+	if transaction.FromId == nil || *transaction.FromId != id {
+		return false
+	}
+
+	switch transaction.Operation {
+	case models.OperationWithdrawMoney, models.OperationTransferMoney:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,119 @@
+package memorydb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+type outboxEvent struct {
+	event     models.Event
+	published bool
+}
+
+// addEvent appends an event to the outbox within tx. The sequence of a rolled
+// back event is not reused, like the one of a rolled back insert.
+func (tx *tx) addEvent(ctx context.Context, eventType, accountId string, data models.AccountEventData) error {
+	event, err := backend.NewEvent(ctx, eventType, accountId, data)
+	if err != nil {
+		return err
+	}
+
+	tx.rep.sequence++
+	event.Sequence = tx.rep.sequence
+
+	n := len(tx.rep.outbox)
+	tx.rep.outbox = append(tx.rep.outbox, &outboxEvent{event: *event})
+	tx.undo = append(tx.undo, func() { tx.rep.outbox = tx.rep.outbox[:n] })
+
+	tx.addWebhookDeliveries(tx.rep.outbox[n])
+
+	return nil
+}
+
+// RelayEvents publishes without holding the lock, so that writers are not
+// kept waiting.
+func (rep *Repository) RelayEvents(ctx context.Context, limit int, publish func(context.Context, *models.Event) error) (int, error) {
+	if !rep.relay.TryLock() {
+		return 0, nil
+	}
+	defer rep.relay.Unlock()
+
+	var pending []*outboxEvent
+
+	rep.mu.RLock()
+	for _, event := range rep.outbox {
+		if len(pending) == limit {
+			break
+		}
+
+		if !event.published {
+			pending = append(pending, event)
+		}
+	}
+	rep.mu.RUnlock()
+
+	published := 0
+	var publishErr error
+
+	for _, pendingEvent := range pending {
+		event := pendingEvent.event
+		if publishErr = publish(ctx, &event); publishErr != nil {
+			break
+		}
+
+		published++
+	}
+
+	rep.mu.Lock()
+	for _, event := range pending[:published] {
+		event.published = true
+	}
+	rep.mu.Unlock()
+
+	return published, publishErr
+}
+
+// webhookDelivery links a delivery to its event in the outbox.
+type webhookDelivery struct {
+	models.WebhookDelivery
+	event *outboxEvent
+}
+
+func (tx *tx) addWebhookDeliveries(event *outboxEvent) {
+	now := time.Now()
+
+	for _, webhook := range tx.rep.webhooks {
+		if !webhook.Active || !subscribed(webhook, event.event.Type) {
+			continue
+		}
+
+		n := len(tx.rep.deliveries)
+		tx.rep.deliveries = append(tx.rep.deliveries, &webhookDelivery{
+			WebhookDelivery: models.WebhookDelivery{
+				Id:             uuid.New().String(),
+				SubscriptionId: webhook.Id,
+				EventId:        event.event.Id,
+				EventType:      event.event.Type,
+				Status:         models.WebhookPending,
+				NextAttemptAt:  &now,
+				CreatedAt:      now,
+			},
+			event: event,
+		})
+		tx.undo = append(tx.undo, func() { tx.rep.deliveries = tx.rep.deliveries[:n] })
+	}
+}
+
+func subscribed(webhook *models.WebhookSubscription, eventType string) bool {
+	for _, subscribed := range webhook.EventTypes {
+		if subscribed == eventType || subscribed == models.AllEvents {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package memorydb
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// Repository keeps the accounts and their ledger in memory. It implements
+// handlers.Repository and the workers of postgresdb.BalanceRepository with
+// the same semantics and error values, so that the service and its tests can
+// run without a database. Every operation holds a single lock, which plays
+// the part of the row locks and the transactions of Postgres.
+type Repository struct {
+	mu sync.RWMutex
+	// relay lets a single RelayEvents publish at a time, like the advisory
+	// lock of the outbox
+	relay sync.Mutex
+
+	accounts     map[string]*models.Account
+	transactions []models.Transaction
+	reserves     []*models.Reserve
+	limits       map[string]*models.Limits
+	schedules    map[string]*models.Schedule
+	runs         []models.ScheduleRun
+	outbox       []*outboxEvent
+	sequence     int64
+	webhooks     []*models.WebhookSubscription
+	deliveries   []*webhookDelivery
+	auditLog     []models.AuditEntry
+}
+
+func NewRepository() *Repository {
+	return &Repository{
+		accounts:  make(map[string]*models.Account),
+		limits:    make(map[string]*models.Limits),
+		schedules: make(map[string]*models.Schedule),
+	}
+}
+
+// tx collects how to undo the changes made under the lock, so that a failed
+// operation leaves nothing behind and batches can roll back single items like
+// savepoints do.
+type tx struct {
+	rep  *Repository
+	undo []func()
+}
+
+func (rep *Repository) begin() *tx {
+	return &tx{rep: rep}
+}
+
+// savepoint returns the point rollbackTo returns to.
+func (tx *tx) savepoint() int {
+	return len(tx.undo)
+}
+
+func (tx *tx) rollbackTo(savepoint int) {
+	for i := len(tx.undo) - 1; i >= savepoint; i-- {
+		tx.undo[i]()
+	}
+
+	tx.undo = tx.undo[:savepoint]
+}
+
+func (tx *tx) rollback() {
+	tx.rollbackTo(0)
+}
+
+// keep restores the current value of v on rollback, it is called before v
+// is changed.
+func keep[T any](tx *tx, v *T) {
+	saved := *v
+	tx.undo = append(tx.undo, func() { *v = saved })
+}
+
+// parseId returns the canonical form of a uuid, the one Postgres returns.
+func parseId(id string) (string, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return "", api.ErrorInvalidInput
+	}
+
+	return parsed.String(), nil
+}
@@ -0,0 +1,102 @@
+package memorydb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/dbtest"
+	"github.com/siraj18/balance-service-new/internal/db/memorydb"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	rep := memorydb.NewRepository()
+
+	dbtest.Run(t, rep, rep)
+}
+
+func TestRelayEvents(t *testing.T) {
+	ctx := context.Background()
+	rep := memorydb.NewRepository()
+	uid := uuid.New().String()
+
+	_, err := rep.ChangeBalance(ctx, uid, 10)
+	require.NoError(t, err)
+
+	_, err = rep.ChangeBalance(ctx, uid, -20)
+	require.Equal(t, api.ErrorNotEnoughMoney, err)
+
+	_, err = rep.ChangeBalance(ctx, uid, -5)
+	require.NoError(t, err)
+
+	var published []*models.Event
+	publish := func(ctx context.Context, event *models.Event) error {
+		if len(published) == 1 {
+			return fmt.Errorf("broker is down")
+		}
+
+		published = append(published, event)
+		return nil
+	}
+
+	count, err := rep.RelayEvents(ctx, 10, publish)
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+
+	publish = func(ctx context.Context, event *models.Event) error {
+		published = append(published, event)
+		return nil
+	}
+
+	count, err = rep.RelayEvents(ctx, 10, publish)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.Len(t, published, 2)
+	assert.Equal(t, models.EventDeposited, published[0].Type)
+	assert.Equal(t, models.EventWithdrawn, published[1].Type)
+	assert.Less(t, published[0].Sequence, published[1].Sequence)
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	rep := memorydb.NewRepository()
+
+	webhook, err := rep.CreateWebhook(ctx, models.CreateWebhookQuery{Url: "https://example.com", EventTypes: []string{models.AllEvents}})
+	require.NoError(t, err)
+
+	_, err = rep.ChangeBalance(ctx, uuid.New().String(), 10)
+	require.NoError(t, err)
+
+	claimed, err := rep.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, webhook.Url, claimed[0].Url)
+	require.NotNil(t, claimed[0].Event)
+	assert.Equal(t, models.EventDeposited, claimed[0].Event.Type)
+
+	// leased deliveries are not claimed again
+	again, err := rep.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	delivery := claimed[0]
+	delivery.Status = models.WebhookDead
+	delivery.Attempt = 5
+	require.NoError(t, rep.FinishWebhookDelivery(ctx, &delivery))
+
+	retried, err := rep.RetryWebhookDelivery(ctx, webhook.Id, delivery.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookPending, retried.Status)
+	assert.Equal(t, 0, retried.Attempt)
+
+	claimed, err = rep.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 1)
+}
@@ -0,0 +1,266 @@
+package memorydb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// openReserves returns the reserves of the account id that still hold money.
+func (rep *Repository) openReserves(id string) []*models.Reserve {
+	var reserves []*models.Reserve
+
+	for _, reserve := range rep.reserves {
+		if reserve.UserId == id && reserve.Status == models.ReserveReserved {
+			reserves = append(reserves, reserve)
+		}
+	}
+
+	return reserves
+}
+
+func (tx *tx) addReserve(ctx context.Context, userId, serviceId, orderId string, amount float64) {
+	n := len(tx.rep.reserves)
+	tx.rep.reserves = append(tx.rep.reserves, &models.Reserve{
+		Id:        uuid.New().String(),
+		UserId:    userId,
+		ServiceId: serviceId,
+		OrderId:   orderId,
+		Amount:    backend.RoundCents(amount),
+		Status:    models.ReserveReserved,
+		Actor:     auth.Actor(ctx),
+		CreatedAt: time.Now(),
+	})
+	tx.undo = append(tx.undo, func() { tx.rep.reserves = tx.rep.reserves[:n] })
+}
+
+func (rep *Repository) ReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	before, after, err := tx.reserveMoney(ctx, userId, serviceId, orderId, amount)
+	if err != nil {
+		tx.rollback()
+	} else {
+		tx.auditChanges(ctx, audit.Change{AccountId: userId, Before: before, After: after})
+	}
+
+	rep.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationReserve, amount)
+
+	return nil
+}
+
+// reserveMoney is shared by ReserveMoney and batches, which hold the lock.
+func (tx *tx) reserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) (float64, float64, error) {
+	if amount < 0 {
+		return 0, 0, api.ErrorNegativeAmount
+	}
+
+	account, err := tx.rep.account(userId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = backend.CanDebit(account); err != nil {
+		return 0, 0, err
+	}
+
+	if account.Balance+account.CreditLimit < amount {
+		metrics.RecordInsufficientFunds()
+		return 0, 0, api.ErrorNotEnoughMoney
+	}
+
+	if err = tx.rep.checkDebitLimits(userId, account.Id, amount, false); err != nil {
+		return 0, 0, err
+	}
+
+	before := account.Balance
+
+	keep(tx, &account.Balance)
+	account.Balance = backend.RoundCents(account.Balance - amount)
+	balance := account.Balance
+
+	tx.addReserve(ctx, account.Id, serviceId, orderId, amount)
+	tx.addTransaction(ctx, nil, &account.Id, models.OperationReserveMoney, amount)
+
+	err = tx.addEvent(ctx, models.EventReserveCreated, account.Id,
+		models.AccountEventData{Amount: amount, Balance: &balance, ServiceId: serviceId, OrderId: orderId})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return before, balance, nil
+}
+
+// reserve finds the reserve of the order, preferring one that still holds
+// money when the same order was reserved more than once.
+func (rep *Repository) reserve(userId, serviceId, orderId string, amount float64) (*models.Reserve, error) {
+	id, err := parseId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *models.Reserve
+
+	for _, reserve := range rep.reserves {
+		if reserve.UserId != id || reserve.ServiceId != serviceId || reserve.OrderId != orderId || reserve.Amount != amount {
+			continue
+		}
+
+		if reserve.Status == models.ReserveReserved {
+			return reserve, nil
+		}
+
+		if found == nil {
+			found = reserve
+		}
+	}
+
+	if found == nil {
+		return nil, api.ErrorReserveNotFound
+	}
+
+	if found.Status == models.ReserveRecognized {
+		return nil, api.ErrorReserveAlreadyRecognized
+	}
+
+	return nil, api.ErrorReserveAlreadyDeReserved
+}
+
+func (rep *Repository) RecognizedMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	reserved, err := tx.recognizeMoney(ctx, userId, serviceId, orderId, amount)
+	if err != nil {
+		tx.rollback()
+	} else {
+		// recognizing leaves the balance as it is
+		tx.auditChanges(ctx)
+	}
+
+	rep.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+
+	metrics.RecordOperation(metrics.OperationRecognize, reserved)
+
+	return nil
+}
+
+func (tx *tx) recognizeMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) (float64, error) {
+	reserve, err := tx.rep.reserve(userId, serviceId, orderId, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	keep(tx, reserve)
+	reserve.Status = models.ReserveRecognized
+	reserve.RecognizedAt = &now
+
+	err = tx.addEvent(ctx, models.EventReserveRecognized, reserve.UserId,
+		models.AccountEventData{Amount: reserve.Amount, ServiceId: serviceId, OrderId: orderId})
+	if err != nil {
+		return 0, err
+	}
+
+	return reserve.Amount, nil
+}
+
+func (rep *Repository) DeReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	rep.mu.Lock()
+
+	tx := rep.begin()
+	reserved, balance, err := tx.deReserveMoney(ctx, userId, serviceId, orderId, amount)
+	if err != nil {
+		tx.rollback()
+	} else {
+		tx.auditChanges(ctx, audit.Change{AccountId: userId, Before: balance - reserved, After: balance})
+	}
+
+	rep.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationDeReserve, reserved)
+
+	return nil
+}
+
+// deReserveMoney returns the reserved amount and the new balance.
+func (tx *tx) deReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) (float64, float64, error) {
+	reserve, err := tx.rep.reserve(userId, serviceId, orderId, amount)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	account, err := tx.rep.account(userId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = backend.CanCredit(account); err != nil {
+		return 0, 0, err
+	}
+
+	keep(tx, &account.Balance)
+	account.Balance = backend.RoundCents(account.Balance + reserve.Amount)
+	balance := account.Balance
+
+	tx.addTransaction(ctx, &account.Id, nil, models.OperationReturnReserveMoney, amount)
+
+	keep(tx, reserve)
+	reserve.Status = models.ReserveDeReserved
+	reserve.RecognizedAt = nil
+
+	err = tx.addEvent(ctx, models.EventReserveReleased, account.Id,
+		models.AccountEventData{Amount: reserve.Amount, Balance: &balance, ServiceId: serviceId, OrderId: orderId})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return reserve.Amount, balance, nil
+}
+
+// GetReserves returns the reserves recognized in the given month.
+func (rep *Repository) GetReserves(ctx context.Context, year, month int) (*[]models.Reserve, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	reserves := []models.Reserve{}
+
+	for _, reserve := range rep.reserves {
+		if reserve.Status != models.ReserveRecognized || reserve.RecognizedAt == nil {
+			continue
+		}
+
+		if reserve.RecognizedAt.Year() == year && int(reserve.RecognizedAt.Month()) == month {
+			reserves = append(reserves, *reserve)
+		}
+	}
+
+	return &reserves, nil
+}
@@ -0,0 +1,270 @@
+package memorydb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (rep *Repository) CreateSchedule(ctx context.Context, query models.CreateScheduleQuery) (*models.Schedule, error) {
+	schedule, err := backend.NewSchedule(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	from, err := rep.account(query.FromId)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := rep.account(query.ToId)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Id = uuid.New().String()
+	schedule.FromId = from.Id
+	schedule.ToId = to.Id
+	schedule.Money = backend.RoundCents(query.Money)
+	schedule.Status = models.ScheduleActive
+	schedule.Actor = auth.Actor(ctx)
+	schedule.CreatedAt = time.Now()
+
+	stored := *schedule
+	rep.schedules[schedule.Id] = &stored
+
+	return schedule, nil
+}
+
+// schedule returns the stored schedule with the given id, provided the
+// caller of ctx may reach it.
+func (rep *Repository) schedule(ctx context.Context, id string) (*models.Schedule, error) {
+	id, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, ok := rep.schedules[id]
+	if owner := backend.ScheduleOwner(ctx); !ok || owner != "" && schedule.Actor != owner {
+		return nil, api.ErrorScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+func (rep *Repository) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	schedule, err := rep.schedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *schedule
+
+	return &copied, nil
+}
+
+// GetScheduleRuns returns the runs of a schedule, the latest first.
+func (rep *Repository) GetScheduleRuns(ctx context.Context, id string) (*[]models.ScheduleRun, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	schedule, err := rep.schedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []models.ScheduleRun{}
+
+	for i := len(rep.runs) - 1; i >= 0; i-- {
+		if rep.runs[i].ScheduleId == schedule.Id {
+			runs = append(runs, rep.runs[i])
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].ExecutedAt.After(runs[j].ExecutedAt)
+	})
+
+	return &runs, nil
+}
+
+func (rep *Repository) updateSchedule(ctx context.Context, id string, update func(*models.Schedule) error) (*models.Schedule, error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	schedule, err := rep.schedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *schedule
+	if err = update(&updated); err != nil {
+		return nil, err
+	}
+
+	*schedule = updated
+
+	return &updated, nil
+}
+
+func (rep *Repository) PauseSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return rep.updateSchedule(ctx, id, func(schedule *models.Schedule) error {
+		if schedule.Status != models.ScheduleActive {
+			return api.ErrorScheduleState
+		}
+
+		schedule.Status = models.SchedulePaused
+
+		return nil
+	})
+}
+
+func (rep *Repository) ResumeSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return rep.updateSchedule(ctx, id, func(schedule *models.Schedule) error {
+		if schedule.Status != models.SchedulePaused {
+			return api.ErrorScheduleState
+		}
+
+		schedule.Status = models.ScheduleActive
+
+		if backend.Recurring(schedule) && schedule.NextRunAt != nil && schedule.NextRunAt.Before(time.Now()) {
+			next, err := backend.NextRun(schedule, time.Now())
+			if err != nil {
+				return err
+			}
+
+			schedule.NextRunAt = next
+			schedule.SlotAt = next
+			schedule.Attempt = 0
+		}
+
+		return nil
+	})
+}
+
+func (rep *Repository) CancelSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return rep.updateSchedule(ctx, id, func(schedule *models.Schedule) error {
+		if schedule.Status != models.ScheduleActive && schedule.Status != models.SchedulePaused {
+			return api.ErrorScheduleState
+		}
+
+		schedule.Status = models.ScheduleCancelled
+		schedule.NextRunAt = nil
+		schedule.SlotAt = nil
+
+		return nil
+	})
+}
+
+// ExecuteDueSchedules takes the lock once per schedule, so that requests are
+// served between the runs.
+func (rep *Repository) ExecuteDueSchedules(ctx context.Context, limit int) (int, error) {
+	executed := 0
+
+	for executed < limit {
+		found, err := rep.executeDueSchedule(ctx)
+		if err != nil {
+			return executed, err
+		}
+
+		if !found {
+			break
+		}
+
+		executed++
+	}
+
+	return executed, nil
+}
+
+// dueSchedule returns the active schedule that is due the longest.
+func (rep *Repository) dueSchedule(now time.Time) *models.Schedule {
+	var due *models.Schedule
+
+	for _, schedule := range rep.schedules {
+		if schedule.Status != models.ScheduleActive || schedule.NextRunAt == nil || schedule.NextRunAt.After(now) {
+			continue
+		}
+
+		if due == nil || schedule.NextRunAt.Before(*due.NextRunAt) {
+			due = schedule
+		}
+	}
+
+	return due
+}
+
+func (rep *Repository) executeDueSchedule(ctx context.Context) (bool, error) {
+	rep.mu.Lock()
+
+	now := time.Now()
+
+	schedule := rep.dueSchedule(now)
+	if schedule == nil {
+		rep.mu.Unlock()
+		return false, nil
+	}
+
+	scheduledAt := *schedule.NextRunAt
+
+	// the transfer runs on behalf of whoever created the schedule
+	actorCtx := auth.WithIdentity(ctx, &auth.Identity{Subject: schedule.Actor})
+	actorCtx = audit.WithSource(actorCtx, audit.ScheduleSource(schedule.Id))
+
+	tx := rep.begin()
+	fromBalance, toBalance, transferErr := tx.transfer(actorCtx, schedule.FromId, schedule.ToId, schedule.Money)
+	if transferErr != nil {
+		tx.rollback()
+	}
+
+	run := models.ScheduleRun{
+		Id:          uuid.New().String(),
+		ScheduleId:  schedule.Id,
+		ScheduledAt: scheduledAt,
+		ExecutedAt:  now,
+		Attempt:     schedule.Attempt + 1,
+		Outcome:     models.ScheduleRunSuccess,
+	}
+
+	if transferErr != nil {
+		message := transferErr.Error()
+		run.Outcome = models.ScheduleRunFailed
+		run.Error = &message
+	}
+
+	updated := *schedule
+	if err := backend.AdvanceSchedule(&updated, transferErr, now); err != nil {
+		tx.rollback()
+		rep.mu.Unlock()
+		return false, err
+	}
+
+	if transferErr == nil {
+		tx.auditChanges(actorCtx, backend.TransferChanges(updated.FromId, updated.ToId, updated.Money, fromBalance, toBalance)...)
+	}
+
+	*schedule = updated
+	rep.runs = append(rep.runs, run)
+
+	rep.mu.Unlock()
+
+	if transferErr == nil {
+		metrics.RecordOperation(metrics.OperationTransfer, updated.Money)
+	}
+
+	return true, nil
+}
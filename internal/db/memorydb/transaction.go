@@ -0,0 +1,112 @@
+package memorydb
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+type importNoteKey struct{}
+
+// importNote is stamped by addTransaction on the transactions of an import.
+type importNote struct {
+	id      string
+	comment string
+}
+
+// addTransaction appends a transaction to the ledger within tx, stamping the
+// actor, the request and the import of ctx on it.
+func (tx *tx) addTransaction(ctx context.Context, toId, fromId *string, operation string, money float64) {
+	transaction := models.Transaction{
+		Id:        uuid.New().String(),
+		ToId:      toId,
+		FromId:    fromId,
+		Money:     backend.RoundCents(money),
+		Operation: operation,
+		Actor:     auth.Actor(ctx),
+		CreatedAt: time.Now(),
+	}
+
+	if note, ok := ctx.Value(importNoteKey{}).(importNote); ok {
+		transaction.ImportId, transaction.Comment = &note.id, &note.comment
+	}
+
+	if id := logging.RequestId(ctx); id != "" {
+		transaction.RequestId = &id
+	}
+
+	n := len(tx.rep.transactions)
+	tx.rep.transactions = append(tx.rep.transactions, transaction)
+	tx.undo = append(tx.undo, func() { tx.rep.transactions = tx.rep.transactions[:n] })
+}
+
+func (rep *Repository) GetAllTransactions(ctx context.Context, id string, sortType string, limit int, page int) (*[]models.Transaction, error) {
+	if limit < 0 || page < 0 {
+		return nil, api.ErrorInvalidSortParameters
+	}
+
+	offset := (page - 1) * limit
+	if offset < 0 {
+		return nil, api.ErrorInvalidSortParameters
+	}
+
+	uid, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+
+	for _, transaction := range rep.transactions {
+		if transaction.ToId != nil && *transaction.ToId == uid || transaction.FromId != nil && *transaction.FromId == uid {
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	sortTransactions(transactions, sortType)
+
+	if offset > len(transactions) {
+		offset = len(transactions)
+	}
+	transactions = transactions[offset:]
+
+	if limit < len(transactions) {
+		transactions = transactions[:limit]
+	}
+
+	return &transactions, nil
+}
+
+// sortTransactions orders transactions by sortType, or keeps the order of
+// the ledger for unknown sort types.
+func sortTransactions(transactions []models.Transaction, sortType string) {
+	var less func(a, b *models.Transaction) bool
+
+	switch strings.ToLower(sortType) {
+	case models.SortDateAsc:
+		less = func(a, b *models.Transaction) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case models.SortDateDesc:
+		less = func(a, b *models.Transaction) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case models.SortMoneyAsc:
+		less = func(a, b *models.Transaction) bool { return a.Money < b.Money }
+	case models.SortMoneyDesc:
+		less = func(a, b *models.Transaction) bool { return a.Money > b.Money }
+	default:
+		return
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return less(&transactions[i], &transactions[j])
+	})
+}
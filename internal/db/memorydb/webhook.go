@@ -0,0 +1,225 @@
+package memorydb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const webhookDeliveriesLimit = 100
+
+func (rep *Repository) CreateWebhook(ctx context.Context, query models.CreateWebhookQuery) (*models.CreatedWebhook, error) {
+	if err := backend.ValidateWebhook(query); err != nil {
+		return nil, err
+	}
+
+	if query.Secret == "" {
+		secret, err := backend.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+
+		query.Secret = secret
+	}
+
+	webhook := &models.WebhookSubscription{
+		Id:         uuid.New().String(),
+		Url:        query.Url,
+		EventTypes: append(models.EventTypes{}, query.EventTypes...),
+		Secret:     query.Secret,
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+
+	rep.mu.Lock()
+	rep.webhooks = append(rep.webhooks, webhook)
+	rep.mu.Unlock()
+
+	return &models.CreatedWebhook{WebhookSubscription: *copyWebhook(webhook), Secret: query.Secret}, nil
+}
+
+// copyWebhook returns a copy of webhook without its secret, like the one
+// Postgres reads back.
+func copyWebhook(webhook *models.WebhookSubscription) *models.WebhookSubscription {
+	copied := *webhook
+	copied.EventTypes = append(models.EventTypes{}, webhook.EventTypes...)
+	copied.Secret = ""
+
+	return &copied
+}
+
+// webhook returns the stored subscription with the given id.
+func (rep *Repository) webhook(id string) (*models.WebhookSubscription, error) {
+	id, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range rep.webhooks {
+		if webhook.Id == id {
+			return webhook, nil
+		}
+	}
+
+	return nil, api.ErrorWebhookNotFound
+}
+
+func (rep *Repository) GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	webhook, err := rep.webhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyWebhook(webhook), nil
+}
+
+func (rep *Repository) DeleteWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	webhook, err := rep.webhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Active = false
+
+	return copyWebhook(webhook), nil
+}
+
+func (rep *Repository) GetWebhookDeliveries(ctx context.Context, id, status string) (*[]models.WebhookDelivery, error) {
+	rep.mu.RLock()
+	defer rep.mu.RUnlock()
+
+	webhook, err := rep.webhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+
+	for i := len(rep.deliveries) - 1; i >= 0; i-- {
+		delivery := rep.deliveries[i]
+		if delivery.SubscriptionId == webhook.Id && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery.WebhookDelivery)
+		}
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	if len(deliveries) > webhookDeliveriesLimit {
+		deliveries = deliveries[:webhookDeliveriesLimit]
+	}
+
+	return &deliveries, nil
+}
+
+func (rep *Repository) RetryWebhookDelivery(ctx context.Context, id, deliveryId string) (*models.WebhookDelivery, error) {
+	subscriptionId, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if deliveryId, err = parseId(deliveryId); err != nil {
+		return nil, err
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	for _, delivery := range rep.deliveries {
+		if delivery.Id != deliveryId || delivery.SubscriptionId != subscriptionId {
+			continue
+		}
+
+		if delivery.Status != models.WebhookDead {
+			return nil, api.ErrorWebhookDeliveryNotDead
+		}
+
+		now := time.Now()
+		delivery.Status = models.WebhookPending
+		delivery.Attempt = 0
+		delivery.NextAttemptAt = &now
+
+		updated := delivery.WebhookDelivery
+
+		return &updated, nil
+	}
+
+	return nil, api.ErrorWebhookDeliveryNotFound
+}
+
+// ClaimWebhookDeliveries leases the deliveries it returns under the write
+// lock, the longest due first.
+func (rep *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	now := time.Now()
+	var due []*webhookDelivery
+
+	for _, delivery := range rep.deliveries {
+		if delivery.Status != models.WebhookPending || delivery.NextAttemptAt == nil || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+
+		if webhook, err := rep.webhook(delivery.SubscriptionId); err == nil && webhook.Active {
+			due = append(due, delivery)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt)
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	leased := now.Add(lease)
+
+	for _, delivery := range due {
+		webhook, _ := rep.webhook(delivery.SubscriptionId)
+		event := delivery.event.event
+
+		claimed := delivery.WebhookDelivery
+		claimed.NextAttemptAt = nil
+		claimed.Url = webhook.Url
+		claimed.Secret = webhook.Secret
+		claimed.Event = &event
+
+		deliveries = append(deliveries, claimed)
+		delivery.NextAttemptAt = &leased
+	}
+
+	return deliveries, nil
+}
+
+func (rep *Repository) FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	for _, stored := range rep.deliveries {
+		if stored.Id == delivery.Id {
+			stored.Status = delivery.Status
+			stored.Attempt = delivery.Attempt
+			stored.NextAttemptAt = delivery.NextAttemptAt
+			stored.LastStatusCode = delivery.LastStatusCode
+			stored.LastError = delivery.LastError
+			stored.DeliveredAt = delivery.DeliveredAt
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"strings"
+	"time"
+)
+
+func accountError(err error) error {
+	if err == sql.ErrNoRows || err == pgx.ErrNoRows {
+		return api.ErrorUserNotFound
+	}
+
+	if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
+		return api.ErrorInvalidInput
+	}
+
+	return err
+}
+
+// rowScanner is a row of database/sql or of pgx.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+
+	err := row.Scan(&account.Id, &account.Balance, &account.CreditLimit, &account.Owner, &account.Metadata, &account.Status,
+		&account.DebitsBlocked, &account.CreditsBlocked, &account.CreatedAt, &account.ClosedAt)
+	if err != nil {
+		return nil, accountError(err)
+	}
+
+	return &account, nil
+}
+
+// lockAccount reads the account and locks its row until tx ends, so that its
+// status cannot change while money is moved.
+func (rep *BalanceRepository) lockAccount(ctx context.Context, uid string, tx *sql.Tx) (*models.Account, error) {
+	return scanAccount(tx.QueryRowContext(ctx, getAccountForUpdateSql, uid))
+}
+
+func (rep *BalanceRepository) CreateAccount(ctx context.Context, uid, owner string, metadata models.Metadata) (*models.Account, error) {
+	if uid == "" {
+		uid = uuid.New().String()
+	}
+
+	var account models.Account
+
+	if err := rep.db.GetContext(ctx, &account, addAccountSql, uid, owner, metadata); err != nil {
+		if strings.Contains(err.Error(), "users_pkey") {
+			return nil, api.ErrorAccountAlreadyExists
+		}
+
+		return nil, accountError(err)
+	}
+
+	return &account, nil
+}
+
+func (rep *BalanceRepository) GetAccount(ctx context.Context, uid string) (*models.Account, error) {
+	var account models.Account
+
+	if err := rep.db.GetContext(ctx, &account, getAccountSql, uid); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &account, nil
+}
+
+func (rep *BalanceRepository) updateAccountStatus(ctx context.Context, uid string, update func(*models.Account, *sql.Tx) error) (*models.Account, error) {
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	account, err := rep.lockAccount(ctx, uid, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == models.AccountClosed {
+		return nil, api.ErrorAccountClosed
+	}
+
+	if err = update(account, tx); err != nil {
+		return nil, err
+	}
+
+	updated, err := scanAccount(tx.QueryRowContext(ctx, updateAccountStatusSql, uid, account.Status,
+		account.DebitsBlocked, account.CreditsBlocked, account.ClosedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// FreezeAccount blocks debits, credits or both. Freezing with neither
+// blocks everything.
+func (rep *BalanceRepository) FreezeAccount(ctx context.Context, uid string, debits, credits bool) (*models.Account, error) {
+	if !debits && !credits {
+		debits, credits = true, true
+	}
+
+	return rep.updateAccountStatus(ctx, uid, func(account *models.Account, tx *sql.Tx) error {
+		account.Status = models.AccountFrozen
+		account.DebitsBlocked = debits
+		account.CreditsBlocked = credits
+
+		return nil
+	})
+}
+
+func (rep *BalanceRepository) UnfreezeAccount(ctx context.Context, uid string) (*models.Account, error) {
+	return rep.updateAccountStatus(ctx, uid, func(account *models.Account, tx *sql.Tx) error {
+		account.Status = models.AccountActive
+		account.DebitsBlocked = false
+		account.CreditsBlocked = false
+
+		return nil
+	})
+}
+
+// CloseAccount closes an account with zero balance and no open reserves.
+// A closed account accepts no further operations.
+func (rep *BalanceRepository) CloseAccount(ctx context.Context, uid string) (*models.Account, error) {
+	return rep.updateAccountStatus(ctx, uid, func(account *models.Account, tx *sql.Tx) error {
+		if account.Balance != 0 {
+			return api.ErrorAccountNotEmpty
+		}
+
+		var reserves int
+		if err := tx.QueryRowContext(ctx, countUserReservesSql, uid, models.ReserveReserved).Scan(&reserves); err != nil {
+			return err
+		}
+
+		if reserves > 0 {
+			return api.ErrorAccountHasReserves
+		}
+
+		now := time.Now()
+		account.Status = models.AccountClosed
+		account.DebitsBlocked = true
+		account.CreditsBlocked = true
+		account.ClosedAt = &now
+
+		return nil
+	})
+}
+
+// SetCreditLimit lets the balance of the account go negative down to
+// -limit. The limit cannot be lowered below the credit already in use.
+func (rep *BalanceRepository) SetCreditLimit(ctx context.Context, uid string, limit float64) (*models.Account, error) {
+	if limit < 0 {
+		return nil, api.ErrorNegativeAmount
+	}
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	account, err := rep.lockAccount(ctx, uid, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == models.AccountClosed {
+		return nil, api.ErrorAccountClosed
+	}
+
+	if account.Balance < -limit {
+		return nil, api.ErrorCreditLimitInUse
+	}
+
+	account, err = scanAccount(tx.QueryRowContext(ctx, updateCreditLimitSql, uid, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (rep *BalanceRepository) GetAccountsUsingCredit(ctx context.Context) (*[]models.Account, error) {
+	accounts := []models.Account{}
+
+	if err := rep.reader(ctx).SelectContext(ctx, &accounts, getAccountsUsingCreditSql); err != nil {
+		return nil, err
+	}
+
+	return &accounts, nil
+}
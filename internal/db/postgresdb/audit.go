@@ -0,0 +1,105 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+const auditVerifyBatch = 1000
+
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+// NewAuditRepository does not touch the schema, which is created by
+// NewSqlRepository, so it is safe to use from maintenance commands.
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+func (rep *AuditRepository) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	if err = appendAuditEntry(ctx, entry, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queryer is what appending an entry needs of a transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// appendAuditEntry links entry to the last entry of the log and appends it
+// within tx. The lock is held until tx ends, so the chain follows the order
+// in which the entries commit.
+func appendAuditEntry(ctx context.Context, entry *models.AuditEntry, tx queryer) error {
+	if _, err := tx.ExecContext(ctx, lockAuditLogSql, auditLockId); err != nil {
+		return err
+	}
+
+	if err := tx.QueryRowContext(ctx, getLastAuditHashSql).Scan(&entry.PrevHash); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	entry.Hash = audit.Hash(entry.PrevHash, entry)
+
+	err := tx.QueryRowContext(ctx, addAuditEntrySql, entry.Actor, entry.Endpoint, entry.PayloadHash, entry.BalancesBefore,
+		entry.BalancesAfter, entry.Status, entry.Outcome, entry.CreatedAt, entry.PrevHash, entry.Hash).Scan(&entry.Id)
+	if err != nil {
+		return fmt.Errorf("error when add audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// auditChanges appends the entry of the changes made on behalf of ctx within
+// tx, before it commits.
+func auditChanges(ctx context.Context, tx queryer, changes ...audit.Change) error {
+	return appendAuditEntry(ctx, audit.NewEntry(ctx, changes...), tx)
+}
+
+// VerifyAuditLog recomputes the hash chain and returns the number of verified
+// entries. A modified, removed or reordered entry yields
+// backend.ErrorAuditChainBroken.
+func (rep *AuditRepository) VerifyAuditLog(ctx context.Context) (int, error) {
+	var lastId int64
+	prevHash := ""
+	count := 0
+
+	for {
+		entries := []models.AuditEntry{}
+
+		if err := rep.db.SelectContext(ctx, &entries, getAuditEntriesSql, lastId, auditVerifyBatch); err != nil {
+			return count, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+
+			if entry.PrevHash != prevHash || audit.Hash(prevHash, entry) != entry.Hash {
+				return count, fmt.Errorf("%w at entry %d", backend.ErrorAuditChainBroken, entry.Id)
+			}
+
+			prevHash = entry.Hash
+			lastId = entry.Id
+			count++
+		}
+
+		if len(entries) < auditVerifyBatch {
+			return count, nil
+		}
+	}
+}
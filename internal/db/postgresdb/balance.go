@@ -1,139 +1,260 @@
 package postgresdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
 	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
 	"math"
 	"strings"
 )
 
-var ErrorUserNotFound = fmt.Errorf("user not found")
-var ErrorNotEnoughMoney = fmt.Errorf("not enough money")
-var ErrorInvalidInput = fmt.Errorf("invalid type for uid")
-var ErrorNegativeAmount = fmt.Errorf("negative amount")
-
-const (
-	operationAddMoney           = "adding money"
-	operationWithdrawMoney      = "withdrawal of money"
-	operationTransferMoney      = "transfer money"
-	operationReserveMoney       = "reserve money"
-	operationReturnReserveMoney = "return reserve money"
-)
-
-func (rep *BalanceRepository) createUserBalance(uid string, tx *sql.Tx) error {
-	_, err := tx.Exec(addUserSql, uid)
+func (rep *BalanceRepository) createUserBalance(ctx context.Context, uid string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, addUserSql, uid)
 
 	return err
 }
 
-func (rep *BalanceRepository) ChangeBalance(uid string, money float64) (*models.User, error) {
-	tx, err := rep.db.Begin()
+func (rep *BalanceRepository) ChangeBalance(ctx context.Context, uid string, money float64) (*models.User, error) {
+	tx, err := rep.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
+	defer rollback(tx)
 
+	before, user, err := rep.changeBalance(ctx, uid, money, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = auditChanges(ctx, tx, audit.Change{AccountId: uid, Before: before, After: user.Balance}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	audit.RecordCommitted(ctx)
+	backend.RecordBalanceChange(money)
+
+	return user, nil
+}
+
+// changeBalance deposits or withdraws money within tx, creating the account
+// on its first deposit, and returns the balance before the change together
+// with the updated user.
+func (rep *BalanceRepository) changeBalance(ctx context.Context, uid string, money float64, tx *sql.Tx) (float64, *models.User, error) {
 	var user models.User
 
-	if err := tx.QueryRow(getUserSql, uid).Scan(&user.Id, &user.Balance); err != nil {
-		if err == sql.ErrNoRows {
-			err = rep.createUserBalance(uid, tx)
-			if err != nil {
-				return nil, err
-			}
+	account, err := rep.lockAccount(ctx, uid, tx)
+	if err == api.ErrorUserNotFound {
+		err = rep.createUserBalance(ctx, uid, tx)
+		if err != nil {
+			return 0, nil, err
+		}
+	} else if err != nil {
+		return 0, nil, err
+	} else {
+		user.Balance = account.Balance
+		user.CreditLimit = account.CreditLimit
 
+		if money < 0 {
+			err = backend.CanDebit(account)
 		} else {
-			return nil, err
+			err = backend.CanCredit(account)
+		}
+		if err != nil {
+			return 0, nil, err
 		}
 	}
 
 	if money < 0 {
-		if user.Balance < math.Abs(money) {
-			return nil, ErrorNotEnoughMoney
+		if user.Balance+user.CreditLimit < math.Abs(money) {
+			metrics.RecordInsufficientFunds()
+			return 0, nil, api.ErrorNotEnoughMoney
+		}
+
+		if err = rep.checkDebitLimits(ctx, uid, math.Abs(money), false, tx); err != nil {
+			return 0, nil, err
 		}
 	}
 
-	row := tx.QueryRow(updateUserBalanceSql, uid, money)
-	if err = row.Scan(&user.Id, &user.Balance); err != nil {
-		return nil, err
+	before := user.Balance
+
+	var empty interface{}
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, uid, money).Scan(&empty, &empty); err != nil {
+		return 0, nil, err
 	}
 
+	err = tx.QueryRowContext(ctx, getUserSql, uid, models.ReserveReserved).Scan(&user.Id, &user.Balance, &user.CreditLimit,
+		&user.Available, &user.Held, &user.Total)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	eventType := models.EventDeposited
 	if money >= 0 {
-		if err = rep.addTransaction(&uid, nil, operationAddMoney, money, tx); err != nil {
-			return nil, err
+		if err = rep.addTransaction(ctx, &uid, nil, models.OperationAddMoney, money, tx); err != nil {
+			return 0, nil, err
 		}
 	} else {
-		if err = rep.addTransaction(nil, &uid, operationWithdrawMoney, money, tx); err != nil {
-			return nil, err
+		eventType = models.EventWithdrawn
+		if err = rep.addTransaction(ctx, nil, &uid, models.OperationWithdrawMoney, money, tx); err != nil {
+			return 0, nil, err
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, err
+	err = rep.addEvent(ctx, eventType, uid, models.AccountEventData{Amount: math.Abs(money), Balance: &user.Balance}, tx)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	return &user, nil
+	return before, &user, nil
 }
 
-func (rep *BalanceRepository) GetBalance(uid string) (*models.User, error) {
+// GetBalance reads the balance and the open reserves from one snapshot, so
+// that the held amount always matches the listed reserves.
+func (rep *BalanceRepository) GetBalance(ctx context.Context, uid string) (*models.User, error) {
+	tx, err := rep.reader(ctx).BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var user models.User
 
-	if err := rep.db.Get(&user, getUserSql, uid); err != nil {
+	if err := tx.GetContext(ctx, &user, getUserSql, uid, models.ReserveReserved); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, ErrorUserNotFound
+			return nil, api.ErrorUserNotFound
 		}
 
 		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
-			return nil, ErrorInvalidInput
+			return nil, api.ErrorInvalidInput
 		}
 
 		return nil, fmt.Errorf("error when get user balance: %w", err)
 	}
 
+	user.Reserves = []models.Reserve{}
+
+	if err := tx.SelectContext(ctx, &user.Reserves, getOpenReservesSql, uid, models.ReserveReserved); err != nil {
+		return nil, fmt.Errorf("error when get user reserves: %w", err)
+	}
+
 	return &user, nil
 
 }
 
-func (rep *BalanceRepository) TransferBalance(fromUid string, toUid string, money float64) error {
-	tx, err := rep.db.Begin()
+func (rep *BalanceRepository) TransferBalance(ctx context.Context, fromUid string, toUid string, money float64) error {
+	tx, err := rep.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	defer rollback(tx)
+
+	fromBalance, toBalance, err := rep.transfer(ctx, fromUid, toUid, money, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = auditChanges(ctx, tx, backend.TransferChanges(fromUid, toUid, money, fromBalance, toBalance)...); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationTransfer, money)
+
+	return nil
+}
 
+// transfer moves money within tx and returns the new balances of both
+// accounts. It is shared by TransferBalance and the scheduled transfers.
+func (rep *BalanceRepository) transfer(ctx context.Context, fromUid string, toUid string, money float64, tx *sql.Tx) (float64, float64, error) {
 	if money < 0 {
-		return ErrorNegativeAmount
+		return 0, 0, api.ErrorNegativeAmount
+	}
+
+	if err := rep.lockTransferAccounts(ctx, fromUid, toUid, tx); err != nil {
+		return 0, 0, err
+	}
+
+	if err := rep.checkDebitLimits(ctx, fromUid, money, true, tx); err != nil {
+		return 0, 0, err
 	}
 
 	var empty interface{}
-	err = tx.QueryRow(updateUserBalanceSql, toUid, money).Scan(&empty, &empty)
+	var toBalance, fromBalance float64
+	err := tx.QueryRowContext(ctx, updateUserBalanceSql, toUid, money).Scan(&empty, &toBalance)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return ErrorUserNotFound
+			return 0, 0, api.ErrorUserNotFound
 		}
 
-		return err
+		return 0, 0, err
 	}
 
-	err = tx.QueryRow(updateUserBalanceSql, fromUid, -money).Scan(&empty, &empty)
+	err = tx.QueryRowContext(ctx, updateUserBalanceSql, fromUid, -money).Scan(&empty, &fromBalance)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return ErrorUserNotFound
+			return 0, 0, api.ErrorUserNotFound
 		} else if strings.Contains(err.Error(), "users_balance_check") {
-			return ErrorNotEnoughMoney
+			metrics.RecordInsufficientFunds()
+			return 0, 0, api.ErrorNotEnoughMoney
 		}
 
-		return err
+		return 0, 0, err
 	}
 
-	if err = rep.addTransaction(&toUid, &fromUid, operationTransferMoney, money, tx); err != nil {
-		return err
+	if err = rep.addTransaction(ctx, &toUid, &fromUid, models.OperationTransferMoney, money, tx); err != nil {
+		return 0, 0, err
 	}
 
-	if err = tx.Commit(); err != nil {
+	err = rep.addEvent(ctx, models.EventTransferDebited, fromUid,
+		models.AccountEventData{Amount: money, Balance: &fromBalance, CounterpartyId: toUid}, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = rep.addEvent(ctx, models.EventTransferCredited, toUid,
+		models.AccountEventData{Amount: money, Balance: &toBalance, CounterpartyId: fromUid}, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fromBalance, toBalance, nil
+}
+
+// lockTransferAccounts locks both accounts in a fixed order, so that opposite
+// transfers between the same pair cannot deadlock, and checks their status.
+func (rep *BalanceRepository) lockTransferAccounts(ctx context.Context, fromUid, toUid string, tx *sql.Tx) error {
+	uids := []string{fromUid, toUid}
+	if toUid < fromUid {
+		uids = []string{toUid, fromUid}
+	}
+
+	accounts := make(map[string]*models.Account)
+
+	for _, uid := range uids {
+		account, err := rep.lockAccount(ctx, uid, tx)
+		if err != nil {
+			return err
+		}
+
+		accounts[uid] = account
+	}
+
+	if err := backend.CanDebit(accounts[fromUid]); err != nil {
 		return err
 	}
 
-	return nil
+	return backend.CanCredit(accounts[toUid])
 }
@@ -0,0 +1,137 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// ExecuteBatch runs operations in a single transaction, each item behind its
+// own savepoint. In atomic mode the first failure rolls back the whole batch
+// and the remaining items are skipped, otherwise failed items are rolled back
+// alone and the rest is committed. The returned error is set only when the
+// batch could not be run at all.
+func (rep *BalanceRepository) ExecuteBatch(ctx context.Context, atomic bool, operations []models.BatchOperation) (*models.BatchResult, error) {
+	result := &models.BatchResult{Atomic: atomic, Results: make([]models.BatchItemResult, len(operations))}
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var changes []audit.Change
+	failed := -1
+
+	for i, operation := range operations {
+		result.Results[i].Index = i
+
+		if failed >= 0 {
+			result.Results[i].Status = models.BatchItemSkipped
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+			return nil, err
+		}
+
+		itemChanges, itemErr := rep.executeBatchOperation(ctx, operation, tx)
+		if itemErr != nil {
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+				return nil, err
+			}
+
+			result.Results[i].Status = models.BatchItemFailed
+			result.Results[i].Error = itemErr.Error()
+
+			if atomic {
+				failed = i
+			}
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item"); err != nil {
+			return nil, err
+		}
+
+		result.Results[i].Status = models.BatchItemSuccess
+		changes = append(changes, itemChanges...)
+	}
+
+	if failed >= 0 {
+		for i := 0; i < failed; i++ {
+			result.Results[i].Status = models.BatchItemRolledBack
+		}
+
+		return result, nil
+	}
+
+	if err = auditChanges(ctx, tx, changes...); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	result.Committed = true
+	audit.RecordCommitted(ctx)
+
+	for i, operation := range operations {
+		if result.Results[i].Status == models.BatchItemSuccess {
+			recordBatchOperation(operation)
+		}
+	}
+
+	return result, nil
+}
+
+func recordBatchOperation(operation models.BatchOperation) {
+	switch operation.Type {
+	case models.BatchDeposit:
+		metrics.RecordOperation(metrics.OperationDeposit, operation.Money)
+	case models.BatchWithdraw:
+		metrics.RecordOperation(metrics.OperationWithdrawal, operation.Money)
+	case models.BatchTransfer:
+		metrics.RecordOperation(metrics.OperationTransfer, operation.Money)
+	case models.BatchReserve:
+		metrics.RecordOperation(metrics.OperationReserve, operation.Money)
+	}
+}
+
+func (rep *BalanceRepository) executeBatchOperation(ctx context.Context, operation models.BatchOperation, tx *sql.Tx) ([]audit.Change, error) {
+	switch operation.Type {
+	case models.BatchDeposit, models.BatchWithdraw:
+		money := operation.Money
+		if operation.Type == models.BatchWithdraw {
+			money = -money
+		}
+
+		before, user, err := rep.changeBalance(ctx, operation.Id, money, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		return []audit.Change{{AccountId: operation.Id, Before: before, After: user.Balance}}, nil
+	case models.BatchTransfer:
+		fromBalance, toBalance, err := rep.transfer(ctx, operation.FromId, operation.ToId, operation.Money, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		return backend.TransferChanges(operation.FromId, operation.ToId, operation.Money, fromBalance, toBalance), nil
+	case models.BatchReserve:
+		before, after, err := rep.reserveMoney(ctx, operation.Id, operation.ServiceId, operation.OrderId, operation.Money, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		return []audit.Change{{AccountId: operation.Id, Before: before, After: after}}, nil
+	default:
+		return nil, api.ErrorInvalidInput
+	}
+}
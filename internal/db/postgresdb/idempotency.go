@@ -0,0 +1,84 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const (
+	// idempotencyKeyTtl is how long a completed response is replayed.
+	idempotencyKeyTtl = 24 * time.Hour
+	// idempotencyLease frees keys of requests whose instance died before
+	// completing or releasing them.
+	idempotencyLease = 5 * time.Minute
+)
+
+type IdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db}
+}
+
+// StartRequest claims key for a request identified by fingerprint. It returns
+// nil when the caller should serve the request and the stored response when
+// the request was already served. A request still being served yields
+// api.ErrorIdempotencyKeyInProgress, a different request with the same key
+// api.ErrorIdempotencyKeyReused.
+func (rep *IdempotencyRepository) StartRequest(ctx context.Context, actor, key, fingerprint string) (*models.IdempotentResponse, error) {
+	var claimed string
+
+	err := rep.db.QueryRowContext(ctx, startIdempotentRequestSql, actor, key, fingerprint, time.Now().Add(idempotencyLease)).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var storedFingerprint string
+	var status *int
+	var response models.IdempotentResponse
+
+	err = rep.db.QueryRowContext(ctx, getIdempotentRequestSql, actor, key).Scan(&storedFingerprint, &status, &response.Header, &response.Body)
+	if err != nil {
+		// the key was released in the meantime, a retry will claim it
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorIdempotencyKeyInProgress
+		}
+
+		return nil, err
+	}
+
+	if storedFingerprint != fingerprint {
+		return nil, api.ErrorIdempotencyKeyReused
+	}
+
+	if status == nil {
+		return nil, api.ErrorIdempotencyKeyInProgress
+	}
+
+	response.Status = *status
+
+	return &response, nil
+}
+
+func (rep *IdempotencyRepository) CompleteRequest(ctx context.Context, actor, key string, response *models.IdempotentResponse) error {
+	_, err := rep.db.ExecContext(ctx, completeIdempotentRequestSql, actor, key, response.Status, response.Header, response.Body,
+		time.Now().Add(idempotencyKeyTtl))
+
+	return err
+}
+
+// ReleaseRequest forgets a claimed key, so that the request can be retried.
+func (rep *IdempotencyRepository) ReleaseRequest(ctx context.Context, actor, key string) error {
+	_, err := rep.db.ExecContext(ctx, releaseIdempotentRequestSql, actor, key)
+
+	return err
+}
@@ -0,0 +1,117 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"time"
+)
+
+func (rep *BalanceRepository) GetLimits(ctx context.Context, scope string) (*models.Limits, error) {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return nil, err
+	}
+
+	var limits models.Limits
+
+	if err := rep.db.GetContext(ctx, &limits, getLimitsSql, scope); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorLimitsNotFound
+		}
+
+		return nil, err
+	}
+
+	return &limits, nil
+}
+
+func (rep *BalanceRepository) SetLimits(ctx context.Context, scope string, limits models.Limits) (*models.Limits, error) {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return nil, err
+	}
+
+	if err := backend.ValidateLimits(limits); err != nil {
+		return nil, err
+	}
+
+	var updated models.Limits
+
+	err := rep.db.GetContext(ctx, &updated, setLimitsSql, scope, limits.MaxOperation, limits.DailyDebit,
+		limits.MonthlyDebit, limits.HourlyTransfers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (rep *BalanceRepository) DeleteLimits(ctx context.Context, scope string) error {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return err
+	}
+
+	res, err := rep.db.ExecContext(ctx, deleteLimitsSql, scope)
+	if err != nil {
+		return err
+	}
+
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		return api.ErrorLimitsNotFound
+	}
+
+	return nil
+}
+
+// effectiveLimits merges the account limits over the global ones.
+func (rep *BalanceRepository) effectiveLimits(ctx context.Context, uid string, tx *sql.Tx) (*models.Limits, error) {
+	rows, err := tx.QueryContext(ctx, getEffectiveLimitsSql, backend.GlobalLimitsScope, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scopes []models.Limits
+
+	for rows.Next() {
+		var limits models.Limits
+		if err := rows.Scan(&limits.Scope, &limits.MaxOperation, &limits.DailyDebit, &limits.MonthlyDebit, &limits.HourlyTransfers); err != nil {
+			return nil, err
+		}
+
+		scopes = append(scopes, limits)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return backend.MergeLimits(scopes), nil
+}
+
+// checkDebitLimits must run after the account row is locked, so that
+// concurrent debits of the account cannot both pass the totals check.
+func (rep *BalanceRepository) checkDebitLimits(ctx context.Context, uid string, amount float64, transfer bool, tx *sql.Tx) error {
+	limits, err := rep.effectiveLimits(ctx, uid, tx)
+	if err != nil {
+		return err
+	}
+
+	return backend.CheckLimits(limits, amount, transfer, time.Now(), backend.DebitTotals{
+		Debited: func(since time.Time) (float64, error) {
+			var total float64
+			err := tx.QueryRowContext(ctx, getDebitTotalSql, uid, models.OperationWithdrawMoney, models.OperationTransferMoney,
+				models.ReserveDeReserved, since).Scan(&total)
+
+			return total, err
+		},
+		Transfers: func(since time.Time) (int, *time.Time, error) {
+			var count int
+			var oldest *time.Time
+			err := tx.QueryRowContext(ctx, getRecentTransfersSql, uid, models.OperationTransferMoney, since).Scan(&count, &oldest)
+
+			return count, oldest, err
+		},
+	})
+}
@@ -0,0 +1,18 @@
+package postgresdb
+
+// The advisory locks of the service share the database with those of other
+// applications, so their keys are the FNV-1a 64-bit hashes of names under
+// "balance-service/" rather than small numbers. A new lock takes the hash of
+// its own name, see TestAdvisoryLockIds.
+const (
+	// auditLockId serializes appends so that every entry links to its
+	// predecessor. Every money-moving transaction appends its audit entries and
+	// holds pg_advisory_xact_lock(auditLockId) until it commits, so all of them
+	// commit one at a time behind this one global lock, whatever accounts they
+	// touch and on whichever replica they run.
+	auditLockId int64 = -8167593330193204595 // "balance-service/audit"
+
+	// outboxLockId lets a single relay publish at a time, which keeps the
+	// events of every account in order.
+	outboxLockId int64 = 4114670546476093891 // "balance-service/outbox"
+)
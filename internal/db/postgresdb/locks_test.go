@@ -0,0 +1,19 @@
+package postgresdb
+
+import (
+	"hash/fnv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvisoryLockIds(t *testing.T) {
+	lockId := func(name string) int64 {
+		hash := fnv.New64a()
+		hash.Write([]byte(name))
+		return int64(hash.Sum64())
+	}
+
+	require.Equal(t, lockId("balance-service/audit"), auditLockId)
+	require.Equal(t, lockId("balance-service/outbox"), outboxLockId)
+}
@@ -0,0 +1,98 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// BalanceChangesChannel is notified with every event as JSON when its
+// transaction commits, so that live streams on every replica learn about it.
+const BalanceChangesChannel = "balance_changes"
+
+// outboxRow reads the JSONB data as text, the driver cannot scan it into a
+// json.RawMessage directly.
+type outboxRow struct {
+	models.Event
+	Data string `db:"data"`
+}
+
+// addEvent writes an event to the outbox within tx, so that it is published
+// if and only if the change commits, queues its webhook deliveries and
+// notifies the live streams.
+func (rep *BalanceRepository) addEvent(ctx context.Context, eventType, accountId string, data models.AccountEventData, tx *sql.Tx) error {
+	event, err := backend.NewEvent(ctx, eventType, accountId, data)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, addEventSql, event.Id, event.Type, event.Version, event.AccountId, []byte(event.Data)).Scan(&event.Sequence)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, addWebhookDeliveriesSql, event.Sequence, event.Id, event.Type, models.WebhookPending, models.AllEvents)
+	if err != nil {
+		return err
+	}
+
+	notification, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, notifySql, BalanceChangesChannel, string(notification))
+
+	return err
+}
+
+// RelayEvents hands up to limit unpublished events to publish in outbox order
+// and marks the published ones. It stops at the first failure, the failed
+// event and the ones after it are retried by the next call, so delivery is at
+// least once. It returns 0 without publishing when another relay is running.
+func (rep *BalanceRepository) RelayEvents(ctx context.Context, limit int, publish func(context.Context, *models.Event) error) (int, error) {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err = tx.GetContext(ctx, &locked, lockOutboxSql, outboxLockId); err != nil || !locked {
+		return 0, err
+	}
+
+	rows := []outboxRow{}
+	if err = tx.SelectContext(ctx, &rows, getUnpublishedEventsSql, limit); err != nil {
+		return 0, err
+	}
+
+	published := make([]int64, 0, len(rows))
+	var publishErr error
+
+	for _, row := range rows {
+		event := row.Event
+		event.Data = json.RawMessage(row.Data)
+
+		if publishErr = publish(ctx, &event); publishErr != nil {
+			break
+		}
+
+		published = append(published, event.Sequence)
+	}
+
+	if len(published) > 0 {
+		if _, err = tx.ExecContext(ctx, markEventsPublishedSql, pq.Array(published)); err != nil {
+			return 0, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(published), publishErr
+}
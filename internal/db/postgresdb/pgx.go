@@ -0,0 +1,566 @@
+package postgresdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// PgxRepository serves the balance reads, the money movements and the
+// imports on a native pgx pool. The statements of every operation are sent in
+// batches, so that an operation takes a few round trips instead of one per
+// statement, and imports are copied in bulk. The rest of the API, which is
+// not on the hot path, is served by the embedded BalanceRepository, and so
+// are the reads of a replica.
+type PgxRepository struct {
+	*BalanceRepository
+	pool *pgxpool.Pool
+}
+
+// NewPgxRepository returns a repository over pool. rep serves the rest of the
+// API and must be over the same database.
+func NewPgxRepository(pool *pgxpool.Pool, rep *BalanceRepository) *PgxRepository {
+	return &PgxRepository{BalanceRepository: rep, pool: pool}
+}
+
+// pgxRollback is rollback for pgx transactions.
+func pgxRollback(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err == nil {
+		metrics.RecordTxRollback()
+	}
+}
+
+// inTx runs fn in a transaction committed when fn succeeds.
+func (rep *PgxRepository) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := rep.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer pgxRollback(ctx, tx)
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// sendBatch sends batch and reads its results in order with read, returning
+// the first error met.
+func sendBatch(ctx context.Context, tx pgx.Tx, batch *pgx.Batch, read func(results pgx.BatchResults) error) error {
+	results := tx.SendBatch(ctx, batch)
+
+	err := read(results)
+	if closeErr := results.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func execBatch(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) error {
+	return sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		for i := 0; i < batch.Len(); i++ {
+			if _, err := results.Exec(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// auditChangesPgx is auditChanges within a pgx transaction, the log is locked
+// and its last hash read in one round trip.
+func auditChangesPgx(ctx context.Context, tx pgx.Tx, changes ...audit.Change) error {
+	entry := audit.NewEntry(ctx, changes...)
+
+	batch := &pgx.Batch{}
+	batch.Queue(lockAuditLogSql, auditLockId)
+	batch.Queue(getLastAuditHashSql)
+
+	err := sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		if _, err := results.Exec(); err != nil {
+			return err
+		}
+
+		if err := results.QueryRow().Scan(&entry.PrevHash); err != nil && err != pgx.ErrNoRows {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	entry.Hash = audit.Hash(entry.PrevHash, entry)
+
+	err = tx.QueryRow(ctx, addAuditEntrySql, entry.Actor, entry.Endpoint, entry.PayloadHash, entry.BalancesBefore,
+		entry.BalancesAfter, entry.Status, entry.Outcome, entry.CreatedAt, entry.PrevHash, entry.Hash).Scan(&entry.Id)
+	if err != nil {
+		return fmt.Errorf("error when add audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func scanLimits(rows pgx.Rows) (*models.Limits, error) {
+	defer rows.Close()
+
+	var scopes []models.Limits
+
+	for rows.Next() {
+		var limits models.Limits
+		if err := rows.Scan(&limits.Scope, &limits.MaxOperation, &limits.DailyDebit, &limits.MonthlyDebit, &limits.HourlyTransfers); err != nil {
+			return nil, err
+		}
+
+		scopes = append(scopes, limits)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return backend.MergeLimits(scopes), nil
+}
+
+// pgxDebitTotals reads the past debits of uid within tx.
+func pgxDebitTotals(ctx context.Context, uid string, tx pgx.Tx) backend.DebitTotals {
+	return backend.DebitTotals{
+		Debited: func(since time.Time) (float64, error) {
+			var total float64
+			err := tx.QueryRow(ctx, getDebitTotalSql, uid, models.OperationWithdrawMoney, models.OperationTransferMoney,
+				models.ReserveDeReserved, since).Scan(&total)
+
+			return total, err
+		},
+		Transfers: func(since time.Time) (int, *time.Time, error) {
+			var count int
+			var oldest *time.Time
+			err := tx.QueryRow(ctx, getRecentTransfersSql, uid, models.OperationTransferMoney, since).Scan(&count, &oldest)
+
+			return count, oldest, err
+		},
+	}
+}
+
+// addEvents writes events to the outbox within tx like addEvent, in two round
+// trips whatever their number.
+func addEvents(ctx context.Context, tx pgx.Tx, events ...*models.Event) error {
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		batch.Queue(addEventSql, event.Id, event.Type, event.Version, event.AccountId, string(event.Data))
+	}
+
+	err := sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		for _, event := range events {
+			if err := results.QueryRow().Scan(&event.Sequence); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	batch = &pgx.Batch{}
+	for _, event := range events {
+		notification, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(addWebhookDeliveriesSql, event.Sequence, event.Id, event.Type, models.WebhookPending, models.AllEvents)
+		batch.Queue(notifySql, BalanceChangesChannel, string(notification))
+	}
+
+	return execBatch(ctx, tx, batch)
+}
+
+// GetBalance reads the balance and the open reserves from one snapshot in a
+// single round trip.
+func (rep *PgxRepository) GetBalance(ctx context.Context, uid string) (*models.User, error) {
+	if rep.replica != nil {
+		return rep.BalanceRepository.GetBalance(ctx, uid)
+	}
+
+	tx, err := rep.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	batch.Queue(getUserSql, uid, models.ReserveReserved)
+	batch.Queue(getOpenReservesSql, uid, models.ReserveReserved)
+
+	var user models.User
+
+	err = sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		err := results.QueryRow().Scan(&user.Id, &user.Balance, &user.CreditLimit, &user.Available, &user.Held, &user.Total)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return api.ErrorUserNotFound
+			}
+
+			if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
+				return api.ErrorInvalidInput
+			}
+
+			return fmt.Errorf("error when get user balance: %w", err)
+		}
+
+		rows, err := results.Query()
+		if err != nil {
+			return fmt.Errorf("error when get user reserves: %w", err)
+		}
+		defer rows.Close()
+
+		user.Reserves = []models.Reserve{}
+
+		for rows.Next() {
+			var reserve models.Reserve
+			err := rows.Scan(&reserve.Id, &reserve.UserId, &reserve.ServiceId, &reserve.OrderId, &reserve.Amount,
+				&reserve.Status, &reserve.Actor, &reserve.CreatedAt, &reserve.RecognizedAt)
+			if err != nil {
+				return fmt.Errorf("error when get user reserves: %w", err)
+			}
+
+			user.Reserves = append(user.Reserves, reserve)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (rep *PgxRepository) GetAllTransactions(ctx context.Context, id string, sortType string, limit int, page int) (*[]models.Transaction, error) {
+	if rep.replica != nil {
+		return rep.BalanceRepository.GetAllTransactions(ctx, id, sortType, limit, page)
+	}
+
+	if limit < 0 || page < 0 {
+		return nil, api.ErrorInvalidSortParameters
+	}
+
+	rows, err := rep.pool.Query(ctx, allTransactionsSql(sortType), id, limit, (page-1)*limit)
+	if err != nil {
+		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
+			return nil, api.ErrorInvalidInput
+		}
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+
+	for rows.Next() {
+		var t models.Transaction
+		err := rows.Scan(&t.Id, &t.ToId, &t.FromId, &t.Money, &t.Operation, &t.Actor, &t.ImportId, &t.Comment,
+			&t.RequestId, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
+			return nil, api.ErrorInvalidInput
+		}
+
+		return nil, err
+	}
+
+	return &transactions, nil
+}
+
+func (rep *PgxRepository) ChangeBalance(ctx context.Context, uid string, money float64) (*models.User, error) {
+	var before float64
+	var user *models.User
+
+	err := rep.inTx(ctx, func(tx pgx.Tx) (err error) {
+		if before, user, err = rep.changeBalance(ctx, uid, money, tx); err != nil {
+			return err
+		}
+
+		return auditChangesPgx(ctx, tx, audit.Change{AccountId: uid, Before: before, After: user.Balance})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	audit.RecordCommitted(ctx)
+	backend.RecordBalanceChange(money)
+
+	return user, nil
+}
+
+// changeBalance is changeBalance of BalanceRepository in three round trips:
+// the account is locked together with reading its limits, the balance update
+// is sent with the transaction, and the event is written by addEvents.
+func (rep *PgxRepository) changeBalance(ctx context.Context, uid string, money float64, tx pgx.Tx) (float64, *models.User, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(getAccountForUpdateSql, uid)
+	if money < 0 {
+		batch.Queue(getEffectiveLimitsSql, backend.GlobalLimitsScope, uid)
+	}
+
+	var account *models.Account
+	var limits *models.Limits
+	var lockErr error
+
+	err := sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		account, lockErr = scanAccount(results.QueryRow())
+		if lockErr != nil && lockErr != api.ErrorUserNotFound {
+			return lockErr
+		}
+
+		if money < 0 {
+			rows, err := results.Query()
+			if err != nil {
+				return err
+			}
+
+			limits, err = scanLimits(rows)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var user models.User
+
+	exists := lockErr == nil
+	if exists {
+		user.Balance = account.Balance
+		user.CreditLimit = account.CreditLimit
+
+		if money < 0 {
+			err = backend.CanDebit(account)
+		} else {
+			err = backend.CanCredit(account)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if money < 0 {
+		if user.Balance+user.CreditLimit < math.Abs(money) {
+			metrics.RecordInsufficientFunds()
+			return 0, nil, api.ErrorNotEnoughMoney
+		}
+
+		if err = backend.CheckLimits(limits, math.Abs(money), false, time.Now(), pgxDebitTotals(ctx, uid, tx)); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	before := user.Balance
+
+	eventType := models.EventDeposited
+	transaction := transactionArgs(ctx, &uid, nil, models.OperationAddMoney, money)
+	if money < 0 {
+		eventType = models.EventWithdrawn
+		transaction = transactionArgs(ctx, nil, &uid, models.OperationWithdrawMoney, money)
+	}
+
+	batch = &pgx.Batch{}
+	if !exists {
+		batch.Queue(addUserSql, uid)
+	}
+	batch.Queue(updateUserBalanceSql, uid, money)
+	batch.Queue(getUserSql, uid, models.ReserveReserved)
+	batch.Queue(addTransactionsSql, transaction...)
+
+	err = sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		if !exists {
+			if _, err := results.Exec(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := results.Exec(); err != nil {
+			return err
+		}
+
+		err := results.QueryRow().Scan(&user.Id, &user.Balance, &user.CreditLimit, &user.Available, &user.Held, &user.Total)
+		if err != nil {
+			return err
+		}
+
+		_, err = results.Exec()
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	event, err := backend.NewEvent(ctx, eventType, uid, models.AccountEventData{Amount: math.Abs(money), Balance: &user.Balance})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err = addEvents(ctx, tx, event); err != nil {
+		return 0, nil, err
+	}
+
+	return before, &user, nil
+}
+
+func (rep *PgxRepository) TransferBalance(ctx context.Context, fromUid string, toUid string, money float64) error {
+	var fromBalance, toBalance float64
+
+	err := rep.inTx(ctx, func(tx pgx.Tx) (err error) {
+		if fromBalance, toBalance, err = rep.transfer(ctx, fromUid, toUid, money, tx); err != nil {
+			return err
+		}
+
+		return auditChangesPgx(ctx, tx, backend.TransferChanges(fromUid, toUid, money, fromBalance, toBalance)...)
+	})
+	if err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationTransfer, money)
+
+	return nil
+}
+
+// transfer is transfer of BalanceRepository in three round trips: both
+// accounts are locked together with reading the limits, both updates are
+// sent with the transaction, and the events are written by addEvents.
+func (rep *PgxRepository) transfer(ctx context.Context, fromUid string, toUid string, money float64, tx pgx.Tx) (float64, float64, error) {
+	if money < 0 {
+		return 0, 0, api.ErrorNegativeAmount
+	}
+
+	// the accounts are locked in a fixed order like in lockTransferAccounts
+	uids := []string{fromUid, toUid}
+	if toUid < fromUid {
+		uids = []string{toUid, fromUid}
+	}
+
+	batch := &pgx.Batch{}
+	for _, uid := range uids {
+		batch.Queue(getAccountForUpdateSql, uid)
+	}
+	batch.Queue(getEffectiveLimitsSql, backend.GlobalLimitsScope, fromUid)
+
+	accounts := make(map[string]*models.Account)
+	var limits *models.Limits
+
+	err := sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		for _, uid := range uids {
+			account, err := scanAccount(results.QueryRow())
+			if err != nil {
+				return err
+			}
+
+			accounts[uid] = account
+		}
+
+		rows, err := results.Query()
+		if err != nil {
+			return err
+		}
+
+		limits, err = scanLimits(rows)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = backend.CanDebit(accounts[fromUid]); err != nil {
+		return 0, 0, err
+	}
+
+	if err = backend.CanCredit(accounts[toUid]); err != nil {
+		return 0, 0, err
+	}
+
+	if err = backend.CheckLimits(limits, money, true, time.Now(), pgxDebitTotals(ctx, fromUid, tx)); err != nil {
+		return 0, 0, err
+	}
+
+	batch = &pgx.Batch{}
+	batch.Queue(updateUserBalanceSql, toUid, money)
+	batch.Queue(updateUserBalanceSql, fromUid, -money)
+	batch.Queue(addTransactionsSql, transactionArgs(ctx, &toUid, &fromUid, models.OperationTransferMoney, money)...)
+
+	var fromBalance, toBalance float64
+
+	err = sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		var empty interface{}
+
+		if err := results.QueryRow().Scan(&empty, &toBalance); err != nil {
+			if err == pgx.ErrNoRows {
+				return api.ErrorUserNotFound
+			}
+
+			return err
+		}
+
+		if err := results.QueryRow().Scan(&empty, &fromBalance); err != nil {
+			if err == pgx.ErrNoRows {
+				return api.ErrorUserNotFound
+			} else if strings.Contains(err.Error(), "users_balance_check") {
+				metrics.RecordInsufficientFunds()
+				return api.ErrorNotEnoughMoney
+			}
+
+			return err
+		}
+
+		_, err := results.Exec()
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	debited, err := backend.NewEvent(ctx, models.EventTransferDebited, fromUid,
+		models.AccountEventData{Amount: money, Balance: &fromBalance, CounterpartyId: toUid})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	credited, err := backend.NewEvent(ctx, models.EventTransferCredited, toUid,
+		models.AccountEventData{Amount: money, Balance: &toBalance, CounterpartyId: fromUid})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = addEvents(ctx, tx, debited, credited); err != nil {
+		return 0, 0, err
+	}
+
+	return fromBalance, toBalance, nil
+}
@@ -0,0 +1,368 @@
+package postgresdb
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+var transactionColumns = []string{"to_id", "from_id", "money", "operation", "actor", "import_id", "comment", "request_id", "created_at"}
+var eventColumns = []string{"event_id", "type", "version", "account_id", "data"}
+
+// pgxImport applies the rows of an import to the locked accounts in memory,
+// the way changeBalance would apply them one after the other.
+type pgxImport struct {
+	ctx      context.Context
+	tx       pgx.Tx
+	now      time.Time
+	accounts map[string]*models.Account
+	limits   map[string]*models.Limits
+	// debited caches the totals read from the database, imported adds the
+	// debits of the import to them
+	debited  map[string]map[time.Time]float64
+	imported map[string]float64
+
+	transactions [][]interface{}
+	events       []*models.Event
+	changes      []audit.Change
+	initial      map[string]float64
+}
+
+// ImportBalances applies rows like ImportBalances of BalanceRepository. The
+// accounts are locked and checked in a few round trips, the rows are applied
+// in memory, and the transactions and events of a committed import are
+// copied in bulk.
+func (rep *PgxRepository) ImportBalances(ctx context.Context, rows []models.ImportRow, dryRun bool) (*models.ImportResult, error) {
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(rows), Errors: make([]models.ImportLineError, 0)}
+	importId := uuid.New().String()
+
+	tx, err := rep.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer pgxRollback(ctx, tx)
+
+	imp, err := startImport(ctx, tx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		rowCtx := context.WithValue(ctx, importNoteKey{}, importNote{importId, row.Comment})
+
+		if rowErr := imp.apply(rowCtx, row); rowErr != nil {
+			result.Errors = append(result.Errors, models.ImportLineError{Line: row.Line, Error: rowErr.Error()})
+		}
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	if err = imp.write(); err != nil {
+		return nil, err
+	}
+
+	if err = auditChangesPgx(ctx, tx, imp.changes...); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	result.Id = importId
+	result.Applied = true
+	audit.RecordCommitted(ctx)
+
+	for _, row := range rows {
+		backend.RecordBalanceChange(row.Amount)
+	}
+
+	return result, nil
+}
+
+// startImport locks the accounts of rows in one round trip and reads the
+// limits of the debited ones in another.
+func startImport(ctx context.Context, tx pgx.Tx, rows []models.ImportRow) (*pgxImport, error) {
+	imp := &pgxImport{
+		ctx:      ctx,
+		tx:       tx,
+		now:      time.Now(),
+		accounts: make(map[string]*models.Account),
+		limits:   make(map[string]*models.Limits),
+		debited:  make(map[string]map[time.Time]float64),
+		imported: make(map[string]float64),
+		initial:  make(map[string]float64),
+	}
+
+	var uids []string
+	debits := make(map[string]bool)
+
+	for _, row := range rows {
+		id, err := uuid.Parse(row.UserId)
+		if err != nil {
+			continue
+		}
+
+		uid := id.String()
+		if _, ok := imp.initial[uid]; !ok {
+			imp.initial[uid] = 0
+			uids = append(uids, uid)
+		}
+
+		if row.Amount < 0 {
+			debits[uid] = true
+		}
+	}
+
+	if len(uids) == 0 {
+		return imp, nil
+	}
+
+	locked, err := tx.Query(ctx, getAccountsForUpdateSql, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	for locked.Next() {
+		account, err := scanAccount(locked)
+		if err != nil {
+			locked.Close()
+			return nil, err
+		}
+
+		imp.accounts[account.Id] = account
+		imp.initial[account.Id] = account.Balance
+	}
+	locked.Close()
+
+	if err = locked.Err(); err != nil {
+		return nil, err
+	}
+
+	debited := make([]string, 0, len(debits))
+	for uid := range debits {
+		if _, ok := imp.accounts[uid]; ok {
+			debited = append(debited, uid)
+		}
+	}
+	sort.Strings(debited)
+
+	batch := &pgx.Batch{}
+	for _, uid := range debited {
+		batch.Queue(getEffectiveLimitsSql, backend.GlobalLimitsScope, uid)
+	}
+
+	err = sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		for _, uid := range debited {
+			rows, err := results.Query()
+			if err != nil {
+				return err
+			}
+
+			if imp.limits[uid], err = scanLimits(rows); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+// apply applies row, or returns why changeBalance would reject it leaving the
+// import unchanged. Unlike ChangeBalance an import never opens new accounts.
+func (imp *pgxImport) apply(ctx context.Context, row models.ImportRow) error {
+	id, err := uuid.Parse(row.UserId)
+	if err != nil {
+		return api.ErrorInvalidInput
+	}
+
+	// the accounts are keyed by the canonical form read back from the database
+	uid := id.String()
+	account, ok := imp.accounts[uid]
+	if !ok {
+		return api.ErrorUserNotFound
+	}
+
+	money := row.Amount
+	if money < 0 {
+		if err := backend.CanDebit(account); err != nil {
+			return err
+		}
+
+		if account.Balance+account.CreditLimit < math.Abs(money) {
+			metrics.RecordInsufficientFunds()
+			return api.ErrorNotEnoughMoney
+		}
+
+		if err := backend.CheckLimits(imp.limits[uid], math.Abs(money), false, imp.now, imp.totals(uid)); err != nil {
+			return err
+		}
+	} else if err := backend.CanCredit(account); err != nil {
+		return err
+	}
+
+	before := account.Balance
+	account.Balance = backend.RoundCents(account.Balance + money)
+
+	eventType := models.EventDeposited
+	transaction := transactionArgs(ctx, &uid, nil, models.OperationAddMoney, money)
+	if money < 0 {
+		imp.imported[uid] += math.Abs(money)
+
+		eventType = models.EventWithdrawn
+		transaction = transactionArgs(ctx, nil, &uid, models.OperationWithdrawMoney, money)
+	}
+
+	balance := account.Balance
+	event, err := backend.NewEvent(ctx, eventType, uid, models.AccountEventData{Amount: math.Abs(money), Balance: &balance})
+	if err != nil {
+		return err
+	}
+
+	imp.transactions = append(imp.transactions, transaction)
+	imp.events = append(imp.events, event)
+	imp.changes = append(imp.changes, audit.Change{AccountId: uid, Before: before, After: balance})
+
+	return nil
+}
+
+// totals reads the past debits of uid, adding the debits of the import to
+// the amounts debited since the start of the day or the month.
+func (imp *pgxImport) totals(uid string) backend.DebitTotals {
+	return backend.DebitTotals{
+		Debited: func(since time.Time) (float64, error) {
+			if imp.debited[uid] == nil {
+				imp.debited[uid] = make(map[time.Time]float64)
+			}
+
+			total, ok := imp.debited[uid][since]
+			if !ok {
+				err := imp.tx.QueryRow(imp.ctx, getDebitTotalSql, uid, models.OperationWithdrawMoney, models.OperationTransferMoney,
+					models.ReserveDeReserved, since).Scan(&total)
+				if err != nil {
+					return 0, err
+				}
+
+				imp.debited[uid][since] = total
+			}
+
+			return total + imp.imported[uid], nil
+		},
+	}
+}
+
+// write stores the applied rows: the balances in one statement, the
+// transactions and the events copied in bulk, then the webhook deliveries
+// and the notifications of the events.
+func (imp *pgxImport) write() error {
+	ctx, tx := imp.ctx, imp.tx
+
+	uids := make([]string, 0, len(imp.accounts))
+	for uid := range imp.accounts {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	var changed []string
+	var amounts []float64
+
+	for _, uid := range uids {
+		if amount := backend.RoundCents(imp.accounts[uid].Balance - imp.initial[uid]); amount != 0 {
+			changed = append(changed, uid)
+			amounts = append(amounts, amount)
+		}
+	}
+
+	if len(changed) > 0 {
+		if _, err := tx.Exec(ctx, addBalancesSql, changed, amounts); err != nil {
+			return err
+		}
+	}
+
+	if len(imp.transactions) == 0 {
+		return nil
+	}
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"transactions"}, transactionColumns, pgx.CopyFromRows(imp.transactions))
+	if err != nil {
+		return err
+	}
+
+	eventIds := make([]string, 0, len(imp.events))
+	events := make([][]interface{}, 0, len(imp.events))
+	for _, event := range imp.events {
+		eventIds = append(eventIds, event.Id)
+		events = append(events, []interface{}{event.Id, event.Type, event.Version, event.AccountId, string(event.Data)})
+	}
+
+	// the outbox numbers the copied events in the order of the rows
+	if _, err = tx.CopyFrom(ctx, pgx.Identifier{"outbox"}, eventColumns, pgx.CopyFromRows(events)); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(addEventsWebhookDeliveriesSql, eventIds, models.WebhookPending, models.AllEvents)
+	batch.Queue(getEventSequencesSql, eventIds)
+
+	sequences := make(map[string]int64, len(eventIds))
+
+	err = sendBatch(ctx, tx, batch, func(results pgx.BatchResults) error {
+		if _, err := results.Exec(); err != nil {
+			return err
+		}
+
+		rows, err := results.Query()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var eventId string
+			var sequence int64
+			if err := rows.Scan(&eventId, &sequence); err != nil {
+				return err
+			}
+
+			sequences[eventId] = sequence
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	notifications := make([]string, 0, len(imp.events))
+	for _, event := range imp.events {
+		event.Sequence = sequences[event.Id]
+
+		notification, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		notifications = append(notifications, string(notification))
+	}
+
+	_, err = tx.Exec(ctx, notifyAllSql, BalanceChangesChannel, notifications)
+
+	return err
+}
@@ -0,0 +1,106 @@
+package postgresdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+// lagCheckInterval is how long a measured replication lag is trusted.
+const lagCheckInterval = time.Second
+
+// replica is a read-only standby serving the reads that tolerate some
+// staleness, as long as it keeps up with the primary.
+type replica struct {
+	db     *sqlx.DB
+	maxLag time.Duration
+	lag    func(ctx context.Context) (time.Duration, error)
+
+	mu       sync.Mutex
+	checked  time.Time
+	checking bool
+	usable   bool
+	now      func() time.Time
+}
+
+func newReplica(db *sqlx.DB, maxLag time.Duration) *replica {
+	r := &replica{db: db, maxLag: maxLag, usable: true, now: time.Now}
+	r.lag = r.replicationLag
+
+	return r
+}
+
+// replicationLag returns how far the replica is behind the primary. A
+// replica that replayed everything it received is not behind, even when the
+// primary was idle since its last transaction.
+func (r *replica) replicationLag(ctx context.Context) (time.Duration, error) {
+	var seconds float64
+	if err := r.db.GetContext(ctx, &seconds, getReplicationLagSql); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ready reports whether the replica may serve reads. The lag is measured at
+// most once per lagCheckInterval, by the first read to find it outdated. The
+// reads meanwhile get the last known state instead of waiting for it.
+func (r *replica) ready() bool {
+	r.mu.Lock()
+	usable := r.usable
+	now := r.now()
+	outdated := !r.checking && now.Sub(r.checked) >= lagCheckInterval
+	if outdated {
+		r.checking = true
+		r.checked = now
+	}
+	r.mu.Unlock()
+
+	if !outdated {
+		return usable
+	}
+
+	return r.check()
+}
+
+// check measures the lag and records whether the replica is usable. The
+// measurement outlives the read that started it, whose cancellation says
+// nothing of the replica.
+func (r *replica) check() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), lagCheckInterval)
+	defer cancel()
+
+	lag, err := r.lag(ctx)
+	usable := err == nil && lag <= r.maxLag
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if usable != r.usable {
+		switch {
+		case err != nil:
+			logrus.Warnf("reading from the primary, the replica is unavailable: %s", err)
+		case !usable:
+			logrus.Warnf("reading from the primary, the replica lags %s behind", lag.Round(time.Millisecond))
+		default:
+			logrus.Info("reading from the replica again")
+		}
+	}
+	r.usable = usable
+	r.checking = false
+
+	return usable
+}
+
+// reader returns the database serving the reads of ctx: the replica when it
+// is configured and keeps up, the primary otherwise.
+func (rep *BalanceRepository) reader(ctx context.Context) *sqlx.DB {
+	if rep.replica != nil && rep.replica.ready() {
+		return rep.replica.db
+	}
+
+	return rep.db
+}
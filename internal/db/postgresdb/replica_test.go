@@ -0,0 +1,75 @@
+package postgresdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderFallsBackToThePrimary(t *testing.T) {
+	primary, standby := &sqlx.DB{}, &sqlx.DB{}
+
+	now := time.Unix(1700000000, 0)
+	lag, checks := time.Duration(0), 0
+	var lagErr error
+
+	rep := ConnectSqlRepository(primary, WithReplica(standby, time.Second))
+	rep.replica.now = func() time.Time { return now }
+	rep.replica.lag = func(context.Context) (time.Duration, error) {
+		checks++
+		return lag, lagErr
+	}
+
+	ctx := context.Background()
+	require.Same(t, standby, rep.reader(ctx))
+
+	// the lag is measured once per interval
+	lag = time.Minute
+	require.Same(t, standby, rep.reader(ctx))
+	require.Equal(t, 1, checks)
+
+	now = now.Add(lagCheckInterval)
+	require.Same(t, primary, rep.reader(ctx))
+
+	now = now.Add(lagCheckInterval)
+	lag, lagErr = 0, fmt.Errorf("connection refused")
+	require.Same(t, primary, rep.reader(ctx))
+
+	now = now.Add(lagCheckInterval)
+	lag, lagErr = time.Second, nil
+	require.Same(t, standby, rep.reader(ctx))
+	require.Equal(t, 4, checks)
+
+	require.Same(t, primary, ConnectSqlRepository(primary).reader(ctx))
+}
+
+func TestReaderServesLastStateDuringCheck(t *testing.T) {
+	primary, standby := &sqlx.DB{}, &sqlx.DB{}
+
+	now := time.Unix(1700000000, 0)
+	measuring, release := make(chan struct{}), make(chan struct{})
+
+	rep := ConnectSqlRepository(primary, WithReplica(standby, time.Second))
+	rep.replica.now = func() time.Time { return now }
+	rep.replica.lag = func(context.Context) (time.Duration, error) {
+		close(measuring)
+		<-release
+		return time.Minute, nil
+	}
+
+	done := make(chan *sqlx.DB)
+	go func() { done <- rep.reader(context.Background()) }()
+
+	<-measuring
+
+	// another read neither waits for the measurement nor starts one
+	require.Same(t, standby, rep.reader(context.Background()))
+
+	close(release)
+	require.Same(t, primary, <-done)
+	require.Same(t, primary, rep.reader(context.Background()))
+}
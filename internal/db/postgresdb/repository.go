@@ -1,13 +1,38 @@
 package postgresdb
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+)
+
+const undefinedTableCode = "42P01"
 
 type BalanceRepository struct {
-	db *sqlx.DB
+	db      *sqlx.DB
+	replica *replica
 }
 
-func NewSqlRepository(db *sqlx.DB) (*BalanceRepository, error) {
-	rep := &BalanceRepository{db}
+type Option func(*BalanceRepository)
+
+// WithReplica serves the balances, the transaction history and the reports
+// from the read-only replica db while it lags at most maxLag behind the
+// primary, and from the primary otherwise. Everything else, including the
+// reads made within money transactions, stays on the primary.
+func WithReplica(db *sqlx.DB, maxLag time.Duration) Option {
+	return func(rep *BalanceRepository) {
+		rep.replica = newReplica(db, maxLag)
+	}
+}
+
+func NewSqlRepository(db *sqlx.DB, opts ...Option) (*BalanceRepository, error) {
+	rep := ConnectSqlRepository(db, opts...)
 
 	if err := rep.init(); err != nil {
 		return nil, err
@@ -16,6 +41,18 @@ func NewSqlRepository(db *sqlx.DB) (*BalanceRepository, error) {
 	return rep, nil
 }
 
+// ConnectSqlRepository returns a repository over an already initialized
+// schema. Unlike NewSqlRepository it leaves the tables untouched, so commands
+// can run next to the service.
+func ConnectSqlRepository(db *sqlx.DB, opts ...Option) *BalanceRepository {
+	rep := &BalanceRepository{db: db}
+	for _, opt := range opts {
+		opt(rep)
+	}
+
+	return rep
+}
+
 func (rep *BalanceRepository) init() error {
 	_, err := rep.db.Exec(initSchema)
 
@@ -23,7 +60,27 @@ func (rep *BalanceRepository) init() error {
 		return err
 	}
 
-	return nil
+	_, err = rep.db.Exec(setSchemaVersionSql, backend.SchemaVersion)
+
+	return err
+}
+
+// CheckSchema returns backend.ErrorSchemaOutdated when the schema of the
+// database is not the one the repository is built for.
+func (rep *BalanceRepository) CheckSchema(ctx context.Context) error {
+	var version int
+	err := rep.db.GetContext(ctx, &version, getSchemaVersionSql)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == undefinedTableCode {
+		return fmt.Errorf("%w: no schema version", backend.ErrorSchemaOutdated)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) || err == nil && version != backend.SchemaVersion {
+		return fmt.Errorf("%w: version %d, expected %d", backend.ErrorSchemaOutdated, version, backend.SchemaVersion)
+	}
+
+	return err
 }
 
 func (rep *BalanceRepository) Close() {
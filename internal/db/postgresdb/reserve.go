@@ -1,108 +1,154 @@
 package postgresdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
 	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
 	"strings"
 	"time"
 )
 
-var ErrorReserveNotFound = fmt.Errorf("reserve not found")
-var ErrorReserveAlreadyRecognized = fmt.Errorf("reserve already recognized")
-var ErrorReserveAlreadyDeReserved = fmt.Errorf("reserve already de-reserved")
-
-const (
-	statusReserveMoney    = "reserved"
-	statusDeReservedMoney = "de-reserved"
-	statusRecognizedMoney = "recognized"
-)
-
-func (rep *BalanceRepository) addReserve(userId, serviceId, orderId, status string, amount float64, tx *sql.Tx) error {
-	_, err := tx.Exec(addReserveSql, userId, serviceId, orderId, amount, status, time.Now())
+func (rep *BalanceRepository) addReserve(ctx context.Context, userId, serviceId, orderId, status string, amount float64, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, addReserveSql, userId, serviceId, orderId, amount, status, auth.Actor(ctx), time.Now())
 
 	return err
 }
 
-func (rep *BalanceRepository) ReserveMoney(userId, serviceId, orderId string, amount float64) error {
-	tx, err := rep.db.Begin()
+func (rep *BalanceRepository) ReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	tx, err := rep.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	defer rollback(tx)
 
-	var user models.User
+	before, after, err := rep.reserveMoney(ctx, userId, serviceId, orderId, amount, tx)
+	if err != nil {
+		return err
+	}
 
-	if amount < 0 {
-		return ErrorNegativeAmount
+	if err = auditChanges(ctx, tx, audit.Change{AccountId: userId, Before: before, After: after}); err != nil {
+		return err
 	}
 
-	if err := tx.QueryRow(getUserSql, userId).Scan(&user.Id, &user.Balance); err != nil {
-		if err == sql.ErrNoRows {
-			return ErrorUserNotFound
-		}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
 
-		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
-			return ErrorInvalidInput
-		}
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationReserve, amount)
 
-		return err
+	return nil
+}
+
+// reserveMoney moves amount from the balance to a new reserve within tx and
+// returns the balance before and after.
+func (rep *BalanceRepository) reserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64, tx *sql.Tx) (float64, float64, error) {
+	if amount < 0 {
+		return 0, 0, api.ErrorNegativeAmount
 	}
 
-	if user.Balance < amount {
-		return ErrorNotEnoughMoney
+	user, err := rep.lockAccount(ctx, userId, tx)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	var empty interface{}
-	if err = tx.QueryRow(updateUserBalanceSql, userId, -amount).Scan(&empty, &empty); err != nil {
-		return err
+	if err = backend.CanDebit(user); err != nil {
+		return 0, 0, err
 	}
 
-	if err = rep.addReserve(userId, serviceId, orderId, statusReserveMoney, amount, tx); err != nil {
-		return err
+	if user.Balance+user.CreditLimit < amount {
+		metrics.RecordInsufficientFunds()
+		return 0, 0, api.ErrorNotEnoughMoney
 	}
 
-	if err = rep.addTransaction(nil, &userId, operationReserveMoney, amount, tx); err != nil {
-		return err
+	if err = rep.checkDebitLimits(ctx, userId, amount, false, tx); err != nil {
+		return 0, 0, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
+	var empty interface{}
+	var balance float64
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, userId, -amount).Scan(&empty, &balance); err != nil {
+		return 0, 0, err
 	}
 
-	return nil
-}
+	if err = rep.addReserve(ctx, userId, serviceId, orderId, models.ReserveReserved, amount, tx); err != nil {
+		return 0, 0, err
+	}
+
+	if err = rep.addTransaction(ctx, nil, &userId, models.OperationReserveMoney, amount, tx); err != nil {
+		return 0, 0, err
+	}
 
-func (rep *BalanceRepository) RecognizedMoney(userId, serviceId, orderId string, amount float64) error {
-	tx, err := rep.db.Begin()
+	err = rep.addEvent(ctx, models.EventReserveCreated, userId,
+		models.AccountEventData{Amount: amount, Balance: &balance, ServiceId: serviceId, OrderId: orderId}, tx)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
-	defer tx.Rollback()
 
+	return user.Balance, balance, nil
+}
+
+// openReserve locks the reserve of the order within tx and returns it while
+// it still holds money, or why it does not.
+func (rep *BalanceRepository) openReserve(ctx context.Context, userId, serviceId, orderId string, amount float64, tx *sql.Tx) (*models.Reserve, error) {
 	var reserve models.Reserve
 
-	if err := rep.db.Get(&reserve, getReserveSql, userId, serviceId, orderId, amount); err != nil {
+	err := tx.QueryRowContext(ctx, getReserveForUpdateSql, userId, serviceId, orderId, amount).Scan(&reserve.Id,
+		&reserve.UserId, &reserve.ServiceId, &reserve.OrderId, &reserve.Amount, &reserve.Status, &reserve.Actor,
+		&reserve.CreatedAt, &reserve.RecognizedAt)
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return ErrorReserveNotFound
+			return nil, api.ErrorReserveNotFound
 		}
 
 		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
-			return ErrorInvalidInput
+			return nil, api.ErrorInvalidInput
 		}
 
-		return fmt.Errorf("error when get reserve: %w", err)
+		return nil, fmt.Errorf("error when get reserve: %w", err)
 	}
 
-	if reserve.Status != statusReserveMoney {
-		if reserve.Status == statusRecognizedMoney {
-			return ErrorReserveAlreadyRecognized
+	if reserve.Status != models.ReserveReserved {
+		if reserve.Status == models.ReserveRecognized {
+			return nil, api.ErrorReserveAlreadyRecognized
 		}
 
-		return ErrorReserveAlreadyDeReserved
+		return nil, api.ErrorReserveAlreadyDeReserved
+	}
+
+	return &reserve, nil
+}
+
+func (rep *BalanceRepository) RecognizedMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	reserve, err := rep.openReserve(ctx, userId, serviceId, orderId, amount, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRowContext(ctx, updateReserveStatus, reserve.Id, models.ReserveRecognized, time.Now()).Err(); err != nil {
+		return err
+	}
+
+	err = rep.addEvent(ctx, models.EventReserveRecognized, userId,
+		models.AccountEventData{Amount: reserve.Amount, ServiceId: serviceId, OrderId: orderId}, tx)
+	if err != nil {
+		return err
 	}
 
-	if err = tx.QueryRow(updateReserveStatus, reserve.Id, statusRecognizedMoney, time.Now()).Err(); err != nil {
+	// recognizing leaves the balance as it is
+	if err = auditChanges(ctx, tx); err != nil {
 		return err
 	}
 
@@ -110,52 +156,58 @@ func (rep *BalanceRepository) RecognizedMoney(userId, serviceId, orderId string,
 		return err
 	}
 
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationRecognize, reserve.Amount)
+
 	return nil
 }
 
-func (rep *BalanceRepository) DeReserveMoney(userId, serviceId, orderId string, amount float64) error {
-	tx, err := rep.db.Begin()
+func (rep *BalanceRepository) DeReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	tx, err := rep.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	var reserve models.Reserve
+	defer rollback(tx)
 
-	if err := rep.db.Get(&reserve, getReserveSql, userId, serviceId, orderId, amount); err != nil {
-		if err == sql.ErrNoRows {
-			return ErrorReserveNotFound
-		}
-
-		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
-			return ErrorInvalidInput
-		}
-
-		return fmt.Errorf("error when get reserve: %w", err)
+	reserve, err := rep.openReserve(ctx, userId, serviceId, orderId, amount, tx)
+	if err != nil {
+		return err
 	}
 
-	if reserve.Status != statusReserveMoney {
-		if reserve.Status == statusRecognizedMoney {
-			return ErrorReserveAlreadyRecognized
-		}
+	account, err := rep.lockAccount(ctx, userId, tx)
+	if err != nil {
+		return err
+	}
 
-		return ErrorReserveAlreadyDeReserved
+	if err = backend.CanCredit(account); err != nil {
+		return err
 	}
 
 	var empty interface{}
-	if err = tx.QueryRow(updateUserBalanceSql, userId, reserve.Amount).Scan(&empty, &empty); err != nil {
+	var balance float64
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, userId, reserve.Amount).Scan(&empty, &balance); err != nil {
 		if err == sql.ErrNoRows {
-			return ErrorUserNotFound
+			return api.ErrorUserNotFound
 		}
 
 		return err
 	}
 
-	if err = rep.addTransaction(&userId, nil, operationReturnReserveMoney, amount, tx); err != nil {
+	if err = rep.addTransaction(ctx, &userId, nil, models.OperationReturnReserveMoney, amount, tx); err != nil {
 		return err
 	}
 
-	if err = tx.QueryRow(updateReserveStatus, reserve.Id, statusDeReservedMoney, nil).Err(); err != nil {
+	if err = tx.QueryRowContext(ctx, updateReserveStatus, reserve.Id, models.ReserveDeReserved, nil).Err(); err != nil {
+		return err
+	}
+
+	err = rep.addEvent(ctx, models.EventReserveReleased, userId,
+		models.AccountEventData{Amount: reserve.Amount, Balance: &balance, ServiceId: serviceId, OrderId: orderId}, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = auditChanges(ctx, tx, audit.Change{AccountId: userId, Before: balance - reserve.Amount, After: balance}); err != nil {
 		return err
 	}
 
@@ -163,13 +215,16 @@ func (rep *BalanceRepository) DeReserveMoney(userId, serviceId, orderId string,
 		return err
 	}
 
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationDeReserve, reserve.Amount)
+
 	return nil
 }
 
-func (rep *BalanceRepository) GetReserves(year, month int) (*[]models.Reserve, error) {
+func (rep *BalanceRepository) GetReserves(ctx context.Context, year, month int) (*[]models.Reserve, error) {
 	reserves := []models.Reserve{}
 
-	err := rep.db.Select(&reserves, getReserveForReportSql, statusRecognizedMoney, year, month)
+	err := rep.reader(ctx).SelectContext(ctx, &reserves, getReserveForReportSql, models.ReserveRecognized, year, month)
 	if err != nil {
 		return nil, err
 	}
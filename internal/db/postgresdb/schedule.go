@@ -0,0 +1,246 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"strings"
+	"time"
+)
+
+func scheduleError(err error) error {
+	if err == sql.ErrNoRows {
+		return api.ErrorScheduleNotFound
+	}
+
+	if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
+		return api.ErrorInvalidInput
+	}
+
+	return err
+}
+
+func (rep *BalanceRepository) CreateSchedule(ctx context.Context, query models.CreateScheduleQuery) (*models.Schedule, error) {
+	schedule, err := backend.NewSchedule(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = rep.db.GetContext(ctx, schedule, addScheduleSql, query.FromId, query.ToId, query.Money, schedule.Cron,
+		schedule.IntervalSeconds, schedule.NextRunAt, models.ScheduleActive, schedule.MaxRetries, auth.Actor(ctx))
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return nil, api.ErrorUserNotFound
+		}
+
+		return nil, scheduleError(err)
+	}
+
+	return schedule, nil
+}
+
+func (rep *BalanceRepository) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	var schedule models.Schedule
+
+	if err := rep.db.GetContext(ctx, &schedule, getScheduleSql, id, backend.ScheduleOwner(ctx)); err != nil {
+		return nil, scheduleError(err)
+	}
+
+	return &schedule, nil
+}
+
+func (rep *BalanceRepository) GetScheduleRuns(ctx context.Context, id string) (*[]models.ScheduleRun, error) {
+	if _, err := rep.GetSchedule(ctx, id); err != nil {
+		return nil, err
+	}
+
+	runs := []models.ScheduleRun{}
+
+	if err := rep.db.SelectContext(ctx, &runs, getScheduleRunsSql, id); err != nil {
+		return nil, err
+	}
+
+	return &runs, nil
+}
+
+func (rep *BalanceRepository) updateSchedule(ctx context.Context, id string, update func(*models.Schedule) error) (*models.Schedule, error) {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var schedule models.Schedule
+
+	if err = tx.GetContext(ctx, &schedule, getScheduleForUpdateSql, id, backend.ScheduleOwner(ctx)); err != nil {
+		return nil, scheduleError(err)
+	}
+
+	if err = update(&schedule); err != nil {
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &schedule, updateScheduleSql, id, schedule.Status, schedule.NextRunAt, schedule.SlotAt, schedule.Attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+func (rep *BalanceRepository) PauseSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return rep.updateSchedule(ctx, id, func(schedule *models.Schedule) error {
+		if schedule.Status != models.ScheduleActive {
+			return api.ErrorScheduleState
+		}
+
+		schedule.Status = models.SchedulePaused
+
+		return nil
+	})
+}
+
+// ResumeSchedule activates a paused schedule. Recurring schedules skip the
+// runs missed while paused, a one-off transfer that is overdue runs at once.
+func (rep *BalanceRepository) ResumeSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return rep.updateSchedule(ctx, id, func(schedule *models.Schedule) error {
+		if schedule.Status != models.SchedulePaused {
+			return api.ErrorScheduleState
+		}
+
+		schedule.Status = models.ScheduleActive
+
+		if backend.Recurring(schedule) && schedule.NextRunAt != nil && schedule.NextRunAt.Before(time.Now()) {
+			next, err := backend.NextRun(schedule, time.Now())
+			if err != nil {
+				return err
+			}
+
+			schedule.NextRunAt = next
+			schedule.SlotAt = next
+			schedule.Attempt = 0
+		}
+
+		return nil
+	})
+}
+
+func (rep *BalanceRepository) CancelSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return rep.updateSchedule(ctx, id, func(schedule *models.Schedule) error {
+		if schedule.Status != models.ScheduleActive && schedule.Status != models.SchedulePaused {
+			return api.ErrorScheduleState
+		}
+
+		schedule.Status = models.ScheduleCancelled
+		schedule.NextRunAt = nil
+		schedule.SlotAt = nil
+
+		return nil
+	})
+}
+
+// ExecuteDueSchedules runs up to limit schedules that are due and returns how
+// many of them were executed. Every schedule runs in its own transaction and
+// is claimed with SKIP LOCKED, so several instances can share the work.
+func (rep *BalanceRepository) ExecuteDueSchedules(ctx context.Context, limit int) (int, error) {
+	executed := 0
+
+	for executed < limit {
+		found, err := rep.executeDueSchedule(ctx)
+		if err != nil {
+			return executed, err
+		}
+
+		if !found {
+			break
+		}
+
+		executed++
+	}
+
+	return executed, nil
+}
+
+func (rep *BalanceRepository) executeDueSchedule(ctx context.Context) (bool, error) {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var schedule models.Schedule
+
+	if err = tx.GetContext(ctx, &schedule, getDueScheduleSql, models.ScheduleActive); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	scheduledAt := *schedule.NextRunAt
+
+	// the transfer runs on behalf of whoever created the schedule
+	actorCtx := auth.WithIdentity(ctx, &auth.Identity{Subject: schedule.Actor})
+	actorCtx = audit.WithSource(actorCtx, audit.ScheduleSource(schedule.Id))
+
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT schedule_transfer"); err != nil {
+		return false, err
+	}
+
+	fromBalance, toBalance, transferErr := rep.transfer(actorCtx, schedule.FromId, schedule.ToId, schedule.Money, tx.Tx)
+	if transferErr != nil {
+		if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT schedule_transfer"); err != nil {
+			return false, err
+		}
+	} else {
+		err = auditChanges(actorCtx, tx, backend.TransferChanges(schedule.FromId, schedule.ToId, schedule.Money, fromBalance, toBalance)...)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	now := time.Now()
+	attempt := schedule.Attempt + 1
+	outcome := models.ScheduleRunSuccess
+	var runError *string
+
+	if transferErr != nil {
+		outcome = models.ScheduleRunFailed
+		message := transferErr.Error()
+		runError = &message
+	}
+
+	_, err = tx.ExecContext(ctx, addScheduleRunSql, schedule.Id, scheduledAt, now, attempt, outcome, runError)
+	if err != nil {
+		return false, err
+	}
+
+	if err = backend.AdvanceSchedule(&schedule, transferErr, now); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, updateScheduleSql, schedule.Id, schedule.Status, schedule.NextRunAt, schedule.SlotAt, schedule.Attempt)
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if transferErr == nil {
+		metrics.RecordOperation(metrics.OperationTransfer, schedule.Money)
+	}
+
+	return true, nil
+}
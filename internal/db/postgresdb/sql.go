@@ -1,14 +1,33 @@
 package postgresdb
 
 const initSchema = `
+				DROP TABLE IF EXISTS idempotency_keys;
+				DROP TABLE IF EXISTS schedule_runs;
+				DROP TABLE IF EXISTS schedules;
 				DROP TABLE IF EXISTS reserves;
+				DROP TABLE IF EXISTS webhook_deliveries;
+				DROP TABLE IF EXISTS outbox;
 				DROP TABLE IF EXISTS transactions;
 				DROP TABLE IF EXISTS users;
+				DROP TABLE IF EXISTS schema_version;
 				
+				CREATE TABLE IF NOT EXISTS schema_version
+				(
+					version INT NOT NULL
+				);
 				CREATE TABLE IF NOT EXISTS users 
 				(
-					id  	UUID PRIMARY KEY,
-					balance DECIMAL(10, 2) DEFAULT 0 CHECK (balance >= 0)
+					id              UUID PRIMARY KEY,
+					balance         DECIMAL(10, 2) DEFAULT 0,
+					credit_limit    DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (credit_limit >= 0),
+					owner           TEXT NOT NULL DEFAULT '',
+					metadata        JSONB NOT NULL DEFAULT '{}',
+					status          TEXT NOT NULL DEFAULT 'active',
+					debits_blocked  BOOLEAN NOT NULL DEFAULT false,
+					credits_blocked BOOLEAN NOT NULL DEFAULT false,
+					created_at      TIMESTAMP DEFAULT now(),
+					closed_at       TIMESTAMP DEFAULT NULL,
+					CONSTRAINT users_balance_check CHECK (balance >= -credit_limit)
 				);
 				CREATE TABLE IF NOT EXISTS transactions
 				(
@@ -17,6 +36,10 @@ const initSchema = `
 					from_id    UUID REFERENCES users(id),
 					money      DECIMAL(10, 2) NOT NULL,
 					operation  TEXT NOT NULL,
+					actor      TEXT NOT NULL,
+					import_id  UUID DEFAULT NULL,
+					comment    TEXT DEFAULT NULL,
+					request_id TEXT DEFAULT NULL,
 					created_at TIMESTAMP DEFAULT now()
 				);
 				CREATE TABLE IF NOT EXISTS reserves
@@ -27,35 +50,173 @@ const initSchema = `
 					order_id      TEXT NOT NULL,
 					amount        DECIMAL(10, 2) NOT NULL,
 					status        TEXT NOT NULL,
+					actor         TEXT NOT NULL,
 					created_at    TIMESTAMP DEFAULT now(),
 					recognized_at TIMESTAMP DEFAULT NULL
 				);
+				CREATE TABLE IF NOT EXISTS schedules
+				(
+					id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					from_id          UUID NOT NULL REFERENCES users(id),
+					to_id            UUID NOT NULL REFERENCES users(id),
+					money            DECIMAL(10, 2) NOT NULL,
+					cron             TEXT DEFAULT NULL,
+					interval_seconds INT DEFAULT NULL,
+					next_run_at      TIMESTAMPTZ DEFAULT NULL,
+					slot_at          TIMESTAMPTZ DEFAULT NULL,
+					status           TEXT NOT NULL,
+					max_retries      INT NOT NULL,
+					attempt          INT NOT NULL DEFAULT 0,
+					actor            TEXT NOT NULL,
+					created_at       TIMESTAMP DEFAULT now()
+				);
+				CREATE TABLE IF NOT EXISTS schedule_runs
+				(
+					id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					schedule_id  UUID NOT NULL REFERENCES schedules(id),
+					scheduled_at TIMESTAMPTZ NOT NULL,
+					executed_at  TIMESTAMPTZ NOT NULL,
+					attempt      INT NOT NULL,
+					outcome      TEXT NOT NULL,
+					error        TEXT DEFAULT NULL
+				);
+				CREATE INDEX ON schedules (status, next_run_at);
+				CREATE INDEX ON schedule_runs (schedule_id);
+				CREATE INDEX ON reserves (user_id, status);
+				CREATE TABLE IF NOT EXISTS outbox
+				(
+					id           BIGSERIAL PRIMARY KEY,
+					event_id     UUID NOT NULL,
+					type         TEXT NOT NULL,
+					version      INT NOT NULL,
+					account_id   UUID NOT NULL,
+					data         JSONB NOT NULL,
+					created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+					published_at TIMESTAMPTZ DEFAULT NULL
+				);
+				CREATE INDEX ON outbox (id) WHERE published_at IS NULL;
+				CREATE TABLE IF NOT EXISTS webhook_subscriptions
+				(
+					id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					url         TEXT NOT NULL,
+					event_types JSONB NOT NULL,
+					secret      TEXT NOT NULL,
+					active      BOOLEAN NOT NULL DEFAULT true,
+					created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+				);
+				CREATE TABLE IF NOT EXISTS webhook_deliveries
+				(
+					id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					subscription_id  UUID NOT NULL REFERENCES webhook_subscriptions(id),
+					outbox_id        BIGINT NOT NULL REFERENCES outbox(id),
+					event_id         UUID NOT NULL,
+					event_type       TEXT NOT NULL,
+					status           TEXT NOT NULL,
+					attempt          INT NOT NULL DEFAULT 0,
+					next_attempt_at  TIMESTAMPTZ DEFAULT NULL,
+					last_status_code INT DEFAULT NULL,
+					last_error       TEXT DEFAULT NULL,
+					created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+					delivered_at     TIMESTAMPTZ DEFAULT NULL
+				);
+				CREATE INDEX ON webhook_deliveries (status, next_attempt_at);
+				CREATE INDEX ON webhook_deliveries (subscription_id, created_at);
 				CREATE INDEX ON transactions (to_id);
 				CREATE INDEX ON transactions (from_id);
+				CREATE INDEX ON transactions (import_id) WHERE import_id IS NOT NULL;
+				CREATE TABLE IF NOT EXISTS idempotency_keys
+				(
+					actor       TEXT NOT NULL,
+					key         TEXT NOT NULL,
+					fingerprint TEXT NOT NULL,
+					status      INT DEFAULT NULL,
+					header      JSONB DEFAULT NULL,
+					body        BYTEA DEFAULT NULL,
+					expires_at  TIMESTAMPTZ NOT NULL,
+					PRIMARY KEY (actor, key)
+				);
+				CREATE TABLE IF NOT EXISTS limits
+				(
+					scope            TEXT PRIMARY KEY,
+					max_operation    DECIMAL(10, 2) DEFAULT NULL,
+					daily_debit      DECIMAL(10, 2) DEFAULT NULL,
+					monthly_debit    DECIMAL(10, 2) DEFAULT NULL,
+					hourly_transfers INT DEFAULT NULL
+				);
+
+				CREATE TABLE IF NOT EXISTS audit_log
+				(
+					id              BIGSERIAL PRIMARY KEY,
+					actor           TEXT NOT NULL,
+					endpoint        TEXT NOT NULL,
+					payload_hash    TEXT NOT NULL,
+					balances_before TEXT NOT NULL,
+					balances_after  TEXT NOT NULL,
+					status          INT NOT NULL,
+					outcome         TEXT NOT NULL,
+					created_at      TIMESTAMPTZ NOT NULL,
+					prev_hash       TEXT NOT NULL,
+					hash            TEXT NOT NULL
+				);
+				CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+				BEGIN
+					RAISE EXCEPTION 'audit_log is append-only';
+				END;
+				$$ LANGUAGE plpgsql;
+				DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+				CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+					FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
 `
 
 const addUserSql = `
-				INSERT INTO users VALUES ($1, 0);
+				INSERT INTO users (id, balance) VALUES ($1, 0);
 `
 
 const updateUserBalanceSql = `
 				UPDATE users SET balance=balance + $2
 				WHERE id=$1
-				RETURNING *;
+				RETURNING id, balance;
 `
 
 const getUserSql = `
-				SELECT id, balance FROM users
+				SELECT id, balance, credit_limit, balance + credit_limit AS available, held, balance + held AS total
+				FROM users, LATERAL (
+					SELECT COALESCE(SUM(amount), 0) AS held FROM reserves
+					WHERE user_id=users.id and status=$2
+				) AS reserved
 				WHERE id=$1;
 `
 
+const getOpenReservesSql = `
+				SELECT id, user_id, service_id, order_id, amount, status, actor, created_at, recognized_at FROM reserves
+				WHERE user_id=$1 and status=$2
+				ORDER BY created_at ASC;
+`
+
+const setSchemaVersionSql = `
+				INSERT INTO schema_version (version) VALUES ($1);
+`
+
+const getSchemaVersionSql = `
+				SELECT version FROM schema_version;
+`
+
+// getReplicationLagSql returns zero on a primary and on a replica that
+// replayed everything it received.
+const getReplicationLagSql = `
+				SELECT COALESCE(CASE
+					WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+					ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+				END, 0);
+`
+
 const addTransactionsSql = `
-				INSERT INTO transactions (to_id, from_id, money, operation, created_at)
-				VALUES ($1, $2, $3, $4, $5);
+				INSERT INTO transactions (to_id, from_id, money, operation, actor, import_id, comment, request_id, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
 `
 
 const getAllTransactionsSql = `
-				SELECT id, to_id, from_id, money, operation, created_at FROM transactions
+				SELECT id, to_id, from_id, money, operation, actor, import_id, comment, request_id, created_at FROM transactions
 				WHERE to_id=$1 OR from_id=$1
 				%s
 				LIMIT $2
@@ -63,17 +224,20 @@ const getAllTransactionsSql = `
 `
 
 const addReserveSql = `
-				INSERT INTO reserves (user_id, service_id, order_id, amount, status, created_at)
-				VALUES ($1, $2, $3, $4, $5, $6);
+				INSERT INTO reserves (user_id, service_id, order_id, amount, status, actor, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7);
 `
 
-const getReserveSql = `
-				SELECT id, user_id, service_id, order_id, amount, status, created_at, recognized_at FROM reserves
-				WHERE user_id=$1 and service_id=$2 and order_id=$3 and amount=$4;
+// getReserveForUpdateSql locks the reserve until the transaction ends, so
+// that a concurrent recognize or de-reserve waits and then sees its status.
+const getReserveForUpdateSql = `
+				SELECT id, user_id, service_id, order_id, amount, status, actor, created_at, recognized_at FROM reserves
+				WHERE user_id=$1 and service_id=$2 and order_id=$3 and amount=$4
+				FOR UPDATE;
 `
 
 const getReserveForReportSql = `
-				SELECT id, user_id, service_id, order_id, amount, status, created_at, recognized_at FROM reserves
+				SELECT id, user_id, service_id, order_id, amount, status, actor, created_at, recognized_at FROM reserves
 				WHERE status=$1 and date_part('year', recognized_at)=$2 and date_part('month', recognized_at)=$3;
 `
 
@@ -81,3 +245,297 @@ const updateReserveStatus = `
 				UPDATE reserves SET status=$2, recognized_at=$3
 				WHERE id=$1;
 `
+
+const lockAuditLogSql = `
+				SELECT pg_advisory_xact_lock($1);
+`
+
+const getLastAuditHashSql = `
+				SELECT hash FROM audit_log
+				ORDER BY id DESC
+				LIMIT 1;
+`
+
+const addAuditEntrySql = `
+				INSERT INTO audit_log (actor, endpoint, payload_hash, balances_before, balances_after, status, outcome, created_at, prev_hash, hash)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				RETURNING id;
+`
+
+const getAuditEntriesSql = `
+				SELECT id, actor, endpoint, payload_hash, balances_before, balances_after, status, outcome, created_at, prev_hash, hash FROM audit_log
+				WHERE id > $1
+				ORDER BY id ASC
+				LIMIT $2;
+`
+
+const addAccountSql = `
+				INSERT INTO users (id, balance, owner, metadata)
+				VALUES ($1, 0, $2, $3)
+				RETURNING id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at;
+`
+
+const getAccountSql = `
+				SELECT id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at FROM users
+				WHERE id=$1;
+`
+
+const getAccountForUpdateSql = `
+				SELECT id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at FROM users
+				WHERE id=$1
+				FOR UPDATE;
+`
+
+const updateAccountStatusSql = `
+				UPDATE users SET status=$2, debits_blocked=$3, credits_blocked=$4, closed_at=$5
+				WHERE id=$1
+				RETURNING id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at;
+`
+
+const countUserReservesSql = `
+				SELECT count(*) FROM reserves
+				WHERE user_id=$1 and status=$2;
+`
+
+const getLimitsSql = `
+				SELECT scope, max_operation, daily_debit, monthly_debit, hourly_transfers FROM limits
+				WHERE scope=$1;
+`
+
+const getEffectiveLimitsSql = `
+				SELECT scope, max_operation, daily_debit, monthly_debit, hourly_transfers FROM limits
+				WHERE scope=$1 OR scope=$2;
+`
+
+const setLimitsSql = `
+				INSERT INTO limits (scope, max_operation, daily_debit, monthly_debit, hourly_transfers)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (scope) DO UPDATE SET max_operation=$2, daily_debit=$3, monthly_debit=$4, hourly_transfers=$5
+				RETURNING scope, max_operation, daily_debit, monthly_debit, hourly_transfers;
+`
+
+const deleteLimitsSql = `
+				DELETE FROM limits
+				WHERE scope=$1;
+`
+
+// getDebitTotalSql counts the reserves made since $5 unless they were
+// released, so that a cancelled order does not use up the limits.
+const getDebitTotalSql = `
+				SELECT COALESCE((SELECT SUM(ABS(money)) FROM transactions
+					WHERE from_id=$1 and operation IN ($2, $3) and created_at >= $5), 0)
+				+ COALESCE((SELECT SUM(amount) FROM reserves
+					WHERE user_id=$1 and status <> $4 and created_at >= $5), 0);
+`
+
+const getRecentTransfersSql = `
+				SELECT count(*), min(created_at) FROM transactions
+				WHERE from_id=$1 and operation=$2 and created_at > $3;
+`
+
+const updateCreditLimitSql = `
+				UPDATE users SET credit_limit=$2
+				WHERE id=$1
+				RETURNING id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at;
+`
+
+const getAccountsUsingCreditSql = `
+				SELECT id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at FROM users
+				WHERE balance < 0
+				ORDER BY balance ASC;
+`
+
+const scheduleColumns = `id, from_id, to_id, money, cron, interval_seconds, next_run_at, slot_at, status, max_retries, attempt, actor, created_at`
+
+const addScheduleSql = `
+				INSERT INTO schedules (from_id, to_id, money, cron, interval_seconds, next_run_at, slot_at, status, max_retries, actor)
+				VALUES ($1, $2, $3, $4, $5, $6, $6, $7, $8, $9)
+				RETURNING ` + scheduleColumns + `;
+`
+
+// getScheduleSql returns the schedule only to its creator $2, or to anyone
+// when $2 is empty.
+const getScheduleSql = `
+				SELECT ` + scheduleColumns + ` FROM schedules
+				WHERE id=$1 and ($2='' or actor=$2);
+`
+
+const getScheduleForUpdateSql = `
+				SELECT ` + scheduleColumns + ` FROM schedules
+				WHERE id=$1 and ($2='' or actor=$2)
+				FOR UPDATE;
+`
+
+const getDueScheduleSql = `
+				SELECT ` + scheduleColumns + ` FROM schedules
+				WHERE status=$1 and next_run_at <= now()
+				ORDER BY next_run_at ASC
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED;
+`
+
+const updateScheduleSql = `
+				UPDATE schedules SET status=$2, next_run_at=$3, slot_at=$4, attempt=$5
+				WHERE id=$1
+				RETURNING ` + scheduleColumns + `;
+`
+
+const addScheduleRunSql = `
+				INSERT INTO schedule_runs (schedule_id, scheduled_at, executed_at, attempt, outcome, error)
+				VALUES ($1, $2, $3, $4, $5, $6);
+`
+
+const getScheduleRunsSql = `
+				SELECT id, schedule_id, scheduled_at, executed_at, attempt, outcome, error FROM schedule_runs
+				WHERE schedule_id=$1
+				ORDER BY executed_at DESC;
+`
+
+const addEventSql = `
+				INSERT INTO outbox (event_id, type, version, account_id, data)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id;
+`
+
+const lockOutboxSql = `
+				SELECT pg_try_advisory_xact_lock($1);
+`
+
+const getUnpublishedEventsSql = `
+				SELECT id, event_id, type, version, account_id, data, created_at FROM outbox
+				WHERE published_at IS NULL
+				ORDER BY id ASC
+				LIMIT $1;
+`
+
+const markEventsPublishedSql = `
+				UPDATE outbox SET published_at=now()
+				WHERE id = ANY($1);
+`
+
+const webhookColumns = `id, url, event_types, active, created_at`
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, status, attempt, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+const addWebhookSql = `
+				INSERT INTO webhook_subscriptions (url, event_types, secret)
+				VALUES ($1, $2, $3)
+				RETURNING ` + webhookColumns + `;
+`
+
+const getWebhookSql = `
+				SELECT ` + webhookColumns + ` FROM webhook_subscriptions
+				WHERE id=$1;
+`
+
+const deactivateWebhookSql = `
+				UPDATE webhook_subscriptions SET active=false
+				WHERE id=$1
+				RETURNING ` + webhookColumns + `;
+`
+
+const addWebhookDeliveriesSql = `
+				INSERT INTO webhook_deliveries (subscription_id, outbox_id, event_id, event_type, status, next_attempt_at)
+				SELECT id, $1, $2, $3, $4, now() FROM webhook_subscriptions
+				WHERE active and (event_types @> jsonb_build_array($3::text) or event_types @> jsonb_build_array($5::text));
+`
+
+const getWebhookDeliveriesSql = `
+				SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+				WHERE subscription_id=$1 and ($2 = '' or status=$2)
+				ORDER BY created_at DESC
+				LIMIT $3;
+`
+
+const retryWebhookDeliverySql = `
+				UPDATE webhook_deliveries SET status=$3, attempt=0, next_attempt_at=now()
+				WHERE id=$1 and subscription_id=$2 and status=$4
+				RETURNING ` + webhookDeliveryColumns + `;
+`
+
+const getWebhookDeliveryStatusSql = `
+				SELECT status FROM webhook_deliveries
+				WHERE id=$1 and subscription_id=$2;
+`
+
+const claimWebhookDeliveriesSql = `
+				SELECT d.id, d.subscription_id, d.event_id, d.event_type, d.status, d.attempt, d.created_at,
+					s.url, s.secret, o.id, o.type, o.version, o.account_id, o.data::text, o.created_at
+				FROM webhook_deliveries d
+				JOIN webhook_subscriptions s ON s.id=d.subscription_id
+				JOIN outbox o ON o.id=d.outbox_id
+				WHERE d.status=$1 and d.next_attempt_at <= now() and s.active
+				ORDER BY d.next_attempt_at ASC
+				LIMIT $2
+				FOR UPDATE OF d SKIP LOCKED;
+`
+
+const leaseWebhookDeliveriesSql = `
+				UPDATE webhook_deliveries SET next_attempt_at=$2
+				WHERE id = ANY($1);
+`
+
+const finishWebhookDeliverySql = `
+				UPDATE webhook_deliveries
+				SET status=$2, attempt=$3, next_attempt_at=$4, last_status_code=$5, last_error=$6, delivered_at=$7
+				WHERE id=$1;
+`
+
+const notifySql = `
+				SELECT pg_notify($1, $2);
+`
+
+const startIdempotentRequestSql = `
+				INSERT INTO idempotency_keys (actor, key, fingerprint, expires_at)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (actor, key) DO UPDATE
+				SET fingerprint=EXCLUDED.fingerprint, status=NULL, header=NULL, body=NULL, expires_at=EXCLUDED.expires_at
+				WHERE idempotency_keys.expires_at < now()
+				RETURNING actor;
+`
+
+const getIdempotentRequestSql = `
+				SELECT fingerprint, status, header, body FROM idempotency_keys
+				WHERE actor=$1 and key=$2;
+`
+
+const completeIdempotentRequestSql = `
+				UPDATE idempotency_keys SET status=$3, header=$4, body=$5, expires_at=$6
+				WHERE actor=$1 and key=$2;
+`
+
+const releaseIdempotentRequestSql = `
+				DELETE FROM idempotency_keys
+				WHERE actor=$1 and key=$2 and status IS NULL;
+`
+
+const getAccountsForUpdateSql = `
+				SELECT id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at FROM users
+				WHERE id = ANY($1)
+				ORDER BY id
+				FOR UPDATE;
+`
+
+const addBalancesSql = `
+				UPDATE users SET balance=balance + changes.money
+				FROM unnest($1::uuid[], $2::numeric[]) AS changes(id, money)
+				WHERE users.id=changes.id;
+`
+
+const getEventSequencesSql = `
+				SELECT event_id, id FROM outbox
+				WHERE event_id = ANY($1);
+`
+
+const addEventsWebhookDeliveriesSql = `
+				INSERT INTO webhook_deliveries (subscription_id, outbox_id, event_id, event_type, status, next_attempt_at)
+				SELECT webhook_subscriptions.id, outbox.id, outbox.event_id, outbox.type, $2, now()
+				FROM outbox JOIN webhook_subscriptions ON webhook_subscriptions.active and (
+					webhook_subscriptions.event_types @> jsonb_build_array(outbox.type) or
+					webhook_subscriptions.event_types @> jsonb_build_array($3::text))
+				WHERE outbox.event_id = ANY($1);
+`
+
+const notifyAllSql = `
+				SELECT pg_notify($1, notification) FROM unnest($2::text[]) AS notification;
+`
@@ -1,56 +1,52 @@
 package postgresdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/logging"
 	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
 	"strings"
 	"time"
 )
 
-const (
-	sortDateAsc   = "date_asc"
-	sortDateDesc  = "date_desc"
-	sortMoneyAsc  = "money_asc"
-	sortMoneyDesc = "money_desc"
-)
-
-var ErrorInvalidSortParameters = fmt.Errorf("invalid sort parameters")
-
-func (rep *BalanceRepository) addTransaction(toId, fromId *string, operation string, money float64, tx *sql.Tx) error {
-	_, err := tx.Exec(addTransactionsSql, toId, fromId, money, operation, time.Now())
+func (rep *BalanceRepository) addTransaction(ctx context.Context, toId, fromId *string, operation string, money float64, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, addTransactionsSql, transactionArgs(ctx, toId, fromId, operation, money)...)
 
 	return err
 }
 
-func (rep *BalanceRepository) GetAllTransactions(id string, sortType string, limit int, page int) (*[]models.Transaction, error) {
-	finalSql := ""
+// transactionArgs returns the arguments of addTransactionsSql, stamping the
+// actor, the request and the import of ctx on the transaction.
+func transactionArgs(ctx context.Context, toId, fromId *string, operation string, money float64) []interface{} {
+	var importId, comment *string
+	if note, ok := ctx.Value(importNoteKey{}).(importNote); ok {
+		importId, comment = &note.id, &note.comment
+	}
 
-	if limit < 0 || page < 0 {
-		return nil, ErrorInvalidSortParameters
+	var requestId *string
+	if id := logging.RequestId(ctx); id != "" {
+		requestId = &id
 	}
 
-	switch strings.ToLower(sortType) {
-	case sortDateAsc:
-		finalSql = fmt.Sprintf(getAllTransactionsSql, "ORDER BY created_at ASC")
-	case sortDateDesc:
-		finalSql = fmt.Sprintf(getAllTransactionsSql, "ORDER BY created_at DESC")
-	case sortMoneyAsc:
-		finalSql = fmt.Sprintf(getAllTransactionsSql, "ORDER BY money ASC")
-	case sortMoneyDesc:
-		finalSql = fmt.Sprintf(getAllTransactionsSql, "ORDER BY money DESC")
-	default:
-		finalSql = getAllTransactionsSql
+	return []interface{}{toId, fromId, money, operation, auth.Actor(ctx), importId, comment, requestId, time.Now()}
+}
+
+func (rep *BalanceRepository) GetAllTransactions(ctx context.Context, id string, sortType string, limit int, page int) (*[]models.Transaction, error) {
+	if limit < 0 || page < 0 {
+		return nil, api.ErrorInvalidSortParameters
 	}
 
 	transactions := []models.Transaction{}
 
 	offset := (page - 1) * limit
 
-	err := rep.db.Select(&transactions, finalSql, id, limit, offset)
+	err := rep.reader(ctx).SelectContext(ctx, &transactions, allTransactionsSql(sortType), id, limit, offset)
 	if err != nil {
 		if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
-			return nil, ErrorInvalidInput
+			return nil, api.ErrorInvalidInput
 		}
 
 		return nil, err
@@ -58,3 +54,20 @@ func (rep *BalanceRepository) GetAllTransactions(id string, sortType string, lim
 
 	return &transactions, nil
 }
+
+// allTransactionsSql returns getAllTransactionsSql ordered by sortType, or in
+// the order of the table for unknown sort types.
+func allTransactionsSql(sortType string) string {
+	switch strings.ToLower(sortType) {
+	case models.SortDateAsc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY created_at ASC")
+	case models.SortDateDesc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY created_at DESC")
+	case models.SortMoneyAsc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY money ASC")
+	case models.SortMoneyDesc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY money DESC")
+	default:
+		return getAllTransactionsSql
+	}
+}
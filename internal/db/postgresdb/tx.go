@@ -0,0 +1,17 @@
+package postgresdb
+
+import "github.com/siraj18/balance-service-new/internal/metrics"
+
+type rollbacker interface {
+	Rollback() error
+}
+
+// rollback is deferred instead of tx.Rollback, it counts the transactions
+// that end without being committed. The background pollers and read-only
+// snapshots, which end most of their transactions without a commit, keep
+// tx.Rollback.
+func rollback(tx rollbacker) {
+	if err := tx.Rollback(); err == nil {
+		metrics.RecordTxRollback()
+	}
+}
@@ -0,0 +1,182 @@
+package postgresdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"strings"
+	"time"
+)
+
+const webhookDeliveriesLimit = 100
+
+func webhookError(err error) error {
+	if err == sql.ErrNoRows {
+		return api.ErrorWebhookNotFound
+	}
+
+	if strings.Contains(err.Error(), "ERROR: invalid input syntax for type uuid:") {
+		return api.ErrorInvalidInput
+	}
+
+	return err
+}
+
+// CreateWebhook subscribes url to the given event types. A secret for the
+// signatures is generated when none is given.
+func (rep *BalanceRepository) CreateWebhook(ctx context.Context, query models.CreateWebhookQuery) (*models.CreatedWebhook, error) {
+	if err := backend.ValidateWebhook(query); err != nil {
+		return nil, err
+	}
+
+	if query.Secret == "" {
+		secret, err := backend.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+
+		query.Secret = secret
+	}
+
+	var webhook models.WebhookSubscription
+
+	err := rep.db.GetContext(ctx, &webhook, addWebhookSql, query.Url, models.EventTypes(query.EventTypes), query.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedWebhook{WebhookSubscription: webhook, Secret: query.Secret}, nil
+}
+
+func (rep *BalanceRepository) GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	var webhook models.WebhookSubscription
+
+	if err := rep.db.GetContext(ctx, &webhook, getWebhookSql, id); err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook deactivates the subscription, its delivery log is kept.
+func (rep *BalanceRepository) DeleteWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	var webhook models.WebhookSubscription
+
+	if err := rep.db.GetContext(ctx, &webhook, deactivateWebhookSql, id); err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &webhook, nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of a subscription,
+// optionally only those with the given status.
+func (rep *BalanceRepository) GetWebhookDeliveries(ctx context.Context, id, status string) (*[]models.WebhookDelivery, error) {
+	if _, err := rep.GetWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+
+	if err := rep.db.SelectContext(ctx, &deliveries, getWebhookDeliveriesSql, id, status, webhookDeliveriesLimit); err != nil {
+		return nil, err
+	}
+
+	return &deliveries, nil
+}
+
+// RetryWebhookDelivery moves a dead delivery back to pending with a fresh
+// attempt budget.
+func (rep *BalanceRepository) RetryWebhookDelivery(ctx context.Context, id, deliveryId string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+
+	err := rep.db.GetContext(ctx, &delivery, retryWebhookDeliverySql, deliveryId, id, models.WebhookPending, models.WebhookDead)
+	if err == nil {
+		return &delivery, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return nil, webhookError(err)
+	}
+
+	var status string
+	if err = rep.db.GetContext(ctx, &status, getWebhookDeliveryStatusSql, deliveryId, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorWebhookDeliveryNotFound
+		}
+
+		return nil, webhookError(err)
+	}
+
+	return nil, api.ErrorWebhookDeliveryNotDead
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due
+// and pushes their next attempt lease into the future, so that other
+// dispatchers skip them while they are sent.
+func (rep *BalanceRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, claimWebhookDeliveriesSql, models.WebhookPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	ids := []string{}
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var event models.Event
+		var data string
+
+		err = rows.Scan(&delivery.Id, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType, &delivery.Status,
+			&delivery.Attempt, &delivery.CreatedAt, &delivery.Url, &delivery.Secret, &event.Sequence, &event.Type,
+			&event.Version, &event.AccountId, &data, &event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Id = delivery.EventId
+		event.Data = json.RawMessage(data)
+		delivery.Event = &event
+
+		deliveries = append(deliveries, delivery)
+		ids = append(ids, delivery.Id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	if _, err = tx.ExecContext(ctx, leaseWebhookDeliveriesSql, pq.Array(ids), time.Now().Add(lease)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// FinishWebhookDelivery stores the outcome of an attempt.
+func (rep *BalanceRepository) FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := rep.db.ExecContext(ctx, finishWebhookDeliverySql, delivery.Id, delivery.Status, delivery.Attempt,
+		delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt)
+
+	return err
+}
@@ -0,0 +1,207 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func accountError(err error) error {
+	if err == sql.ErrNoRows {
+		return api.ErrorUserNotFound
+	}
+
+	return err
+}
+
+// lockAccount reads the account within tx. The transaction holds the write
+// lock of the database, so its status cannot change while money is moved.
+func (rep *BalanceRepository) lockAccount(ctx context.Context, uid string, tx *sqlx.Tx) (*models.Account, error) {
+	id, err := parseId(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.Account
+
+	if err = tx.GetContext(ctx, &account, getAccountSql, id); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &account, nil
+}
+
+func (rep *BalanceRepository) CreateAccount(ctx context.Context, uid, owner string, metadata models.Metadata) (*models.Account, error) {
+	if uid == "" {
+		uid = uuid.New().String()
+	}
+
+	id, err := parseId(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.Account
+
+	if err := rep.db.GetContext(ctx, &account, addAccountSql, id, owner, metadata); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: users.id") {
+			return nil, api.ErrorAccountAlreadyExists
+		}
+
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (rep *BalanceRepository) GetAccount(ctx context.Context, uid string) (*models.Account, error) {
+	id, err := parseId(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.Account
+
+	if err := rep.db.GetContext(ctx, &account, getAccountSql, id); err != nil {
+		return nil, accountError(err)
+	}
+
+	return &account, nil
+}
+
+func (rep *BalanceRepository) updateAccountStatus(ctx context.Context, uid string, update func(*models.Account, *sqlx.Tx) error) (*models.Account, error) {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	account, err := rep.lockAccount(ctx, uid, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == models.AccountClosed {
+		return nil, api.ErrorAccountClosed
+	}
+
+	if err = update(account, tx); err != nil {
+		return nil, err
+	}
+
+	var updated models.Account
+
+	err = tx.GetContext(ctx, &updated, updateAccountStatusSql, account.Id, account.Status, account.DebitsBlocked,
+		account.CreditsBlocked, account.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (rep *BalanceRepository) FreezeAccount(ctx context.Context, uid string, debits, credits bool) (*models.Account, error) {
+	if !debits && !credits {
+		debits, credits = true, true
+	}
+
+	return rep.updateAccountStatus(ctx, uid, func(account *models.Account, tx *sqlx.Tx) error {
+		account.Status = models.AccountFrozen
+		account.DebitsBlocked = debits
+		account.CreditsBlocked = credits
+
+		return nil
+	})
+}
+
+func (rep *BalanceRepository) UnfreezeAccount(ctx context.Context, uid string) (*models.Account, error) {
+	return rep.updateAccountStatus(ctx, uid, func(account *models.Account, tx *sqlx.Tx) error {
+		account.Status = models.AccountActive
+		account.DebitsBlocked = false
+		account.CreditsBlocked = false
+
+		return nil
+	})
+}
+
+func (rep *BalanceRepository) CloseAccount(ctx context.Context, uid string) (*models.Account, error) {
+	return rep.updateAccountStatus(ctx, uid, func(account *models.Account, tx *sqlx.Tx) error {
+		if account.Balance != 0 {
+			return api.ErrorAccountNotEmpty
+		}
+
+		var reserves int
+		if err := tx.GetContext(ctx, &reserves, countUserReservesSql, account.Id, models.ReserveReserved); err != nil {
+			return err
+		}
+
+		if reserves > 0 {
+			return api.ErrorAccountHasReserves
+		}
+
+		now := time.Now()
+		account.Status = models.AccountClosed
+		account.DebitsBlocked = true
+		account.CreditsBlocked = true
+		account.ClosedAt = &now
+
+		return nil
+	})
+}
+
+func (rep *BalanceRepository) SetCreditLimit(ctx context.Context, uid string, limit float64) (*models.Account, error) {
+	if limit < 0 {
+		return nil, api.ErrorNegativeAmount
+	}
+
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	account, err := rep.lockAccount(ctx, uid, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Status == models.AccountClosed {
+		return nil, api.ErrorAccountClosed
+	}
+
+	if account.Balance < -limit {
+		return nil, api.ErrorCreditLimitInUse
+	}
+
+	var updated models.Account
+
+	if err = tx.GetContext(ctx, &updated, updateCreditLimitSql, account.Id, limit); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (rep *BalanceRepository) GetAccountsUsingCredit(ctx context.Context) (*[]models.Account, error) {
+	accounts := []models.Account{}
+
+	if err := rep.db.SelectContext(ctx, &accounts, getAccountsUsingCreditSql); err != nil {
+		return nil, err
+	}
+
+	return &accounts, nil
+}
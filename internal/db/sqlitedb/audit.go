@@ -0,0 +1,89 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+const auditVerifyBatch = 1000
+
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+func (rep *AuditRepository) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	if err = appendAuditEntry(ctx, entry, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appendAuditEntry links entry to the last one within tx. The transaction
+// holds the write lock of the database, so appends are serialized without a
+// lock of their own.
+func appendAuditEntry(ctx context.Context, entry *models.AuditEntry, tx *sqlx.Tx) error {
+	if err := tx.QueryRowContext(ctx, getLastAuditHashSql).Scan(&entry.PrevHash); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	entry.Hash = audit.Hash(entry.PrevHash, entry)
+
+	err := tx.QueryRowContext(ctx, addAuditEntrySql, entry.Actor, entry.Endpoint, entry.PayloadHash, entry.BalancesBefore,
+		entry.BalancesAfter, entry.Status, entry.Outcome, entry.CreatedAt, entry.PrevHash, entry.Hash).Scan(&entry.Id)
+	if err != nil {
+		return fmt.Errorf("error when add audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func auditChanges(ctx context.Context, tx *sqlx.Tx, changes ...audit.Change) error {
+	return appendAuditEntry(ctx, audit.NewEntry(ctx, changes...), tx)
+}
+
+func (rep *AuditRepository) VerifyAuditLog(ctx context.Context) (int, error) {
+	var lastId int64
+	prevHash := ""
+	count := 0
+
+	for {
+		entries := []models.AuditEntry{}
+
+		if err := rep.db.SelectContext(ctx, &entries, getAuditEntriesSql, lastId, auditVerifyBatch); err != nil {
+			return count, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+
+			if entry.PrevHash != prevHash || audit.Hash(prevHash, entry) != entry.Hash {
+				return count, fmt.Errorf("%w at entry %d", backend.ErrorAuditChainBroken, entry.Id)
+			}
+
+			prevHash = entry.Hash
+			lastId = entry.Id
+			count++
+		}
+
+		if len(entries) < auditVerifyBatch {
+			return count, nil
+		}
+	}
+}
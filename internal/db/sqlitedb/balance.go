@@ -0,0 +1,253 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (rep *BalanceRepository) ChangeBalance(ctx context.Context, uid string, money float64) (*models.User, error) {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	before, user, err := rep.changeBalance(ctx, uid, money, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = auditChanges(ctx, tx, audit.Change{AccountId: uid, Before: before, After: user.Balance}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	audit.RecordCommitted(ctx)
+	backend.RecordBalanceChange(money)
+
+	return user, nil
+}
+
+// changeBalance stores the account of a first deposit under the canonical id,
+// which Postgres derives from its UUID column on its own.
+func (rep *BalanceRepository) changeBalance(ctx context.Context, uid string, money float64, tx *sqlx.Tx) (float64, *models.User, error) {
+	var user models.User
+
+	account, err := rep.lockAccount(ctx, uid, tx)
+	if err == api.ErrorUserNotFound {
+		id, _ := parseId(uid)
+		if _, err = tx.ExecContext(ctx, addUserSql, id); err != nil {
+			return 0, nil, err
+		}
+
+		account = &models.Account{Id: id}
+	} else if err != nil {
+		return 0, nil, err
+	} else {
+		user.Balance = account.Balance
+		user.CreditLimit = account.CreditLimit
+
+		if money < 0 {
+			err = backend.CanDebit(account)
+		} else {
+			err = backend.CanCredit(account)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if money < 0 {
+		if user.Balance+user.CreditLimit < math.Abs(money) {
+			metrics.RecordInsufficientFunds()
+			return 0, nil, api.ErrorNotEnoughMoney
+		}
+
+		if err = rep.checkDebitLimits(ctx, uid, account.Id, math.Abs(money), false, tx); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	before := user.Balance
+
+	var empty interface{}
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, account.Id, money).Scan(&empty, &empty); err != nil {
+		return 0, nil, err
+	}
+
+	if err = tx.GetContext(ctx, &user, getUserSql, account.Id, models.ReserveReserved); err != nil {
+		return 0, nil, err
+	}
+
+	eventType := models.EventDeposited
+	if money >= 0 {
+		if err = rep.addTransaction(ctx, &account.Id, nil, models.OperationAddMoney, money, tx); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		eventType = models.EventWithdrawn
+		if err = rep.addTransaction(ctx, nil, &account.Id, models.OperationWithdrawMoney, money, tx); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	err = rep.addEvent(ctx, eventType, account.Id, models.AccountEventData{Amount: math.Abs(money), Balance: &user.Balance}, tx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return before, &user, nil
+}
+
+func (rep *BalanceRepository) GetBalance(ctx context.Context, uid string) (*models.User, error) {
+	id, err := parseId(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user models.User
+
+	if err := tx.GetContext(ctx, &user, getUserSql, id, models.ReserveReserved); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorUserNotFound
+		}
+
+		return nil, fmt.Errorf("error when get user balance: %w", err)
+	}
+
+	user.Reserves = []models.Reserve{}
+
+	if err := tx.SelectContext(ctx, &user.Reserves, getOpenReservesSql, id, models.ReserveReserved); err != nil {
+		return nil, fmt.Errorf("error when get user reserves: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (rep *BalanceRepository) TransferBalance(ctx context.Context, fromUid string, toUid string, money float64) error {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	fromBalance, toBalance, err := rep.transfer(ctx, fromUid, toUid, money, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = auditChanges(ctx, tx, backend.TransferChanges(fromUid, toUid, money, fromBalance, toBalance)...); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationTransfer, money)
+
+	return nil
+}
+
+// transfer is shared by TransferBalance and the scheduled transfers, whose
+// transactions already hold the write lock.
+func (rep *BalanceRepository) transfer(ctx context.Context, fromUid string, toUid string, money float64, tx *sqlx.Tx) (float64, float64, error) {
+	if money < 0 {
+		return 0, 0, api.ErrorNegativeAmount
+	}
+
+	from, to, err := rep.lockTransferAccounts(ctx, fromUid, toUid, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = rep.checkDebitLimits(ctx, fromUid, from.Id, money, true, tx); err != nil {
+		return 0, 0, err
+	}
+
+	var empty interface{}
+	var toBalance, fromBalance float64
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, to.Id, money).Scan(&empty, &toBalance); err != nil {
+		return 0, 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, updateUserBalanceSql, from.Id, -money).Scan(&empty, &fromBalance)
+	if err != nil {
+		if strings.Contains(err.Error(), "users_balance_check") {
+			metrics.RecordInsufficientFunds()
+			return 0, 0, api.ErrorNotEnoughMoney
+		}
+
+		return 0, 0, err
+	}
+
+	if err = rep.addTransaction(ctx, &to.Id, &from.Id, models.OperationTransferMoney, money, tx); err != nil {
+		return 0, 0, err
+	}
+
+	err = rep.addEvent(ctx, models.EventTransferDebited, from.Id,
+		models.AccountEventData{Amount: money, Balance: &fromBalance, CounterpartyId: to.Id}, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = rep.addEvent(ctx, models.EventTransferCredited, to.Id,
+		models.AccountEventData{Amount: money, Balance: &toBalance, CounterpartyId: from.Id}, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fromBalance, toBalance, nil
+}
+
+// lockTransferAccounts reads both accounts in the order Postgres locks
+// them, so that the same error is returned when both are wrong.
+func (rep *BalanceRepository) lockTransferAccounts(ctx context.Context, fromUid, toUid string, tx *sqlx.Tx) (*models.Account, *models.Account, error) {
+	uids := []string{fromUid, toUid}
+	if toUid < fromUid {
+		uids = []string{toUid, fromUid}
+	}
+
+	accounts := make(map[string]*models.Account)
+
+	for _, uid := range uids {
+		account, err := rep.lockAccount(ctx, uid, tx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		accounts[uid] = account
+	}
+
+	from, to := accounts[fromUid], accounts[toUid]
+
+	if err := backend.CanDebit(from); err != nil {
+		return nil, nil, err
+	}
+
+	if err := backend.CanCredit(to); err != nil {
+		return nil, nil, err
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,85 @@
+package sqlitedb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+type importNoteKey struct{}
+
+// importNote is stamped by addTransaction on the transactions of an import.
+type importNote struct {
+	id      string
+	comment string
+}
+
+func (rep *BalanceRepository) ImportBalances(ctx context.Context, rows []models.ImportRow, dryRun bool) (*models.ImportResult, error) {
+	result := &models.ImportResult{DryRun: dryRun, Rows: len(rows), Errors: make([]models.ImportLineError, 0)}
+	importId := uuid.New().String()
+
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var changes []audit.Change
+
+	for _, row := range rows {
+		rowCtx := context.WithValue(ctx, importNoteKey{}, importNote{importId, row.Comment})
+
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+
+		// unlike ChangeBalance an import never opens new accounts
+		_, rowErr := rep.lockAccount(ctx, row.UserId, tx)
+
+		var before float64
+		var user *models.User
+		if rowErr == nil {
+			before, user, rowErr = rep.changeBalance(rowCtx, row.UserId, row.Amount, tx)
+		}
+
+		if rowErr != nil {
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return nil, err
+			}
+
+			result.Errors = append(result.Errors, models.ImportLineError{Line: row.Line, Error: rowErr.Error()})
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, audit.Change{AccountId: row.UserId, Before: before, After: user.Balance})
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	if err = auditChanges(ctx, tx, changes...); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	result.Id = importId
+	result.Applied = true
+	audit.RecordCommitted(ctx)
+
+	for _, row := range rows {
+		backend.RecordBalanceChange(row.Amount)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (rep *BalanceRepository) GetLimits(ctx context.Context, scope string) (*models.Limits, error) {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return nil, err
+	}
+
+	var limits models.Limits
+
+	if err := rep.db.GetContext(ctx, &limits, getLimitsSql, scope); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorLimitsNotFound
+		}
+
+		return nil, err
+	}
+
+	return &limits, nil
+}
+
+func (rep *BalanceRepository) SetLimits(ctx context.Context, scope string, limits models.Limits) (*models.Limits, error) {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return nil, err
+	}
+
+	if err := backend.ValidateLimits(limits); err != nil {
+		return nil, err
+	}
+
+	var updated models.Limits
+
+	err := rep.db.GetContext(ctx, &updated, setLimitsSql, scope, limits.MaxOperation, limits.DailyDebit,
+		limits.MonthlyDebit, limits.HourlyTransfers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (rep *BalanceRepository) DeleteLimits(ctx context.Context, scope string) error {
+	if err := backend.ValidateLimitsScope(scope); err != nil {
+		return err
+	}
+
+	res, err := rep.db.ExecContext(ctx, deleteLimitsSql, scope)
+	if err != nil {
+		return err
+	}
+
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		return api.ErrorLimitsNotFound
+	}
+
+	return nil
+}
+
+func (rep *BalanceRepository) effectiveLimits(ctx context.Context, scope string, tx *sqlx.Tx) (*models.Limits, error) {
+	var scopes []models.Limits
+
+	if err := tx.SelectContext(ctx, &scopes, getEffectiveLimitsSql, backend.GlobalLimitsScope, scope); err != nil {
+		return nil, err
+	}
+
+	return backend.MergeLimits(scopes), nil
+}
+
+// checkDebitLimits checks a debit of amount from the account id against the
+// limits of scope, the account id as the caller gave it.
+func (rep *BalanceRepository) checkDebitLimits(ctx context.Context, scope, id string, amount float64, transfer bool, tx *sqlx.Tx) error {
+	limits, err := rep.effectiveLimits(ctx, scope, tx)
+	if err != nil {
+		return err
+	}
+
+	return backend.CheckLimits(limits, amount, transfer, time.Now(), backend.DebitTotals{
+		Debited: func(since time.Time) (float64, error) {
+			var total float64
+			err := tx.GetContext(ctx, &total, getDebitTotalSql, id, models.OperationWithdrawMoney, models.OperationTransferMoney,
+				models.ReserveDeReserved, since)
+
+			return total, err
+		},
+		Transfers: func(since time.Time) (int, *time.Time, error) {
+			var count int
+			if err := tx.GetContext(ctx, &count, countRecentTransfersSql, id, models.OperationTransferMoney, since); err != nil || count == 0 {
+				return count, nil, err
+			}
+
+			var oldest time.Time
+			err := tx.GetContext(ctx, &oldest, getOldestRecentTransferSql, id, models.OperationTransferMoney, since)
+
+			return count, &oldest, err
+		},
+	})
+}
@@ -0,0 +1,99 @@
+package sqlitedb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// outboxRow reads the JSON data as text, the driver cannot scan it into a
+// json.RawMessage directly.
+type outboxRow struct {
+	models.Event
+	Data string `db:"data"`
+}
+
+// addEvent writes an event to the outbox within tx. There are no
+// notifications for live streams, which need Postgres.
+func (rep *BalanceRepository) addEvent(ctx context.Context, eventType, accountId string, data models.AccountEventData, tx *sqlx.Tx) error {
+	event, err := backend.NewEvent(ctx, eventType, accountId, data)
+	if err != nil {
+		return err
+	}
+
+	err = tx.GetContext(ctx, &event.Sequence, addEventSql, event.Id, event.Type, event.Version, event.AccountId,
+		string(event.Data), event.OccurredAt)
+	if err != nil {
+		return err
+	}
+
+	return rep.addWebhookDeliveries(ctx, event, tx)
+}
+
+// addWebhookDeliveries queues a delivery of event for every active
+// subscription to its type.
+func (rep *BalanceRepository) addWebhookDeliveries(ctx context.Context, event *models.Event, tx *sqlx.Tx) error {
+	var subscriptions []string
+
+	if err := tx.SelectContext(ctx, &subscriptions, getSubscribedWebhooksSql, event.Type, models.AllEvents); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, subscription := range subscriptions {
+		_, err := tx.ExecContext(ctx, addWebhookDeliverySql, uuid.New().String(), subscription, event.Sequence, event.Id,
+			event.Type, models.WebhookPending, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RelayEvents holds no transaction while publishing, unlike postgresdb, as it
+// would keep every writer waiting.
+func (rep *BalanceRepository) RelayEvents(ctx context.Context, limit int, publish func(context.Context, *models.Event) error) (int, error) {
+	if !rep.relay.TryLock() {
+		return 0, nil
+	}
+	defer rep.relay.Unlock()
+
+	rows := []outboxRow{}
+	if err := rep.db.SelectContext(ctx, &rows, getUnpublishedEventsSql, limit); err != nil {
+		return 0, err
+	}
+
+	published := make([]int64, 0, len(rows))
+	var publishErr error
+
+	for _, row := range rows {
+		event := row.Event
+		event.Data = json.RawMessage(row.Data)
+
+		if publishErr = publish(ctx, &event); publishErr != nil {
+			break
+		}
+
+		published = append(published, event.Sequence)
+	}
+
+	if len(published) > 0 {
+		query, args, err := sqlx.In(markEventsPublishedSql, time.Now(), published)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = rep.db.ExecContext(ctx, query, args...); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(published), publishErr
+}
@@ -0,0 +1,98 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// BalanceRepository implements handlers.Repository and the workers of
+// postgresdb.BalanceRepository on SQLite, for deployments that cannot run
+// Postgres, with the same semantics and error values. Its transactions take
+// the write lock of the database when they begin, see sqlite.NewDb, which
+// plays the part of the row locks of Postgres.
+type BalanceRepository struct {
+	db *sqlx.DB
+	// relay lets a single RelayEvents publish at a time, like the advisory
+	// lock of the outbox
+	relay sync.Mutex
+}
+
+// NewSqlRepository creates the schema, dropping the tables postgresdb's
+// NewSqlRepository drops.
+func NewSqlRepository(db *sqlx.DB) (*BalanceRepository, error) {
+	rep := ConnectSqlRepository(db)
+
+	if err := rep.init(); err != nil {
+		return nil, err
+	}
+
+	return rep, nil
+}
+
+func ConnectSqlRepository(db *sqlx.DB) *BalanceRepository {
+	return &BalanceRepository{db: db}
+}
+
+func (rep *BalanceRepository) init() error {
+	_, err := rep.db.Exec(initSchema)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = rep.db.Exec(setSchemaVersionSql, backend.SchemaVersion)
+
+	return err
+}
+
+// CheckSchema takes a database without the schema_version table for an
+// outdated one.
+func (rep *BalanceRepository) CheckSchema(ctx context.Context) error {
+	var version int
+	err := rep.db.GetContext(ctx, &version, getSchemaVersionSql)
+
+	if err != nil && strings.Contains(err.Error(), "no such table") {
+		return fmt.Errorf("%w: no schema version", backend.ErrorSchemaOutdated)
+	}
+
+	if err == sql.ErrNoRows || err == nil && version != backend.SchemaVersion {
+		return fmt.Errorf("%w: version %d, expected %d", backend.ErrorSchemaOutdated, version, backend.SchemaVersion)
+	}
+
+	return err
+}
+
+func (rep *BalanceRepository) Close() {
+	rep.db.Close()
+}
+
+// parseId returns uid in the canonical form the ids are stored in. Postgres
+// does the same for its UUID columns.
+func parseId(uid string) (string, error) {
+	id, err := uuid.Parse(uid)
+	if err != nil {
+		return "", api.ErrorInvalidInput
+	}
+
+	return id.String(), nil
+}
+
+type rollbacker interface {
+	Rollback() error
+}
+
+// rollback is deferred instead of tx.Rollback to count the rollbacks.
+func rollback(tx rollbacker) {
+	if err := tx.Rollback(); err == nil {
+		metrics.RecordTxRollback()
+	}
+}
@@ -0,0 +1,176 @@
+package sqlitedb_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/db/dbtest"
+	"github.com/siraj18/balance-service-new/internal/db/sqlitedb"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/siraj18/balance-service-new/pkg/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRepository(t *testing.T) (*sqlitedb.BalanceRepository, *sqlx.DB) {
+	db, err := sqlite.NewDb(filepath.Join(t.TempDir(), "balance.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	rep, err := sqlitedb.NewSqlRepository(db)
+	require.NoError(t, err)
+
+	return rep, db
+}
+
+func TestConformance(t *testing.T) {
+	rep, db := newRepository(t)
+
+	dbtest.Run(t, rep, sqlitedb.NewAuditRepository(db))
+}
+
+func TestCheckSchema(t *testing.T) {
+	ctx := context.Background()
+	rep, db := newRepository(t)
+
+	require.NoError(t, rep.CheckSchema(ctx))
+
+	require.NoError(t, sqlitedb.ConnectSqlRepository(db).CheckSchema(ctx))
+
+	empty, err := sqlite.NewDb(filepath.Join(t.TempDir(), "empty.db"))
+	require.NoError(t, err)
+	defer empty.Close()
+
+	err = sqlitedb.ConnectSqlRepository(empty).CheckSchema(ctx)
+	assert.ErrorIs(t, err, backend.ErrorSchemaOutdated)
+}
+
+func TestRelayEvents(t *testing.T) {
+	ctx := context.Background()
+	rep, _ := newRepository(t)
+	uid := uuid.New().String()
+
+	_, err := rep.ChangeBalance(ctx, uid, 10)
+	require.NoError(t, err)
+
+	_, err = rep.ChangeBalance(ctx, uid, -20)
+	require.Equal(t, api.ErrorNotEnoughMoney, err)
+
+	_, err = rep.ChangeBalance(ctx, uid, -5)
+	require.NoError(t, err)
+
+	var published []*models.Event
+	publish := func(ctx context.Context, event *models.Event) error {
+		if len(published) == 1 {
+			return fmt.Errorf("broker is down")
+		}
+
+		published = append(published, event)
+		return nil
+	}
+
+	count, err := rep.RelayEvents(ctx, 10, publish)
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+
+	publish = func(ctx context.Context, event *models.Event) error {
+		published = append(published, event)
+		return nil
+	}
+
+	count, err = rep.RelayEvents(ctx, 10, publish)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.Len(t, published, 2)
+	assert.Equal(t, models.EventDeposited, published[0].Type)
+	assert.Equal(t, models.EventWithdrawn, published[1].Type)
+	assert.Less(t, published[0].Sequence, published[1].Sequence)
+	assert.JSONEq(t, `{"actor":"anonymous","amount":5,"balance":5}`, string(published[1].Data))
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	rep, _ := newRepository(t)
+
+	webhook, err := rep.CreateWebhook(ctx, models.CreateWebhookQuery{Url: "https://example.com", EventTypes: []string{models.AllEvents}})
+	require.NoError(t, err)
+
+	_, err = rep.ChangeBalance(ctx, uuid.New().String(), 10)
+	require.NoError(t, err)
+
+	claimed, err := rep.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, webhook.Url, claimed[0].Url)
+	require.NotNil(t, claimed[0].Event)
+	assert.Equal(t, models.EventDeposited, claimed[0].Event.Type)
+
+	// leased deliveries are not claimed again
+	again, err := rep.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	delivery := claimed[0]
+	delivery.Status = models.WebhookDead
+	delivery.Attempt = 5
+	require.NoError(t, rep.FinishWebhookDelivery(ctx, &delivery))
+
+	retried, err := rep.RetryWebhookDelivery(ctx, webhook.Id, delivery.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookPending, retried.Status)
+	assert.Equal(t, 0, retried.Attempt)
+
+	claimed, err = rep.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 1)
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	_, db := newRepository(t)
+	rep := sqlitedb.NewAuditRepository(db)
+
+	for i := 0; i < 3; i++ {
+		entry := &models.AuditEntry{Actor: "admin", Endpoint: "POST /balance", PayloadHash: "hash", BalancesBefore: "{}",
+			BalancesAfter: "{}", Status: 200, Outcome: "success", CreatedAt: time.Now()}
+		require.NoError(t, rep.AppendAuditEntry(ctx, entry))
+	}
+
+	count, err := rep.VerifyAuditLog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = db.ExecContext(ctx, "UPDATE audit_log SET status=500 WHERE id=2")
+	assert.ErrorContains(t, err, "append-only")
+}
+
+func TestIdempotency(t *testing.T) {
+	ctx := context.Background()
+	_, db := newRepository(t)
+	rep := sqlitedb.NewIdempotencyRepository(db)
+
+	response, err := rep.StartRequest(ctx, "admin", "key", "fingerprint")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	_, err = rep.StartRequest(ctx, "admin", "key", "fingerprint")
+	assert.Equal(t, api.ErrorIdempotencyKeyInProgress, err)
+
+	stored := &models.IdempotentResponse{Status: 200, Header: models.Metadata{"Content-Type": "application/json"}, Body: []byte(`{}`)}
+	require.NoError(t, rep.CompleteRequest(ctx, "admin", "key", stored))
+
+	response, err = rep.StartRequest(ctx, "admin", "key", "fingerprint")
+	require.NoError(t, err)
+	assert.Equal(t, stored, response)
+
+	_, err = rep.StartRequest(ctx, "admin", "key", "other")
+	assert.Equal(t, api.ErrorIdempotencyKeyReused, err)
+}
@@ -0,0 +1,229 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (rep *BalanceRepository) addReserve(ctx context.Context, userId, serviceId, orderId, status string, amount float64, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, addReserveSql, uuid.New().String(), userId, serviceId, orderId, amount, status,
+		auth.Actor(ctx), time.Now())
+
+	return err
+}
+
+func (rep *BalanceRepository) ReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	before, after, err := rep.reserveMoney(ctx, userId, serviceId, orderId, amount, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = auditChanges(ctx, tx, audit.Change{AccountId: userId, Before: before, After: after}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationReserve, amount)
+
+	return nil
+}
+
+func (rep *BalanceRepository) reserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64, tx *sqlx.Tx) (float64, float64, error) {
+	if amount < 0 {
+		return 0, 0, api.ErrorNegativeAmount
+	}
+
+	user, err := rep.lockAccount(ctx, userId, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = backend.CanDebit(user); err != nil {
+		return 0, 0, err
+	}
+
+	if user.Balance+user.CreditLimit < amount {
+		metrics.RecordInsufficientFunds()
+		return 0, 0, api.ErrorNotEnoughMoney
+	}
+
+	if err = rep.checkDebitLimits(ctx, userId, user.Id, amount, false, tx); err != nil {
+		return 0, 0, err
+	}
+
+	var empty interface{}
+	var balance float64
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, user.Id, -amount).Scan(&empty, &balance); err != nil {
+		return 0, 0, err
+	}
+
+	if err = rep.addReserve(ctx, user.Id, serviceId, orderId, models.ReserveReserved, amount, tx); err != nil {
+		return 0, 0, err
+	}
+
+	if err = rep.addTransaction(ctx, nil, &user.Id, models.OperationReserveMoney, amount, tx); err != nil {
+		return 0, 0, err
+	}
+
+	err = rep.addEvent(ctx, models.EventReserveCreated, user.Id,
+		models.AccountEventData{Amount: amount, Balance: &balance, ServiceId: serviceId, OrderId: orderId}, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return user.Balance, balance, nil
+}
+
+func (rep *BalanceRepository) openReserve(ctx context.Context, userId, serviceId, orderId string, amount float64, tx *sqlx.Tx) (*models.Reserve, error) {
+	id, err := parseId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var reserve models.Reserve
+
+	err = tx.GetContext(ctx, &reserve, getReserveSql, id, serviceId, orderId, amount, models.ReserveReserved)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorReserveNotFound
+		}
+
+		return nil, fmt.Errorf("error when get reserve: %w", err)
+	}
+
+	if reserve.Status != models.ReserveReserved {
+		if reserve.Status == models.ReserveRecognized {
+			return nil, api.ErrorReserveAlreadyRecognized
+		}
+
+		return nil, api.ErrorReserveAlreadyDeReserved
+	}
+
+	return &reserve, nil
+}
+
+func (rep *BalanceRepository) RecognizedMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	reserve, err := rep.openReserve(ctx, userId, serviceId, orderId, amount, tx)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, updateReserveStatus, reserve.Id, models.ReserveRecognized, time.Now()); err != nil {
+		return err
+	}
+
+	err = rep.addEvent(ctx, models.EventReserveRecognized, reserve.UserId,
+		models.AccountEventData{Amount: reserve.Amount, ServiceId: serviceId, OrderId: orderId}, tx)
+	if err != nil {
+		return err
+	}
+
+	// recognizing leaves the balance as it is
+	if err = auditChanges(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+
+	metrics.RecordOperation(metrics.OperationRecognize, reserve.Amount)
+
+	return nil
+}
+
+func (rep *BalanceRepository) DeReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	reserve, err := rep.openReserve(ctx, userId, serviceId, orderId, amount, tx)
+	if err != nil {
+		return err
+	}
+
+	account, err := rep.lockAccount(ctx, userId, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = backend.CanCredit(account); err != nil {
+		return err
+	}
+
+	var empty interface{}
+	var balance float64
+	if err = tx.QueryRowContext(ctx, updateUserBalanceSql, account.Id, reserve.Amount).Scan(&empty, &balance); err != nil {
+		return err
+	}
+
+	if err = rep.addTransaction(ctx, &account.Id, nil, models.OperationReturnReserveMoney, amount, tx); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, updateReserveStatus, reserve.Id, models.ReserveDeReserved, nil); err != nil {
+		return err
+	}
+
+	err = rep.addEvent(ctx, models.EventReserveReleased, account.Id,
+		models.AccountEventData{Amount: reserve.Amount, Balance: &balance, ServiceId: serviceId, OrderId: orderId}, tx)
+	if err != nil {
+		return err
+	}
+
+	if err = auditChanges(ctx, tx, audit.Change{AccountId: userId, Before: balance - reserve.Amount, After: balance}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	audit.RecordCommitted(ctx)
+	metrics.RecordOperation(metrics.OperationDeReserve, reserve.Amount)
+
+	return nil
+}
+
+// GetReserves returns the reserves recognized in the given month of UTC.
+func (rep *BalanceRepository) GetReserves(ctx context.Context, year, month int) (*[]models.Reserve, error) {
+	reserves := []models.Reserve{}
+
+	err := rep.db.SelectContext(ctx, &reserves, getReserveForReportSql, models.ReserveRecognized, year, month)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reserves, nil
+}
@@ -0,0 +1,504 @@
+package sqlitedb
+
+// initSchema is postgresdb's schema in SQLite: uuids are text generated by
+// the repository, money is numeric rounded by the statements, JSON is text and
+// times are text in UTC, which the driver parses for the TIMESTAMP columns.
+const initSchema = `
+				DROP TABLE IF EXISTS idempotency_keys;
+				DROP TABLE IF EXISTS schedule_runs;
+				DROP TABLE IF EXISTS schedules;
+				DROP TABLE IF EXISTS reserves;
+				DROP TABLE IF EXISTS webhook_deliveries;
+				DROP TABLE IF EXISTS outbox;
+				DROP TABLE IF EXISTS transactions;
+				DROP TABLE IF EXISTS users;
+				DROP TABLE IF EXISTS schema_version;
+
+				CREATE TABLE IF NOT EXISTS schema_version
+				(
+					version INT NOT NULL
+				);
+				CREATE TABLE IF NOT EXISTS users
+				(
+					id              TEXT PRIMARY KEY,
+					balance         DECIMAL(10, 2) DEFAULT 0,
+					credit_limit    DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (credit_limit >= 0),
+					owner           TEXT NOT NULL DEFAULT '',
+					metadata        TEXT NOT NULL DEFAULT '{}',
+					status          TEXT NOT NULL DEFAULT 'active',
+					debits_blocked  BOOLEAN NOT NULL DEFAULT false,
+					credits_blocked BOOLEAN NOT NULL DEFAULT false,
+					created_at      TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					closed_at       TIMESTAMP DEFAULT NULL,
+					CONSTRAINT users_balance_check CHECK (balance >= -credit_limit)
+				);
+				CREATE TABLE IF NOT EXISTS transactions
+				(
+					id         TEXT PRIMARY KEY,
+					to_id      TEXT REFERENCES users(id),
+					from_id    TEXT REFERENCES users(id),
+					money      DECIMAL(10, 2) NOT NULL,
+					operation  TEXT NOT NULL,
+					actor      TEXT NOT NULL,
+					import_id  TEXT DEFAULT NULL,
+					comment    TEXT DEFAULT NULL,
+					request_id TEXT DEFAULT NULL,
+					created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				);
+				CREATE TABLE IF NOT EXISTS reserves
+				(
+					id            TEXT PRIMARY KEY,
+					user_id       TEXT REFERENCES users(id),
+					service_id    TEXT NOT NULL,
+					order_id      TEXT NOT NULL,
+					amount        DECIMAL(10, 2) NOT NULL,
+					status        TEXT NOT NULL,
+					actor         TEXT NOT NULL,
+					created_at    TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					recognized_at TIMESTAMP DEFAULT NULL
+				);
+				CREATE TABLE IF NOT EXISTS schedules
+				(
+					id               TEXT PRIMARY KEY,
+					from_id          TEXT NOT NULL REFERENCES users(id),
+					to_id            TEXT NOT NULL REFERENCES users(id),
+					money            DECIMAL(10, 2) NOT NULL,
+					cron             TEXT DEFAULT NULL,
+					interval_seconds INT DEFAULT NULL,
+					next_run_at      TIMESTAMP DEFAULT NULL,
+					slot_at          TIMESTAMP DEFAULT NULL,
+					status           TEXT NOT NULL,
+					max_retries      INT NOT NULL,
+					attempt          INT NOT NULL DEFAULT 0,
+					actor            TEXT NOT NULL,
+					created_at       TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				);
+				CREATE TABLE IF NOT EXISTS schedule_runs
+				(
+					id           TEXT PRIMARY KEY,
+					schedule_id  TEXT NOT NULL REFERENCES schedules(id),
+					scheduled_at TIMESTAMP NOT NULL,
+					executed_at  TIMESTAMP NOT NULL,
+					attempt      INT NOT NULL,
+					outcome      TEXT NOT NULL,
+					error        TEXT DEFAULT NULL
+				);
+				CREATE INDEX IF NOT EXISTS schedules_status_next_run_at_idx ON schedules (status, next_run_at);
+				CREATE INDEX IF NOT EXISTS schedule_runs_schedule_id_idx ON schedule_runs (schedule_id);
+				CREATE INDEX IF NOT EXISTS reserves_user_id_status_idx ON reserves (user_id, status);
+				CREATE TABLE IF NOT EXISTS outbox
+				(
+					id           INTEGER PRIMARY KEY AUTOINCREMENT,
+					event_id     TEXT NOT NULL,
+					type         TEXT NOT NULL,
+					version      INT NOT NULL,
+					account_id   TEXT NOT NULL,
+					data         TEXT NOT NULL,
+					created_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					published_at TIMESTAMP DEFAULT NULL
+				);
+				CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+				CREATE TABLE IF NOT EXISTS webhook_subscriptions
+				(
+					id          TEXT PRIMARY KEY,
+					url         TEXT NOT NULL,
+					event_types TEXT NOT NULL,
+					secret      TEXT NOT NULL,
+					active      BOOLEAN NOT NULL DEFAULT true,
+					created_at  TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				);
+				CREATE TABLE IF NOT EXISTS webhook_deliveries
+				(
+					id               TEXT PRIMARY KEY,
+					subscription_id  TEXT NOT NULL REFERENCES webhook_subscriptions(id),
+					outbox_id        INTEGER NOT NULL REFERENCES outbox(id),
+					event_id         TEXT NOT NULL,
+					event_type       TEXT NOT NULL,
+					status           TEXT NOT NULL,
+					attempt          INT NOT NULL DEFAULT 0,
+					next_attempt_at  TIMESTAMP DEFAULT NULL,
+					last_status_code INT DEFAULT NULL,
+					last_error       TEXT DEFAULT NULL,
+					created_at       TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					delivered_at     TIMESTAMP DEFAULT NULL
+				);
+				CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_idx ON webhook_deliveries (status, next_attempt_at);
+				CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_created_at_idx ON webhook_deliveries (subscription_id, created_at);
+				CREATE INDEX IF NOT EXISTS transactions_to_id_idx ON transactions (to_id);
+				CREATE INDEX IF NOT EXISTS transactions_from_id_idx ON transactions (from_id);
+				CREATE INDEX IF NOT EXISTS transactions_import_id_idx ON transactions (import_id) WHERE import_id IS NOT NULL;
+				CREATE TABLE IF NOT EXISTS idempotency_keys
+				(
+					actor       TEXT NOT NULL,
+					key         TEXT NOT NULL,
+					fingerprint TEXT NOT NULL,
+					status      INT DEFAULT NULL,
+					header      TEXT DEFAULT NULL,
+					body        BLOB DEFAULT NULL,
+					expires_at  TIMESTAMP NOT NULL,
+					PRIMARY KEY (actor, key)
+				);
+				CREATE TABLE IF NOT EXISTS limits
+				(
+					scope            TEXT PRIMARY KEY,
+					max_operation    DECIMAL(10, 2) DEFAULT NULL,
+					daily_debit      DECIMAL(10, 2) DEFAULT NULL,
+					monthly_debit    DECIMAL(10, 2) DEFAULT NULL,
+					hourly_transfers INT DEFAULT NULL
+				);
+
+				CREATE TABLE IF NOT EXISTS audit_log
+				(
+					id              INTEGER PRIMARY KEY AUTOINCREMENT,
+					actor           TEXT NOT NULL,
+					endpoint        TEXT NOT NULL,
+					payload_hash    TEXT NOT NULL,
+					balances_before TEXT NOT NULL,
+					balances_after  TEXT NOT NULL,
+					status          INT NOT NULL,
+					outcome         TEXT NOT NULL,
+					created_at      TIMESTAMP NOT NULL,
+					prev_hash       TEXT NOT NULL,
+					hash            TEXT NOT NULL
+				);
+				CREATE TRIGGER IF NOT EXISTS audit_log_append_only_update BEFORE UPDATE ON audit_log
+				BEGIN
+					SELECT RAISE(ABORT, 'audit_log is append-only');
+				END;
+				CREATE TRIGGER IF NOT EXISTS audit_log_append_only_delete BEFORE DELETE ON audit_log
+				BEGIN
+					SELECT RAISE(ABORT, 'audit_log is append-only');
+				END;
+`
+
+const accountColumns = `id, balance, credit_limit, owner, metadata, status, debits_blocked, credits_blocked, created_at, closed_at`
+
+const addUserSql = `
+				INSERT INTO users (id, balance) VALUES (?1, 0);
+`
+
+const updateUserBalanceSql = `
+				UPDATE users SET balance=ROUND(balance + ?2, 2)
+				WHERE id=?1
+				RETURNING id, balance;
+`
+
+const getUserSql = `
+				SELECT id, balance, credit_limit, ROUND(balance + credit_limit, 2) AS available, held, ROUND(balance + held, 2) AS total
+				FROM (
+					SELECT id, balance, credit_limit, (
+						SELECT ROUND(COALESCE(SUM(amount), 0), 2) FROM reserves
+						WHERE user_id=users.id and status=?2
+					) AS held
+					FROM users
+					WHERE id=?1
+				);
+`
+
+const getOpenReservesSql = `
+				SELECT id, user_id, service_id, order_id, amount, status, actor, created_at, recognized_at FROM reserves
+				WHERE user_id=?1 and status=?2
+				ORDER BY created_at ASC;
+`
+
+const setSchemaVersionSql = `
+				INSERT INTO schema_version (version) VALUES (?1);
+`
+
+const getSchemaVersionSql = `
+				SELECT version FROM schema_version;
+`
+
+const addTransactionsSql = `
+				INSERT INTO transactions (id, to_id, from_id, money, operation, actor, import_id, comment, request_id, created_at)
+				VALUES (?1, ?2, ?3, ROUND(?4, 2), ?5, ?6, ?7, ?8, ?9, ?10);
+`
+
+const getAllTransactionsSql = `
+				SELECT id, to_id, from_id, money, operation, actor, import_id, comment, request_id, created_at FROM transactions
+				WHERE to_id=?1 OR from_id=?1
+				%s
+				LIMIT ?2
+				OFFSET ?3;
+`
+
+const addReserveSql = `
+				INSERT INTO reserves (id, user_id, service_id, order_id, amount, status, actor, created_at)
+				VALUES (?1, ?2, ?3, ?4, ROUND(?5, 2), ?6, ?7, ?8);
+`
+
+// getReserveSql prefers the reserve still holding money when the same order
+// was reserved more than once.
+const getReserveSql = `
+				SELECT id, user_id, service_id, order_id, amount, status, actor, created_at, recognized_at FROM reserves
+				WHERE user_id=?1 and service_id=?2 and order_id=?3 and amount=ROUND(?4, 2)
+				ORDER BY status=?5 DESC
+				LIMIT 1;
+`
+
+const getReserveForReportSql = `
+				SELECT id, user_id, service_id, order_id, amount, status, actor, created_at, recognized_at FROM reserves
+				WHERE status=?1 and CAST(strftime('%Y', recognized_at) AS INT)=?2 and CAST(strftime('%m', recognized_at) AS INT)=?3;
+`
+
+const updateReserveStatus = `
+				UPDATE reserves SET status=?2, recognized_at=?3
+				WHERE id=?1;
+`
+
+const getLastAuditHashSql = `
+				SELECT hash FROM audit_log
+				ORDER BY id DESC
+				LIMIT 1;
+`
+
+const addAuditEntrySql = `
+				INSERT INTO audit_log (actor, endpoint, payload_hash, balances_before, balances_after, status, outcome, created_at, prev_hash, hash)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)
+				RETURNING id;
+`
+
+const getAuditEntriesSql = `
+				SELECT id, actor, endpoint, payload_hash, balances_before, balances_after, status, outcome, created_at, prev_hash, hash FROM audit_log
+				WHERE id > ?1
+				ORDER BY id ASC
+				LIMIT ?2;
+`
+
+const addAccountSql = `
+				INSERT INTO users (id, balance, owner, metadata)
+				VALUES (?1, 0, ?2, ?3)
+				RETURNING ` + accountColumns + `;
+`
+
+const getAccountSql = `
+				SELECT ` + accountColumns + ` FROM users
+				WHERE id=?1;
+`
+
+const updateAccountStatusSql = `
+				UPDATE users SET status=?2, debits_blocked=?3, credits_blocked=?4, closed_at=?5
+				WHERE id=?1
+				RETURNING ` + accountColumns + `;
+`
+
+const countUserReservesSql = `
+				SELECT count(*) FROM reserves
+				WHERE user_id=?1 and status=?2;
+`
+
+const getLimitsSql = `
+				SELECT scope, max_operation, daily_debit, monthly_debit, hourly_transfers FROM limits
+				WHERE scope=?1;
+`
+
+const getEffectiveLimitsSql = `
+				SELECT scope, max_operation, daily_debit, monthly_debit, hourly_transfers FROM limits
+				WHERE scope=?1 OR scope=?2;
+`
+
+const setLimitsSql = `
+				INSERT INTO limits (scope, max_operation, daily_debit, monthly_debit, hourly_transfers)
+				VALUES (?1, ROUND(?2, 2), ROUND(?3, 2), ROUND(?4, 2), ?5)
+				ON CONFLICT (scope) DO UPDATE SET max_operation=ROUND(?2, 2), daily_debit=ROUND(?3, 2), monthly_debit=ROUND(?4, 2),
+					hourly_transfers=?5
+				RETURNING scope, max_operation, daily_debit, monthly_debit, hourly_transfers;
+`
+
+const deleteLimitsSql = `
+				DELETE FROM limits
+				WHERE scope=?1;
+`
+
+// getDebitTotalSql counts the reserves made since ?5 unless they were
+// released, like the one of postgresdb.
+const getDebitTotalSql = `
+				SELECT COALESCE((SELECT SUM(ABS(money)) FROM transactions
+					WHERE from_id=?1 and operation IN (?2, ?3) and created_at >= ?5), 0)
+				+ COALESCE((SELECT SUM(amount) FROM reserves
+					WHERE user_id=?1 and status <> ?4 and created_at >= ?5), 0);
+`
+
+const countRecentTransfersSql = `
+				SELECT count(*) FROM transactions
+				WHERE from_id=?1 and operation=?2 and created_at > ?3;
+`
+
+// getOldestRecentTransferSql reads the time on its own, the driver parses
+// created_at but not an aggregate of it.
+const getOldestRecentTransferSql = `
+				SELECT created_at FROM transactions
+				WHERE from_id=?1 and operation=?2 and created_at > ?3
+				ORDER BY created_at ASC
+				LIMIT 1;
+`
+
+const updateCreditLimitSql = `
+				UPDATE users SET credit_limit=ROUND(?2, 2)
+				WHERE id=?1
+				RETURNING ` + accountColumns + `;
+`
+
+const getAccountsUsingCreditSql = `
+				SELECT ` + accountColumns + ` FROM users
+				WHERE balance < 0
+				ORDER BY balance ASC;
+`
+
+const scheduleColumns = `id, from_id, to_id, money, cron, interval_seconds, next_run_at, slot_at, status, max_retries, attempt, actor, created_at`
+
+const addScheduleSql = `
+				INSERT INTO schedules (id, from_id, to_id, money, cron, interval_seconds, next_run_at, slot_at, status, max_retries, actor, created_at)
+				VALUES (?1, ?2, ?3, ROUND(?4, 2), ?5, ?6, ?7, ?7, ?8, ?9, ?10, ?11)
+				RETURNING ` + scheduleColumns + `;
+`
+
+// getScheduleSql returns the schedule only to its creator ?2, or to anyone
+// when ?2 is empty.
+const getScheduleSql = `
+				SELECT ` + scheduleColumns + ` FROM schedules
+				WHERE id=?1 and (?2='' or actor=?2);
+`
+
+const getDueScheduleSql = `
+				SELECT ` + scheduleColumns + ` FROM schedules
+				WHERE status=?1 and next_run_at <= ?2
+				ORDER BY next_run_at ASC
+				LIMIT 1;
+`
+
+const updateScheduleSql = `
+				UPDATE schedules SET status=?2, next_run_at=?3, slot_at=?4, attempt=?5
+				WHERE id=?1
+				RETURNING ` + scheduleColumns + `;
+`
+
+const addScheduleRunSql = `
+				INSERT INTO schedule_runs (id, schedule_id, scheduled_at, executed_at, attempt, outcome, error)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7);
+`
+
+const getScheduleRunsSql = `
+				SELECT id, schedule_id, scheduled_at, executed_at, attempt, outcome, error FROM schedule_runs
+				WHERE schedule_id=?1
+				ORDER BY executed_at DESC;
+`
+
+const addEventSql = `
+				INSERT INTO outbox (event_id, type, version, account_id, data, created_at)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+				RETURNING id;
+`
+
+const getUnpublishedEventsSql = `
+				SELECT id, event_id, type, version, account_id, data, created_at FROM outbox
+				WHERE published_at IS NULL
+				ORDER BY id ASC
+				LIMIT ?1;
+`
+
+// markEventsPublishedSql is expanded by sqlx.In, which binds with plain
+// question marks.
+const markEventsPublishedSql = `
+				UPDATE outbox SET published_at=?
+				WHERE id IN (?);
+`
+
+const webhookColumns = `id, url, event_types, active, created_at`
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, status, attempt, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+const addWebhookSql = `
+				INSERT INTO webhook_subscriptions (id, url, event_types, secret, created_at)
+				VALUES (?1, ?2, ?3, ?4, ?5)
+				RETURNING ` + webhookColumns + `;
+`
+
+const getWebhookSql = `
+				SELECT ` + webhookColumns + ` FROM webhook_subscriptions
+				WHERE id=?1;
+`
+
+const deactivateWebhookSql = `
+				UPDATE webhook_subscriptions SET active=false
+				WHERE id=?1
+				RETURNING ` + webhookColumns + `;
+`
+
+const getSubscribedWebhooksSql = `
+				SELECT id FROM webhook_subscriptions
+				WHERE active and EXISTS (
+					SELECT 1 FROM json_each(webhook_subscriptions.event_types)
+					WHERE json_each.value IN (?1, ?2)
+				);
+`
+
+const addWebhookDeliverySql = `
+				INSERT INTO webhook_deliveries (id, subscription_id, outbox_id, event_id, event_type, status, next_attempt_at, created_at)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7);
+`
+
+const getWebhookDeliveriesSql = `
+				SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+				WHERE subscription_id=?1 and (?2 = '' or status=?2)
+				ORDER BY created_at DESC
+				LIMIT ?3;
+`
+
+const retryWebhookDeliverySql = `
+				UPDATE webhook_deliveries SET status=?3, attempt=0, next_attempt_at=?5
+				WHERE id=?1 and subscription_id=?2 and status=?4
+				RETURNING ` + webhookDeliveryColumns + `;
+`
+
+const getWebhookDeliveryStatusSql = `
+				SELECT status FROM webhook_deliveries
+				WHERE id=?1 and subscription_id=?2;
+`
+
+const claimWebhookDeliveriesSql = `
+				SELECT d.id, d.subscription_id, d.event_id, d.event_type, d.status, d.attempt, d.created_at,
+					s.url, s.secret, o.id, o.type, o.version, o.account_id, o.data, o.created_at
+				FROM webhook_deliveries d
+				JOIN webhook_subscriptions s ON s.id=d.subscription_id
+				JOIN outbox o ON o.id=d.outbox_id
+				WHERE d.status=?1 and d.next_attempt_at <= ?2 and s.active
+				ORDER BY d.next_attempt_at ASC
+				LIMIT ?3;
+`
+
+// leaseWebhookDeliveriesSql is expanded by sqlx.In.
+const leaseWebhookDeliveriesSql = `
+				UPDATE webhook_deliveries SET next_attempt_at=?
+				WHERE id IN (?);
+`
+
+const finishWebhookDeliverySql = `
+				UPDATE webhook_deliveries
+				SET status=?2, attempt=?3, next_attempt_at=?4, last_status_code=?5, last_error=?6, delivered_at=?7
+				WHERE id=?1;
+`
+
+const startIdempotentRequestSql = `
+				INSERT INTO idempotency_keys (actor, key, fingerprint, expires_at)
+				VALUES (?1, ?2, ?3, ?4)
+				ON CONFLICT (actor, key) DO UPDATE
+				SET fingerprint=excluded.fingerprint, status=NULL, header=NULL, body=NULL, expires_at=excluded.expires_at
+				WHERE idempotency_keys.expires_at < ?5
+				RETURNING actor;
+`
+
+const getIdempotentRequestSql = `
+				SELECT fingerprint, status, header, body FROM idempotency_keys
+				WHERE actor=?1 and key=?2;
+`
+
+const completeIdempotentRequestSql = `
+				UPDATE idempotency_keys SET status=?3, header=?4, body=?5, expires_at=?6
+				WHERE actor=?1 and key=?2;
+`
+
+const releaseIdempotentRequestSql = `
+				DELETE FROM idempotency_keys
+				WHERE actor=?1 and key=?2 and status IS NULL;
+`
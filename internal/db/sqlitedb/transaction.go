@@ -0,0 +1,71 @@
+package sqlitedb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// addTransaction writes a transaction within tx, stamping the actor, the
+// request and the import of ctx on it.
+func (rep *BalanceRepository) addTransaction(ctx context.Context, toId, fromId *string, operation string, money float64, tx *sqlx.Tx) error {
+	var importId, comment *string
+	if note, ok := ctx.Value(importNoteKey{}).(importNote); ok {
+		importId, comment = &note.id, &note.comment
+	}
+
+	var requestId *string
+	if id := logging.RequestId(ctx); id != "" {
+		requestId = &id
+	}
+
+	_, err := tx.ExecContext(ctx, addTransactionsSql, uuid.New().String(), toId, fromId, money, operation, auth.Actor(ctx),
+		importId, comment, requestId, time.Now())
+
+	return err
+}
+
+func (rep *BalanceRepository) GetAllTransactions(ctx context.Context, id string, sortType string, limit int, page int) (*[]models.Transaction, error) {
+	if limit < 0 || page < 0 {
+		return nil, api.ErrorInvalidSortParameters
+	}
+
+	id, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []models.Transaction{}
+
+	offset := (page - 1) * limit
+
+	if err = rep.db.SelectContext(ctx, &transactions, allTransactionsSql(sortType), id, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return &transactions, nil
+}
+
+// allTransactionsSql keeps the order of the table for unknown sort types.
+func allTransactionsSql(sortType string) string {
+	switch strings.ToLower(sortType) {
+	case models.SortDateAsc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY created_at ASC")
+	case models.SortDateDesc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY created_at DESC")
+	case models.SortMoneyAsc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY money ASC")
+	case models.SortMoneyDesc:
+		return fmt.Sprintf(getAllTransactionsSql, "ORDER BY money DESC")
+	default:
+		return fmt.Sprintf(getAllTransactionsSql, "")
+	}
+}
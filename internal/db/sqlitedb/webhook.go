@@ -0,0 +1,197 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const webhookDeliveriesLimit = 100
+
+func webhookError(err error) error {
+	if err == sql.ErrNoRows {
+		return api.ErrorWebhookNotFound
+	}
+
+	return err
+}
+
+func (rep *BalanceRepository) CreateWebhook(ctx context.Context, query models.CreateWebhookQuery) (*models.CreatedWebhook, error) {
+	if err := backend.ValidateWebhook(query); err != nil {
+		return nil, err
+	}
+
+	if query.Secret == "" {
+		secret, err := backend.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+
+		query.Secret = secret
+	}
+
+	var webhook models.WebhookSubscription
+
+	err := rep.db.GetContext(ctx, &webhook, addWebhookSql, uuid.New().String(), query.Url, models.EventTypes(query.EventTypes),
+		query.Secret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedWebhook{WebhookSubscription: webhook, Secret: query.Secret}, nil
+}
+
+func (rep *BalanceRepository) GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	id, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook models.WebhookSubscription
+
+	if err := rep.db.GetContext(ctx, &webhook, getWebhookSql, id); err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &webhook, nil
+}
+
+func (rep *BalanceRepository) DeleteWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	id, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook models.WebhookSubscription
+
+	if err := rep.db.GetContext(ctx, &webhook, deactivateWebhookSql, id); err != nil {
+		return nil, webhookError(err)
+	}
+
+	return &webhook, nil
+}
+
+func (rep *BalanceRepository) GetWebhookDeliveries(ctx context.Context, id, status string) (*[]models.WebhookDelivery, error) {
+	webhook, err := rep.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+
+	if err := rep.db.SelectContext(ctx, &deliveries, getWebhookDeliveriesSql, webhook.Id, status, webhookDeliveriesLimit); err != nil {
+		return nil, err
+	}
+
+	return &deliveries, nil
+}
+
+func (rep *BalanceRepository) RetryWebhookDelivery(ctx context.Context, id, deliveryId string) (*models.WebhookDelivery, error) {
+	id, err := parseId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if deliveryId, err = parseId(deliveryId); err != nil {
+		return nil, err
+	}
+
+	var delivery models.WebhookDelivery
+
+	err = rep.db.GetContext(ctx, &delivery, retryWebhookDeliverySql, deliveryId, id, models.WebhookPending, models.WebhookDead,
+		time.Now())
+	if err == nil {
+		return &delivery, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var status string
+	if err = rep.db.GetContext(ctx, &status, getWebhookDeliveryStatusSql, deliveryId, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, api.ErrorWebhookDeliveryNotFound
+		}
+
+		return nil, err
+	}
+
+	return nil, api.ErrorWebhookDeliveryNotDead
+}
+
+// ClaimWebhookDeliveries leases the deliveries within a transaction, whose
+// write lock keeps concurrent claims apart.
+func (rep *BalanceRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, claimWebhookDeliveriesSql, models.WebhookPending, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	ids := []string{}
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var event models.Event
+		var data string
+
+		err = rows.Scan(&delivery.Id, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType, &delivery.Status,
+			&delivery.Attempt, &delivery.CreatedAt, &delivery.Url, &delivery.Secret, &event.Sequence, &event.Type,
+			&event.Version, &event.AccountId, &data, &event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Id = delivery.EventId
+		event.Data = json.RawMessage(data)
+		delivery.Event = &event
+
+		deliveries = append(deliveries, delivery)
+		ids = append(ids, delivery.Id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	query, args, err := sqlx.In(leaseWebhookDeliveriesSql, time.Now().Add(lease), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (rep *BalanceRepository) FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := rep.db.ExecContext(ctx, finishWebhookDeliverySql, delivery.Id, delivery.Status, delivery.Attempt,
+		delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt)
+
+	return err
+}
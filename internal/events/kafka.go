@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"strconv"
+)
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher writes events to topic keyed by account id, the hash
+// balancer sends all events of an account to the same partition.
+func NewKafkaPublisher(brokers []string, topic string) *kafkaPublisher {
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			MaxAttempts:  1,
+		},
+	}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, event *models.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.AccountId),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "event_id", Value: []byte(event.Id)},
+			{Key: "event_type", Value: []byte(event.Type)},
+			{Key: "event_version", Value: []byte(strconv.Itoa(event.Version))},
+		},
+	})
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
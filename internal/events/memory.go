@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// MemoryPublisher keeps published events in memory, it is meant for tests.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []models.Event
+	err    error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event *models.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	p.events = append(p.events, *event)
+
+	return nil
+}
+
+// Fail makes every following Publish return err, nil restores delivery.
+func (p *MemoryPublisher) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
+
+// Events returns the published events in publishing order.
+func (p *MemoryPublisher) Events() []models.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]models.Event(nil), p.events...)
+}
+
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/nats-io/nats.go"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+// NewNatsPublisher publishes events to JetStream under subject.<account id>,
+// so a stream bound to subject.> stores the events of every account in order.
+// The event id is used as message id, which lets JetStream drop redeliveries.
+func NewNatsPublisher(url, subject string) (*natsPublisher, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &natsPublisher{conn: conn, js: js, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, event *models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.js.Publish(p.subject+"."+event.AccountId, data, nats.MsgId(event.Id), nats.Context(ctx))
+
+	return err
+}
+
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,14 @@
+package events
+
+import (
+	"context"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+// EventPublisher delivers outbox events to a broker. Publish returns only
+// after the broker has accepted the event. Events of one account must be
+// published under the same key, so that brokers keep them in order.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *models.Event) error
+	Close() error
+}
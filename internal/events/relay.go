@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Outbox hands unpublished events to publish in order, see
+// postgresdb.BalanceRepository.RelayEvents.
+type Outbox interface {
+	RelayEvents(ctx context.Context, limit int, publish func(context.Context, *models.Event) error) (int, error)
+}
+
+type relay struct {
+	outbox    Outbox
+	publisher EventPublisher
+	interval  time.Duration
+	batch     int
+	logger    *logrus.Logger
+}
+
+func NewRelay(outbox Outbox, publisher EventPublisher, interval time.Duration, batch int) *relay {
+	return &relay{
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  interval,
+		batch:     batch,
+		logger:    logrus.StandardLogger(),
+	}
+}
+
+// Run publishes the outbox every interval until ctx is cancelled. A full
+// batch means more events are probably waiting, so the next one starts at
+// once. A failed publish is retried with the same event on the next tick.
+func (r *relay) Run(ctx context.Context) {
+	r.logger.Info("starting event relay")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := r.outbox.RelayEvents(ctx, r.batch, r.publisher.Publish)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error(err)
+		}
+
+		if err == nil && published == r.batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("stopping event relay...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
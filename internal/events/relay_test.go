@@ -0,0 +1,180 @@
+package events_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/memorydb"
+	"github.com/siraj18/balance-service-new/internal/events"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// start runs a relay from rep to publisher until the test ends.
+func start(t *testing.T, rep *memorydb.Repository, publisher events.EventPublisher, interval time.Duration, batch int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		events.NewRelay(rep, publisher, interval, batch).Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// deposit writes n events to the outbox of rep.
+func deposit(t *testing.T, rep *memorydb.Repository, n int) {
+	uid := uuid.New().String()
+
+	for i := 0; i < n; i++ {
+		_, err := rep.ChangeBalance(context.Background(), uid, 10)
+		require.NoError(t, err)
+	}
+}
+
+func sequences(published []models.Event) []int64 {
+	var sequences []int64
+	for _, event := range published {
+		sequences = append(sequences, event.Sequence)
+	}
+
+	return sequences
+}
+
+// failingPublisher rejects the event with the given sequence the first time
+// it is published.
+type failingPublisher struct {
+	*events.MemoryPublisher
+	mu       sync.Mutex
+	sequence int64
+	failed   bool
+	attempts []int64
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, event *models.Event) error {
+	p.mu.Lock()
+	p.attempts = append(p.attempts, event.Sequence)
+	fail := event.Sequence == p.sequence && !p.failed
+	p.failed = p.failed || fail
+	p.mu.Unlock()
+
+	if fail {
+		return fmt.Errorf("broker is down")
+	}
+
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func (p *failingPublisher) Attempts() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]int64(nil), p.attempts...)
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	rep := memorydb.NewRepository()
+	publisher := events.NewMemoryPublisher()
+	deposit(t, rep, 5)
+
+	// the interval is never reached, a full batch is followed by the next one
+	start(t, rep, publisher, time.Hour, 2)
+
+	require.Eventually(t, func() bool {
+		return len(publisher.Events()) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, sequences(publisher.Events()))
+}
+
+func TestRelayStopsAtFirstFailure(t *testing.T) {
+	rep := memorydb.NewRepository()
+	publisher := &failingPublisher{MemoryPublisher: events.NewMemoryPublisher(), sequence: 2}
+	deposit(t, rep, 3)
+
+	published, err := rep.RelayEvents(context.Background(), 10, publisher.Publish)
+	assert.Error(t, err)
+	assert.Equal(t, 1, published)
+
+	// the events after the failed one wait for it
+	assert.Equal(t, []int64{1, 2}, publisher.Attempts())
+	assert.Equal(t, []int64{1}, sequences(publisher.Events()))
+
+	published, err = rep.RelayEvents(context.Background(), 10, publisher.Publish)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	assert.Equal(t, []int64{1, 2, 2, 3}, publisher.Attempts())
+	assert.Equal(t, []int64{1, 2, 3}, sequences(publisher.Events()))
+}
+
+func TestRelayRetriesUntilPublished(t *testing.T) {
+	rep := memorydb.NewRepository()
+	publisher := events.NewMemoryPublisher()
+	publisher.Fail(fmt.Errorf("broker is down"))
+	deposit(t, rep, 2)
+
+	start(t, rep, publisher, 10*time.Millisecond, 10)
+
+	// a few failed ticks pass before the broker is back
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, publisher.Events())
+
+	publisher.Fail(nil)
+
+	require.Eventually(t, func() bool {
+		return len(publisher.Events()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deposit(t, rep, 1)
+
+	require.Eventually(t, func() bool {
+		return len(publisher.Events()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// published events are not published again
+	assert.Equal(t, []int64{1, 2, 3}, sequences(publisher.Events()))
+}
+
+func TestRelaySkipsWhileAnotherRuns(t *testing.T) {
+	rep := memorydb.NewRepository()
+	deposit(t, rep, 1)
+
+	publishing := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan int)
+
+	go func() {
+		published, _ := rep.RelayEvents(context.Background(), 10, func(ctx context.Context, event *models.Event) error {
+			close(publishing)
+			<-release
+			return nil
+		})
+		done <- published
+	}()
+
+	<-publishing
+
+	publisher := events.NewMemoryPublisher()
+	published, err := rep.RelayEvents(context.Background(), 10, publisher.Publish)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Empty(t, publisher.Events())
+
+	close(release)
+	assert.Equal(t, 1, <-done)
+
+	// the event was published by the first relay
+	published, err = rep.RelayEvents(context.Background(), 10, publisher.Publish)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+}
@@ -0,0 +1,32 @@
+package grpcapi
+
+import (
+	"errors"
+
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusError maps repository errors to status codes the same way the HTTP
+// handlers map them to status codes: 404 becomes NotFound, 400 InvalidArgument,
+// 409 and the business errors reported with 200 FailedPrecondition and 422
+// ResourceExhausted. Other errors are logged and hidden behind Internal.
+func (s *service) statusError(err error) error {
+	switch {
+	case errors.Is(err, api.ErrorUserNotFound), errors.Is(err, api.ErrorReserveNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, api.ErrorInvalidInput), errors.Is(err, api.ErrorNegativeAmount),
+		errors.Is(err, api.ErrorInvalidSortParameters):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, api.ErrorAccountFrozen), errors.Is(err, api.ErrorAccountClosed),
+		errors.Is(err, api.ErrorNotEnoughMoney), errors.Is(err, api.ErrorReserveAlreadyRecognized),
+		errors.Is(err, api.ErrorReserveAlreadyDeReserved):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, api.ErrorLimitExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		s.logger.Error(err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,174 @@
+package grpcapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/siraj18/balance-service-new/pkg/balancepb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type method struct {
+	scope   string
+	audited bool
+}
+
+// methods lists the scope every balance method requires and whether it is
+// recorded in the audit log, mirroring the HTTP routes.
+var methods = map[string]method{
+	"GetBalance":       {auth.ScopeReadBalance, false},
+	"ChangeBalance":    {auth.ScopeWriteBalance, true},
+	"Transfer":         {auth.ScopeTransfer, true},
+	"ReserveMoney":     {auth.ScopeReserve, true},
+	"RecognizeMoney":   {auth.ScopeReserve, true},
+	"DeReserveMoney":   {auth.ScopeReserve, true},
+	"ListTransactions": {auth.ScopeReadBalance, false},
+	"GetReport":        {auth.ScopeReports, false},
+}
+
+// lookup returns the method of the balance service called by fullMethod.
+// Health checks and reflection are not part of it and stay open.
+func lookup(fullMethod string) (method, bool) {
+	prefix := "/" + balancepb.BalanceService_ServiceDesc.ServiceName + "/"
+	if !strings.HasPrefix(fullMethod, prefix) {
+		return method{}, false
+	}
+
+	m, ok := methods[strings.TrimPrefix(fullMethod, prefix)]
+	if !ok {
+		// a method missing from the table is left to admins
+		m.scope = auth.ScopeAdmin
+	}
+
+	return m, true
+}
+
+// requestId serves every call with the request id of the x-request-id
+// metadata, or a generated one, and echoes it in the response header, like
+// the X-Request-ID header of the HTTP API.
+func requestId(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(logging.RequestIdHeader); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		id = logging.NewRequestId(id)
+
+		grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIdHeader, id))
+
+		return handler(logging.WithRequest(ctx, logger, id), req)
+	}
+}
+
+// authenticate resolves the caller from the request metadata with the
+// authenticator of the HTTP API, so the same X-API-Key and Authorization
+// credentials are accepted.
+func authenticate(authenticator auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := lookup(info.FullMethod); !ok {
+			return handler(ctx, req)
+		}
+
+		identity, err := authenticator.Authenticate(credentials(ctx))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return handler(auth.WithIdentity(ctx, identity), req)
+	}
+}
+
+// authorize rejects calls whose identity lacks the scope of the method.
+func authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	m, ok := lookup(info.FullMethod)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	identity := auth.IdentityFromContext(ctx)
+	if identity == nil || !identity.HasScope(m.scope) {
+		return nil, status.Error(codes.PermissionDenied, api.ErrorForbidden.Error())
+	}
+
+	return handler(ctx, req)
+}
+
+func credentials(ctx context.Context) *http.Request {
+	header := http.Header{}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	return (&http.Request{Header: header}).WithContext(ctx)
+}
+
+// audited audits every mutating call like audit.Middleware. The entry holds
+// the gRPC status code where HTTP requests record their status.
+func audited(log audit.Log, logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if m, ok := lookup(info.FullMethod); !ok || !m.audited {
+			return handler(ctx, req)
+		}
+
+		payload, _ := proto.Marshal(req.(proto.Message))
+
+		source := audit.Source{
+			Endpoint:    "GRPC " + info.FullMethod,
+			PayloadHash: audit.HashPayload(payload),
+			Status:      int(codes.OK),
+		}
+
+		recorder := audit.NewRecorder()
+		res, err := handler(audit.WithRecorder(audit.WithSource(ctx, source), recorder), req)
+
+		if recorder.Committed() {
+			return res, err
+		}
+
+		entry := &models.AuditEntry{
+			Actor:          auth.Actor(ctx),
+			Endpoint:       source.Endpoint,
+			PayloadHash:    source.PayloadHash,
+			BalancesBefore: audit.NoBalances,
+			BalancesAfter:  audit.NoBalances,
+			Status:         int(status.Code(err)),
+			Outcome:        outcome(err),
+			CreatedAt:      time.Now().UTC().Truncate(time.Microsecond),
+		}
+
+		if err := log.AppendAuditEntry(context.Background(), entry); err != nil {
+			logger.Error(err)
+		}
+
+		return res, err
+	}
+}
+
+func outcome(err error) string {
+	switch status.Code(err) {
+	case codes.OK:
+		return audit.OutcomeSuccess
+	case codes.Internal, codes.Unknown:
+		return audit.OutcomeFailed
+	default:
+		return audit.OutcomeRejected + ": " + status.Convert(err).Message()
+	}
+}
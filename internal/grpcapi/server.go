@@ -0,0 +1,126 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/pkg/balancepb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+type server struct {
+	addr          string
+	server        *grpc.Server
+	health        *health.Server
+	logger        *logrus.Logger
+	authenticator auth.Authenticator
+	auditLog      audit.Log
+}
+
+type Option func(*server)
+
+// WithAuthenticator requires every call of the balance service to carry
+// credentials accepted by authenticator and the scope of the method.
+// Without it the service is left open.
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(s *server) {
+		s.authenticator = authenticator
+	}
+}
+
+// WithAuditLog records every mutating call in log.
+func WithAuditLog(log audit.Log) Option {
+	return func(s *server) {
+		s.auditLog = log
+	}
+}
+
+// NewServer serves the balance service backed by rep on addr, together with
+// the standard health and reflection services.
+func NewServer(addr string, rep Repository, opts ...Option) *server {
+	s := &server{
+		addr:   addr,
+		health: health.NewServer(),
+		logger: logrus.StandardLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	interceptors := []grpc.UnaryServerInterceptor{requestId(s.logger)}
+	if s.authenticator != nil {
+		interceptors = append(interceptors, authenticate(s.authenticator))
+	}
+	if s.auditLog != nil {
+		interceptors = append(interceptors, audited(s.auditLog, s.logger))
+	}
+	if s.authenticator != nil {
+		interceptors = append(interceptors, authorize)
+	}
+
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+
+	balancepb.RegisterBalanceServiceServer(s.server, &service{repository: rep, logger: s.logger})
+	healthpb.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+
+	s.health.SetServingStatus(balancepb.BalanceService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return s
+}
+
+// Serve accepts connections on lis until the server is stopped.
+func (s *server) Serve(lis net.Listener) error {
+	err := s.server.Serve(lis)
+	if err == grpc.ErrServerStopped {
+		return nil
+	}
+
+	return err
+}
+
+// Run serves on the address of the server until it is stopped.
+func (s *server) Run() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("starting grpc server on port" + s.addr)
+
+	return s.Serve(lis)
+}
+
+// Stop reports the server as not serving to health checks and waits for the
+// calls in flight to finish.
+func (s *server) Stop() {
+	s.logger.Info("stopping grpc server...")
+
+	s.health.Shutdown()
+	s.server.GracefulStop()
+}
+
+// Shutdown stops the server like Stop, but cancels the calls still in flight
+// when ctx expires.
+func (s *server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,274 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/grpcapi"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/siraj18/balance-service-new/pkg/balancepb"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const userId = "f0812ab6-9993-11ec-b909-0242ac120002"
+
+type memoryAuditLog struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (l *memoryAuditLog) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, *entry)
+
+	return nil
+}
+
+type grpcSuite struct {
+	suite.Suite
+	rep      *mocks.MockRepository
+	auditLog *memoryAuditLog
+	conn     *grpc.ClientConn
+	client   balancepb.BalanceServiceClient
+	stop     func()
+}
+
+func TestGrpcSuite(t *testing.T) {
+	suite.Run(t, new(grpcSuite))
+}
+
+func (t *grpcSuite) SetupTest() {
+	t.rep = mocks.NewMockRepository()
+	t.auditLog = &memoryAuditLog{}
+
+	t.serve(grpcapi.WithAuditLog(t.auditLog))
+}
+
+func (t *grpcSuite) TearDownTest() {
+	t.conn.Close()
+	t.stop()
+}
+
+// serve restarts the server with opts on an in-memory listener.
+func (t *grpcSuite) serve(opts ...grpcapi.Option) {
+	if t.conn != nil {
+		t.TearDownTest()
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpcapi.NewServer("", t.rep, opts...)
+	go server.Serve(lis)
+	t.stop = server.Stop
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	t.Require().NoError(err)
+
+	t.conn = conn
+	t.client = balancepb.NewBalanceServiceClient(conn)
+}
+
+func (t *grpcSuite) Test_getBalance() {
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId, Balance: 100, Available: 100, Total: 120, Held: 20}, nil)
+
+	res, err := t.client.GetBalance(context.Background(), &balancepb.GetBalanceRequest{UserId: userId})
+	t.Require().NoError(err)
+
+	t.Equal(userId, res.UserId)
+	t.Equal(100.0, res.Balance)
+	t.Equal(20.0, res.Held)
+	t.Equal(120.0, res.Total)
+}
+
+func (t *grpcSuite) Test_requestIdEchoed() {
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId}, nil)
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "balance-1")
+	_, err := t.client.GetBalance(ctx, &balancepb.GetBalanceRequest{UserId: userId}, grpc.Header(&header))
+	t.Require().NoError(err)
+	t.Equal([]string{"balance-1"}, header.Get("x-request-id"))
+
+	_, err = t.client.GetBalance(context.Background(), &balancepb.GetBalanceRequest{UserId: userId}, grpc.Header(&header))
+	t.Require().NoError(err)
+	t.Len(header.Get("x-request-id"), 1)
+	t.NotEqual("balance-1", header.Get("x-request-id")[0])
+}
+
+func (t *grpcSuite) Test_errorMapping() {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{api.ErrorUserNotFound, codes.NotFound},
+		{api.ErrorInvalidInput, codes.InvalidArgument},
+		{api.ErrorNegativeAmount, codes.InvalidArgument},
+		{api.ErrorNotEnoughMoney, codes.FailedPrecondition},
+		{api.ErrorAccountFrozen, codes.FailedPrecondition},
+		{api.ErrorLimitExceeded, codes.ResourceExhausted},
+		{context.DeadlineExceeded, codes.Internal},
+	}
+
+	for _, c := range cases {
+		t.rep.ExpectedCalls = nil
+		t.rep.On("TransferBalance", userId, "other", 10.0).Return(c.err)
+
+		_, err := t.client.Transfer(context.Background(), &balancepb.TransferRequest{FromId: userId, ToId: "other", Amount: 10})
+
+		t.Equal(c.code, status.Code(err), c.err.Error())
+	}
+}
+
+func (t *grpcSuite) Test_reserve() {
+	t.rep.On("ReserveMoney", userId, "service", "order", 20.0).Return(nil)
+	t.rep.On("RecognizedMoney", userId, "service", "order", 20.0).Return(api.ErrorReserveAlreadyRecognized)
+	t.rep.On("DeReserveMoney", userId, "service", "order", 20.0).Return(api.ErrorReserveNotFound)
+
+	req := &balancepb.ReserveRequest{UserId: userId, ServiceId: "service", OrderId: "order", Amount: 20}
+
+	_, err := t.client.ReserveMoney(context.Background(), req)
+	t.NoError(err)
+
+	_, err = t.client.RecognizeMoney(context.Background(), req)
+	t.Equal(codes.FailedPrecondition, status.Code(err))
+
+	_, err = t.client.DeReserveMoney(context.Background(), req)
+	t.Equal(codes.NotFound, status.Code(err))
+}
+
+func (t *grpcSuite) Test_listTransactions() {
+	created := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	t.rep.On("GetAllTransactions", userId, "date_desc", 10, 1).Return(&[]models.Transaction{
+		{Id: "1", ToId: &[]string{userId}[0], Money: 100, Operation: "deposit", Actor: "billing", CreatedAt: created},
+	}, nil)
+
+	res, err := t.client.ListTransactions(context.Background(),
+		&balancepb.ListTransactionsRequest{UserId: userId, SortType: "date_desc", Limit: 10, Page: 1})
+	t.Require().NoError(err)
+
+	t.Require().Len(res.Transactions, 1)
+	t.Equal(userId, res.Transactions[0].ToId)
+	t.Equal("", res.Transactions[0].FromId)
+	t.Equal("billing", res.Transactions[0].Actor)
+	t.True(created.Equal(res.Transactions[0].CreatedAt.AsTime()))
+}
+
+func (t *grpcSuite) Test_getReport() {
+	t.rep.On("GetReserves", 2022, 10).Return(&[]models.Reserve{
+		{ServiceId: "b", Amount: 10},
+		{ServiceId: "a", Amount: 5},
+		{ServiceId: "b", Amount: 15},
+	}, nil)
+
+	res, err := t.client.GetReport(context.Background(), &balancepb.GetReportRequest{Year: 2022, Month: 10})
+	t.Require().NoError(err)
+
+	t.Require().Len(res.Services, 2)
+	t.Equal("a", res.Services[0].ServiceId)
+	t.Equal(5.0, res.Services[0].Amount)
+	t.Equal("b", res.Services[1].ServiceId)
+	t.Equal(25.0, res.Services[1].Amount)
+}
+
+func (t *grpcSuite) Test_auditsMutations() {
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId}, nil)
+	t.rep.On("ChangeBalance", userId, -500.0).Return(nil, api.ErrorNotEnoughMoney)
+
+	_, err := t.client.GetBalance(context.Background(), &balancepb.GetBalanceRequest{UserId: userId})
+	t.Require().NoError(err)
+	_, err = t.client.ChangeBalance(context.Background(), &balancepb.ChangeBalanceRequest{UserId: userId, Amount: -500})
+	t.Require().Error(err)
+
+	t.Require().Len(t.auditLog.entries, 1)
+	entry := t.auditLog.entries[0]
+	t.Equal("GRPC /balance.v1.BalanceService/ChangeBalance", entry.Endpoint)
+	t.Equal("anonymous", entry.Actor)
+	t.Equal(int(codes.FailedPrecondition), entry.Status)
+	t.Equal("rejected: not enough money", entry.Outcome)
+}
+
+func (t *grpcSuite) Test_auth() {
+	t.serve(
+		grpcapi.WithAuditLog(t.auditLog),
+		grpcapi.WithAuthenticator(auth.NewApiKeyAuthenticator([]auth.ApiKey{
+			{Key: "reader-key", Subject: "reader", Scopes: []string{auth.ScopeReadBalance}},
+		})),
+	)
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId}, nil)
+
+	_, err := t.client.GetBalance(context.Background(), &balancepb.GetBalanceRequest{UserId: userId})
+	t.Equal(codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong")
+	_, err = t.client.GetBalance(ctx, &balancepb.GetBalanceRequest{UserId: userId})
+	t.Equal(codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "reader-key")
+	_, err = t.client.GetBalance(ctx, &balancepb.GetBalanceRequest{UserId: userId})
+	t.NoError(err)
+
+	_, err = t.client.Transfer(ctx, &balancepb.TransferRequest{FromId: userId, ToId: "other", Amount: 1})
+	t.Equal(codes.PermissionDenied, status.Code(err))
+	t.rep.AssertNotCalled(t.T(), "TransferBalance", userId, "other", 1.0)
+
+	t.Require().Len(t.auditLog.entries, 1)
+	t.Equal("reader", t.auditLog.entries[0].Actor)
+	t.Equal(int(codes.PermissionDenied), t.auditLog.entries[0].Status)
+
+	// health checks stay open
+	health, err := healthpb.NewHealthClient(t.conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	t.Require().NoError(err)
+	t.Equal(healthpb.HealthCheckResponse_SERVING, health.Status)
+}
+
+func (t *grpcSuite) Test_health() {
+	client := healthpb.NewHealthClient(t.conn)
+
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "balance.v1.BalanceService"})
+	t.Require().NoError(err)
+	t.Equal(healthpb.HealthCheckResponse_SERVING, res.Status)
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	t.Equal(codes.NotFound, status.Code(err))
+}
+
+func (t *grpcSuite) Test_reflection() {
+	stream, err := reflectionpb.NewServerReflectionClient(t.conn).ServerReflectionInfo(context.Background())
+	t.Require().NoError(err)
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	t.Require().NoError(err)
+
+	res, err := stream.Recv()
+	t.Require().NoError(err)
+
+	var services []string
+	for _, service := range res.GetListServicesResponse().Service {
+		services = append(services, service.Name)
+	}
+
+	t.Contains(services, "balance.v1.BalanceService")
+	t.Contains(services, "grpc.health.v1.Health")
+}
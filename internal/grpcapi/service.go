@@ -0,0 +1,148 @@
+package grpcapi
+
+import (
+	"context"
+	"sort"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/balancepb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Repository is the part of the handlers repository served over gRPC.
+type Repository interface {
+	GetBalance(context.Context, string) (*models.User, error)
+	ChangeBalance(context.Context, string, float64) (*models.User, error)
+	TransferBalance(context.Context, string, string, float64) error
+	GetAllTransactions(context.Context, string, string, int, int) (*[]models.Transaction, error)
+	ReserveMoney(context.Context, string, string, string, float64) error
+	RecognizedMoney(context.Context, string, string, string, float64) error
+	DeReserveMoney(context.Context, string, string, string, float64) error
+	GetReserves(context.Context, int, int) (*[]models.Reserve, error)
+}
+
+type service struct {
+	balancepb.UnimplementedBalanceServiceServer
+
+	repository Repository
+	logger     *logrus.Logger
+}
+
+func (s *service) GetBalance(ctx context.Context, req *balancepb.GetBalanceRequest) (*balancepb.Balance, error) {
+	user, err := s.repository.GetBalance(ctx, req.UserId)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return balance(user), nil
+}
+
+func (s *service) ChangeBalance(ctx context.Context, req *balancepb.ChangeBalanceRequest) (*balancepb.Balance, error) {
+	user, err := s.repository.ChangeBalance(ctx, req.UserId, req.Amount)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return balance(user), nil
+}
+
+func (s *service) Transfer(ctx context.Context, req *balancepb.TransferRequest) (*balancepb.TransferResponse, error) {
+	if err := s.repository.TransferBalance(ctx, req.FromId, req.ToId, req.Amount); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return &balancepb.TransferResponse{}, nil
+}
+
+func (s *service) ReserveMoney(ctx context.Context, req *balancepb.ReserveRequest) (*balancepb.ReserveResponse, error) {
+	if err := s.repository.ReserveMoney(ctx, req.UserId, req.ServiceId, req.OrderId, req.Amount); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return &balancepb.ReserveResponse{}, nil
+}
+
+func (s *service) RecognizeMoney(ctx context.Context, req *balancepb.ReserveRequest) (*balancepb.ReserveResponse, error) {
+	if err := s.repository.RecognizedMoney(ctx, req.UserId, req.ServiceId, req.OrderId, req.Amount); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return &balancepb.ReserveResponse{}, nil
+}
+
+func (s *service) DeReserveMoney(ctx context.Context, req *balancepb.ReserveRequest) (*balancepb.ReserveResponse, error) {
+	if err := s.repository.DeReserveMoney(ctx, req.UserId, req.ServiceId, req.OrderId, req.Amount); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return &balancepb.ReserveResponse{}, nil
+}
+
+func (s *service) ListTransactions(ctx context.Context, req *balancepb.ListTransactionsRequest) (*balancepb.ListTransactionsResponse, error) {
+	transactions, err := s.repository.GetAllTransactions(ctx, req.UserId, req.SortType, int(req.Limit), int(req.Page))
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	res := &balancepb.ListTransactionsResponse{Transactions: make([]*balancepb.Transaction, 0, len(*transactions))}
+	for _, t := range *transactions {
+		res.Transactions = append(res.Transactions, &balancepb.Transaction{
+			Id:        t.Id,
+			ToId:      value(t.ToId),
+			FromId:    value(t.FromId),
+			Money:     t.Money,
+			Operation: t.Operation,
+			Actor:     t.Actor,
+			ImportId:  value(t.ImportId),
+			Comment:   value(t.Comment),
+			CreatedAt: timestamppb.New(t.CreatedAt),
+			RequestId: value(t.RequestId),
+		})
+	}
+
+	return res, nil
+}
+
+// GetReport returns the sums the HTTP API writes to the csv report.
+func (s *service) GetReport(ctx context.Context, req *balancepb.GetReportRequest) (*balancepb.GetReportResponse, error) {
+	reserves, err := s.repository.GetReserves(ctx, int(req.Year), int(req.Month))
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	revenue := make(map[string]float64)
+	for _, reserve := range *reserves {
+		revenue[reserve.ServiceId] += reserve.Amount
+	}
+
+	res := &balancepb.GetReportResponse{Services: make([]*balancepb.ServiceRevenue, 0, len(revenue))}
+	for serviceId, amount := range revenue {
+		res.Services = append(res.Services, &balancepb.ServiceRevenue{ServiceId: serviceId, Amount: amount})
+	}
+
+	sort.Slice(res.Services, func(i, j int) bool {
+		return res.Services[i].ServiceId < res.Services[j].ServiceId
+	})
+
+	return res, nil
+}
+
+func balance(user *models.User) *balancepb.Balance {
+	return &balancepb.Balance{
+		UserId:      user.Id,
+		Balance:     user.Balance,
+		CreditLimit: user.CreditLimit,
+		Available:   user.Available,
+		Held:        user.Held,
+		Total:       user.Total,
+	}
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+)
+
+func (handler *handler) writeAccount(w http.ResponseWriter, r *http.Request, account *models.Account, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrorUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, api.ErrorInvalidInput), errors.Is(err, api.ErrorNegativeAmount):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, api.ErrorAccountAlreadyExists),
+			errors.Is(err, api.ErrorAccountClosed),
+			errors.Is(err, api.ErrorAccountNotEmpty),
+			errors.Is(err, api.ErrorAccountHasReserves),
+			errors.Is(err, api.ErrorCreditLimitInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			logging.FromContext(r.Context()).Error(err)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(account)
+}
+
+// CreateAccount godoc
+// @Summary      Create account
+// @Description  create an empty account with owner metadata, the id is generated when omitted
+// @Tags         accounts
+// @Accept       json
+// @Produce      json
+// @Param   account   body    models.CreateAccountQuery  true  "Account"
+// @Success 200 {object} models.Account
+// @Failure      400  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /accounts [post]
+func (handler *handler) createAccount(w http.ResponseWriter, r *http.Request) {
+	var postData models.CreateAccountQuery
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil || postData.Owner == "" {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	account, err := handler.repository.CreateAccount(r.Context(), postData.Id, postData.Owner, postData.Metadata)
+	handler.writeAccount(w, r, account, err)
+}
+
+// GetAccount godoc
+// @Summary      Get account
+// @Description  get account with its status by UID
+// @Tags         accounts
+// @Produce      json
+// @Param   uid   path    string  true  "User account ID" default(34be95d0-9a41-11ec-b909-0242ac120003)
+// @Success 200 {object} models.Account
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /accounts/{uid} [get]
+func (handler *handler) getAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := handler.repository.GetAccount(r.Context(), chi.URLParam(r, "uid"))
+	handler.writeAccount(w, r, account, err)
+}
+
+// FreezeAccount godoc
+// @Summary      Freeze account
+// @Description  block debits, credits or both (when neither is set) on the account
+// @Tags         accounts
+// @Accept       json
+// @Produce      json
+// @Param   uid   path    string  true  "User account ID" default(34be95d0-9a41-11ec-b909-0242ac120003)
+// @Param   freeze   body    models.FreezeAccountQuery  true  "Blocked operations"
+// @Success 200 {object} models.Account
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /accounts/{uid}/freeze [post]
+func (handler *handler) freezeAccount(w http.ResponseWriter, r *http.Request) {
+	var postData models.FreezeAccountQuery
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	account, err := handler.repository.FreezeAccount(r.Context(), chi.URLParam(r, "uid"), postData.Debits, postData.Credits)
+	handler.writeAccount(w, r, account, err)
+}
+
+// UnfreezeAccount godoc
+// @Summary      Unfreeze account
+// @Description  allow all operations on the account again
+// @Tags         accounts
+// @Produce      json
+// @Param   uid   path    string  true  "User account ID" default(34be95d0-9a41-11ec-b909-0242ac120003)
+// @Success 200 {object} models.Account
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /accounts/{uid}/unfreeze [post]
+func (handler *handler) unfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := handler.repository.UnfreezeAccount(r.Context(), chi.URLParam(r, "uid"))
+	handler.writeAccount(w, r, account, err)
+}
+
+// CloseAccount godoc
+// @Summary      Close account
+// @Description  close the account, only possible at zero balance without open reserves
+// @Tags         accounts
+// @Produce      json
+// @Param   uid   path    string  true  "User account ID" default(34be95d0-9a41-11ec-b909-0242ac120003)
+// @Success 200 {object} models.Account
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /accounts/{uid}/close [post]
+func (handler *handler) closeAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := handler.repository.CloseAccount(r.Context(), chi.URLParam(r, "uid"))
+	handler.writeAccount(w, r, account, err)
+}
+
+// SetCreditLimit godoc
+// @Summary      Set account credit limit
+// @Description  let the account balance go negative down to minus the credit limit, the limit cannot be lowered below the credit in use
+// @Tags         accounts
+// @Accept       json
+// @Produce      json
+// @Param   uid   path    string  true  "User account ID" default(34be95d0-9a41-11ec-b909-0242ac120003)
+// @Param   limit   body    models.SetCreditLimitQuery  true  "Credit limit"
+// @Success 200 {object} models.Account
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /accounts/{uid}/creditLimit [put]
+func (handler *handler) setCreditLimit(w http.ResponseWriter, r *http.Request) {
+	var postData models.SetCreditLimitQuery
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	account, err := handler.repository.SetCreditLimit(r.Context(), chi.URLParam(r, "uid"), postData.CreditLimit)
+	handler.writeAccount(w, r, account, err)
+}
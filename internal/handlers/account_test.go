@@ -0,0 +1,224 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (t *handlerSuite) post(rep *mocks.MockRepository, path string, data interface{}) *http.Response {
+	h := handlers.NewHandler(rep)
+
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	body, err := json.Marshal(data)
+	t.Nil(err)
+
+	resp, err := testSrv.Client().Post(testSrv.URL+path, "application/json", bytes.NewReader(body))
+	t.Nil(err)
+
+	return resp
+}
+
+func (t *handlerSuite) Test_createAccountSuccess() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	metadata := models.Metadata{"contract": "B2B-1"}
+
+	rep := mocks.NewMockRepository()
+	rep.On("CreateAccount", userId, "ACME", metadata).Return(&models.Account{
+		Id:       userId,
+		Owner:    "ACME",
+		Metadata: metadata,
+		Status:   "active",
+	}, nil)
+
+	resp := t.post(rep, "/accounts", map[string]interface{}{"id": userId, "owner": "ACME", "metadata": metadata})
+	defer resp.Body.Close()
+
+	account := models.Account{}
+	json.NewDecoder(resp.Body).Decode(&account)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal(userId, account.Id)
+	t.Equal("active", account.Status)
+	t.Equal(metadata, account.Metadata)
+}
+
+func (t *handlerSuite) Test_createAccountWithoutOwner() {
+	rep := mocks.NewMockRepository()
+
+	resp := t.post(rep, "/accounts", map[string]interface{}{"id": "f0812ab6-9993-11ec-b909-0242ac120002"})
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_createAccountAlreadyExists() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("CreateAccount", userId, "ACME", models.Metadata(nil)).Return(nil, api.ErrorAccountAlreadyExists)
+
+	resp := t.post(rep, "/accounts", map[string]interface{}{"id": userId, "owner": "ACME"})
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_getAccountNotFound() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetAccount", userId).Return(nil, api.ErrorUserNotFound)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/accounts/" + userId)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_freezeAccountSuccess() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("FreezeAccount", userId, true, false).Return(&models.Account{
+		Id:            userId,
+		Status:        "frozen",
+		DebitsBlocked: true,
+	}, nil)
+
+	resp := t.post(rep, "/accounts/"+userId+"/freeze", map[string]interface{}{"debits": true})
+	defer resp.Body.Close()
+
+	account := models.Account{}
+	json.NewDecoder(resp.Body).Decode(&account)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("frozen", account.Status)
+	t.True(account.DebitsBlocked)
+	t.False(account.CreditsBlocked)
+}
+
+func (t *handlerSuite) Test_closeAccountNotEmpty() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("CloseAccount", userId).Return(nil, api.ErrorAccountNotEmpty)
+
+	resp := t.post(rep, "/accounts/"+userId+"/close", nil)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_withdrawFromFrozenAccount() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("ChangeBalance", userId, -10.0).Return(nil, api.ErrorAccountFrozen)
+
+	resp := t.post(rep, "/changeBalance", map[string]interface{}{"id": userId, "money": -10.0})
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_transferToClosedAccount() {
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120003"
+
+	rep := mocks.NewMockRepository()
+	rep.On("TransferBalance", fromId, toId, 10.0).Return(api.ErrorAccountClosed)
+
+	resp := t.post(rep, "/transferBalance", map[string]interface{}{"from_id": fromId, "to_id": toId, "money": 10.0})
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_setCreditLimitSuccess() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("SetCreditLimit", userId, 500.0).Return(&models.Account{
+		Id:          userId,
+		Balance:     -100,
+		CreditLimit: 500,
+	}, nil)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	req, err := http.NewRequest("PUT", testSrv.URL+"/accounts/"+userId+"/creditLimit", bytes.NewReader([]byte(`{"credit_limit": 500}`)))
+	t.Nil(err)
+
+	resp, err := testSrv.Client().Do(req)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	account := models.Account{}
+	json.NewDecoder(resp.Body).Decode(&account)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal(500.0, account.CreditLimit)
+}
+
+func (t *handlerSuite) Test_setCreditLimitInUse() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("SetCreditLimit", userId, 50.0).Return(nil, api.ErrorCreditLimitInUse)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	req, err := http.NewRequest("PUT", testSrv.URL+"/accounts/"+userId+"/creditLimit", bytes.NewReader([]byte(`{"credit_limit": 50}`)))
+	t.Nil(err)
+
+	resp, err := testSrv.Client().Do(req)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_getBalanceWithCredit() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetBalance", userId).Return(&models.User{
+		Id:          userId,
+		Balance:     -100,
+		CreditLimit: 500,
+		Available:   400,
+	}, nil)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/balance/" + userId)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	u := models.User{}
+	json.NewDecoder(resp.Body).Decode(&u)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal(-100.0, u.Balance)
+	t.Equal(400.0, u.Available)
+}
@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/db/memorydb"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type memoryAuditLog struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (l *memoryAuditLog) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) > 0 {
+		entry.PrevHash = l.entries[len(l.entries)-1].Hash
+	}
+	entry.Hash = audit.Hash(entry.PrevHash, entry)
+	l.entries = append(l.entries, *entry)
+
+	return nil
+}
+
+type auditSuite struct {
+	suite.Suite
+	rep    *mocks.MockRepository
+	log    *memoryAuditLog
+	server *httptest.Server
+}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, new(auditSuite))
+}
+
+func (t *auditSuite) SetupTest() {
+	t.rep = mocks.NewMockRepository()
+	t.log = &memoryAuditLog{}
+
+	h := handlers.NewHandler(t.rep, handlers.WithAuditLog(t.log))
+	t.server = httptest.NewServer(h.InitRoutes())
+}
+
+func (t *auditSuite) TearDownTest() {
+	t.server.Close()
+}
+
+func (t *auditSuite) post(path string, data map[string]interface{}) []byte {
+	body, err := json.Marshal(data)
+	t.Require().NoError(err)
+
+	resp, err := t.server.Client().Post(t.server.URL+path, "application/json", bytes.NewReader(body))
+	t.Require().NoError(err)
+	resp.Body.Close()
+
+	return body
+}
+
+func (t *auditSuite) Test_changeBalanceRecorded() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	t.rep.On("ChangeBalance", userId, 50.0).Return(&models.User{Id: userId, Balance: 50}, nil)
+
+	body := t.post("/changeBalance", map[string]interface{}{"id": userId, "money": 50.0})
+
+	t.Require().Len(t.log.entries, 1)
+	entry := t.log.entries[0]
+	t.Equal("POST /changeBalance", entry.Endpoint)
+	t.Equal("anonymous", entry.Actor)
+	t.Equal(audit.HashPayload(body), entry.PayloadHash)
+	t.Equal(http.StatusOK, entry.Status)
+	t.Equal(audit.OutcomeSuccess, entry.Outcome)
+}
+
+func (t *auditSuite) Test_rejectedOperationRecorded() {
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120003"
+	t.rep.On("TransferBalance", fromId, toId, 10.0).Return(api.ErrorNotEnoughMoney)
+
+	t.post("/transferBalance", map[string]interface{}{"from_id": fromId, "to_id": toId, "money": 10.0})
+
+	t.Require().Len(t.log.entries, 1)
+	t.Equal(audit.OutcomeRejected+": "+api.ErrorNotEnoughMoney.Error(), t.log.entries[0].Outcome)
+}
+
+func (t *auditSuite) Test_readsNotRecorded() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId}, nil)
+
+	resp, err := t.server.Client().Get(t.server.URL + "/balance/" + userId)
+	t.Require().NoError(err)
+	resp.Body.Close()
+
+	t.Empty(t.log.entries)
+}
+
+func (t *auditSuite) Test_entriesChained() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	t.rep.On("ChangeBalance", userId, 50.0).Return(&models.User{Id: userId, Balance: 50}, nil)
+
+	t.post("/changeBalance", map[string]interface{}{"id": userId, "money": 50.0})
+	t.post("/changeBalance", map[string]interface{}{"id": userId, "money": 50.0})
+
+	t.Require().Len(t.log.entries, 2)
+	t.Equal(t.log.entries[0].Hash, t.log.entries[1].PrevHash)
+
+	tampered := t.log.entries[0]
+	tampered.Outcome = audit.OutcomeFailed
+	t.NotEqual(t.log.entries[0].Hash, audit.Hash(tampered.PrevHash, &tampered))
+}
+
+// TestAuditAppendedWithChange checks that the repository appends the entry of
+// a change in its transaction and the middleware does not append another.
+func TestAuditAppendedWithChange(t *testing.T) {
+	rep := memorydb.NewRepository()
+	server := httptest.NewServer(handlers.NewHandler(rep, handlers.WithAuditLog(rep)).InitRoutes())
+	defer server.Close()
+
+	post := func(path string, data map[string]interface{}) {
+		body, err := json.Marshal(data)
+		require.NoError(t, err)
+
+		resp, err := server.Client().Post(server.URL+path, "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	post("/changeBalance", map[string]interface{}{"id": userId, "money": 50.0})
+
+	count, err := rep.VerifyAuditLog(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// a rejected change is still audited, by the middleware
+	post("/changeBalance", map[string]interface{}{"id": userId, "money": -100.0})
+
+	count, err = rep.VerifyAuditLog(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
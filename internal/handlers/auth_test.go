@@ -0,0 +1,181 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type authSuite struct {
+	suite.Suite
+	rep    *mocks.MockRepository
+	server *httptest.Server
+	secret []byte
+}
+
+func TestAuthSuite(t *testing.T) {
+	suite.Run(t, new(authSuite))
+}
+
+func (t *authSuite) SetupTest() {
+	t.secret = []byte("secret")
+	t.rep = mocks.NewMockRepository()
+
+	authenticator := auth.NewChain(
+		auth.NewApiKeyAuthenticator([]auth.ApiKey{
+			{Key: "reader-key", Subject: "reader", Scopes: []string{auth.ScopeReadBalance}},
+		}),
+		auth.NewJwtAuthenticator(t.secret, nil, "https://issuer.example.com", "balance-service"),
+	)
+
+	h := handlers.NewHandler(t.rep, handlers.WithAuthenticator(authenticator))
+	t.server = httptest.NewServer(h.InitRoutes())
+}
+
+func (t *authSuite) TearDownTest() {
+	t.server.Close()
+}
+
+func (t *authSuite) token(subject, scope string) string {
+	return t.sign(jwt.MapClaims{
+		"sub":   subject,
+		"scope": scope,
+		"iss":   "https://issuer.example.com",
+		"aud":   "balance-service",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+}
+
+func (t *authSuite) sign(claims jwt.MapClaims) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+	t.Require().NoError(err)
+
+	return signed
+}
+
+func (t *authSuite) get(path string, header, value string) *http.Response {
+	req, err := http.NewRequest("GET", t.server.URL+path, nil)
+	t.Require().NoError(err)
+
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+
+	resp, err := t.server.Client().Do(req)
+	t.Require().NoError(err)
+
+	return resp
+}
+
+func (t *authSuite) Test_noCredentials() {
+	resp := t.get("/balance/f0812ab6-9993-11ec-b909-0242ac120002", "", "")
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (t *authSuite) Test_invalidApiKey() {
+	resp := t.get("/balance/f0812ab6-9993-11ec-b909-0242ac120002", "X-API-Key", "wrong")
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (t *authSuite) Test_apiKeySuccess() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId}, nil)
+
+	resp := t.get("/balance/"+userId, "X-API-Key", "reader-key")
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func (t *authSuite) Test_apiKeyMissingScope() {
+	req, err := http.NewRequest("POST", t.server.URL+"/changeBalance", nil)
+	t.Require().NoError(err)
+	req.Header.Set("X-API-Key", "reader-key")
+
+	resp, err := t.server.Client().Do(req)
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusForbidden, resp.StatusCode)
+}
+
+func (t *authSuite) Test_jwtSuccess() {
+	resp := t.get("/reports/unknown", "Authorization", "Bearer "+t.token("billing", auth.ScopeReports))
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (t *authSuite) Test_jwtMissingScope() {
+	resp := t.get("/reports/unknown", "Authorization", "Bearer "+t.token("billing", auth.ScopeReadBalance))
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusForbidden, resp.StatusCode)
+}
+
+func (t *authSuite) Test_jwtInvalidSignature() {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "billing", "scope": auth.ScopeReports})
+	signed, err := token.SignedString([]byte("other"))
+	t.Require().NoError(err)
+
+	resp := t.get("/reports/unknown", "Authorization", "Bearer "+signed)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (t *authSuite) Test_jwtWithoutExpiry() {
+	token := t.sign(jwt.MapClaims{
+		"sub":   "billing",
+		"scope": auth.ScopeReports,
+		"iss":   "https://issuer.example.com",
+		"aud":   "balance-service",
+	})
+
+	resp := t.get("/reports/unknown", "Authorization", "Bearer "+token)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (t *authSuite) Test_jwtWrongAudience() {
+	token := t.sign(jwt.MapClaims{
+		"sub":   "billing",
+		"scope": auth.ScopeReports,
+		"iss":   "https://issuer.example.com",
+		"aud":   "other-service",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+
+	resp := t.get("/reports/unknown", "Authorization", "Bearer "+token)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (t *authSuite) Test_batchMissingOperationScope() {
+	body := `{"operations": [{"type": "transfer", "from_id": "f0812ab6-9993-11ec-b909-0242ac120002", "to_id": "f0812ab6-9993-11ec-b909-0242ac120003", "money": 10}]}`
+
+	req, err := http.NewRequest("POST", t.server.URL+"/batch", strings.NewReader(body))
+	t.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+t.token("billing", auth.ScopeWriteBalance))
+
+	resp, err := t.server.Client().Do(req)
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+)
+
+const maxBatchSize = 500
+
+var batchScopes = map[string]string{
+	models.BatchDeposit:  auth.ScopeWriteBalance,
+	models.BatchWithdraw: auth.ScopeWriteBalance,
+	models.BatchTransfer: auth.ScopeTransfer,
+	models.BatchReserve:  auth.ScopeReserve,
+}
+
+func validUuid(id string) bool {
+	_, err := uuid.Parse(id)
+
+	return err == nil
+}
+
+func validateBatchOperation(operation models.BatchOperation) error {
+	if operation.Money <= 0 {
+		return fmt.Errorf("money must be positive")
+	}
+
+	switch operation.Type {
+	case models.BatchDeposit, models.BatchWithdraw:
+		if !validUuid(operation.Id) {
+			return fmt.Errorf("invalid id")
+		}
+	case models.BatchTransfer:
+		if !validUuid(operation.FromId) || !validUuid(operation.ToId) {
+			return fmt.Errorf("invalid from_id or to_id")
+		}
+
+		if operation.FromId == operation.ToId {
+			return fmt.Errorf("from_id and to_id are the same")
+		}
+	case models.BatchReserve:
+		if !validUuid(operation.Id) {
+			return fmt.Errorf("invalid id")
+		}
+
+		if operation.ServiceId == "" || operation.OrderId == "" {
+			return fmt.Errorf("service_id and order_id are required")
+		}
+	default:
+		return fmt.Errorf("unknown operation type %q", operation.Type)
+	}
+
+	return nil
+}
+
+// Batch godoc
+// @Summary      Execute batch of operations
+// @Description  execute up to 500 deposits, withdrawals, transfers and reserves in one request. In atomic mode everything is committed or nothing (status 422 with the failed item), otherwise every item succeeds or fails on its own. The batch is rejected with status 400 when any item is invalid
+// @Tags         batch
+// @Accept       json
+// @Produce      json
+// @Param   batch   body    models.BatchQuery  true  "Batch"
+// @Success 200 {object} models.BatchResult
+// @Failure      400  {object} models.BatchResult
+// @Failure      403  {string} string
+// @Failure      422  {object} models.BatchResult
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /batch [post]
+func (handler *handler) batch(w http.ResponseWriter, r *http.Request) {
+	var postData models.BatchQuery
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	if len(postData.Operations) == 0 || len(postData.Operations) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("batch must contain from 1 to %d operations", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	invalid := &models.BatchResult{Atomic: postData.Atomic, Results: make([]models.BatchItemResult, 0)}
+	identity := auth.IdentityFromContext(r.Context())
+
+	for i, operation := range postData.Operations {
+		if err := validateBatchOperation(operation); err != nil {
+			invalid.Results = append(invalid.Results, models.BatchItemResult{Index: i, Status: models.BatchItemInvalid, Error: err.Error()})
+			continue
+		}
+
+		if handler.authenticator != nil && (identity == nil || !identity.HasScope(batchScopes[operation.Type])) {
+			http.Error(w, api.ErrorForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	if len(invalid.Results) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(invalid)
+		return
+	}
+
+	result, err := handler.repository.ExecuteBatch(r.Context(), postData.Atomic, postData.Operations)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error(err)
+		return
+	}
+
+	if !result.Committed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
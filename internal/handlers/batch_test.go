@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+func (t *handlerSuite) Test_batchAtomicFailure() {
+	operations := []models.BatchOperation{
+		{Type: models.BatchDeposit, Id: "f0812ab6-9993-11ec-b909-0242ac120002", Money: 100},
+		{Type: models.BatchWithdraw, Id: "f0812ab6-9993-11ec-b909-0242ac120003", Money: 100},
+		{Type: models.BatchDeposit, Id: "f0812ab6-9993-11ec-b909-0242ac120004", Money: 100},
+	}
+
+	rep := mocks.NewMockRepository()
+	rep.On("ExecuteBatch", true, operations).Return(&models.BatchResult{
+		Atomic: true,
+		Results: []models.BatchItemResult{
+			{Index: 0, Status: models.BatchItemRolledBack},
+			{Index: 1, Status: models.BatchItemFailed, Error: "not enough money"},
+			{Index: 2, Status: models.BatchItemSkipped},
+		},
+	}, nil)
+
+	resp := t.post(rep, "/batch", models.BatchQuery{Atomic: true, Operations: operations})
+	defer resp.Body.Close()
+
+	result := models.BatchResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	t.False(result.Committed)
+	t.Equal(models.BatchItemFailed, result.Results[1].Status)
+}
+
+func (t *handlerSuite) Test_batchBestEffort() {
+	operations := []models.BatchOperation{
+		{Type: models.BatchTransfer, FromId: "f0812ab6-9993-11ec-b909-0242ac120002", ToId: "f0812ab6-9993-11ec-b909-0242ac120003", Money: 10},
+		{Type: models.BatchReserve, Id: "f0812ab6-9993-11ec-b909-0242ac120002", ServiceId: "service", OrderId: "order", Money: 10},
+	}
+
+	rep := mocks.NewMockRepository()
+	rep.On("ExecuteBatch", false, operations).Return(&models.BatchResult{
+		Committed: true,
+		Results: []models.BatchItemResult{
+			{Index: 0, Status: models.BatchItemSuccess},
+			{Index: 1, Status: models.BatchItemFailed, Error: "not enough money"},
+		},
+	}, nil)
+
+	resp := t.post(rep, "/batch", models.BatchQuery{Operations: operations})
+	defer resp.Body.Close()
+
+	result := models.BatchResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.True(result.Committed)
+	t.Len(result.Results, 2)
+}
+
+func (t *handlerSuite) Test_batchInvalidItems() {
+	operations := []models.BatchOperation{
+		{Type: models.BatchDeposit, Id: "f0812ab6-9993-11ec-b909-0242ac120002", Money: 100},
+		{Type: models.BatchWithdraw, Id: "not-a-uuid", Money: 100},
+		{Type: "refund", Id: "f0812ab6-9993-11ec-b909-0242ac120002", Money: 100},
+	}
+
+	rep := mocks.NewMockRepository()
+
+	resp := t.post(rep, "/batch", models.BatchQuery{Operations: operations})
+	defer resp.Body.Close()
+
+	result := models.BatchResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusBadRequest, resp.StatusCode)
+	t.Len(result.Results, 2)
+	t.Equal(1, result.Results[0].Index)
+	t.Equal(2, result.Results[1].Index)
+	rep.AssertNotCalled(t.T(), "ExecuteBatch")
+}
+
+func (t *handlerSuite) Test_batchTooLarge() {
+	operations := make([]models.BatchOperation, 501)
+	for i := range operations {
+		operations[i] = models.BatchOperation{Type: models.BatchDeposit, Id: "f0812ab6-9993-11ec-b909-0242ac120002", Money: 1}
+	}
+
+	rep := mocks.NewMockRepository()
+
+	resp := t.post(rep, "/batch", models.BatchQuery{Operations: operations})
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusBadRequest, resp.StatusCode)
+}
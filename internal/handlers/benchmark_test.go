@@ -0,0 +1,139 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/postgres"
+)
+
+// Required running docker
+
+// BenchmarkRepositories compares the sqlx and the pgx repositories on the same
+// database. Every repository works on accounts of its own, so the runs do not
+// contend for locks:
+//
+//	go test ./internal/handlers -run '^$' -bench Repositories
+func BenchmarkRepositories(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	container, err := NewPostgreSQLContainer(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer container.Terminate(context.Background())
+
+	db, err := postgres.NewDb(container.GetDSN(), 10, postgres.WithMaxIdleConns(10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	sqlRep, err := postgresdb.NewSqlRepository(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	pool, err := postgres.NewPool(container.GetDSN(), 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pool.Close()
+
+	repositories := []struct {
+		name string
+		rep  handlers.Repository
+	}{
+		{"sqlx", sqlRep},
+		{"pgx", postgresdb.NewPgxRepository(pool, sqlRep)},
+	}
+
+	for _, r := range repositories {
+		benchmarkRepository(b, r.name, r.rep)
+	}
+}
+
+func benchmarkRepository(b *testing.B, name string, rep handlers.Repository) {
+	ctx := context.Background()
+
+	from, to := uuid.New().String(), uuid.New().String()
+	for _, uid := range []string{from, to} {
+		if _, err := rep.ChangeBalance(ctx, uid, 1_000_000); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run(name+"/ChangeBalance", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := rep.ChangeBalance(ctx, from, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run(name+"/TransferBalance", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := rep.TransferBalance(ctx, from, to, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run(name+"/TransferBalanceParallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := rep.TransferBalance(ctx, to, from, 1); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+
+	b.Run(name+"/GetBalance", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := rep.GetBalance(ctx, from); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, size := range []int{100, 1000} {
+		accounts := make([]string, 10)
+		for i := range accounts {
+			accounts[i] = uuid.New().String()
+			if _, err := rep.ChangeBalance(ctx, accounts[i], 1_000_000); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		rows := make([]models.ImportRow, size)
+		for i := range rows {
+			amount := 2.0
+			if i%2 == 1 {
+				amount = -1
+			}
+
+			rows[i] = models.ImportRow{Line: i + 1, UserId: accounts[i%len(accounts)], Amount: amount, Comment: "benchmark"}
+		}
+
+		b.Run(fmt.Sprintf("%s/ImportBalances/%d", name, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result, err := rep.ImportBalances(ctx, rows, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !result.Applied {
+					b.Fatalf("import not applied: %v", result.Errors)
+				}
+			}
+		})
+	}
+}
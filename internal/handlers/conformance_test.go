@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/db/dbtest"
+	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/pkg/postgres"
+)
+
+// Required running docker
+
+// TestConformance runs the repository conformance suite against the sqlx and
+// the pgx repositories, on a database of its own.
+func TestConformance(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	container, err := NewPostgreSQLContainer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer container.Terminate(context.Background())
+
+	db, err := postgres.NewDb(container.GetDSN(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rep, err := postgresdb.NewSqlRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := postgres.NewPool(container.GetDSN(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	log := postgresdb.NewAuditRepository(db)
+
+	t.Run("sqlx", func(t *testing.T) {
+		dbtest.Run(t, rep, log)
+	})
+
+	t.Run("pgx", func(t *testing.T) {
+		dbtest.Run(t, postgresdb.NewPgxRepository(pool, rep), log)
+	})
+}
@@ -1,44 +1,170 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/siraj18/balance-service-new/docs"
-	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/idempotency"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/metrics"
 	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/ratelimit"
+	"github.com/siraj18/balance-service-new/internal/tracing"
 	"github.com/siraj18/balance-service-new/internal/utils"
+	"github.com/siraj18/balance-service-new/pkg/api"
 	"github.com/sirupsen/logrus"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 type handler struct {
-	router     *chi.Mux
-	logger     *logrus.Logger
-	repository Repository
+	router        *chi.Mux
+	logger        *logrus.Logger
+	repository    Repository
+	authenticator auth.Authenticator
+	auditLog      audit.Log
+	idempotency   idempotency.Store
+	stream        BalanceStream
+	reportsFolder string
+	health        HealthChecker
+	rateLimits    ratelimit.Store
+	addressLimit  ratelimit.Limit
+	readLimit     ratelimit.Limit
+	writeLimit    ratelimit.Limit
+	timeout       time.Duration
 }
 
-func NewHandler(rep Repository) *handler {
-	return &handler{
-		router:     chi.NewRouter(),
-		logger:     logrus.New(),
-		repository: rep,
+// HealthChecker serves the liveness and readiness probes.
+type HealthChecker interface {
+	Live(http.ResponseWriter, *http.Request)
+	Ready(http.ResponseWriter, *http.Request)
+}
+
+type Option func(*handler)
+
+// WithAuthenticator protects every route except swagger with authenticator
+// and the per-route scopes. Without it the routes are left open.
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(h *handler) {
+		h.authenticator = authenticator
+	}
+}
+
+// WithAuditLog records every mutating request in log.
+func WithAuditLog(log audit.Log) Option {
+	return func(h *handler) {
+		h.auditLog = log
+	}
+}
+
+// WithIdempotency replays the stored response to mutating requests repeated
+// with the same Idempotency-Key header instead of serving them again.
+func WithIdempotency(store idempotency.Store) Option {
+	return func(h *handler) {
+		h.idempotency = store
+	}
+}
+
+// WithReportsFolder stores the generated reports in folder instead of
+// ./files/reports/.
+func WithReportsFolder(folder string) Option {
+	return func(h *handler) {
+		h.reportsFolder = folder
 	}
 }
 
+// WithHealth serves /healthz and /readyz with checker, they are left open
+// like /metrics.
+func WithHealth(checker HealthChecker) Option {
+	return func(h *handler) {
+		h.health = checker
+	}
+}
+
+// WithLogger logs the requests with logger instead of the standard logger.
+func WithLogger(logger *logrus.Logger) Option {
+	return func(h *handler) {
+		h.logger = logger
+	}
+}
+
+// WithRateLimits limits the requests of every client to the read routes with
+// read and to the mutating routes with write, keeping the buckets in store.
+// Before authenticating them the requests of every address to the api are
+// limited with address.
+func WithRateLimits(store ratelimit.Store, address, read, write ratelimit.Limit) Option {
+	return func(h *handler) {
+		h.rateLimits = store
+		h.addressLimit = address
+		h.readLimit = read
+		h.writeLimit = write
+	}
+}
+
+// WithTimeout cuts off the requests still being handled after timeout, except
+// the balance streams, which stay open for as long as the client listens.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *handler) {
+		h.timeout = timeout
+	}
+}
+
+func NewHandler(rep Repository, opts ...Option) *handler {
+	h := &handler{
+		router:        chi.NewRouter(),
+		logger:        logrus.StandardLogger(),
+		repository:    &tracedRepository{rep},
+		reportsFolder: "./files/reports/",
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
 type Repository interface {
-	GetBalance(string) (*models.User, error)
-	ChangeBalance(string, float64) (*models.User, error)
-	TransferBalance(string, string, float64) error
-	GetAllTransactions(string, string, int, int) (*[]models.Transaction, error)
-	ReserveMoney(string, string, string, float64) error
-	RecognizedMoney(string, string, string, float64) error
-	DeReserveMoney(string, string, string, float64) error
-	GetReserves(int, int) (*[]models.Reserve, error)
+	GetBalance(context.Context, string) (*models.User, error)
+	ChangeBalance(context.Context, string, float64) (*models.User, error)
+	TransferBalance(context.Context, string, string, float64) error
+	GetAllTransactions(context.Context, string, string, int, int) (*[]models.Transaction, error)
+	ReserveMoney(context.Context, string, string, string, float64) error
+	RecognizedMoney(context.Context, string, string, string, float64) error
+	DeReserveMoney(context.Context, string, string, string, float64) error
+	GetReserves(context.Context, int, int) (*[]models.Reserve, error)
+	CreateAccount(context.Context, string, string, models.Metadata) (*models.Account, error)
+	GetAccount(context.Context, string) (*models.Account, error)
+	FreezeAccount(context.Context, string, bool, bool) (*models.Account, error)
+	UnfreezeAccount(context.Context, string) (*models.Account, error)
+	CloseAccount(context.Context, string) (*models.Account, error)
+	GetLimits(context.Context, string) (*models.Limits, error)
+	SetLimits(context.Context, string, models.Limits) (*models.Limits, error)
+	DeleteLimits(context.Context, string) error
+	SetCreditLimit(context.Context, string, float64) (*models.Account, error)
+	GetAccountsUsingCredit(context.Context) (*[]models.Account, error)
+	CreateSchedule(context.Context, models.CreateScheduleQuery) (*models.Schedule, error)
+	GetSchedule(context.Context, string) (*models.Schedule, error)
+	GetScheduleRuns(context.Context, string) (*[]models.ScheduleRun, error)
+	PauseSchedule(context.Context, string) (*models.Schedule, error)
+	ResumeSchedule(context.Context, string) (*models.Schedule, error)
+	CancelSchedule(context.Context, string) (*models.Schedule, error)
+	ExecuteBatch(context.Context, bool, []models.BatchOperation) (*models.BatchResult, error)
+	ImportBalances(context.Context, []models.ImportRow, bool) (*models.ImportResult, error)
+	CreateWebhook(context.Context, models.CreateWebhookQuery) (*models.CreatedWebhook, error)
+	GetWebhook(context.Context, string) (*models.WebhookSubscription, error)
+	DeleteWebhook(context.Context, string) (*models.WebhookSubscription, error)
+	GetWebhookDeliveries(context.Context, string, string) (*[]models.WebhookDelivery, error)
+	RetryWebhookDelivery(context.Context, string, string) (*models.WebhookDelivery, error)
 }
 
 // handler - Returns all the available APIs
@@ -52,25 +178,28 @@ type Repository interface {
 // @Success 200 {object} models.User
 // @Failure      400  {string} string
 // @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /balance/{uid} [get]
 func (handler *handler) getBalance(w http.ResponseWriter, r *http.Request) {
 	uid := chi.URLParam(r, "uid")
 
-	user, err := handler.repository.GetBalance(uid)
+	logging.AddFields(r.Context(), logrus.Fields{"user_id": uid})
+	user, err := handler.repository.GetBalance(r.Context(), uid)
 
 	if err != nil {
-		if errors.Is(err, postgresdb.ErrorUserNotFound) {
+		if errors.Is(err, api.ErrorUserNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorInvalidInput) {
+		if errors.Is(err, api.ErrorInvalidInput) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		w.WriteHeader(500)
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		return
 	}
 
@@ -86,6 +215,8 @@ func (handler *handler) getBalance(w http.ResponseWriter, r *http.Request) {
 // @Param   account   body    models.UserChangeBalanceQuery  true  "Account"
 // @Success 200 {object} models.User
 // @Failure      400  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /changeBalance [post]
 func (handler *handler) changeBalance(w http.ResponseWriter, r *http.Request) {
 	var postData models.UserChangeBalanceQuery
@@ -98,20 +229,31 @@ func (handler *handler) changeBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := handler.repository.ChangeBalance(postData.Id, postData.Money)
+	logging.AddFields(r.Context(), logrus.Fields{"user_id": postData.Id, "amount": postData.Money})
+	user, err := handler.repository.ChangeBalance(r.Context(), postData.Id, postData.Money)
 	if err != nil {
-		if errors.Is(err, postgresdb.ErrorNotEnoughMoney) {
+		if errors.Is(err, api.ErrorAccountFrozen) || errors.Is(err, api.ErrorAccountClosed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if errors.Is(err, api.ErrorLimitExceeded) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if errors.Is(err, api.ErrorNotEnoughMoney) {
 			http.Error(w, err.Error(), http.StatusOK)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorInvalidInput) {
+		if errors.Is(err, api.ErrorInvalidInput) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		w.WriteHeader(http.StatusInternalServerError)
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		return
 	}
 	json.NewEncoder(w).Encode(user)
@@ -126,6 +268,8 @@ func (handler *handler) changeBalance(w http.ResponseWriter, r *http.Request) {
 // @Param   account   body    models.UserTransferBalanceQuery  true  "Account"
 // @Success 200 {string} string
 // @Failure      400  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /transferBalance [post]
 func (handler *handler) transferBalance(w http.ResponseWriter, r *http.Request) {
 	var postData models.UserTransferBalanceQuery
@@ -138,20 +282,25 @@ func (handler *handler) transferBalance(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = handler.repository.TransferBalance(postData.FromId, postData.ToId, postData.Money)
+	logging.AddFields(r.Context(), logrus.Fields{"from_id": postData.FromId, "to_id": postData.ToId, "amount": postData.Money})
+	err = handler.repository.TransferBalance(r.Context(), postData.FromId, postData.ToId, postData.Money)
 
 	if err != nil {
-		switch err {
-		case postgresdb.ErrorNotEnoughMoney:
+		switch {
+		case errors.Is(err, api.ErrorLimitExceeded):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, api.ErrorNotEnoughMoney):
 			http.Error(w, err.Error(), http.StatusOK)
-		case postgresdb.ErrorUserNotFound:
+		case errors.Is(err, api.ErrorAccountFrozen), errors.Is(err, api.ErrorAccountClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, api.ErrorUserNotFound):
 			http.Error(w, err.Error(), http.StatusNotFound)
-		case postgresdb.ErrorInvalidInput, postgresdb.ErrorNegativeAmount:
+		case errors.Is(err, api.ErrorInvalidInput), errors.Is(err, api.ErrorNegativeAmount):
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
 			w.WriteHeader(http.StatusInternalServerError)
 		}
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -167,6 +316,8 @@ func (handler *handler) transferBalance(w http.ResponseWriter, r *http.Request)
 // @Param   account   body    models.AllTransactionsGetQuery  true  "TransactionParams"
 // @Success 200 {object} []models.Transaction
 // @Failure      400  {object} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /allTransactions [post]
 func (handler *handler) getAllTransactions(w http.ResponseWriter, r *http.Request) {
 	var postData models.AllTransactionsGetQuery
@@ -175,19 +326,20 @@ func (handler *handler) getAllTransactions(w http.ResponseWriter, r *http.Reques
 	defer r.Body.Close()
 
 	if err != nil {
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		http.Error(w, "invalid post data", http.StatusBadRequest)
 		return
 	}
 
-	transactions, err := handler.repository.GetAllTransactions(postData.Id, postData.SortType, postData.Limit, postData.Page)
+	logging.AddFields(r.Context(), logrus.Fields{"user_id": postData.Id})
+	transactions, err := handler.repository.GetAllTransactions(r.Context(), postData.Id, postData.SortType, postData.Limit, postData.Page)
 	if err != nil {
-		if errors.Is(err, postgresdb.ErrorInvalidSortParameters) || errors.Is(err, postgresdb.ErrorInvalidInput) {
+		if errors.Is(err, api.ErrorInvalidSortParameters) || errors.Is(err, api.ErrorInvalidInput) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -205,6 +357,8 @@ func (handler *handler) getAllTransactions(w http.ResponseWriter, r *http.Reques
 // @Param   account   body    models.ReserveMoneyQuery  true  "Account"
 // @Success 200 {string} string
 // @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /reserveMoney [post]
 func (handler *handler) reserveMoney(w http.ResponseWriter, r *http.Request) {
 	var postData models.ReserveMoneyQuery
@@ -217,30 +371,41 @@ func (handler *handler) reserveMoney(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.repository.ReserveMoney(postData.UserId, postData.ServiceId, postData.OrderId, postData.Amount)
+	logging.AddFields(r.Context(), logrus.Fields{"user_id": postData.UserId, "amount": postData.Amount})
+	err = handler.repository.ReserveMoney(r.Context(), postData.UserId, postData.ServiceId, postData.OrderId, postData.Amount)
 	if err != nil {
-		if errors.Is(err, postgresdb.ErrorNotEnoughMoney) {
+		if errors.Is(err, api.ErrorAccountFrozen) || errors.Is(err, api.ErrorAccountClosed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if errors.Is(err, api.ErrorLimitExceeded) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if errors.Is(err, api.ErrorNotEnoughMoney) {
 			http.Error(w, err.Error(), http.StatusOK)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorUserNotFound) {
+		if errors.Is(err, api.ErrorUserNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorNegativeAmount) {
+		if errors.Is(err, api.ErrorNegativeAmount) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorInvalidInput) {
+		if errors.Is(err, api.ErrorInvalidInput) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		w.WriteHeader(http.StatusInternalServerError)
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		return
 	}
 
@@ -257,6 +422,8 @@ func (handler *handler) reserveMoney(w http.ResponseWriter, r *http.Request) {
 // @Param   account   body    models.ReserveMoneyQuery  true  "Account"
 // @Success 200 {string} string
 // @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /deReserveMoney [post]
 func (handler *handler) deReserveMoney(w http.ResponseWriter, r *http.Request) {
 	var postData models.ReserveMoneyQuery
@@ -269,30 +436,36 @@ func (handler *handler) deReserveMoney(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.repository.DeReserveMoney(postData.UserId, postData.ServiceId, postData.OrderId, postData.Amount)
+	logging.AddFields(r.Context(), logrus.Fields{"user_id": postData.UserId, "amount": postData.Amount})
+	err = handler.repository.DeReserveMoney(r.Context(), postData.UserId, postData.ServiceId, postData.OrderId, postData.Amount)
 	if err != nil {
-		if errors.Is(err, postgresdb.ErrorReserveAlreadyRecognized) {
+		if errors.Is(err, api.ErrorAccountFrozen) || errors.Is(err, api.ErrorAccountClosed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if errors.Is(err, api.ErrorReserveAlreadyRecognized) {
 			http.Error(w, err.Error(), http.StatusOK)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorReserveAlreadyDeReserved) {
+		if errors.Is(err, api.ErrorReserveAlreadyDeReserved) {
 			http.Error(w, err.Error(), http.StatusOK)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorReserveNotFound) {
+		if errors.Is(err, api.ErrorReserveNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 
-		if errors.Is(err, postgresdb.ErrorInvalidInput) {
+		if errors.Is(err, api.ErrorInvalidInput) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		w.WriteHeader(http.StatusInternalServerError)
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		return
 	}
 
@@ -309,6 +482,8 @@ func (handler *handler) deReserveMoney(w http.ResponseWriter, r *http.Request) {
 // @Param   account   body    models.ReserveMoneyQuery  true  "Account"
 // @Success 200 {string} string
 // @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /recognizeMoney [post]
 func (handler *handler) recognizeMoney(w http.ResponseWriter, r *http.Request) {
 	var postData models.ReserveMoneyQuery
@@ -321,21 +496,22 @@ func (handler *handler) recognizeMoney(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.repository.RecognizedMoney(postData.UserId, postData.ServiceId, postData.OrderId, postData.Amount)
+	logging.AddFields(r.Context(), logrus.Fields{"user_id": postData.UserId, "amount": postData.Amount})
+	err = handler.repository.RecognizedMoney(r.Context(), postData.UserId, postData.ServiceId, postData.OrderId, postData.Amount)
 	if err != nil {
 		switch err {
-		case postgresdb.ErrorReserveNotFound:
+		case api.ErrorReserveNotFound:
 			http.Error(w, err.Error(), http.StatusNotFound)
-		case postgresdb.ErrorUserNotFound:
+		case api.ErrorUserNotFound:
 			http.Error(w, err.Error(), http.StatusNotFound)
-		case postgresdb.ErrorInvalidInput:
+		case api.ErrorInvalidInput:
 			http.Error(w, err.Error(), http.StatusBadRequest)
-		case postgresdb.ErrorReserveAlreadyRecognized, postgresdb.ErrorReserveAlreadyDeReserved:
+		case api.ErrorReserveAlreadyRecognized, api.ErrorReserveAlreadyDeReserved:
 			http.Error(w, err.Error(), http.StatusOK)
 		default:
 			w.WriteHeader(http.StatusInternalServerError)
 		}
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		return
 	}
 
@@ -352,6 +528,8 @@ func (handler *handler) recognizeMoney(w http.ResponseWriter, r *http.Request) {
 // @Param   account   body    models.GetReportLinkQuery  true  "ReportsParams"
 // @Success 200 {string} string
 // @Failure      400  {object} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router /getReportLink [post]
 func (handler *handler) getReportLink(w http.ResponseWriter, r *http.Request) {
 	var postData models.GetReportLinkQuery
@@ -360,21 +538,21 @@ func (handler *handler) getReportLink(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		http.Error(w, "invalid post data", http.StatusBadRequest)
 		return
 	}
 
-	reserves, err := handler.repository.GetReserves(postData.Year, postData.Month)
+	reserves, err := handler.repository.GetReserves(r.Context(), postData.Year, postData.Month)
 	if err != nil {
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	link, err := utils.GenerateReportsLink(reserves, r.Host)
+	link, err := utils.GenerateReportsLink(reserves, handler.reportsFolder, r.Host)
 	if err != nil {
-		handler.logger.Error(err)
+		logging.FromContext(r.Context()).Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -383,10 +561,38 @@ func (handler *handler) getReportLink(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, link)
 }
 
+// GetCreditReportLink godoc
+// @Summary      Get credit usage report
+// @Description  Get a link to a csv report of the accounts currently using their credit line
+// @Tags         reports
+// @Produce      json
+// @Success 200 {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /getCreditReportLink [get]
+func (handler *handler) getCreditReportLink(w http.ResponseWriter, r *http.Request) {
+	accounts, err := handler.repository.GetAccountsUsingCredit(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	link, err := utils.GenerateCreditReportLink(accounts, handler.reportsFolder, r.Host)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, link)
+}
+
 func (handler *handler) HandleFile(w http.ResponseWriter, r *http.Request) {
 	fileId := chi.URLParam(r, "fileId")
 
-	file, err := os.Open("./files/reports/" + fileId + ".csv")
+	file, err := os.Open(filepath.Join(handler.reportsFolder, fileId+".csv"))
 	defer file.Close()
 
 	if err != nil {
@@ -399,21 +605,134 @@ func (handler *handler) HandleFile(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, file)
 }
 
-func (handler *handler) InitRoutes() *chi.Mux {
+func (handler *handler) scope(scope string) func(http.Handler) http.Handler {
+	if handler.authenticator == nil {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
 
-	handler.router.Get("/balance/{uid}", handler.getBalance)
-	handler.router.Post("/changeBalance", handler.changeBalance)
-	handler.router.Post("/transferBalance", handler.transferBalance)
-	handler.router.Post("/reserveMoney", handler.reserveMoney)
-	handler.router.Post("/recognizeMoney", handler.recognizeMoney)
-	handler.router.Post("/deReserveMoney", handler.deReserveMoney)
+	return auth.RequireScope(scope)
+}
 
-	handler.router.Post("/allTransactions", handler.getAllTransactions)
+func (handler *handler) audited(next http.Handler) http.Handler {
+	if handler.auditLog == nil {
+		return next
+	}
 
-	handler.router.Get("/reports/{fileId}", handler.HandleFile)
-	handler.router.Post("/getReportLink", handler.getReportLink)
+	return audit.Middleware(handler.auditLog, handler.logger)(next)
+}
 
-	handler.router.Mount("/swagger", httpSwagger.WrapHandler)
+func (handler *handler) addresses(next http.Handler) http.Handler {
+	if handler.rateLimits == nil {
+		return next
+	}
+
+	return ratelimit.Middleware(handler.rateLimits, ratelimit.BudgetAddress, handler.addressLimit)(next)
+}
+
+func (handler *handler) reads(next http.Handler) http.Handler {
+	if handler.rateLimits == nil {
+		return next
+	}
+
+	return ratelimit.Middleware(handler.rateLimits, ratelimit.BudgetRead, handler.readLimit)(next)
+}
+
+func (handler *handler) writes(next http.Handler) http.Handler {
+	if handler.rateLimits == nil {
+		return next
+	}
+
+	return ratelimit.Middleware(handler.rateLimits, ratelimit.BudgetWrite, handler.writeLimit)(next)
+}
+
+func (handler *handler) limited(next http.Handler) http.Handler {
+	if handler.timeout == 0 {
+		return next
+	}
+
+	return http.TimeoutHandler(next, handler.timeout, "request timed out")
+}
+
+// authenticated protects the routes of r with the authenticator and serves
+// repeated mutating requests from the idempotency store. The address budget
+// is taken first, credentials are not checked for addresses over it.
+func (handler *handler) authenticated(r chi.Router) {
+	r.Use(handler.addresses)
+	if handler.authenticator != nil {
+		r.Use(auth.Middleware(handler.authenticator))
+	}
+	if handler.idempotency != nil {
+		r.Use(idempotency.Middleware(handler.idempotency, handler.logger))
+	}
+}
+
+func (handler *handler) InitRoutes() *chi.Mux {
+	handler.router.Use(tracing.Middleware, logging.Middleware(handler.logger), metrics.Middleware)
+
+	handler.router.Group(func(r chi.Router) {
+		handler.authenticated(r)
+
+		r.With(handler.reads, handler.scope(auth.ScopeReadBalance)).Get("/balance/{uid}/stream", handler.streamBalance)
+	})
+
+	handler.router.Group(func(r chi.Router) {
+		r.Use(handler.limited)
+		handler.authenticated(r)
+
+		r.With(handler.reads, handler.scope(auth.ScopeReadBalance)).Get("/balance/{uid}", handler.getBalance)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeWriteBalance)).Post("/changeBalance", handler.changeBalance)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeTransfer)).Post("/transferBalance", handler.transferBalance)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeReserve)).Post("/reserveMoney", handler.reserveMoney)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeReserve)).Post("/recognizeMoney", handler.recognizeMoney)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeReserve)).Post("/deReserveMoney", handler.deReserveMoney)
+
+		// the scopes of a batch depend on its operations and are checked by the handler
+		r.With(handler.writes, handler.audited).Post("/batch", handler.batch)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeWriteBalance)).Post("/imports", handler.importBalances)
+		r.With(handler.reads, handler.scope(auth.ScopeReadBalance)).Post("/allTransactions", handler.getAllTransactions)
+
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAccounts)).Post("/accounts", handler.createAccount)
+		r.With(handler.reads, handler.scope(auth.ScopeReadBalance)).Get("/accounts/{uid}", handler.getAccount)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAccounts)).Post("/accounts/{uid}/freeze", handler.freezeAccount)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAccounts)).Post("/accounts/{uid}/unfreeze", handler.unfreezeAccount)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAccounts)).Post("/accounts/{uid}/close", handler.closeAccount)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAdmin)).Put("/accounts/{uid}/creditLimit", handler.setCreditLimit)
+
+		r.With(handler.reads, handler.scope(auth.ScopeAdmin)).Get("/limits/{scope}", handler.getLimits)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAdmin)).Put("/limits/{scope}", handler.setLimits)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAdmin)).Delete("/limits/{scope}", handler.deleteLimits)
+
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeTransfer)).Post("/schedules", handler.createSchedule)
+		r.With(handler.reads, handler.scope(auth.ScopeTransfer)).Get("/schedules/{id}", handler.getSchedule)
+		r.With(handler.reads, handler.scope(auth.ScopeTransfer)).Get("/schedules/{id}/runs", handler.getScheduleRuns)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeTransfer)).Post("/schedules/{id}/pause", handler.pauseSchedule)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeTransfer)).Post("/schedules/{id}/resume", handler.resumeSchedule)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeTransfer)).Post("/schedules/{id}/cancel", handler.cancelSchedule)
+
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAdmin)).Post("/webhooks", handler.createWebhook)
+		r.With(handler.reads, handler.scope(auth.ScopeAdmin)).Get("/webhooks/{id}", handler.getWebhook)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAdmin)).Delete("/webhooks/{id}", handler.deleteWebhook)
+		r.With(handler.reads, handler.scope(auth.ScopeAdmin)).Get("/webhooks/{id}/deliveries", handler.getWebhookDeliveries)
+		r.With(handler.writes, handler.audited, handler.scope(auth.ScopeAdmin)).Post("/webhooks/{id}/deliveries/{deliveryId}/retry", handler.retryWebhookDelivery)
+
+		r.With(handler.reads, handler.scope(auth.ScopeReports)).Get("/reports/{fileId}", handler.HandleFile)
+		r.With(handler.reads, handler.scope(auth.ScopeReports)).Post("/getReportLink", handler.getReportLink)
+		r.With(handler.reads, handler.scope(auth.ScopeReports)).Get("/getCreditReportLink", handler.getCreditReportLink)
+	})
+
+	handler.router.Group(func(r chi.Router) {
+		r.Use(handler.limited)
+
+		r.Mount("/swagger", httpSwagger.WrapHandler)
+		r.Handle("/metrics", metrics.Handler())
+
+		if handler.health != nil {
+			r.Get("/healthz", handler.health.Live)
+			r.Get("/readyz", handler.health.Ready)
+		}
+	})
 
 	return handler.router
 }
@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
 	"github.com/siraj18/balance-service-new/internal/handlers"
 	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
 	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
 	"github.com/stretchr/testify/suite"
 	"io"
 	"net/http"
@@ -57,7 +57,7 @@ func (t *handlerSuite) Test_getBalanceUserNotFound() {
 	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
 
 	rep := mocks.NewMockRepository()
-	rep.On("GetBalance", userId).Return(nil, postgresdb.ErrorUserNotFound)
+	rep.On("GetBalance", userId).Return(nil, api.ErrorUserNotFound)
 
 	h := handlers.NewHandler(rep)
 
@@ -138,7 +138,7 @@ func (t *handlerSuite) Test_withdrawBalanceNotEnoughMoney() {
 	money := -1000.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("ChangeBalance", userId, money).Return(nil, postgresdb.ErrorNotEnoughMoney)
+	rep.On("ChangeBalance", userId, money).Return(nil, api.ErrorNotEnoughMoney)
 
 	h := handlers.NewHandler(rep)
 
@@ -162,7 +162,7 @@ func (t *handlerSuite) Test_withdrawBalanceNotEnoughMoney() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorNotEnoughMoney.Error())
+	t.Contains(string(body), api.ErrorNotEnoughMoney.Error())
 	t.Equal(http.StatusOK, resp.StatusCode)
 }
 
@@ -232,7 +232,7 @@ func (t *handlerSuite) Test_transferBalanceNotEnoughMoney() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("TransferBalance", fromId, toId, amount).Return(postgresdb.ErrorNotEnoughMoney)
+	rep.On("TransferBalance", fromId, toId, amount).Return(api.ErrorNotEnoughMoney)
 
 	h := handlers.NewHandler(rep)
 
@@ -255,7 +255,7 @@ func (t *handlerSuite) Test_transferBalanceNotEnoughMoney() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorNotEnoughMoney.Error())
+	t.Contains(string(body), api.ErrorNotEnoughMoney.Error())
 	t.Equal(http.StatusOK, resp.StatusCode)
 }
 
@@ -265,7 +265,7 @@ func (t *handlerSuite) Test_transferBalanceUserNotFound() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("TransferBalance", fromId, toId, amount).Return(postgresdb.ErrorUserNotFound)
+	rep.On("TransferBalance", fromId, toId, amount).Return(api.ErrorUserNotFound)
 
 	h := handlers.NewHandler(rep)
 
@@ -288,7 +288,7 @@ func (t *handlerSuite) Test_transferBalanceUserNotFound() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorUserNotFound.Error())
+	t.Contains(string(body), api.ErrorUserNotFound.Error())
 
 	t.Equal(http.StatusNotFound, resp.StatusCode)
 }
@@ -362,7 +362,7 @@ func (t *handlerSuite) Test_reserveMoneyNotEnoughMoney() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("ReserveMoney", userId, serviceId, orderId, amount).Return(postgresdb.ErrorNotEnoughMoney)
+	rep.On("ReserveMoney", userId, serviceId, orderId, amount).Return(api.ErrorNotEnoughMoney)
 
 	h := handlers.NewHandler(rep)
 
@@ -386,7 +386,7 @@ func (t *handlerSuite) Test_reserveMoneyNotEnoughMoney() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorNotEnoughMoney.Error())
+	t.Contains(string(body), api.ErrorNotEnoughMoney.Error())
 	t.Equal(http.StatusOK, resp.StatusCode)
 }
 
@@ -397,7 +397,7 @@ func (t *handlerSuite) Test_reserveMoneyUserNotFound() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("ReserveMoney", userId, serviceId, orderId, amount).Return(postgresdb.ErrorUserNotFound)
+	rep.On("ReserveMoney", userId, serviceId, orderId, amount).Return(api.ErrorUserNotFound)
 
 	h := handlers.NewHandler(rep)
 
@@ -421,7 +421,7 @@ func (t *handlerSuite) Test_reserveMoneyUserNotFound() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorUserNotFound.Error())
+	t.Contains(string(body), api.ErrorUserNotFound.Error())
 	t.Equal(http.StatusNotFound, resp.StatusCode)
 }
 
@@ -432,7 +432,7 @@ func (t *handlerSuite) Test_reserveMoneyBadRequest() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("ReserveMoney", userId, serviceId, orderId, amount).Return(postgresdb.ErrorNegativeAmount)
+	rep.On("ReserveMoney", userId, serviceId, orderId, amount).Return(api.ErrorNegativeAmount)
 
 	h := handlers.NewHandler(rep)
 
@@ -496,7 +496,7 @@ func (t *handlerSuite) Test_deReserveMoneyAlreadyDeReserved() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("DeReserveMoney", userId, serviceId, orderId, amount).Return(postgresdb.ErrorReserveAlreadyDeReserved)
+	rep.On("DeReserveMoney", userId, serviceId, orderId, amount).Return(api.ErrorReserveAlreadyDeReserved)
 
 	h := handlers.NewHandler(rep)
 
@@ -520,7 +520,7 @@ func (t *handlerSuite) Test_deReserveMoneyAlreadyDeReserved() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorReserveAlreadyDeReserved.Error())
+	t.Contains(string(body), api.ErrorReserveAlreadyDeReserved.Error())
 	t.Equal(http.StatusOK, resp.StatusCode)
 }
 
@@ -563,7 +563,7 @@ func (t *handlerSuite) Test_RecognizeMoneyReserveNotFound() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("RecognizedMoney", userId, serviceId, orderId, amount).Return(postgresdb.ErrorReserveNotFound)
+	rep.On("RecognizedMoney", userId, serviceId, orderId, amount).Return(api.ErrorReserveNotFound)
 
 	h := handlers.NewHandler(rep)
 
@@ -587,7 +587,7 @@ func (t *handlerSuite) Test_RecognizeMoneyReserveNotFound() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorReserveNotFound.Error())
+	t.Contains(string(body), api.ErrorReserveNotFound.Error())
 
 	t.Equal(http.StatusNotFound, resp.StatusCode)
 }
@@ -599,7 +599,7 @@ func (t *handlerSuite) Test_RecognizeMoneyAlreadyRecognized() {
 	amount := 50.0
 
 	rep := mocks.NewMockRepository()
-	rep.On("RecognizedMoney", userId, serviceId, orderId, amount).Return(postgresdb.ErrorReserveAlreadyRecognized)
+	rep.On("RecognizedMoney", userId, serviceId, orderId, amount).Return(api.ErrorReserveAlreadyRecognized)
 
 	h := handlers.NewHandler(rep)
 
@@ -623,7 +623,7 @@ func (t *handlerSuite) Test_RecognizeMoneyAlreadyRecognized() {
 
 	body, err = io.ReadAll(resp.Body)
 
-	t.Contains(string(body), postgresdb.ErrorReserveAlreadyRecognized.Error())
+	t.Contains(string(body), api.ErrorReserveAlreadyRecognized.Error())
 
 	t.Equal(http.StatusOK, resp.StatusCode)
 }
@@ -681,7 +681,7 @@ func (t *handlerSuite) Test_GetAllTransactionsInvalidSortParameters() {
 	page := 1
 
 	rep := mocks.NewMockRepository()
-	rep.On("GetAllTransactions", userId, sortType, limit, page).Return(nil, postgresdb.ErrorInvalidSortParameters)
+	rep.On("GetAllTransactions", userId, sortType, limit, page).Return(nil, api.ErrorInvalidSortParameters)
 
 	h := handlers.NewHandler(rep)
 
@@ -708,3 +708,38 @@ func (t *handlerSuite) Test_GetAllTransactionsInvalidSortParameters() {
 
 	t.Equal(http.StatusBadRequest, resp.StatusCode)
 }
+
+func (t *handlerSuite) Test_getBalanceWithReserves() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetBalance", userId).Return(&models.User{
+		Id:        userId,
+		Balance:   30,
+		Available: 30,
+		Held:      20,
+		Total:     50,
+		Reserves: []models.Reserve{
+			{UserId: userId, ServiceId: "service", OrderId: "order", Amount: 20, Status: "reserved"},
+		},
+	}, nil)
+
+	h := handlers.NewHandler(rep)
+
+	testSrv := httptest.NewServer(h.InitRoutes())
+	client := testSrv.Client()
+
+	resp, err := client.Get(testSrv.URL + "/balance/" + userId)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	u := models.User{}
+	json.NewDecoder(resp.Body).Decode(&u)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal(50.0, u.Total)
+	t.Equal(20.0, u.Held)
+	t.Equal(30.0, u.Available)
+	t.Len(u.Reserves, 1)
+	t.Equal("order", u.Reserves[0].OrderId)
+}
@@ -0,0 +1,42 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/health"
+)
+
+func (t *handlerSuite) Test_healthRoutesAreOpen() {
+	checker := health.NewChecker(time.Second,
+		health.Check{Name: "schema", Run: func(ctx context.Context) error { return fmt.Errorf("outdated") }},
+	)
+
+	h := handlers.NewHandler(mocks.NewMockRepository(),
+		handlers.WithAuthenticator(auth.NewApiKeyAuthenticator(nil)),
+		handlers.WithHealth(checker),
+	)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/healthz")
+	t.Require().NoError(err)
+	resp.Body.Close()
+	t.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, err = testSrv.Client().Get(testSrv.URL + "/readyz")
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+	t.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+
+	report := health.Report{}
+	t.Require().NoError(json.NewDecoder(resp.Body).Decode(&report))
+	t.Equal("outdated", report.Checks["schema"].Error)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+const maxImportSize = 10 << 20
+
+// ImportBalances godoc
+// @Summary      Import balance adjustments
+// @Description  apply a csv file of user_id;amount;comment lines in one transaction. Every line is validated first (uuid, known user, sufficient funds), with errors the file is rejected with status 422 and nothing is applied. The import id is stamped on every created transaction
+// @Tags         imports
+// @Accept       plain
+// @Produce      json
+// @Param   file   body    string  true  "CSV file"
+// @Param   dry_run   query    bool  false  "Only validate the file"
+// @Success 200 {object} models.ImportResult
+// @Failure      400  {string} string
+// @Failure      422  {object} models.ImportResult
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /imports [post]
+func (handler *handler) importBalances(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	defer body.Close()
+
+	result, err := utils.ImportCsv(r.Context(), handler.repository, body, dryRun)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		var parseErr *csv.ParseError
+
+		switch {
+		case errors.As(err, &maxBytesErr):
+			http.Error(w, "file is too large", http.StatusRequestEntityTooLarge)
+		case errors.As(err, &parseErr):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			logging.FromContext(r.Context()).Error(err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
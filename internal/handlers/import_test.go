@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+func (t *handlerSuite) postCsv(rep *mocks.MockRepository, path, data string) *http.Response {
+	h := handlers.NewHandler(rep)
+
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Post(testSrv.URL+path, "text/csv", strings.NewReader(data))
+	t.Nil(err)
+
+	return resp
+}
+
+func (t *handlerSuite) Test_importSuccess() {
+	rows := []models.ImportRow{
+		{Line: 2, UserId: "f0812ab6-9993-11ec-b909-0242ac120002", Amount: 100, Comment: "refund"},
+		{Line: 3, UserId: "f0812ab6-9993-11ec-b909-0242ac120003", Amount: -20.5, Comment: "correction"},
+	}
+
+	rep := mocks.NewMockRepository()
+	rep.On("ImportBalances", rows, false).Return(&models.ImportResult{
+		Id:      "34be95d0-9a41-11ec-b909-0242ac120010",
+		Applied: true,
+		Rows:    2,
+		Errors:  []models.ImportLineError{},
+	}, nil)
+
+	resp := t.postCsv(rep, "/imports", "user_id;amount;comment\n"+
+		"f0812ab6-9993-11ec-b909-0242ac120002;100;refund\n"+
+		"f0812ab6-9993-11ec-b909-0242ac120003;-20.5;correction\n")
+	defer resp.Body.Close()
+
+	result := models.ImportResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.True(result.Applied)
+	t.Equal("34be95d0-9a41-11ec-b909-0242ac120010", result.Id)
+}
+
+func (t *handlerSuite) Test_importReportsEveryLine() {
+	rows := []models.ImportRow{
+		{Line: 2, UserId: "f0812ab6-9993-11ec-b909-0242ac120003", Amount: -50},
+	}
+
+	rep := mocks.NewMockRepository()
+	rep.On("ImportBalances", rows, true).Return(&models.ImportResult{
+		DryRun: true,
+		Rows:   1,
+		Errors: []models.ImportLineError{{Line: 2, Error: "not enough money"}},
+	}, nil)
+
+	resp := t.postCsv(rep, "/imports", "not-a-uuid;100;bonus\n"+
+		"f0812ab6-9993-11ec-b909-0242ac120003;-50\n"+
+		"f0812ab6-9993-11ec-b909-0242ac120004;ten;bonus\n")
+	defer resp.Body.Close()
+
+	result := models.ImportResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	t.False(result.DryRun)
+	t.False(result.Applied)
+	t.Equal(3, result.Rows)
+	t.Require().Len(result.Errors, 3)
+	t.Equal(1, result.Errors[0].Line)
+	t.Equal("not enough money", result.Errors[1].Error)
+	t.Equal(3, result.Errors[2].Line)
+}
+
+func (t *handlerSuite) Test_importDryRun() {
+	rows := []models.ImportRow{
+		{Line: 1, UserId: "f0812ab6-9993-11ec-b909-0242ac120002", Amount: 10},
+	}
+
+	rep := mocks.NewMockRepository()
+	rep.On("ImportBalances", rows, true).Return(&models.ImportResult{DryRun: true, Rows: 1, Errors: []models.ImportLineError{}}, nil)
+
+	resp := t.postCsv(rep, "/imports?dry_run=true", "f0812ab6-9993-11ec-b909-0242ac120002;10\n")
+	defer resp.Body.Close()
+
+	result := models.ImportResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.True(result.DryRun)
+	t.False(result.Applied)
+}
@@ -8,15 +8,21 @@ import (
 	_ "github.com/jackc/pgx/v4/stdlib"
 	_ "github.com/lib/pq"
 	"github.com/siraj18/balance-service-new/internal/db/postgresdb"
+	"github.com/siraj18/balance-service-new/internal/events"
 	"github.com/siraj18/balance-service-new/internal/handlers"
 	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/stream"
+	"github.com/siraj18/balance-service-new/internal/webhooks"
+	"github.com/siraj18/balance-service-new/pkg/api"
 	"github.com/siraj18/balance-service-new/pkg/postgres"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -33,6 +39,8 @@ type TestSuite struct {
 	suite.Suite
 	psqlContainer *PostgreSQLContainer
 	server        *httptest.Server
+	auditLog      *postgresdb.AuditRepository
+	rep           *postgresdb.BalanceRepository
 }
 
 func (s *TestSuite) SetupSuite() {
@@ -53,7 +61,10 @@ func (s *TestSuite) SetupSuite() {
 		logrus.Fatal(err)
 	}
 
-	handler := handlers.NewHandler(rep)
+	s.rep = rep
+	s.auditLog = postgresdb.NewAuditRepository(db)
+
+	handler := handlers.NewHandler(rep, handlers.WithAuditLog(s.auditLog))
 
 	s.server = httptest.NewServer(handler.InitRoutes())
 
@@ -159,3 +170,552 @@ func (s *TestSuite) TestGetBalance() {
 
 	s.Assert().Equal(userId, response.Id)
 }
+
+func (s *TestSuite) TestAuditLogChain() {
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"id":    "f0812ab6-9993-11ec-b909-0242ac120005",
+			"money": 10.0,
+		},
+	)
+	s.Require().NoError(err)
+
+	res, err := s.server.Client().Post(s.server.URL+"/changeBalance", "", bytes.NewReader(body))
+	s.Require().NoError(err)
+	res.Body.Close()
+
+	count, err := s.auditLog.VerifyAuditLog(context.Background())
+	s.Require().NoError(err)
+	s.Assert().Greater(count, 0)
+}
+
+func (s *TestSuite) post(path string, data interface{}) *http.Response {
+	body, err := json.Marshal(data)
+	s.Require().NoError(err)
+
+	res, err := s.server.Client().Post(s.server.URL+path, "application/json", bytes.NewReader(body))
+	s.Require().NoError(err)
+
+	return res
+}
+
+func (s *TestSuite) TestAccountLifecycle() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120006"
+
+	res := s.post("/accounts", map[string]interface{}{"id": userId, "owner": "ACME"})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 10.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/accounts/"+userId+"/freeze", map[string]interface{}{"debits": true})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -10.0})
+	res.Body.Close()
+	s.Assert().Equal(http.StatusConflict, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 5.0})
+	res.Body.Close()
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/accounts/"+userId+"/unfreeze", nil)
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/accounts/"+userId+"/close", nil)
+	res.Body.Close()
+	s.Assert().Equal(http.StatusConflict, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -15.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/accounts/"+userId+"/close", nil)
+	defer res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	account := models.Account{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&account))
+	s.Assert().Equal("closed", account.Status)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 5.0})
+	res.Body.Close()
+	s.Assert().Equal(http.StatusConflict, res.StatusCode)
+}
+
+func (s *TestSuite) TestDailyDebitLimit() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120007"
+
+	res := s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 100.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	body, err := json.Marshal(map[string]interface{}{"daily_debit": 30.0})
+	s.Require().NoError(err)
+
+	req, err := http.NewRequest("PUT", s.server.URL+"/limits/"+userId, bytes.NewReader(body))
+	s.Require().NoError(err)
+
+	res, err = s.server.Client().Do(req)
+	s.Require().NoError(err)
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -20.0})
+	res.Body.Close()
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -20.0})
+	res.Body.Close()
+	s.Assert().Equal(http.StatusUnprocessableEntity, res.StatusCode)
+}
+
+func (s *TestSuite) TestCreditLine() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120008"
+
+	res := s.post("/accounts", map[string]interface{}{"id": userId, "owner": "ACME"})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	req, err := http.NewRequest("PUT", s.server.URL+"/accounts/"+userId+"/creditLimit", bytes.NewReader([]byte(`{"credit_limit": 100}`)))
+	s.Require().NoError(err)
+
+	res, err = s.server.Client().Do(req)
+	s.Require().NoError(err)
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -60.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -60.0})
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	s.Require().NoError(err)
+	s.Assert().Contains(string(body), api.ErrorNotEnoughMoney.Error())
+
+	res, err = s.server.Client().Get(s.server.URL + "/balance/" + userId)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+
+	user := models.User{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&user))
+	s.Assert().Equal(-60.0, user.Balance)
+	s.Assert().Equal(40.0, user.Available)
+}
+
+func (s *TestSuite) TestHeldBalance() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120009"
+
+	res := s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 50.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/reserveMoney", map[string]interface{}{"user_id": userId, "service_id": "service", "order_id": "order", "amount": 20.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res, err := s.server.Client().Get(s.server.URL + "/balance/" + userId)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+
+	user := models.User{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&user))
+	s.Assert().Equal(50.0, user.Total)
+	s.Assert().Equal(20.0, user.Held)
+	s.Assert().Equal(30.0, user.Available)
+	s.Require().Len(user.Reserves, 1)
+	s.Assert().Equal("order", user.Reserves[0].OrderId)
+}
+
+func (s *TestSuite) TestScheduledTransfer() {
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120010"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120011"
+
+	for _, id := range []string{fromId, toId} {
+		res := s.post("/changeBalance", map[string]interface{}{"id": id, "money": 30.0})
+		res.Body.Close()
+		s.Require().Equal(http.StatusOK, res.StatusCode)
+	}
+
+	runAt := time.Now().Add(-time.Second)
+
+	res := s.post("/schedules", map[string]interface{}{"from_id": fromId, "to_id": toId, "money": 20.0, "run_at": runAt.Add(-time.Second)})
+	paid := models.Schedule{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&paid))
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/schedules", map[string]interface{}{"from_id": fromId, "to_id": toId, "money": 20.0, "run_at": runAt, "max_retries": 0})
+	unpaid := models.Schedule{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&unpaid))
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	executed, err := s.rep.ExecuteDueSchedules(context.Background(), 10)
+	s.Require().NoError(err)
+	s.Assert().Equal(2, executed)
+
+	from, err := s.rep.GetBalance(context.Background(), fromId)
+	s.Require().NoError(err)
+	s.Assert().Equal(10.0, from.Balance)
+
+	schedule, err := s.rep.GetSchedule(context.Background(), paid.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal("completed", schedule.Status)
+
+	schedule, err = s.rep.GetSchedule(context.Background(), unpaid.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal("failed", schedule.Status)
+
+	runs, err := s.rep.GetScheduleRuns(context.Background(), unpaid.Id)
+	s.Require().NoError(err)
+	s.Require().Len(*runs, 1)
+	s.Assert().Equal(api.ErrorNotEnoughMoney.Error(), *(*runs)[0].Error)
+}
+
+func (s *TestSuite) TestBatch() {
+	firstId := "f0812ab6-9993-11ec-b909-0242ac120012"
+	secondId := "f0812ab6-9993-11ec-b909-0242ac120013"
+
+	operations := []models.BatchOperation{
+		{Type: models.BatchDeposit, Id: firstId, Money: 100},
+		{Type: models.BatchTransfer, FromId: firstId, ToId: secondId, Money: 40},
+		{Type: models.BatchWithdraw, Id: secondId, Money: 50},
+	}
+
+	res := s.post("/batch", models.BatchQuery{Atomic: true, Operations: operations})
+	result := models.BatchResult{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&result))
+	res.Body.Close()
+
+	s.Require().Equal(http.StatusUnprocessableEntity, res.StatusCode)
+	s.Assert().Equal(models.BatchItemRolledBack, result.Results[0].Status)
+	s.Assert().Equal(models.BatchItemFailed, result.Results[1].Status)
+
+	_, err := s.rep.GetBalance(context.Background(), firstId)
+	s.Assert().ErrorIs(err, api.ErrorUserNotFound)
+
+	operations[1].Money = 60
+	operations = append([]models.BatchOperation{{Type: models.BatchDeposit, Id: secondId, Money: 1}}, operations...)
+
+	res = s.post("/batch", models.BatchQuery{Operations: operations})
+	result = models.BatchResult{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&result))
+	res.Body.Close()
+
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().True(result.Committed)
+
+	second, err := s.rep.GetBalance(context.Background(), secondId)
+	s.Require().NoError(err)
+	s.Assert().Equal(11.0, second.Balance)
+}
+
+func (s *TestSuite) TestImport() {
+	firstId := "f0812ab6-9993-11ec-b909-0242ac120014"
+	secondId := "f0812ab6-9993-11ec-b909-0242ac120015"
+
+	res := s.post("/changeBalance", map[string]interface{}{"id": firstId, "money": 10.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	file := firstId + ";50;bonus\n" + firstId + ";-70;correction\n" + secondId + ";5;unknown\n"
+
+	res, err := s.server.Client().Post(s.server.URL+"/imports", "text/csv", strings.NewReader(file))
+	s.Require().NoError(err)
+	result := models.ImportResult{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&result))
+	res.Body.Close()
+
+	s.Require().Equal(http.StatusUnprocessableEntity, res.StatusCode)
+	s.Require().Len(result.Errors, 2)
+	s.Assert().Equal(2, result.Errors[0].Line)
+	s.Assert().Equal(3, result.Errors[1].Line)
+
+	file = firstId + ";50;bonus\n" + firstId + ";-30;correction\n"
+
+	res, err = s.server.Client().Post(s.server.URL+"/imports", "text/csv", strings.NewReader(file))
+	s.Require().NoError(err)
+	result = models.ImportResult{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&result))
+	res.Body.Close()
+
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	s.Require().True(result.Applied)
+
+	transactions, err := s.rep.GetAllTransactions(context.Background(), firstId, "date_asc", 10, 1)
+	s.Require().NoError(err)
+	s.Require().Len(*transactions, 3)
+	s.Assert().Nil((*transactions)[0].ImportId)
+	s.Assert().Equal(result.Id, *(*transactions)[2].ImportId)
+	s.Assert().Equal("correction", *(*transactions)[2].Comment)
+}
+
+func (s *TestSuite) TestOutboxRelay() {
+	firstId := "f0812ab6-9993-11ec-b909-0242ac120016"
+	secondId := "f0812ab6-9993-11ec-b909-0242ac120017"
+
+	publisher := events.NewMemoryPublisher()
+	relay := func() (int, error) {
+		return s.rep.RelayEvents(context.Background(), 1000, publisher.Publish)
+	}
+
+	_, err := relay()
+	s.Require().NoError(err)
+
+	res := s.post("/changeBalance", map[string]interface{}{"id": firstId, "money": 100.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	publisher.Fail(fmt.Errorf("broker is down"))
+	published, err := relay()
+	s.Assert().Error(err)
+	s.Assert().Equal(0, published)
+	publisher.Fail(nil)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": secondId, "money": 1.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/transferBalance", map[string]interface{}{"from_id": firstId, "to_id": secondId, "money": 30.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	published, err = relay()
+	s.Require().NoError(err)
+	s.Assert().Equal(4, published)
+
+	var types []string
+	for _, event := range publisher.Events() {
+		if event.AccountId == firstId {
+			types = append(types, event.Type)
+		}
+	}
+	s.Assert().Equal([]string{models.EventDeposited, models.EventTransferDebited}, types)
+
+	last := publisher.Events()[3]
+	data := models.AccountEventData{}
+	s.Require().NoError(json.Unmarshal(last.Data, &data))
+	s.Assert().Equal(models.EventTransferCredited, last.Type)
+	s.Assert().Equal(models.EventSchemaVersion, last.Version)
+	s.Assert().Equal(firstId, data.CounterpartyId)
+	s.Assert().Equal(31.0, *data.Balance)
+}
+
+func (s *TestSuite) TestWebhookDelivery() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120018"
+	received := make(chan *http.Request, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer receiver.Close()
+
+	res := s.post("/webhooks", models.CreateWebhookQuery{Url: receiver.URL, EventTypes: []string{models.EventDeposited}})
+	webhook := models.CreatedWebhook{}
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&webhook))
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	defer s.rep.DeleteWebhook(context.Background(), webhook.Id)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 10.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -5.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	sent, err := webhooks.NewDispatcher(s.rep, time.Second, 10).Dispatch(context.Background())
+	s.Require().NoError(err)
+	s.Assert().Equal(1, sent)
+
+	r := <-received
+	s.Assert().Equal(models.EventDeposited, r.Header.Get(webhooks.HeaderEvent))
+
+	deliveries, err := s.rep.GetWebhookDeliveries(context.Background(), webhook.Id, models.WebhookDelivered)
+	s.Require().NoError(err)
+	s.Require().Len(*deliveries, 1)
+	s.Assert().Equal(1, (*deliveries)[0].Attempt)
+}
+
+func (s *TestSuite) TestBalanceNotifications() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120019"
+
+	hub := stream.NewHub(s.psqlContainer.GetDSN(), postgresdb.BalanceChangesChannel)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	events, unsubscribe := hub.Subscribe(userId)
+	defer unsubscribe()
+
+	// the hub starts listening in the background, deposit until it does
+	var event models.Event
+	s.Require().Eventually(func() bool {
+		res := s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 1.0})
+		res.Body.Close()
+
+		select {
+		case event = <-events:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.Assert().Equal(models.EventDeposited, event.Type)
+	s.Assert().Equal(userId, event.AccountId)
+}
+
+func (s *TestSuite) TestMetrics() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120020"
+
+	res := s.post("/changeBalance", map[string]interface{}{"id": userId, "money": 10.0})
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = s.post("/changeBalance", map[string]interface{}{"id": userId, "money": -20.0})
+	res.Body.Close()
+
+	res, err := s.server.Client().Get(s.server.URL + "/metrics")
+	s.Require().NoError(err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	s.Require().NoError(err)
+
+	s.Assert().Contains(string(body), `balance_http_request_duration_seconds_count{method="POST",route="/changeBalance",status="200"}`)
+	s.Assert().Contains(string(body), `balance_operations_total{operation="deposit"}`)
+	s.Assert().Contains(string(body), `balance_operation_amount_total{operation="deposit"}`)
+	s.Assert().Contains(string(body), "balance_insufficient_funds_total")
+	s.Assert().Contains(string(body), "balance_db_transaction_rollbacks_total")
+}
+
+func (s *TestSuite) TestRequestIdStoredOnTransactions() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120021"
+
+	body, err := json.Marshal(map[string]interface{}{"id": userId, "money": 10.0})
+	s.Require().NoError(err)
+
+	req, err := http.NewRequest(http.MethodPost, s.server.URL+"/changeBalance", bytes.NewReader(body))
+	s.Require().NoError(err)
+	req.Header.Set("X-Request-ID", "deposit-21")
+
+	res, err := s.server.Client().Do(req)
+	s.Require().NoError(err)
+	res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal("deposit-21", res.Header.Get("X-Request-ID"))
+
+	transactions, err := s.rep.GetAllTransactions(context.Background(), userId, "date_asc", 10, 1)
+	s.Require().NoError(err)
+	s.Require().Len(*transactions, 1)
+	s.Require().NotNil((*transactions)[0].RequestId)
+	s.Assert().Equal("deposit-21", *(*transactions)[0].RequestId)
+}
+
+func (s *TestSuite) TestSchemaIsCurrent() {
+	s.Require().NoError(s.rep.CheckSchema(context.Background()))
+}
+
+func (s *TestSuite) TestReplicaServesReads() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120022"
+
+	primary, err := postgres.NewDb(s.psqlContainer.GetDSN(), 1)
+	s.Require().NoError(err)
+	defer primary.Close()
+
+	// the primary stands in for its replica, which it never lags behind
+	replica, err := postgres.NewDb(s.psqlContainer.GetDSN(), 1,
+		postgres.WithStatementTimeout(100*time.Millisecond), postgres.WithMaxOpenConns(2))
+	s.Require().NoError(err)
+	defer replica.Close()
+
+	rep := postgresdb.ConnectSqlRepository(primary, postgresdb.WithReplica(replica, time.Second))
+
+	_, err = rep.ChangeBalance(context.Background(), userId, 30)
+	s.Require().NoError(err)
+
+	user, err := rep.GetBalance(context.Background(), userId)
+	s.Require().NoError(err)
+	s.Assert().Equal(30.0, user.Balance)
+
+	transactions, err := rep.GetAllTransactions(context.Background(), userId, "date_asc", 10, 1)
+	s.Require().NoError(err)
+	s.Assert().Len(*transactions, 1)
+
+	_, err = replica.Exec("SELECT pg_sleep(1)")
+	s.Assert().ErrorContains(err, "statement timeout")
+}
+
+func (s *TestSuite) TestPgxRepository() {
+	firstId := "f0812ab6-9993-11ec-b909-0242ac120023"
+	secondId := "f0812ab6-9993-11ec-b909-0242ac120024"
+	ctx := context.Background()
+
+	pool, err := postgres.NewPool(s.psqlContainer.GetDSN(), 1)
+	s.Require().NoError(err)
+	defer pool.Close()
+
+	rep := postgresdb.NewPgxRepository(pool, s.rep)
+
+	user, err := rep.ChangeBalance(ctx, firstId, 100)
+	s.Require().NoError(err)
+	s.Assert().Equal(100.0, user.Balance)
+
+	s.Require().NoError(rep.TransferBalance(ctx, firstId, secondId, 40))
+	s.Assert().Equal(api.ErrorNotEnoughMoney, rep.TransferBalance(ctx, secondId, firstId, 50))
+
+	_, err = rep.ChangeBalance(ctx, "not-a-uuid", 10)
+	s.Assert().Equal(api.ErrorInvalidInput, err)
+
+	rows := []models.ImportRow{
+		{Line: 1, UserId: firstId, Amount: 15.5, Comment: "bonus"},
+		{Line: 2, UserId: secondId, Amount: -45, Comment: "correction"},
+		{Line: 3, UserId: "f0812ab6-9993-11ec-b909-0242ac120025", Amount: 5, Comment: "unknown"},
+	}
+
+	result, err := rep.ImportBalances(ctx, rows, false)
+	s.Require().NoError(err)
+	s.Require().Len(result.Errors, 2)
+	s.Assert().Equal(models.ImportLineError{Line: 2, Error: api.ErrorNotEnoughMoney.Error()}, result.Errors[0])
+	s.Assert().Equal(models.ImportLineError{Line: 3, Error: api.ErrorUserNotFound.Error()}, result.Errors[1])
+
+	rows[1].Amount = -30
+	result, err = rep.ImportBalances(ctx, rows[:2], false)
+	s.Require().NoError(err)
+	s.Require().True(result.Applied)
+
+	first, err := s.rep.GetBalance(ctx, firstId)
+	s.Require().NoError(err)
+	s.Assert().Equal(75.5, first.Balance)
+
+	second, err := rep.GetBalance(ctx, secondId)
+	s.Require().NoError(err)
+	s.Assert().Equal(10.0, second.Balance)
+
+	transactions, err := s.rep.GetAllTransactions(ctx, secondId, "date_asc", 10, 1)
+	s.Require().NoError(err)
+	s.Require().Len(*transactions, 2)
+	s.Assert().Equal(result.Id, *(*transactions)[1].ImportId)
+	s.Assert().Equal("correction", *(*transactions)[1].Comment)
+
+	var types []string
+	eventRows, err := pool.Query(ctx, "SELECT type FROM outbox WHERE account_id = ANY($1) ORDER BY id", []string{firstId, secondId})
+	s.Require().NoError(err)
+	for eventRows.Next() {
+		var eventType string
+		s.Require().NoError(eventRows.Scan(&eventType))
+		types = append(types, eventType)
+	}
+	s.Require().NoError(eventRows.Err())
+
+	s.Assert().Equal([]string{models.EventDeposited, models.EventTransferDebited, models.EventTransferCredited,
+		models.EventDeposited, models.EventWithdrawn}, types)
+}
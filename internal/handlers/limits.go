@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+)
+
+func (handler *handler) writeLimitsError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, api.ErrorLimitsNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, api.ErrorInvalidInput), errors.Is(err, api.ErrorInvalidLimits):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+// GetLimits godoc
+// @Summary      Get limits
+// @Description  get limits of an account by UID, or the global limits with scope "global"
+// @Tags         limits
+// @Produce      json
+// @Param   scope   path    string  true  "Account ID or global" default(global)
+// @Success 200 {object} models.Limits
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /limits/{scope} [get]
+func (handler *handler) getLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := handler.repository.GetLimits(r.Context(), chi.URLParam(r, "scope"))
+	if err != nil {
+		handler.writeLimitsError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(limits)
+}
+
+// SetLimits godoc
+// @Summary      Set limits
+// @Description  replace limits of an account by UID, or the global limits with scope "global". Omitted limits are not enforced, account limits override the global ones
+// @Tags         limits
+// @Accept       json
+// @Produce      json
+// @Param   scope   path    string  true  "Account ID or global" default(global)
+// @Param   limits   body    models.Limits  true  "Limits"
+// @Success 200 {object} models.Limits
+// @Failure      400  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /limits/{scope} [put]
+func (handler *handler) setLimits(w http.ResponseWriter, r *http.Request) {
+	var postData models.Limits
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	limits, err := handler.repository.SetLimits(r.Context(), chi.URLParam(r, "scope"), postData)
+	if err != nil {
+		handler.writeLimitsError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(limits)
+}
+
+// DeleteLimits godoc
+// @Summary      Delete limits
+// @Description  remove limits of an account by UID, or the global limits with scope "global"
+// @Tags         limits
+// @Produce      json
+// @Param   scope   path    string  true  "Account ID or global" default(global)
+// @Success 200 {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /limits/{scope} [delete]
+func (handler *handler) deleteLimits(w http.ResponseWriter, r *http.Request) {
+	if err := handler.repository.DeleteLimits(r.Context(), chi.URLParam(r, "scope")); err != nil {
+		handler.writeLimitsError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/db/backend"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (t *handlerSuite) Test_getLimitsNotFound() {
+	rep := mocks.NewMockRepository()
+	rep.On("GetLimits", backend.GlobalLimitsScope).Return(nil, api.ErrorLimitsNotFound)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/limits/global")
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_setLimitsSuccess() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	maxOperation := 100.0
+	hourlyTransfers := 5
+	limits := models.Limits{MaxOperation: &maxOperation, HourlyTransfers: &hourlyTransfers}
+
+	rep := mocks.NewMockRepository()
+	rep.On("SetLimits", userId, limits).Return(&models.Limits{
+		Scope:           userId,
+		MaxOperation:    &maxOperation,
+		HourlyTransfers: &hourlyTransfers,
+	}, nil)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	body, err := json.Marshal(limits)
+	t.Nil(err)
+
+	req, err := http.NewRequest("PUT", testSrv.URL+"/limits/"+userId, bytes.NewReader(body))
+	t.Nil(err)
+
+	resp, err := testSrv.Client().Do(req)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	result := models.Limits{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal(userId, result.Scope)
+	t.Equal(maxOperation, *result.MaxOperation)
+	t.Nil(result.DailyDebit)
+}
+
+func (t *handlerSuite) Test_setLimitsInvalid() {
+	maxOperation := -1.0
+	limits := models.Limits{MaxOperation: &maxOperation}
+
+	rep := mocks.NewMockRepository()
+	rep.On("SetLimits", backend.GlobalLimitsScope, limits).Return(nil, api.ErrorInvalidLimits)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	body, err := json.Marshal(limits)
+	t.Nil(err)
+
+	req, err := http.NewRequest("PUT", testSrv.URL+"/limits/global", bytes.NewReader(body))
+	t.Nil(err)
+
+	resp, err := testSrv.Client().Do(req)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_transferLimitExceeded() {
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120003"
+	resetsAt := time.Date(2022, 11, 2, 0, 0, 0, 0, time.UTC)
+	limitErr := &backend.LimitExceededError{Limit: "daily_debit", ResetsAt: &resetsAt}
+
+	rep := mocks.NewMockRepository()
+	rep.On("TransferBalance", fromId, toId, 10.0).Return(limitErr)
+
+	resp := t.post(rep, "/transferBalance", map[string]interface{}{"from_id": fromId, "to_id": toId, "money": 10.0})
+	defer resp.Body.Close()
+
+	message, err := io.ReadAll(resp.Body)
+	t.Nil(err)
+
+	t.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	t.Equal("limit exceeded: daily_debit, resets at 2022-11-02T00:00:00Z\n", string(message))
+}
+
+func (t *handlerSuite) Test_withdrawLimitExceeded() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("ChangeBalance", userId, -500.0).Return(nil, &backend.LimitExceededError{Limit: "max_operation"})
+
+	resp := t.post(rep, "/changeBalance", map[string]interface{}{"id": userId, "money": -500.0})
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+}
@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func (t *handlerSuite) Test_logsRequests() {
+	logger, hook := test.NewNullLogger()
+
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("ChangeBalance", userId, 50.0).Return(&models.User{Id: userId, Balance: 50}, nil)
+
+	testSrv := httptest.NewServer(handlers.NewHandler(rep, handlers.WithLogger(logger)).InitRoutes())
+	defer testSrv.Close()
+
+	body := []byte(`{"id": "` + userId + `", "money": 50}`)
+	req, err := http.NewRequest(http.MethodPost, testSrv.URL+"/changeBalance", bytes.NewReader(body))
+	t.Require().NoError(err)
+	req.Header.Set("X-Request-ID", "change-1")
+
+	resp, err := testSrv.Client().Do(req)
+	t.Require().NoError(err)
+	resp.Body.Close()
+
+	t.Equal("change-1", resp.Header.Get("X-Request-ID"))
+
+	entry := hook.LastEntry()
+	t.Require().NotNil(entry)
+	t.Equal(logrus.InfoLevel, entry.Level)
+	t.Equal("change-1", entry.Data["request_id"])
+	t.Equal("/changeBalance", entry.Data["route"])
+	t.Equal(http.StatusOK, entry.Data["status"])
+	t.Equal(userId, entry.Data["user_id"])
+	t.Equal(50.0, entry.Data["amount"])
+	t.Contains(entry.Data, "latency_ms")
+}
+
+func (t *handlerSuite) Test_logsFailuresWithRequestId() {
+	logger, hook := test.NewNullLogger()
+
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120003"
+
+	rep := mocks.NewMockRepository()
+	rep.On("TransferBalance", fromId, toId, 50.0).Return(fmt.Errorf("some error"))
+
+	testSrv := httptest.NewServer(handlers.NewHandler(rep, handlers.WithLogger(logger)).InitRoutes())
+	defer testSrv.Close()
+
+	body := []byte(`{"from_id": "` + fromId + `", "to_id": "` + toId + `", "money": 50}`)
+	resp, err := testSrv.Client().Post(testSrv.URL+"/transferBalance", "application/json", bytes.NewReader(body))
+	t.Require().NoError(err)
+	resp.Body.Close()
+
+	requestId := resp.Header.Get("X-Request-ID")
+	t.NotEmpty(requestId)
+
+	entries := hook.AllEntries()
+	t.Require().Len(entries, 2)
+	for _, entry := range entries {
+		t.Equal(logrus.ErrorLevel, entry.Level)
+		t.Equal(requestId, entry.Data["request_id"])
+		t.Equal(fromId, entry.Data["from_id"])
+		t.Equal(toId, entry.Data["to_id"])
+	}
+	t.Equal(http.StatusInternalServerError, entries[1].Data["status"])
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"github.com/siraj18/balance-service-new/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,7 +14,7 @@ func NewMockRepository() *MockRepository {
 	return &MockRepository{}
 }
 
-func (m *MockRepository) GetBalance(id string) (*models.User, error) {
+func (m *MockRepository) GetBalance(ctx context.Context, id string) (*models.User, error) {
 	args := m.Called(id)
 
 	arg0 := args.Get(0)
@@ -24,7 +25,7 @@ func (m *MockRepository) GetBalance(id string) (*models.User, error) {
 	return arg0.(*models.User), args.Error(1)
 }
 
-func (m *MockRepository) ChangeBalance(id string, money float64) (*models.User, error) {
+func (m *MockRepository) ChangeBalance(ctx context.Context, id string, money float64) (*models.User, error) {
 	args := m.Called(id, money)
 
 	arg0 := args.Get(0)
@@ -35,13 +36,13 @@ func (m *MockRepository) ChangeBalance(id string, money float64) (*models.User,
 	return arg0.(*models.User), args.Error(1)
 }
 
-func (m *MockRepository) TransferBalance(fromId, toId string, money float64) error {
+func (m *MockRepository) TransferBalance(ctx context.Context, fromId, toId string, money float64) error {
 	args := m.Called(fromId, toId, money)
 
 	return args.Error(0)
 }
 
-func (m *MockRepository) GetAllTransactions(userId, sortType string, limit, page int) (*[]models.Transaction, error) {
+func (m *MockRepository) GetAllTransactions(ctx context.Context, userId, sortType string, limit, page int) (*[]models.Transaction, error) {
 	args := m.Called(userId, sortType, limit, page)
 
 	arg0 := args.Get(0)
@@ -53,25 +54,25 @@ func (m *MockRepository) GetAllTransactions(userId, sortType string, limit, page
 	return arg0.(*[]models.Transaction), args.Error(1)
 }
 
-func (m *MockRepository) ReserveMoney(userId, serviceId, orderId string, amount float64) error {
+func (m *MockRepository) ReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
 	args := m.Called(userId, serviceId, orderId, amount)
 
 	return args.Error(0)
 }
 
-func (m *MockRepository) RecognizedMoney(userId, serviceId, orderId string, amount float64) error {
+func (m *MockRepository) RecognizedMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
 	args := m.Called(userId, serviceId, orderId, amount)
 
 	return args.Error(0)
 }
 
-func (m *MockRepository) DeReserveMoney(userId, serviceId, orderId string, amount float64) error {
+func (m *MockRepository) DeReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
 	args := m.Called(userId, serviceId, orderId, amount)
 
 	return args.Error(0)
 }
 
-func (m *MockRepository) GetReserves(year int, month int) (*[]models.Reserve, error) {
+func (m *MockRepository) GetReserves(ctx context.Context, year int, month int) (*[]models.Reserve, error) {
 	args := m.Called(year, month)
 
 	arg0 := args.Get(0)
@@ -82,3 +83,190 @@ func (m *MockRepository) GetReserves(year int, month int) (*[]models.Reserve, er
 
 	return arg0.(*[]models.Reserve), args.Error(1)
 }
+
+func (m *MockRepository) account(args mock.Arguments) (*models.Account, error) {
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.Account), args.Error(1)
+}
+
+func (m *MockRepository) CreateAccount(ctx context.Context, id, owner string, metadata models.Metadata) (*models.Account, error) {
+	return m.account(m.Called(id, owner, metadata))
+}
+
+func (m *MockRepository) GetAccount(ctx context.Context, id string) (*models.Account, error) {
+	return m.account(m.Called(id))
+}
+
+func (m *MockRepository) FreezeAccount(ctx context.Context, id string, debits, credits bool) (*models.Account, error) {
+	return m.account(m.Called(id, debits, credits))
+}
+
+func (m *MockRepository) UnfreezeAccount(ctx context.Context, id string) (*models.Account, error) {
+	return m.account(m.Called(id))
+}
+
+func (m *MockRepository) CloseAccount(ctx context.Context, id string) (*models.Account, error) {
+	return m.account(m.Called(id))
+}
+
+func (m *MockRepository) limits(args mock.Arguments) (*models.Limits, error) {
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.Limits), args.Error(1)
+}
+
+func (m *MockRepository) GetLimits(ctx context.Context, scope string) (*models.Limits, error) {
+	return m.limits(m.Called(scope))
+}
+
+func (m *MockRepository) SetLimits(ctx context.Context, scope string, limits models.Limits) (*models.Limits, error) {
+	return m.limits(m.Called(scope, limits))
+}
+
+func (m *MockRepository) DeleteLimits(ctx context.Context, scope string) error {
+	return m.Called(scope).Error(0)
+}
+
+func (m *MockRepository) SetCreditLimit(ctx context.Context, id string, limit float64) (*models.Account, error) {
+	return m.account(m.Called(id, limit))
+}
+
+func (m *MockRepository) GetAccountsUsingCredit(ctx context.Context) (*[]models.Account, error) {
+	args := m.Called()
+
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*[]models.Account), args.Error(1)
+}
+
+func (m *MockRepository) schedule(args mock.Arguments) (*models.Schedule, error) {
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.Schedule), args.Error(1)
+}
+
+func (m *MockRepository) CreateSchedule(ctx context.Context, query models.CreateScheduleQuery) (*models.Schedule, error) {
+	return m.schedule(m.Called(query))
+}
+
+func (m *MockRepository) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return m.schedule(m.Called(id))
+}
+
+func (m *MockRepository) GetScheduleRuns(ctx context.Context, id string) (*[]models.ScheduleRun, error) {
+	args := m.Called(id)
+
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*[]models.ScheduleRun), args.Error(1)
+}
+
+func (m *MockRepository) PauseSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return m.schedule(m.Called(id))
+}
+
+func (m *MockRepository) ResumeSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return m.schedule(m.Called(id))
+}
+
+func (m *MockRepository) CancelSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	return m.schedule(m.Called(id))
+}
+
+func (m *MockRepository) ExecuteBatch(ctx context.Context, atomic bool, operations []models.BatchOperation) (*models.BatchResult, error) {
+	args := m.Called(atomic, operations)
+
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.BatchResult), args.Error(1)
+}
+
+func (m *MockRepository) ImportBalances(ctx context.Context, rows []models.ImportRow, dryRun bool) (*models.ImportResult, error) {
+	args := m.Called(rows, dryRun)
+
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.ImportResult), args.Error(1)
+}
+
+func (m *MockRepository) webhook(args mock.Arguments) (*models.WebhookSubscription, error) {
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockRepository) CreateWebhook(ctx context.Context, query models.CreateWebhookQuery) (*models.CreatedWebhook, error) {
+	args := m.Called(query)
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.CreatedWebhook), args.Error(1)
+}
+
+func (m *MockRepository) GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	return m.webhook(m.Called(id))
+}
+
+func (m *MockRepository) DeleteWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	return m.webhook(m.Called(id))
+}
+
+func (m *MockRepository) GetWebhookDeliveries(ctx context.Context, id, status string) (*[]models.WebhookDelivery, error) {
+	args := m.Called(id, status)
+
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*[]models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockRepository) RetryWebhookDelivery(ctx context.Context, id, deliveryId string) (*models.WebhookDelivery, error) {
+	args := m.Called(id, deliveryId)
+
+	arg0 := args.Get(0)
+
+	if arg0 == nil {
+		return nil, args.Error(1)
+	}
+
+	return arg0.(*models.WebhookDelivery), args.Error(1)
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/ratelimit"
+)
+
+func (t *handlerSuite) Test_readsAndWritesHaveSeparateRateLimits() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetAllTransactions", userId, "", 10, 1).Return(&[]models.Transaction{}, nil)
+	rep.On("ChangeBalance", userId, 50.0).Return(&models.User{Id: userId, Balance: 50}, nil)
+
+	h := handlers.NewHandler(rep, handlers.WithRateLimits(ratelimit.NewMemoryStore(),
+		ratelimit.Limit{},
+		ratelimit.Limit{Rate: 0.01, Burst: 2},
+		ratelimit.Limit{Rate: 0.01, Burst: 1},
+	))
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	post := func(path, body string) *http.Response {
+		resp, err := testSrv.Client().Post(testSrv.URL+path, "application/json", bytes.NewReader([]byte(body)))
+		t.Require().NoError(err)
+		resp.Body.Close()
+
+		return resp
+	}
+
+	transactions := `{"id": "` + userId + `", "limit": 10, "page": 1}`
+	t.Equal(http.StatusOK, post("/allTransactions", transactions).StatusCode)
+	t.Equal(http.StatusOK, post("/allTransactions", transactions).StatusCode)
+
+	resp := post("/allTransactions", transactions)
+	t.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	t.Equal("100", resp.Header.Get("Retry-After"))
+	rep.AssertNumberOfCalls(t.T(), "GetAllTransactions", 2)
+
+	// a client looping on reads does not use up its writes
+	resp = post("/changeBalance", `{"id": "`+userId+`", "money": 50}`)
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("1", resp.Header.Get("RateLimit-Limit"))
+	t.Equal("0", resp.Header.Get("RateLimit-Remaining"))
+}
+
+func (t *handlerSuite) Test_addressLimitedBeforeAuthentication() {
+	authenticator := auth.NewApiKeyAuthenticator([]auth.ApiKey{
+		{Key: "reader-key", Subject: "reader", Scopes: []string{auth.ScopeReadBalance}},
+	})
+
+	h := handlers.NewHandler(mocks.NewMockRepository(),
+		handlers.WithAuthenticator(authenticator),
+		handlers.WithRateLimits(ratelimit.NewMemoryStore(),
+			ratelimit.Limit{Rate: 0.01, Burst: 2},
+			ratelimit.Limit{},
+			ratelimit.Limit{},
+		))
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	get := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, testSrv.URL+"/balance/f0812ab6-9993-11ec-b909-0242ac120002", nil)
+		t.Require().NoError(err)
+		req.Header.Set("X-API-Key", "guessed-key")
+
+		resp, err := testSrv.Client().Do(req)
+		t.Require().NoError(err)
+		resp.Body.Close()
+
+		return resp
+	}
+
+	t.Equal(http.StatusUnauthorized, get().StatusCode)
+	t.Equal(http.StatusUnauthorized, get().StatusCode)
+
+	// guessing keys uses up the budget of the address
+	resp := get()
+	t.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	t.Equal("100", resp.Header.Get("Retry-After"))
+}
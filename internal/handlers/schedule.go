@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+)
+
+func (handler *handler) writeScheduleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, api.ErrorScheduleNotFound), errors.Is(err, api.ErrorUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, api.ErrorInvalidInput), errors.Is(err, api.ErrorInvalidSchedule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, api.ErrorScheduleState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+func (handler *handler) writeSchedule(w http.ResponseWriter, r *http.Request, schedule *models.Schedule, err error) {
+	if err != nil {
+		handler.writeScheduleError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// CreateSchedule godoc
+// @Summary      Schedule transfer
+// @Description  schedule a transfer once at run_at, or repeatedly by cron expression or interval_seconds. Failed runs are retried with exponential backoff up to max_retries times
+// @Tags         schedules
+// @Accept       json
+// @Produce      json
+// @Param   schedule   body    models.CreateScheduleQuery  true  "Schedule"
+// @Success 200 {object} models.Schedule
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /schedules [post]
+func (handler *handler) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var postData models.CreateScheduleQuery
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := handler.repository.CreateSchedule(r.Context(), postData)
+	handler.writeSchedule(w, r, schedule, err)
+}
+
+// GetSchedule godoc
+// @Summary      Get schedule
+// @Description  get a scheduled transfer with its status and next run
+// @Tags         schedules
+// @Produce      json
+// @Param   id   path    string  true  "Schedule ID"
+// @Success 200 {object} models.Schedule
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /schedules/{id} [get]
+func (handler *handler) getSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := handler.repository.GetSchedule(r.Context(), chi.URLParam(r, "id"))
+	handler.writeSchedule(w, r, schedule, err)
+}
+
+// GetScheduleRuns godoc
+// @Summary      Get schedule runs
+// @Description  get the execution history of a scheduled transfer, newest first
+// @Tags         schedules
+// @Produce      json
+// @Param   id   path    string  true  "Schedule ID"
+// @Success 200 {array} models.ScheduleRun
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /schedules/{id}/runs [get]
+func (handler *handler) getScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := handler.repository.GetScheduleRuns(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handler.writeScheduleError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(runs)
+}
+
+// PauseSchedule godoc
+// @Summary      Pause schedule
+// @Description  stop running an active schedule until it is resumed
+// @Tags         schedules
+// @Produce      json
+// @Param   id   path    string  true  "Schedule ID"
+// @Success 200 {object} models.Schedule
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /schedules/{id}/pause [post]
+func (handler *handler) pauseSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := handler.repository.PauseSchedule(r.Context(), chi.URLParam(r, "id"))
+	handler.writeSchedule(w, r, schedule, err)
+}
+
+// ResumeSchedule godoc
+// @Summary      Resume schedule
+// @Description  activate a paused schedule, recurring schedules skip the runs missed while paused
+// @Tags         schedules
+// @Produce      json
+// @Param   id   path    string  true  "Schedule ID"
+// @Success 200 {object} models.Schedule
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /schedules/{id}/resume [post]
+func (handler *handler) resumeSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := handler.repository.ResumeSchedule(r.Context(), chi.URLParam(r, "id"))
+	handler.writeSchedule(w, r, schedule, err)
+}
+
+// CancelSchedule godoc
+// @Summary      Cancel schedule
+// @Description  cancel an active or paused schedule for good
+// @Tags         schedules
+// @Produce      json
+// @Param   id   path    string  true  "Schedule ID"
+// @Success 200 {object} models.Schedule
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /schedules/{id}/cancel [post]
+func (handler *handler) cancelSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := handler.repository.CancelSchedule(r.Context(), chi.URLParam(r, "id"))
+	handler.writeSchedule(w, r, schedule, err)
+}
@@ -0,0 +1,70 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (t *handlerSuite) Test_createScheduleSuccess() {
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120003"
+	cron := "0 10 1 * *"
+	nextRunAt := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	query := models.CreateScheduleQuery{FromId: fromId, ToId: toId, Money: 50, Cron: cron}
+
+	rep := mocks.NewMockRepository()
+	rep.On("CreateSchedule", query).Return(&models.Schedule{
+		Id:         "34be95d0-9a41-11ec-b909-0242ac120010",
+		FromId:     fromId,
+		ToId:       toId,
+		Money:      50,
+		Cron:       &cron,
+		NextRunAt:  &nextRunAt,
+		Status:     "active",
+		MaxRetries: 3,
+	}, nil)
+
+	resp := t.post(rep, "/schedules", query)
+	defer resp.Body.Close()
+
+	schedule := models.Schedule{}
+	json.NewDecoder(resp.Body).Decode(&schedule)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("active", schedule.Status)
+	t.Equal(nextRunAt, *schedule.NextRunAt)
+}
+
+func (t *handlerSuite) Test_createScheduleInvalid() {
+	query := models.CreateScheduleQuery{
+		FromId: "f0812ab6-9993-11ec-b909-0242ac120002",
+		ToId:   "f0812ab6-9993-11ec-b909-0242ac120003",
+		Money:  50,
+		Cron:   "every monday",
+	}
+
+	rep := mocks.NewMockRepository()
+	rep.On("CreateSchedule", query).Return(nil, api.ErrorInvalidSchedule)
+
+	resp := t.post(rep, "/schedules", query)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_resumeActiveSchedule() {
+	scheduleId := "34be95d0-9a41-11ec-b909-0242ac120010"
+
+	rep := mocks.NewMockRepository()
+	rep.On("ResumeSchedule", scheduleId).Return(nil, api.ErrorScheduleState)
+
+	resp := t.post(rep, "/schedules/"+scheduleId+"/resume", nil)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+	"time"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+// BalanceStream delivers the events of an account as they are committed.
+type BalanceStream interface {
+	Subscribe(uid string) (<-chan models.Event, func())
+}
+
+// WithBalanceStream enables GET /balance/{uid}/stream.
+func WithBalanceStream(stream BalanceStream) Option {
+	return func(h *handler) {
+		h.stream = stream
+	}
+}
+
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, id string, update *models.BalanceUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err = fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "event: balance\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+
+	flusher.Flush()
+
+	return nil
+}
+
+// StreamBalance godoc
+// @Summary      Stream account balance
+// @Description  server-sent events stream of the account balance. The current balance is sent first, then every change with the event that caused it. The stream ends when the client falls behind, clients reconnect and get the current balance again
+// @Tags         users
+// @Produce      text/event-stream
+// @Param   uid   path    string  true  "User account ID" default(34be95d0-9a41-11ec-b909-0242ac120003)
+// @Success 200 {object} models.BalanceUpdate
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Failure      501  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /balance/{uid}/stream [get]
+func (handler *handler) streamBalance(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if handler.stream == nil || !ok {
+		http.Error(w, "balance streaming is not available", http.StatusNotImplemented)
+		return
+	}
+
+	uid := chi.URLParam(r, "uid")
+
+	// subscribe before reading the balance, so that no change falls in between
+	events, unsubscribe := handler.stream.Subscribe(uid)
+	defer unsubscribe()
+
+	user, err := handler.repository.GetBalance(r.Context(), uid)
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrorUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, api.ErrorInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			logging.FromContext(r.Context()).Error(err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if err = writeSSE(w, flusher, "", &models.BalanceUpdate{AccountId: uid, Balance: user.Balance}); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			var data models.AccountEventData
+			if err = json.Unmarshal(event.Data, &data); err != nil || data.Balance == nil {
+				continue
+			}
+
+			update := &models.BalanceUpdate{AccountId: uid, Balance: *data.Balance, Transaction: &event}
+			if err = writeSSE(w, flusher, fmt.Sprint(event.Sequence), update); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+type channelStream struct {
+	events chan models.Event
+}
+
+func (s *channelStream) Subscribe(uid string) (<-chan models.Event, func()) {
+	return s.events, func() {}
+}
+
+func readSSE(reader *bufio.Reader) (map[string]string, error) {
+	fields := map[string]string{}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return fields, nil
+		}
+
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			fields[key] = value
+		}
+	}
+}
+
+func (t *handlerSuite) Test_streamBalance() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	events := make(chan models.Event, 2)
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetBalance", userId).Return(&models.User{Id: userId, Balance: 10}, nil)
+
+	h := handlers.NewHandler(rep, handlers.WithBalanceStream(&channelStream{events}))
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/balance/" + userId + "/stream")
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+
+	fields, err := readSSE(reader)
+	t.Require().NoError(err)
+
+	update := models.BalanceUpdate{}
+	t.Require().NoError(json.Unmarshal([]byte(fields["data"]), &update))
+	t.Equal(10.0, update.Balance)
+	t.Nil(update.Transaction)
+
+	events <- models.Event{Sequence: 7, Type: models.EventReserveRecognized, AccountId: userId, Data: json.RawMessage(`{"amount":5}`)}
+	events <- models.Event{Sequence: 8, Type: models.EventDeposited, AccountId: userId, Data: json.RawMessage(`{"amount":5,"balance":15}`)}
+
+	fields, err = readSSE(reader)
+	t.Require().NoError(err)
+
+	update = models.BalanceUpdate{}
+	t.Require().NoError(json.Unmarshal([]byte(fields["data"]), &update))
+	t.Equal("8", fields["id"])
+	t.Equal("balance", fields["event"])
+	t.Equal(15.0, update.Balance)
+	t.Equal(models.EventDeposited, update.Transaction.Type)
+}
+
+func (t *handlerSuite) Test_streamBalanceNotConfigured() {
+	rep := mocks.NewMockRepository()
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/balance/f0812ab6-9993-11ec-b909-0242ac120002/stream")
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusNotImplemented, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_timeoutSparesOnlyStreams() {
+	userId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	events := make(chan models.Event, 1)
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetBalance", userId).After(100*time.Millisecond).Return(&models.User{Id: userId, Balance: 10}, nil)
+
+	h := handlers.NewHandler(rep, handlers.WithBalanceStream(&channelStream{events}), handlers.WithTimeout(20*time.Millisecond))
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	// asking for a stream does not lift the limit of other routes
+	req, err := http.NewRequest(http.MethodGet, testSrv.URL+"/balance/"+userId, nil)
+	t.Require().NoError(err)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := testSrv.Client().Do(req)
+	t.Require().NoError(err)
+	resp.Body.Close()
+	t.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+
+	resp, err = testSrv.Client().Get(testSrv.URL + "/balance/" + userId + "/stream")
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+	t.Equal(http.StatusOK, resp.StatusCode)
+
+	reader := bufio.NewReader(resp.Body)
+
+	_, err = readSSE(reader)
+	t.Require().NoError(err)
+
+	events <- models.Event{Sequence: 1, Type: models.EventDeposited, AccountId: userId, Data: json.RawMessage(`{"amount":5,"balance":15}`)}
+
+	fields, err := readSSE(reader)
+	t.Require().NoError(err)
+	t.Equal("1", fields["id"])
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/siraj18/balance-service-new/internal/metrics"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/tracing"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rejections are the errors the repository turns requests down with, as
+// opposed to its failures.
+var rejections = []error{
+	api.ErrorUserNotFound, api.ErrorNotEnoughMoney, api.ErrorInvalidInput,
+	api.ErrorNegativeAmount, api.ErrorInvalidSortParameters,
+	api.ErrorReserveNotFound, api.ErrorReserveAlreadyRecognized, api.ErrorReserveAlreadyDeReserved,
+	api.ErrorAccountAlreadyExists, api.ErrorAccountFrozen, api.ErrorAccountClosed,
+	api.ErrorAccountNotEmpty, api.ErrorAccountHasReserves, api.ErrorCreditLimitInUse,
+	api.ErrorLimitExceeded, api.ErrorLimitsNotFound, api.ErrorInvalidLimits,
+	api.ErrorScheduleNotFound, api.ErrorInvalidSchedule, api.ErrorScheduleState,
+	api.ErrorWebhookNotFound, api.ErrorInvalidWebhook,
+	api.ErrorWebhookDeliveryNotFound, api.ErrorWebhookDeliveryNotDead,
+	api.ErrorForbidden,
+}
+
+func outcome(err error) string {
+	if err == nil {
+		return tracing.OutcomeSuccess
+	}
+
+	for _, rejection := range rejections {
+		if errors.Is(err, rejection) {
+			return tracing.OutcomeRejected
+		}
+	}
+
+	return tracing.OutcomeFailed
+}
+
+func amountAttributes(operation string, amount float64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		tracing.OperationKey.String(operation),
+		tracing.AmountBucketKey.String(tracing.AmountBucket(amount)),
+	}
+}
+
+func changeAttributes(money float64) []attribute.KeyValue {
+	if money < 0 {
+		return amountAttributes(metrics.OperationWithdrawal, money)
+	}
+
+	return amountAttributes(metrics.OperationDeposit, money)
+}
+
+// tracedRepository starts a span for every call of the repository, a child of
+// the span of the request, so that the time spent in the repository and its
+// statements can be told apart from the rest of the request.
+type tracedRepository struct {
+	Repository
+}
+
+func (r *tracedRepository) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "Repository."+method, trace.WithAttributes(attrs...))
+}
+
+func (r *tracedRepository) end(span trace.Span, err error) {
+	tracing.End(span, outcome(err), err)
+}
+
+func (r *tracedRepository) GetBalance(ctx context.Context, id string) (result *models.User, err error) {
+	ctx, span := r.start(ctx, "GetBalance")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetBalance(ctx, id)
+}
+
+func (r *tracedRepository) ChangeBalance(ctx context.Context, id string, money float64) (result *models.User, err error) {
+	ctx, span := r.start(ctx, "ChangeBalance", changeAttributes(money)...)
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.ChangeBalance(ctx, id, money)
+}
+
+func (r *tracedRepository) TransferBalance(ctx context.Context, fromId string, toId string, money float64) (err error) {
+	ctx, span := r.start(ctx, "TransferBalance", amountAttributes(metrics.OperationTransfer, money)...)
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.TransferBalance(ctx, fromId, toId, money)
+}
+
+func (r *tracedRepository) GetAllTransactions(ctx context.Context, id string, sortType string, limit int, page int) (result *[]models.Transaction, err error) {
+	ctx, span := r.start(ctx, "GetAllTransactions")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetAllTransactions(ctx, id, sortType, limit, page)
+}
+
+func (r *tracedRepository) ReserveMoney(ctx context.Context, userId string, serviceId string, orderId string, amount float64) (err error) {
+	ctx, span := r.start(ctx, "ReserveMoney", amountAttributes(metrics.OperationReserve, amount)...)
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.ReserveMoney(ctx, userId, serviceId, orderId, amount)
+}
+
+func (r *tracedRepository) RecognizedMoney(ctx context.Context, userId string, serviceId string, orderId string, amount float64) (err error) {
+	ctx, span := r.start(ctx, "RecognizedMoney", amountAttributes(metrics.OperationRecognize, amount)...)
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.RecognizedMoney(ctx, userId, serviceId, orderId, amount)
+}
+
+func (r *tracedRepository) DeReserveMoney(ctx context.Context, userId string, serviceId string, orderId string, amount float64) (err error) {
+	ctx, span := r.start(ctx, "DeReserveMoney", amountAttributes(metrics.OperationDeReserve, amount)...)
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.DeReserveMoney(ctx, userId, serviceId, orderId, amount)
+}
+
+func (r *tracedRepository) GetReserves(ctx context.Context, year int, month int) (result *[]models.Reserve, err error) {
+	ctx, span := r.start(ctx, "GetReserves")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetReserves(ctx, year, month)
+}
+
+func (r *tracedRepository) CreateAccount(ctx context.Context, id string, owner string, metadata models.Metadata) (result *models.Account, err error) {
+	ctx, span := r.start(ctx, "CreateAccount")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.CreateAccount(ctx, id, owner, metadata)
+}
+
+func (r *tracedRepository) GetAccount(ctx context.Context, id string) (result *models.Account, err error) {
+	ctx, span := r.start(ctx, "GetAccount")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetAccount(ctx, id)
+}
+
+func (r *tracedRepository) FreezeAccount(ctx context.Context, id string, debits bool, credits bool) (result *models.Account, err error) {
+	ctx, span := r.start(ctx, "FreezeAccount")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.FreezeAccount(ctx, id, debits, credits)
+}
+
+func (r *tracedRepository) UnfreezeAccount(ctx context.Context, id string) (result *models.Account, err error) {
+	ctx, span := r.start(ctx, "UnfreezeAccount")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.UnfreezeAccount(ctx, id)
+}
+
+func (r *tracedRepository) CloseAccount(ctx context.Context, id string) (result *models.Account, err error) {
+	ctx, span := r.start(ctx, "CloseAccount")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.CloseAccount(ctx, id)
+}
+
+func (r *tracedRepository) GetLimits(ctx context.Context, scope string) (result *models.Limits, err error) {
+	ctx, span := r.start(ctx, "GetLimits")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetLimits(ctx, scope)
+}
+
+func (r *tracedRepository) SetLimits(ctx context.Context, scope string, limits models.Limits) (result *models.Limits, err error) {
+	ctx, span := r.start(ctx, "SetLimits")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.SetLimits(ctx, scope, limits)
+}
+
+func (r *tracedRepository) DeleteLimits(ctx context.Context, scope string) (err error) {
+	ctx, span := r.start(ctx, "DeleteLimits")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.DeleteLimits(ctx, scope)
+}
+
+func (r *tracedRepository) SetCreditLimit(ctx context.Context, id string, creditLimit float64) (result *models.Account, err error) {
+	ctx, span := r.start(ctx, "SetCreditLimit")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.SetCreditLimit(ctx, id, creditLimit)
+}
+
+func (r *tracedRepository) GetAccountsUsingCredit(ctx context.Context) (result *[]models.Account, err error) {
+	ctx, span := r.start(ctx, "GetAccountsUsingCredit")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetAccountsUsingCredit(ctx)
+}
+
+func (r *tracedRepository) CreateSchedule(ctx context.Context, query models.CreateScheduleQuery) (result *models.Schedule, err error) {
+	ctx, span := r.start(ctx, "CreateSchedule")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.CreateSchedule(ctx, query)
+}
+
+func (r *tracedRepository) GetSchedule(ctx context.Context, id string) (result *models.Schedule, err error) {
+	ctx, span := r.start(ctx, "GetSchedule")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetSchedule(ctx, id)
+}
+
+func (r *tracedRepository) GetScheduleRuns(ctx context.Context, id string) (result *[]models.ScheduleRun, err error) {
+	ctx, span := r.start(ctx, "GetScheduleRuns")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetScheduleRuns(ctx, id)
+}
+
+func (r *tracedRepository) PauseSchedule(ctx context.Context, id string) (result *models.Schedule, err error) {
+	ctx, span := r.start(ctx, "PauseSchedule")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.PauseSchedule(ctx, id)
+}
+
+func (r *tracedRepository) ResumeSchedule(ctx context.Context, id string) (result *models.Schedule, err error) {
+	ctx, span := r.start(ctx, "ResumeSchedule")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.ResumeSchedule(ctx, id)
+}
+
+func (r *tracedRepository) CancelSchedule(ctx context.Context, id string) (result *models.Schedule, err error) {
+	ctx, span := r.start(ctx, "CancelSchedule")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.CancelSchedule(ctx, id)
+}
+
+func (r *tracedRepository) ExecuteBatch(ctx context.Context, atomic bool, operations []models.BatchOperation) (result *models.BatchResult, err error) {
+	ctx, span := r.start(ctx, "ExecuteBatch")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.ExecuteBatch(ctx, atomic, operations)
+}
+
+func (r *tracedRepository) ImportBalances(ctx context.Context, rows []models.ImportRow, dryRun bool) (result *models.ImportResult, err error) {
+	ctx, span := r.start(ctx, "ImportBalances")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.ImportBalances(ctx, rows, dryRun)
+}
+
+func (r *tracedRepository) CreateWebhook(ctx context.Context, query models.CreateWebhookQuery) (result *models.CreatedWebhook, err error) {
+	ctx, span := r.start(ctx, "CreateWebhook")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.CreateWebhook(ctx, query)
+}
+
+func (r *tracedRepository) GetWebhook(ctx context.Context, id string) (result *models.WebhookSubscription, err error) {
+	ctx, span := r.start(ctx, "GetWebhook")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetWebhook(ctx, id)
+}
+
+func (r *tracedRepository) DeleteWebhook(ctx context.Context, id string) (result *models.WebhookSubscription, err error) {
+	ctx, span := r.start(ctx, "DeleteWebhook")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.DeleteWebhook(ctx, id)
+}
+
+func (r *tracedRepository) GetWebhookDeliveries(ctx context.Context, id string, status string) (result *[]models.WebhookDelivery, err error) {
+	ctx, span := r.start(ctx, "GetWebhookDeliveries")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.GetWebhookDeliveries(ctx, id, status)
+}
+
+func (r *tracedRepository) RetryWebhookDelivery(ctx context.Context, id string, deliveryId string) (result *models.WebhookDelivery, err error) {
+	ctx, span := r.start(ctx, "RetryWebhookDelivery")
+	defer func() { r.end(span, err) }()
+
+	return r.Repository.RetryWebhookDelivery(ctx, id, deliveryId)
+}
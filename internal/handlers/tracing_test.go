@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/tracing"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func (t *handlerSuite) Test_tracesRepositoryCalls() {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.Install(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	fromId := "f0812ab6-9993-11ec-b909-0242ac120002"
+	toId := "f0812ab6-9993-11ec-b909-0242ac120003"
+
+	rep := mocks.NewMockRepository()
+	rep.On("TransferBalance", fromId, toId, 50.0).Return(api.ErrorNotEnoughMoney)
+
+	testSrv := httptest.NewServer(handlers.NewHandler(rep).InitRoutes())
+	defer testSrv.Close()
+
+	body := []byte(`{"from_id": "` + fromId + `", "to_id": "` + toId + `", "money": 50}`)
+	resp, err := testSrv.Client().Post(testSrv.URL+"/transferBalance", "application/json", bytes.NewReader(body))
+	t.Require().NoError(err)
+	resp.Body.Close()
+
+	spans := exporter.GetSpans()
+	t.Require().Len(spans, 2)
+
+	repository, request := spans[0], spans[1]
+	t.Equal("Repository.TransferBalance", repository.Name)
+	t.Equal("POST /transferBalance", request.Name)
+	t.Equal(request.SpanContext.SpanID(), repository.Parent.SpanID())
+
+	attributes := map[string]string{}
+	for _, attr := range repository.Attributes {
+		attributes[string(attr.Key)] = attr.Value.Emit()
+	}
+
+	t.Equal("transfer", attributes[string(tracing.OperationKey)])
+	t.Equal("10-100", attributes[string(tracing.AmountBucketKey)])
+	t.Equal(tracing.OutcomeRejected, attributes[string(tracing.OutcomeKey)])
+	t.Equal(http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/logging"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"net/http"
+)
+
+func (handler *handler) writeWebhook(w http.ResponseWriter, r *http.Request, v interface{}, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrorWebhookNotFound), errors.Is(err, api.ErrorWebhookDeliveryNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, api.ErrorInvalidInput), errors.Is(err, api.ErrorInvalidWebhook):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, api.ErrorWebhookDeliveryNotDead):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			logging.FromContext(r.Context()).Error(err)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(v)
+}
+
+// CreateWebhook godoc
+// @Summary      Create webhook
+// @Description  subscribe an url to event types ("*" for all). Deliveries are signed with the secret, which is generated when omitted and only returned here
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param   webhook   body    models.CreateWebhookQuery  true  "Webhook"
+// @Success 200 {object} models.CreatedWebhook
+// @Failure      400  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /webhooks [post]
+func (handler *handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var postData models.CreateWebhookQuery
+
+	err := json.NewDecoder(r.Body).Decode(&postData)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, "invalid post data", http.StatusBadRequest)
+		return
+	}
+
+	// the secret must not be kept by caches nor by the idempotency store
+	w.Header().Set("Cache-Control", "no-store")
+
+	webhook, err := handler.repository.CreateWebhook(r.Context(), postData)
+	handler.writeWebhook(w, r, webhook, err)
+}
+
+// GetWebhook godoc
+// @Summary      Get webhook
+// @Description  get a webhook subscription
+// @Tags         webhooks
+// @Produce      json
+// @Param   id   path    string  true  "Webhook ID"
+// @Success 200 {object} models.WebhookSubscription
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /webhooks/{id} [get]
+func (handler *handler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := handler.repository.GetWebhook(r.Context(), chi.URLParam(r, "id"))
+	handler.writeWebhook(w, r, webhook, err)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete webhook
+// @Description  deactivate a webhook subscription, its delivery log is kept
+// @Tags         webhooks
+// @Produce      json
+// @Param   id   path    string  true  "Webhook ID"
+// @Success 200 {object} models.WebhookSubscription
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /webhooks/{id} [delete]
+func (handler *handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := handler.repository.DeleteWebhook(r.Context(), chi.URLParam(r, "id"))
+	handler.writeWebhook(w, r, webhook, err)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary      Get webhook deliveries
+// @Description  get the latest 100 deliveries of a webhook with their attempts and last outcome, optionally filtered by status
+// @Tags         webhooks
+// @Produce      json
+// @Param   id   path    string  true  "Webhook ID"
+// @Param   status   query    string  false  "Delivery status" Enums(pending, delivered, dead)
+// @Success 200 {array} models.WebhookDelivery
+// @Failure      400  {string} string
+// @Failure      404  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func (handler *handler) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := handler.repository.GetWebhookDeliveries(r.Context(), chi.URLParam(r, "id"), r.URL.Query().Get("status"))
+	handler.writeWebhook(w, r, deliveries, err)
+}
+
+// RetryWebhookDelivery godoc
+// @Summary      Retry dead webhook delivery
+// @Description  move a dead delivery back to pending with a fresh attempt budget
+// @Tags         webhooks
+// @Produce      json
+// @Param   id   path    string  true  "Webhook ID"
+// @Param   deliveryId   path    string  true  "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure      404  {string} string
+// @Failure      409  {string} string
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router /webhooks/{id}/deliveries/{deliveryId}/retry [post]
+func (handler *handler) retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := handler.repository.RetryWebhookDelivery(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "deliveryId"))
+	handler.writeWebhook(w, r, delivery, err)
+}
@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+func (t *handlerSuite) Test_createWebhookSuccess() {
+	query := models.CreateWebhookQuery{Url: "https://partner.example.com/hooks", EventTypes: []string{models.EventTransferCredited}}
+
+	rep := mocks.NewMockRepository()
+	rep.On("CreateWebhook", query).Return(&models.CreatedWebhook{
+		WebhookSubscription: models.WebhookSubscription{
+			Id:         "34be95d0-9a41-11ec-b909-0242ac120020",
+			Url:        query.Url,
+			EventTypes: query.EventTypes,
+			Active:     true,
+		},
+		Secret: "generated",
+	}, nil)
+
+	resp := t.post(rep, "/webhooks", query)
+	defer resp.Body.Close()
+
+	webhook := models.CreatedWebhook{}
+	json.NewDecoder(resp.Body).Decode(&webhook)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("no-store", resp.Header.Get("Cache-Control"))
+	t.Equal("generated", webhook.Secret)
+	t.True(webhook.Active)
+}
+
+func (t *handlerSuite) Test_getWebhookWithoutSecret() {
+	webhookId := "34be95d0-9a41-11ec-b909-0242ac120020"
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetWebhook", webhookId).Return(&models.WebhookSubscription{
+		Id:     webhookId,
+		Url:    "https://partner.example.com/hooks",
+		Secret: "stored",
+		Active: true,
+	}, nil)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/webhooks/" + webhookId)
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	t.Nil(err)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.NotContains(string(body), "secret")
+	t.NotContains(string(body), "stored")
+}
+
+func (t *handlerSuite) Test_createWebhookInvalid() {
+	query := models.CreateWebhookQuery{Url: "ftp://partner.example.com", EventTypes: []string{models.AllEvents}}
+
+	rep := mocks.NewMockRepository()
+	rep.On("CreateWebhook", query).Return(nil, api.ErrorInvalidWebhook)
+
+	resp := t.post(rep, "/webhooks", query)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (t *handlerSuite) Test_getDeadWebhookDeliveries() {
+	webhookId := "34be95d0-9a41-11ec-b909-0242ac120020"
+	lastError := "unexpected status 500"
+
+	rep := mocks.NewMockRepository()
+	rep.On("GetWebhookDeliveries", webhookId, models.WebhookDead).Return(&[]models.WebhookDelivery{
+		{Id: "34be95d0-9a41-11ec-b909-0242ac120021", SubscriptionId: webhookId, Status: models.WebhookDead, Attempt: 10, LastError: &lastError},
+	}, nil)
+
+	h := handlers.NewHandler(rep)
+	testSrv := httptest.NewServer(h.InitRoutes())
+	defer testSrv.Close()
+
+	resp, err := testSrv.Client().Get(testSrv.URL + "/webhooks/" + webhookId + "/deliveries?status=dead")
+	t.Nil(err)
+	defer resp.Body.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	json.NewDecoder(resp.Body).Decode(&deliveries)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Len(deliveries, 1)
+	t.Equal(lastError, *deliveries[0].LastError)
+}
+
+func (t *handlerSuite) Test_retryPendingWebhookDelivery() {
+	webhookId := "34be95d0-9a41-11ec-b909-0242ac120020"
+	deliveryId := "34be95d0-9a41-11ec-b909-0242ac120021"
+
+	rep := mocks.NewMockRepository()
+	rep.On("RetryWebhookDelivery", webhookId, deliveryId).Return(nil, api.ErrorWebhookDeliveryNotDead)
+
+	resp := t.post(rep, "/webhooks/"+webhookId+"/deliveries/"+deliveryId+"/retry", nil)
+	defer resp.Body.Close()
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+}
@@ -0,0 +1,145 @@
+// Package health reports whether the service is alive and whether it is
+// ready to serve requests, with the result of every dependency check.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOk          = "ok"
+	StatusFailed      = "failed"
+	StatusUnavailable = "unavailable"
+)
+
+var ErrorDraining = fmt.Errorf("shutting down")
+
+// Check is a dependency the service needs to serve requests.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewChecker runs checks for readiness, each limited to timeout.
+func NewChecker(timeout time.Duration, checks ...Check) *checker {
+	return &checker{checks: checks, timeout: timeout}
+}
+
+// Drain makes the service report itself not ready from now on, so that load
+// balancers stop sending it requests before it shuts down.
+func (c *checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Live reports that the process is up, it runs no checks.
+func (c *checker) Live(w http.ResponseWriter, r *http.Request) {
+	write(w, &Report{Status: StatusOk, Checks: map[string]CheckResult{"process": {Status: StatusOk}}})
+}
+
+// Ready runs every check concurrently and responds 503 when one of them
+// fails or the service is draining.
+func (c *checker) Ready(w http.ResponseWriter, r *http.Request) {
+	write(w, c.Run(r.Context()))
+}
+
+// Run returns the report of every check.
+func (c *checker) Run(ctx context.Context) *Report {
+	report := &Report{Status: StatusOk, Checks: make(map[string]CheckResult, len(c.checks)+1)}
+
+	if c.draining.Load() {
+		report.Status = StatusUnavailable
+		report.Checks["shutdown"] = CheckResult{Status: StatusFailed, Error: ErrorDraining.Error()}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusOk {
+				report.Status = StatusUnavailable
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := CheckResult{Status: StatusOk, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status, result.Error = StatusFailed, err.Error()
+	}
+
+	return result
+}
+
+func write(w http.ResponseWriter, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusOk {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// Pinger is implemented by *sql.DB and *sqlx.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Database checks that db accepts connections.
+func Database(db Pinger) Check {
+	return Check{Name: "database", Run: db.PingContext}
+}
+
+// Writable checks that files can be created in folder.
+func Writable(name, folder string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		file, err := os.CreateTemp(folder, ".healthcheck-*")
+		if err != nil {
+			return err
+		}
+		file.Close()
+
+		return os.Remove(file.Name())
+	}}
+}
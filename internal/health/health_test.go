@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ready(t *testing.T, c *checker) (int, *Report) {
+	rec := httptest.NewRecorder()
+	c.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	report := &Report{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(report))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	return rec.Code, report
+}
+
+func TestReady(t *testing.T) {
+	c := NewChecker(time.Second,
+		Check{Name: "database", Run: func(ctx context.Context) error { return nil }},
+		Writable("reports", t.TempDir()),
+	)
+
+	code, report := ready(t, c)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, StatusOk, report.Status)
+	require.Equal(t, StatusOk, report.Checks["database"].Status)
+	require.Equal(t, StatusOk, report.Checks["reports"].Status)
+}
+
+func TestNotReadyWhenACheckFails(t *testing.T) {
+	c := NewChecker(20*time.Millisecond,
+		Check{Name: "database", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		Check{Name: "schema", Run: func(ctx context.Context) error { return fmt.Errorf("outdated") }},
+		Writable("reports", filepath.Join(t.TempDir(), "missing")),
+	)
+
+	start := time.Now()
+	code, report := ready(t, c)
+	require.Less(t, time.Since(start), time.Second)
+
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, StatusUnavailable, report.Status)
+	require.Equal(t, CheckResult{Status: StatusFailed, Error: "outdated"}, report.Checks["schema"])
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks["database"].Error)
+	require.Equal(t, StatusFailed, report.Checks["reports"].Status)
+}
+
+func TestDrain(t *testing.T) {
+	c := NewChecker(time.Second)
+
+	code, _ := ready(t, c)
+	require.Equal(t, http.StatusOK, code)
+
+	c.Drain()
+
+	code, report := ready(t, c)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, ErrorDraining.Error(), report.Checks["shutdown"].Error)
+
+	rec := httptest.NewRecorder()
+	c.Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+type memoryEntry struct {
+	fingerprint string
+	response    *models.IdempotentResponse
+}
+
+// MemoryStore keeps idempotency keys in memory without expiring them, it is
+// meant for tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *MemoryStore) StartRequest(ctx context.Context, actor, key, fingerprint string) (*models.IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[actor+"/"+key]
+	switch {
+	case !ok:
+		s.entries[actor+"/"+key] = &memoryEntry{fingerprint: fingerprint}
+		return nil, nil
+	case entry.fingerprint != fingerprint:
+		return nil, api.ErrorIdempotencyKeyReused
+	case entry.response == nil:
+		return nil, api.ErrorIdempotencyKeyInProgress
+	default:
+		return entry.response, nil
+	}
+}
+
+func (s *MemoryStore) CompleteRequest(ctx context.Context, actor, key string, response *models.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[actor+"/"+key]; ok {
+		entry.response = response
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ReleaseRequest(ctx context.Context, actor, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[actor+"/"+key]; ok && entry.response == nil {
+		delete(s.entries, actor+"/"+key)
+	}
+
+	return nil
+}
+
+// Pending reports whether a request with key of actor is being served.
+func (s *MemoryStore) Pending(actor, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[actor+"/"+key]
+
+	return ok && entry.response == nil
+}
@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/siraj18/balance-service-new/internal/audit"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/response"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/sirupsen/logrus"
+)
+
+const maxKeyLength = 255
+
+// replayedHeaders are stored with a response. X-Content-Type-Options tells
+// errors reported with status 200 apart from successful responses.
+var replayedHeaders = []string{"Content-Type", "X-Content-Type-Options"}
+
+type Store interface {
+	StartRequest(ctx context.Context, actor, key, fingerprint string) (*models.IdempotentResponse, error)
+	CompleteRequest(ctx context.Context, actor, key string, response *models.IdempotentResponse) error
+	ReleaseRequest(ctx context.Context, actor, key string) error
+}
+
+// Middleware serves a mutating request carrying an Idempotency-Key header at
+// most once per caller and key, and replays the stored response to repeated
+// requests. Responses with a server error are not stored, so the request can
+// be retried, and neither are the ones marked Cache-Control: no-store, which
+// carry secrets.
+func Middleware(store Store, logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(api.IdempotencyKeyHeader)
+			if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxKeyLength {
+				http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+				return
+			}
+
+			payload, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "invalid post data", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(payload))
+
+			actor := auth.Actor(r.Context())
+			fingerprint := r.Method + " " + r.URL.RequestURI() + " " + audit.HashPayload(payload)
+
+			stored, err := store.StartRequest(r.Context(), actor, key, fingerprint)
+			switch {
+			case errors.Is(err, api.ErrorIdempotencyKeyInProgress):
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case errors.Is(err, api.ErrorIdempotencyKeyReused):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			case err != nil:
+				w.WriteHeader(http.StatusInternalServerError)
+				logger.Error(err)
+				return
+			}
+
+			if stored != nil {
+				replay(w, stored)
+				return
+			}
+
+			ww := response.NewBodyWriter(w)
+			next.ServeHTTP(ww, r)
+
+			// the request may have been cancelled by the client, which is the
+			// usual reason to retry it
+			ctx := context.Background()
+
+			if ww.Failed() || noStore(w.Header()) {
+				if err := store.ReleaseRequest(ctx, actor, key); err != nil {
+					logger.Error(err)
+				}
+				return
+			}
+
+			result := &models.IdempotentResponse{Status: ww.Status(), Header: models.Metadata{}, Body: ww.Body()}
+			for _, header := range replayedHeaders {
+				if value := w.Header().Get(header); value != "" {
+					result.Header[header] = value
+				}
+			}
+
+			if err := store.CompleteRequest(ctx, actor, key, result); err != nil {
+				logger.Error(err)
+			}
+		})
+	}
+}
+
+func noStore(header http.Header) bool {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func replay(w http.ResponseWriter, response *models.IdempotentResponse) {
+	for header, value := range response.Header {
+		w.Header().Set(header, value)
+	}
+	w.Header().Set(api.IdempotentReplayedHeader, "true")
+
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
@@ -0,0 +1,164 @@
+package idempotency_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/idempotency"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type middlewareSuite struct {
+	suite.Suite
+	store   *idempotency.MemoryStore
+	server  *httptest.Server
+	calls   int
+	status  int
+	noStore bool
+	release chan struct{}
+}
+
+func TestMiddlewareSuite(t *testing.T) {
+	suite.Run(t, new(middlewareSuite))
+}
+
+func (t *middlewareSuite) SetupTest() {
+	t.store = idempotency.NewMemoryStore()
+	t.calls = 0
+	t.status = http.StatusOK
+	t.noStore = false
+	t.release = nil
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.calls++
+		if t.release != nil {
+			<-t.release
+		}
+
+		if t.noStore {
+			w.Header().Set("Cache-Control", "private, no-store")
+		}
+
+		if t.status != http.StatusOK {
+			http.Error(w, "not enough money", t.status)
+			return
+		}
+
+		fmt.Fprintf(w, "call %d", t.calls)
+	})
+
+	t.server = httptest.NewServer(idempotency.Middleware(t.store, logrus.New())(next))
+}
+
+func (t *middlewareSuite) TearDownTest() {
+	t.server.Close()
+}
+
+func (t *middlewareSuite) post(key, body string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodPost, t.server.URL+"/transferBalance", strings.NewReader(body))
+	t.Require().NoError(err)
+
+	if key != "" {
+		req.Header.Set(api.IdempotencyKeyHeader, key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	t.Require().NoError(err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	t.Require().NoError(err)
+
+	return resp, string(data)
+}
+
+func (t *middlewareSuite) Test_replaysResponse() {
+	resp, body := t.post("key-1", `{"money": 10}`)
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("call 1", body)
+	t.Empty(resp.Header.Get(api.IdempotentReplayedHeader))
+
+	resp, body = t.post("key-1", `{"money": 10}`)
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("call 1", body)
+	t.Equal("true", resp.Header.Get(api.IdempotentReplayedHeader))
+
+	t.Equal(1, t.calls)
+}
+
+func (t *middlewareSuite) Test_replaysBusinessErrors() {
+	t.status = http.StatusConflict
+
+	t.post("key-1", `{}`)
+	resp, body := t.post("key-1", `{}`)
+
+	t.Equal(http.StatusConflict, resp.StatusCode)
+	t.Equal("not enough money\n", body)
+	t.Equal("nosniff", resp.Header.Get("X-Content-Type-Options"))
+	t.Equal(1, t.calls)
+}
+
+func (t *middlewareSuite) Test_withoutKey() {
+	t.post("", `{}`)
+	t.post("", `{}`)
+
+	t.Equal(2, t.calls)
+}
+
+func (t *middlewareSuite) Test_reusedKey() {
+	t.post("key-1", `{"money": 10}`)
+	resp, _ := t.post("key-1", `{"money": 20}`)
+
+	t.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	t.Equal(1, t.calls)
+}
+
+func (t *middlewareSuite) Test_serverErrorIsRetried() {
+	t.status = http.StatusInternalServerError
+	t.post("key-1", `{}`)
+
+	t.status = http.StatusOK
+	resp, body := t.post("key-1", `{}`)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("call 2", body)
+}
+
+func (t *middlewareSuite) Test_noStoreIsNotKept() {
+	t.noStore = true
+	t.post("key-1", `{}`)
+
+	resp, body := t.post("key-1", `{}`)
+
+	t.Equal(http.StatusOK, resp.StatusCode)
+	t.Equal("call 2", body)
+	t.Empty(resp.Header.Get(api.IdempotentReplayedHeader))
+}
+
+func (t *middlewareSuite) Test_inProgress() {
+	t.release = make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t.post("key-1", `{}`)
+	}()
+
+	// wait until the first request holds the key
+	for !t.store.Pending("anonymous", "key-1") {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, _ := t.post("key-1", `{}`)
+	t.Equal(http.StatusConflict, resp.StatusCode)
+
+	close(t.release)
+	<-done
+}
@@ -0,0 +1,170 @@
+// Package lifecycle starts the components of the service and stops them in
+// the reverse order on shutdown, each within its own timeout.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Component is a part of the service with a lifetime, such as a server, a
+// background worker or a connection pool.
+type Component struct {
+	Name string
+	// Run runs the component until its context is cancelled or Stop is
+	// called. Components that only need stopping leave it nil.
+	Run func(ctx context.Context) error
+	// Stop stops the component and waits for its work in flight until ctx
+	// expires. When nil, cancelling the context of Run stops the component.
+	Stop func(ctx context.Context) error
+	// Timeout bounds stopping the component, zero waits as long as it takes.
+	Timeout time.Duration
+}
+
+type running struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type manager struct {
+	components []Component
+	logger     *logrus.Logger
+}
+
+func NewManager() *manager {
+	return &manager{logger: logrus.StandardLogger()}
+}
+
+// Add appends c to the components. Components are started in the order they
+// are added and stopped in the reverse order, so a component is added after
+// the components it uses.
+func (m *manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Run starts every component and blocks until ctx is cancelled or one of the
+// components fails, then stops them all. It returns the failure, or the
+// first error met while stopping.
+func (m *manager) Run(ctx context.Context) error {
+	failed := make(chan error, len(m.components))
+	started := make([]*running, 0, len(m.components))
+
+	for _, c := range m.components {
+		runCtx, cancel := context.WithCancel(context.Background())
+		r := &running{Component: c, cancel: cancel, done: make(chan struct{})}
+		started = append(started, r)
+
+		if c.Run == nil {
+			close(r.done)
+			continue
+		}
+
+		go func() {
+			defer close(r.done)
+
+			if err := r.Run(runCtx); err != nil {
+				failed <- fmt.Errorf("%s: %w", r.Name, err)
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		m.logger.Info("shutting down...")
+	case err = <-failed:
+		m.logger.Errorf("shutting down after a failure: %s", err)
+	}
+
+	for i := len(started) - 1; i >= 0; i-- {
+		if stopErr := m.stop(started[i]); stopErr != nil {
+			m.logger.Error(stopErr)
+			if err == nil {
+				err = stopErr
+			}
+		}
+	}
+
+	return err
+}
+
+func (m *manager) stop(r *running) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if r.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+	}
+	defer cancel()
+
+	start := time.Now()
+
+	var err error
+	if r.Stop != nil {
+		err = r.Stop(ctx)
+	}
+	r.cancel()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return fmt.Errorf("%s did not stop within %s", r.Name, r.Timeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error stopping %s: %w", r.Name, err)
+	}
+
+	m.logger.Infof("stopped %s in %s", r.Name, time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
+
+// Worker returns a component running run until it is stopped.
+func Worker(name string, run func(ctx context.Context), timeout time.Duration) Component {
+	return Component{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			run(ctx)
+			return nil
+		},
+		Timeout: timeout,
+	}
+}
+
+// Closer returns a component closed by close when it is stopped.
+func Closer(name string, close func() error, timeout time.Duration) Component {
+	return Component{
+		Name: name,
+		Stop: func(ctx context.Context) error {
+			done := make(chan error, 1)
+			go func() { done <- close() }()
+
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		Timeout: timeout,
+	}
+}
+
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.Context.Value(key) }
+
+// Detach returns a context with the values of ctx that is never cancelled,
+// for work that must finish once started, such as a money transaction, even
+// when ctx is cancelled by a shutdown.
+func Detach(ctx context.Context) context.Context {
+	return detached{ctx}
+}
@@ -0,0 +1,213 @@
+package lifecycle_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/memorydb"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/lifecycle"
+	"github.com/siraj18/balance-service-new/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) worker(name string) lifecycle.Component {
+	return lifecycle.Worker(name, func(ctx context.Context) {
+		r.record("start " + name)
+		<-ctx.Done()
+		r.record("stop " + name)
+	}, time.Second)
+}
+
+func TestStopsInReverseOrder(t *testing.T) {
+	r := &recorder{}
+
+	m := lifecycle.NewManager()
+	m.Add(lifecycle.Closer("database", func() error {
+		r.record("stop database")
+		return nil
+	}, time.Second))
+	m.Add(r.worker("relay"))
+	m.Add(r.worker("scheduler"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		require.Eventually(t, func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return len(r.events) == 2
+		}, time.Second, time.Millisecond)
+		cancel()
+	}()
+
+	require.NoError(t, m.Run(ctx))
+	require.ElementsMatch(t, []string{"start relay", "start scheduler"}, r.events[:2])
+	require.Equal(t, []string{"stop scheduler", "stop relay", "stop database"}, r.events[2:])
+}
+
+func TestFailureStopsEverything(t *testing.T) {
+	r := &recorder{}
+
+	m := lifecycle.NewManager()
+	m.Add(r.worker("scheduler"))
+	m.Add(lifecycle.Component{Name: "http server", Run: func(ctx context.Context) error {
+		return fmt.Errorf("address already in use")
+	}})
+
+	err := m.Run(context.Background())
+	require.EqualError(t, err, "http server: address already in use")
+	require.Contains(t, r.events, "stop scheduler")
+}
+
+func TestStopTimeout(t *testing.T) {
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	m := lifecycle.NewManager()
+	m.Add(lifecycle.Worker("stuck", func(ctx context.Context) { <-stuck }, 20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.EqualError(t, m.Run(ctx), "stuck did not stop within 20ms")
+}
+
+func TestDetach(t *testing.T) {
+	type key struct{}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	detached := lifecycle.Detach(ctx)
+	require.NoError(t, detached.Err())
+	require.Nil(t, detached.Done())
+	require.Equal(t, "value", detached.Value(key{}))
+}
+
+// stallingRepository holds every transfer until the service is told to stop,
+// then lets it go through on a closable database.
+type stallingRepository struct {
+	*memorydb.Repository
+	started  chan struct{}
+	stopping <-chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (rep *stallingRepository) TransferBalance(ctx context.Context, fromUid, toUid string, money float64) error {
+	close(rep.started)
+	<-rep.stopping
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.closed {
+		return fmt.Errorf("sql: database is closed")
+	}
+
+	return rep.Repository.TransferBalance(ctx, fromUid, toUid, money)
+}
+
+func (rep *stallingRepository) Close() error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	rep.closed = true
+	return nil
+}
+
+// TestSigtermLetsRequestsFinish sends SIGTERM while a request is in the
+// middle of a transfer. The transfer must still commit and the database must
+// only be closed after it did.
+func TestSigtermLetsRequestsFinish(t *testing.T) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	rep := &stallingRepository{Repository: memorydb.NewRepository(), started: make(chan struct{}), stopping: ctx.Done()}
+
+	from, to := uuid.New().String(), uuid.New().String()
+	_, err := rep.ChangeBalance(context.Background(), from, 100)
+	require.NoError(t, err)
+	_, err = rep.ChangeBalance(context.Background(), to, 0)
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	httpServer := server.NewServer(lis.Addr().String(), handlers.NewHandler(rep).InitRoutes(), time.Second)
+
+	m := lifecycle.NewManager()
+	m.Add(lifecycle.Closer("database", rep.Close, time.Second))
+	m.Add(lifecycle.Component{
+		Name:    "http server",
+		Run:     func(context.Context) error { return httpServer.Serve(lis) },
+		Stop:    httpServer.Shutdown,
+		Timeout: time.Second,
+	})
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- m.Run(ctx) }()
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+
+	responses := make(chan response, 1)
+	go func() {
+		body := fmt.Sprintf(`{"from_id": %q, "to_id": %q, "money": 30}`, from, to)
+		resp, err := http.Post("http://"+lis.Addr().String()+"/transferBalance", "application/json", strings.NewReader(body))
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		payload, err := io.ReadAll(resp.Body)
+		responses <- response{status: resp.StatusCode, body: string(payload), err: err}
+	}()
+
+	<-rep.started
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+
+	resp := <-responses
+	require.NoError(t, resp.err)
+	require.Equal(t, http.StatusOK, resp.status, resp.body)
+	require.Equal(t, "The transfer was completed successfully", strings.TrimSpace(resp.body))
+
+	require.NoError(t, <-stopped)
+	require.True(t, rep.closed)
+
+	fromUser, err := rep.GetBalance(context.Background(), from)
+	require.NoError(t, err)
+	require.Equal(t, 70.0, fromUser.Balance)
+
+	toUser, err := rep.GetBalance(context.Background(), to)
+	require.NoError(t, err)
+	require.Equal(t, 30.0, toUser.Balance)
+}
@@ -0,0 +1,86 @@
+// Package logging configures the logger of the service and carries a logger
+// scoped to the request, and the id of the request, in the context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Configure sets the format, text or json, and the level of logger.
+func Configure(logger *logrus.Logger, format, level string) error {
+	switch format {
+	case "", "text":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+
+	if level == "" {
+		return nil
+	}
+
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(parsed)
+
+	return nil
+}
+
+type requestKey struct{}
+
+// request is the logging state of a request. Handlers add fields to it while
+// the request is served, they end up on the line logged when it completes.
+type request struct {
+	id string
+
+	mu    sync.Mutex
+	entry *logrus.Entry
+}
+
+// WithRequest returns ctx carrying the request id and a logger with the
+// request_id field.
+func WithRequest(ctx context.Context, logger *logrus.Logger, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: id, entry: logger.WithField("request_id", id)})
+}
+
+// RequestId returns the id of the request ctx belongs to, or an empty string
+// outside of requests.
+func RequestId(ctx context.Context) string {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		return r.id
+	}
+
+	return ""
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the
+// standard logger outside of requests.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		return r.entry
+	}
+
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// AddFields adds fields, such as the user ids and the amount, to the logger
+// of the request ctx belongs to. It is a no-op outside of requests.
+func AddFields(ctx context.Context, fields logrus.Fields) {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.entry = r.entry.WithFields(fields)
+	}
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	logger := logrus.New()
+
+	require.NoError(t, Configure(logger, "json", "warn"))
+	require.IsType(t, &logrus.JSONFormatter{}, logger.Formatter)
+	require.Equal(t, logrus.WarnLevel, logger.Level)
+
+	require.NoError(t, Configure(logger, "", ""))
+	require.IsType(t, &logrus.TextFormatter{}, logger.Formatter)
+	require.Equal(t, logrus.WarnLevel, logger.Level)
+
+	require.Error(t, Configure(logger, "xml", ""))
+	require.Error(t, Configure(logger, "text", "loud"))
+}
+
+func TestMiddlewareReplacesInvalidRequestIds(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	var seen string
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestId(r.Context())
+	}))
+
+	for _, id := range []string{"", "has space", strings.Repeat("a", 129), "line\nbreak"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIdHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		require.NotEqual(t, id, seen)
+		require.Len(t, seen, 36)
+		require.Equal(t, seen, rec.Header().Get(RequestIdHeader))
+		require.Equal(t, seen, hook.LastEntry().Data["request_id"])
+		require.Equal(t, http.StatusOK, hook.LastEntry().Data["status"])
+	}
+}
+
+func TestMiddlewareLogsAddedFields(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddFields(r.Context(), logrus.Fields{"user_id": "42"})
+		FromContext(r.Context()).Warn("slow")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIdHeader, "abc-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	require.Equal(t, "42", entries[0].Data["user_id"])
+	require.Equal(t, "abc-1", entries[0].Data["request_id"])
+	require.Equal(t, logrus.ErrorLevel, entries[1].Level)
+	require.Equal(t, "42", entries[1].Data["user_id"])
+	require.Equal(t, http.StatusBadGateway, entries[1].Data["status"])
+}
+
+func TestOutsideRequests(t *testing.T) {
+	ctx := context.Background()
+
+	AddFields(ctx, logrus.Fields{"user_id": "42"})
+	require.Empty(t, RequestId(ctx))
+	require.NotNil(t, FromContext(ctx))
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/response"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// validRequestId limits the ids accepted from callers, they are logged and
+// stored on the transactions.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// NewRequestId returns id when it is a valid request id and a new one
+// otherwise.
+func NewRequestId(id string) string {
+	if validRequestId.MatchString(id) {
+		return id
+	}
+
+	return uuid.NewString()
+}
+
+// Middleware serves every request with a logger scoped to it and logs the
+// request once it is served. The request id is taken from the X-Request-ID
+// header or generated, and echoed in the response.
+func Middleware(logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := NewRequestId(r.Header.Get(RequestIdHeader))
+			w.Header().Set(RequestIdHeader, id)
+
+			ctx := WithRequest(r.Context(), logger, id)
+			AddFields(ctx, logrus.Fields{"method": r.Method, "path": r.URL.Path})
+			if span := trace.SpanFromContext(ctx).SpanContext(); span.IsValid() {
+				AddFields(ctx, logrus.Fields{"trace_id": span.TraceID().String()})
+			}
+
+			rw := response.NewWriter(w)
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			entry := FromContext(ctx).WithFields(logrus.Fields{
+				"status":     rw.Status(),
+				"latency_ms": time.Since(start).Milliseconds(),
+			})
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				entry = entry.WithField("route", rctx.RoutePattern())
+			}
+
+			if rw.Failed() {
+				entry.Error("request served")
+			} else {
+				entry.Info("request served")
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package metrics exposes the Prometheus metrics of the service: request
+// latency, the database pool and the business operations.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "balance"
+
+// The business operations counted by RecordOperation.
+const (
+	OperationDeposit    = "deposit"
+	OperationWithdrawal = "withdrawal"
+	OperationTransfer   = "transfer"
+	OperationReserve    = "reserve"
+	OperationRecognize  = "recognize"
+	OperationDeReserve  = "de_reserve"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Committed balance operations.",
+	}, []string{"operation"})
+
+	operationAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operation_amount_total",
+		Help:      "Money moved by the committed balance operations.",
+	}, []string{"operation"})
+
+	insufficientFunds = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "insufficient_funds_total",
+		Help:      "Operations rejected for insufficient funds.",
+	})
+
+	txRollbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_transaction_rollbacks_total",
+		Help:      "Transactions rolled back instead of committed.",
+	})
+
+	connectRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_connect_retries_total",
+		Help:      "Failed attempts to connect to the database that were retried.",
+	})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exposes the connection pool stats of db.
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RecordOperation counts a committed operation moving amount.
+func RecordOperation(operation string, amount float64) {
+	operations.WithLabelValues(operation).Inc()
+	operationAmount.WithLabelValues(operation).Add(amount)
+}
+
+func RecordInsufficientFunds() {
+	insufficientFunds.Inc()
+}
+
+func RecordTxRollback() {
+	txRollbacks.Inc()
+}
+
+// RecordConnectRetry counts a failed attempt to connect, its error is logged
+// by the caller.
+func RecordConnectRetry(error) {
+	connectRetries.Inc()
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestMiddlewareLabelsRoutePattern(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/balance/{uid}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "user not found", http.StatusNotFound)
+	})
+	router.Post("/transferBalance", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	for _, path := range []string{"/balance/1", "/balance/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/transferBalance", nil))
+
+	body := scrape(t)
+	require.Contains(t, body, `balance_http_request_duration_seconds_count{method="GET",route="/balance/{uid}",status="404"} 2`)
+	require.Contains(t, body, `balance_http_request_duration_seconds_count{method="POST",route="/transferBalance",status="200"} 1`)
+}
+
+func TestMiddlewareKeepsFlusher(t *testing.T) {
+	var flushed bool
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flushed = w.(http.Flusher)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.True(t, flushed)
+}
+
+func TestRecordOperation(t *testing.T) {
+	before := testutil.ToFloat64(operationAmount.WithLabelValues(OperationTransfer))
+
+	RecordOperation(OperationTransfer, 10)
+	RecordOperation(OperationTransfer, 2.5)
+
+	require.Equal(t, before+12.5, testutil.ToFloat64(operationAmount.WithLabelValues(OperationTransfer)))
+	require.Contains(t, scrape(t), `balance_operations_total{operation="transfer"}`)
+}
+
+func TestRegisterPool(t *testing.T) {
+	require.NoError(t, RegisterPool("balance_test_pool", func() PoolStats {
+		return PoolStats{MaxConns: 10, TotalConns: 4, AcquiredConns: 3, IdleConns: 1, AcquireCount: 7, AcquireDuration: 1500 * time.Millisecond}
+	}))
+
+	body := scrape(t)
+	require.Contains(t, body, `balance_db_pool_max_connections{db_name="balance_test_pool"} 10`)
+	require.Contains(t, body, `balance_db_pool_acquired_connections{db_name="balance_test_pool"} 3`)
+	require.Contains(t, body, `balance_db_pool_acquire_duration_seconds_total{db_name="balance_test_pool"} 1.5`)
+}
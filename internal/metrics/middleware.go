@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/siraj18/balance-service-new/internal/response"
+)
+
+// Middleware observes the latency of every request under its route pattern,
+// so that requests to /balance/{uid} share one series. Requests matching no
+// route are observed under an empty route.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := response.NewWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		var route string
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+
+		requestDuration.WithLabelValues(r.Method, route, strconv.Itoa(rw.Status())).Observe(time.Since(start).Seconds())
+	})
+}
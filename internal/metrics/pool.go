@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStats is a snapshot of a connection pool that database/sql does not
+// manage, such as the pool of pgx.
+type PoolStats struct {
+	MaxConns        int32
+	TotalConns      int32
+	AcquiredConns   int32
+	IdleConns       int32
+	AcquireCount    int64
+	AcquireDuration time.Duration
+}
+
+type poolCollector struct {
+	stats           func() PoolStats
+	maxConns        *prometheus.Desc
+	totalConns      *prometheus.Desc
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+}
+
+// RegisterPool exposes the stats of the pool name, which are read from stats
+// on every scrape.
+func RegisterPool(name string, stats func() PoolStats) error {
+	labels := prometheus.Labels{"db_name": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", metric), help, nil, labels)
+	}
+
+	return prometheus.Register(&poolCollector{
+		stats:           stats,
+		maxConns:        desc("max_connections", "Maximum number of connections of the pool."),
+		totalConns:      desc("connections", "Open connections of the pool."),
+		acquiredConns:   desc("acquired_connections", "Connections of the pool in use."),
+		idleConns:       desc("idle_connections", "Idle connections of the pool."),
+		acquireCount:    desc("acquires_total", "Connections acquired from the pool."),
+		acquireDuration: desc("acquire_duration_seconds_total", "Time spent acquiring connections from the pool."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxConns
+	ch <- c.totalConns
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stats.MaxConns))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stats.AcquiredConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stats.AcquireCount))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stats.AcquireDuration.Seconds())
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	AccountActive = "active"
+	AccountFrozen = "frozen"
+	AccountClosed = "closed"
+)
+
+type Metadata map[string]string
+
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	data, err := json.Marshal(m)
+
+	return string(data), err
+}
+
+func (m *Metadata) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", src)
+	}
+}
+
+type Account struct {
+	Id             string     `json:"id" db:"id"`
+	Balance        float64    `json:"balance" db:"balance"`
+	CreditLimit    float64    `json:"credit_limit" db:"credit_limit"`
+	Owner          string     `json:"owner" db:"owner"`
+	Metadata       Metadata   `json:"metadata,omitempty" db:"metadata"`
+	Status         string     `json:"status" db:"status"`
+	DebitsBlocked  bool       `json:"debits_blocked" db:"debits_blocked"`
+	CreditsBlocked bool       `json:"credits_blocked" db:"credits_blocked"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty" db:"closed_at"`
+}
+
+type CreateAccountQuery struct {
+	Id       string   `json:"id,omitempty" swaggertype:"string" format:"base64" example:"34be95d0-9a41-11ec-b909-0242ac120003"`
+	Owner    string   `json:"owner" swaggertype:"string" format:"base64" example:"ACME Corp"`
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+type FreezeAccountQuery struct {
+	Debits  bool `json:"debits" swaggertype:"boolean" example:"true"`
+	Credits bool `json:"credits" swaggertype:"boolean" example:"false"`
+}
+
+type SetCreditLimitQuery struct {
+	CreditLimit float64 `json:"credit_limit" swaggertype:"number" example:"500"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type AuditEntry struct {
+	Id             int64     `json:"id" db:"id"`
+	Actor          string    `json:"actor" db:"actor"`
+	Endpoint       string    `json:"endpoint" db:"endpoint"`
+	PayloadHash    string    `json:"payload_hash" db:"payload_hash"`
+	BalancesBefore string    `json:"balances_before" db:"balances_before"`
+	BalancesAfter  string    `json:"balances_after" db:"balances_after"`
+	Status         int       `json:"status" db:"status"`
+	Outcome        string    `json:"outcome" db:"outcome"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	PrevHash       string    `json:"prev_hash" db:"prev_hash"`
+	Hash           string    `json:"hash" db:"hash"`
+}
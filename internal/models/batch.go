@@ -0,0 +1,46 @@
+package models
+
+const (
+	BatchDeposit  = "deposit"
+	BatchWithdraw = "withdraw"
+	BatchTransfer = "transfer"
+	BatchReserve  = "reserve"
+)
+
+// BatchOperation is one item of a batch. Id is the account of deposits,
+// withdrawals and reserves, transfers use FromId and ToId instead. Money is
+// always positive, a withdrawal takes it off the balance.
+type BatchOperation struct {
+	Type      string  `json:"type" swaggertype:"string" enums:"deposit,withdraw,transfer,reserve" example:"transfer"`
+	Id        string  `json:"id,omitempty" swaggertype:"string" format:"base64" example:""`
+	FromId    string  `json:"from_id,omitempty" swaggertype:"string" format:"base64" example:"34be95d0-9a41-11ec-b909-0242ac120003"`
+	ToId      string  `json:"to_id,omitempty" swaggertype:"string" format:"base64" example:"34be95d0-9a41-11ec-b909-0242ac120004"`
+	ServiceId string  `json:"service_id,omitempty" swaggertype:"string" format:"base64" example:""`
+	OrderId   string  `json:"order_id,omitempty" swaggertype:"string" format:"base64" example:""`
+	Money     float64 `json:"money" swaggertype:"number" format:"base64" example:"50"`
+}
+
+type BatchQuery struct {
+	Atomic     bool             `json:"atomic" swaggertype:"boolean" example:"true"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+const (
+	BatchItemSuccess    = "success"
+	BatchItemFailed     = "failed"
+	BatchItemInvalid    = "invalid"
+	BatchItemRolledBack = "rolled_back"
+	BatchItemSkipped    = "skipped"
+)
+
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+	Atomic    bool              `json:"atomic"`
+	Committed bool              `json:"committed"`
+	Results   []BatchItemResult `json:"results"`
+}
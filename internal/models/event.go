@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventSchemaVersion is bumped whenever the Data of an event changes in a way
+// consumers have to know about.
+const EventSchemaVersion = 1
+
+const (
+	EventDeposited         = "balance.deposited"
+	EventWithdrawn         = "balance.withdrawn"
+	EventTransferDebited   = "transfer.debited"
+	EventTransferCredited  = "transfer.credited"
+	EventReserveCreated    = "reserve.created"
+	EventReserveRecognized = "reserve.recognized"
+	EventReserveReleased   = "reserve.released"
+)
+
+// Event is a balance change of a single account read from the outbox.
+// Sequence grows with every event and orders the events of an account.
+type Event struct {
+	Id         string          `json:"id" db:"event_id"`
+	Sequence   int64           `json:"sequence" db:"id"`
+	Type       string          `json:"type" db:"type"`
+	Version    int             `json:"version" db:"version"`
+	AccountId  string          `json:"account_id" db:"account_id"`
+	OccurredAt time.Time       `json:"occurred_at" db:"created_at"`
+	Data       json.RawMessage `json:"data" db:"data" swaggertype:"object"`
+}
+
+// AccountEventData is the Data of every event type of version 1. Balance is
+// the account balance after the change and is omitted for recognized
+// reserves, which do not change it.
+type AccountEventData struct {
+	Amount         float64  `json:"amount"`
+	Balance        *float64 `json:"balance,omitempty"`
+	CounterpartyId string   `json:"counterparty_id,omitempty"`
+	ServiceId      string   `json:"service_id,omitempty"`
+	OrderId        string   `json:"order_id,omitempty"`
+	Actor          string   `json:"actor"`
+}
+
+// BalanceUpdate is pushed to balance streams. Transaction is the event that
+// changed the balance and is omitted in the first update of a stream.
+type BalanceUpdate struct {
+	AccountId   string  `json:"account_id"`
+	Balance     float64 `json:"balance"`
+	Transaction *Event  `json:"transaction,omitempty"`
+}
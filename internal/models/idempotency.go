@@ -0,0 +1,9 @@
+package models
+
+// IdempotentResponse is the response stored for an idempotency key and
+// replayed to repeated requests with the same key.
+type IdempotentResponse struct {
+	Status int      `db:"status"`
+	Header Metadata `db:"header"`
+	Body   []byte   `db:"body"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// ImportRow is a balance adjustment read from line Line of an import file.
+// A positive amount is deposited, a negative one withdrawn.
+type ImportRow struct {
+	Line    int     `json:"line"`
+	UserId  string  `json:"user_id"`
+	Amount  float64 `json:"amount"`
+	Comment string  `json:"comment"`
+}
+
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Id      string            `json:"id,omitempty"`
+	DryRun  bool              `json:"dry_run"`
+	Applied bool              `json:"applied"`
+	Rows    int               `json:"rows"`
+	Errors  []ImportLineError `json:"errors"`
+}
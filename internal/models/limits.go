@@ -0,0 +1,11 @@
+package models
+
+// Limits restrict debits of an account. Unset fields are not limited. The
+// "global" scope applies to every account that does not override the field.
+type Limits struct {
+	Scope           string   `json:"scope" db:"scope"`
+	MaxOperation    *float64 `json:"max_operation,omitempty" db:"max_operation" example:"1000"`
+	DailyDebit      *float64 `json:"daily_debit,omitempty" db:"daily_debit" example:"5000"`
+	MonthlyDebit    *float64 `json:"monthly_debit,omitempty" db:"monthly_debit" example:"50000"`
+	HourlyTransfers *int     `json:"hourly_transfers,omitempty" db:"hourly_transfers" example:"10"`
+}
@@ -2,6 +2,12 @@ package models
 
 import "time"
 
+const (
+	ReserveReserved   = "reserved"
+	ReserveDeReserved = "de-reserved"
+	ReserveRecognized = "recognized"
+)
+
 type Reserve struct {
 	Id           string     `json:"id" db:"id"`
 	UserId       string     `json:"user_id" db:"user_id"`
@@ -9,6 +15,7 @@ type Reserve struct {
 	OrderId      string     `json:"order_id" db:"order_id"`
 	Amount       float64    `json:"amount" db:"amount"`
 	Status       string     `json:"status" db:"status"`
+	Actor        string     `json:"actor" db:"actor"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	RecognizedAt *time.Time `json:"recognized_at,omitempty" db:"recognized_at"`
 }
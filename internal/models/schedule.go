@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCompleted = "completed"
+	ScheduleFailed    = "failed"
+	ScheduleCancelled = "cancelled"
+
+	ScheduleRunSuccess = "success"
+	ScheduleRunFailed  = "failed"
+)
+
+// Schedule is a future transfer. It runs once at NextRunAt, or repeatedly
+// when Cron or IntervalSeconds is set. NextRunAt is nil once the schedule
+// is finished. SlotAt is the planned run that NextRunAt belongs to, which
+// differs from it while a failed run is retried.
+type Schedule struct {
+	Id              string     `json:"id" db:"id"`
+	FromId          string     `json:"from_id" db:"from_id"`
+	ToId            string     `json:"to_id" db:"to_id"`
+	Money           float64    `json:"money" db:"money"`
+	Cron            *string    `json:"cron,omitempty" db:"cron"`
+	IntervalSeconds *int       `json:"interval_seconds,omitempty" db:"interval_seconds"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
+	SlotAt          *time.Time `json:"-" db:"slot_at"`
+	Status          string     `json:"status" db:"status"`
+	MaxRetries      int        `json:"max_retries" db:"max_retries"`
+	Attempt         int        `json:"attempt" db:"attempt"`
+	Actor           string     `json:"actor" db:"actor"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+type ScheduleRun struct {
+	Id          string    `json:"id" db:"id"`
+	ScheduleId  string    `json:"schedule_id" db:"schedule_id"`
+	ScheduledAt time.Time `json:"scheduled_at" db:"scheduled_at"`
+	ExecutedAt  time.Time `json:"executed_at" db:"executed_at"`
+	Attempt     int       `json:"attempt" db:"attempt"`
+	Outcome     string    `json:"outcome" db:"outcome"`
+	Error       *string   `json:"error,omitempty" db:"error"`
+}
+
+type CreateScheduleQuery struct {
+	FromId          string     `json:"from_id" swaggertype:"string" format:"base64" example:"34be95d0-9a41-11ec-b909-0242ac120003"`
+	ToId            string     `json:"to_id" swaggertype:"string" format:"base64" example:"34be95d0-9a41-11ec-b909-0242ac120004"`
+	Money           float64    `json:"money" swaggertype:"number" format:"base64" example:"50"`
+	RunAt           *time.Time `json:"run_at,omitempty" example:"2022-11-01T10:00:00Z"`
+	Cron            string     `json:"cron,omitempty" example:"0 10 1 * *"`
+	IntervalSeconds int        `json:"interval_seconds,omitempty" example:"0"`
+	MaxRetries      *int       `json:"max_retries,omitempty" example:"3"`
+}
@@ -2,12 +2,33 @@ package models
 
 import "time"
 
+const (
+	OperationAddMoney           = "adding money"
+	OperationWithdrawMoney      = "withdrawal of money"
+	OperationTransferMoney      = "transfer money"
+	OperationReserveMoney       = "reserve money"
+	OperationReturnReserveMoney = "return reserve money"
+)
+
+// The transaction history is ordered by one of these, or kept in the order
+// of the ledger for any other sort type.
+const (
+	SortDateAsc   = "date_asc"
+	SortDateDesc  = "date_desc"
+	SortMoneyAsc  = "money_asc"
+	SortMoneyDesc = "money_desc"
+)
+
 type Transaction struct {
 	Id        string    `json:"id" db:"id"`
 	ToId      *string   `json:"to_id,omitempty" db:"to_id"`
 	FromId    *string   `json:"from_id,omitempty" db:"from_id"`
 	Money     float64   `json:"money" db:"money"`
 	Operation string    `json:"operation" db:"operation"`
+	Actor     string    `json:"actor" db:"actor"`
+	ImportId  *string   `json:"import_id,omitempty" db:"import_id"`
+	Comment   *string   `json:"comment,omitempty" db:"comment"`
+	RequestId *string   `json:"request_id,omitempty" db:"request_id"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
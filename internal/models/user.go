@@ -1,8 +1,17 @@
 package models
 
+// User is the balance view of an account. Balance is the ledger balance
+// without the money held by open reserves, it goes negative when the account
+// uses its credit line. Total adds the held money back and Available is what
+// the account can still spend including the credit line.
 type User struct {
-	Id      string  `json:"id" db:"id"`
-	Balance float64 `json:"balance" db:"balance"`
+	Id          string    `json:"id" db:"id"`
+	Balance     float64   `json:"balance" db:"balance"`
+	CreditLimit float64   `json:"credit_limit" db:"credit_limit"`
+	Available   float64   `json:"available" db:"available"`
+	Held        float64   `json:"held" db:"held"`
+	Total       float64   `json:"total" db:"total"`
+	Reserves    []Reserve `json:"reserves,omitempty" db:"-"`
 }
 
 type UserChangeBalanceQuery struct {
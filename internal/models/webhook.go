@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AllEvents subscribes a webhook to every event type.
+const AllEvents = "*"
+
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead"
+)
+
+type EventTypes []string
+
+func (t EventTypes) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+
+	data, err := json.Marshal(t)
+
+	return string(data), err
+}
+
+func (t *EventTypes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("unsupported event types type %T", src)
+	}
+}
+
+// WebhookSubscription is a subscription as it is read back. Its Secret is
+// never serialized, it is only returned once by CreatedWebhook.
+type WebhookSubscription struct {
+	Id         string     `json:"id" db:"id"`
+	Url        string     `json:"url" db:"url"`
+	EventTypes EventTypes `json:"event_types" db:"event_types"`
+	Secret     string     `json:"-" db:"-"`
+	Active     bool       `json:"active" db:"active"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreatedWebhook is the response to the creation of a subscription, the only
+// one that carries the secret of its signatures.
+type CreatedWebhook struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookQuery struct {
+	Url        string   `json:"url" example:"https://partner.example.com/hooks/balance"`
+	EventTypes []string `json:"event_types" example:"transfer.credited,reserve.created"`
+	Secret     string   `json:"secret,omitempty" example:""`
+}
+
+// WebhookDelivery is the delivery of one event to one subscription and its
+// log: the number of attempts and the outcome of the last one. Url, Secret
+// and Event are only loaded for the dispatcher.
+type WebhookDelivery struct {
+	Id             string     `json:"id" db:"id"`
+	SubscriptionId string     `json:"subscription_id" db:"subscription_id"`
+	EventId        string     `json:"event_id" db:"event_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	Status         string     `json:"status" db:"status"`
+	Attempt        int        `json:"attempt" db:"attempt"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	Url            string     `json:"-" db:"-"`
+	Secret         string     `json:"-" db:"-"`
+	Event          *Event     `json:"-" db:"-"`
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/logging"
+)
+
+const (
+	LimitHeader      = "RateLimit-Limit"
+	RemainingHeader  = "RateLimit-Remaining"
+	ResetHeader      = "RateLimit-Reset"
+	RetryAfterHeader = "Retry-After"
+)
+
+// Client returns the key of the caller of r: the subject of its identity
+// when it authenticated, its address otherwise.
+func Client(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil && identity.Subject != "" {
+		return "subject:" + identity.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// Middleware takes a token of budget from the bucket of the client for every
+// request and rejects the request with 429 when the bucket is empty. When the
+// store fails the request is let through rather than taking the service down
+// with it.
+func Middleware(store Store, budget string, limit Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !limit.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), budget+":"+Client(r), limit)
+			if err != nil {
+				logging.FromContext(r.Context()).Errorf("error taking rate limit: %s", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(LimitHeader, strconv.Itoa(res.Limit))
+			w.Header().Set(RemainingHeader, strconv.Itoa(res.Remaining))
+			w.Header().Set(ResetHeader, ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				w.Header().Set(RetryAfterHeader, ceilSeconds(res.RetryAfter))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,75 @@
+// Package ratelimit limits the requests of every client with token buckets
+// kept in a pluggable store.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+const (
+	// BudgetAddress is taken by every request before it is authenticated,
+	// so that requests with bad credentials are limited too.
+	BudgetAddress = "address"
+	BudgetRead    = "read"
+	BudgetWrite   = "write"
+)
+
+// Limit is a token bucket holding up to Burst requests and refilled with
+// Rate requests per second. A zero Rate disables the limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the number of requests allowed right away.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// this one was.
+	RetryAfter time.Duration
+}
+
+// Store takes a token from the bucket of key. Stores shared between
+// instances of the service limit clients across all of them.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// refill returns the tokens of a bucket holding tokens elapsed ago.
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
+
+// result describes a bucket left with tokens after a request.
+func result(allowed bool, tokens float64, limit Limit) *Result {
+	r := &Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+	// servers follow the clock
+	servers []*miniredis.Miniredis
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	for _, server := range c.servers {
+		server.SetTime(c.now)
+	}
+}
+
+// stores returns every store, each reading the time of c.
+func stores(t *testing.T, c *clock) map[string]Store {
+	memory := NewMemoryStore()
+	memory.now = c.Now
+
+	server := miniredis.RunT(t)
+	server.SetTime(c.now)
+	c.servers = append(c.servers, server)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	shared := NewRedisStore(client)
+
+	return map[string]Store{"memory": memory, "redis": shared}
+}
+
+func TestTake(t *testing.T) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for name, store := range stores(t, c) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for i := 2; i >= 0; i-- {
+				res, err := store.Take(ctx, "read:ip:10.0.0.1", limit)
+				require.NoError(t, err)
+				require.True(t, res.Allowed)
+				require.Equal(t, 3, res.Limit)
+				require.Equal(t, i, res.Remaining)
+			}
+
+			res, err := store.Take(ctx, "read:ip:10.0.0.1", limit)
+			require.NoError(t, err)
+			require.False(t, res.Allowed)
+			require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+			require.Equal(t, 1500*time.Millisecond, res.Reset)
+
+			// other clients have buckets of their own
+			res, err = store.Take(ctx, "read:ip:10.0.0.2", limit)
+			require.NoError(t, err)
+			require.True(t, res.Allowed)
+
+			c.Advance(500 * time.Millisecond)
+			res, err = store.Take(ctx, "read:ip:10.0.0.1", limit)
+			require.NoError(t, err)
+			require.True(t, res.Allowed)
+			require.Equal(t, 0, res.Remaining)
+
+			// a bucket never holds more than its burst
+			c.Advance(time.Hour)
+			res, err = store.Take(ctx, "read:ip:10.0.0.1", limit)
+			require.NoError(t, err)
+			require.Equal(t, 2, res.Remaining)
+		})
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	store := NewMemoryStore()
+	store.now = c.Now
+
+	for i := 0; i < sweepEvery-1; i++ {
+		_, err := store.Take(context.Background(), fmt.Sprintf("client-%d", i), Limit{Rate: 1, Burst: 1})
+		require.NoError(t, err)
+	}
+	require.Len(t, store.buckets, sweepEvery-1)
+
+	c.Advance(time.Second)
+	_, err := store.Take(context.Background(), "client-0", Limit{Rate: 1, Burst: 1})
+	require.NoError(t, err)
+	require.Len(t, store.buckets, 1)
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (*Result, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(NewMemoryStore(), BudgetRead, Limit{Rate: 0.1, Burst: 2})(ok)
+
+	request := func(remoteAddr string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/allTransactions", nil)
+		r.RemoteAddr = remoteAddr
+		return r
+	}
+
+	rec := serve(handler, request("10.0.0.1:5000"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "2", rec.Header().Get(LimitHeader))
+	require.Equal(t, "1", rec.Header().Get(RemainingHeader))
+	require.Equal(t, "10", rec.Header().Get(ResetHeader))
+	require.Empty(t, rec.Header().Get(RetryAfterHeader))
+
+	// the port of the client does not matter
+	require.Equal(t, http.StatusOK, serve(handler, request("10.0.0.1:5001")).Code)
+
+	rec = serve(handler, request("10.0.0.1:5002"))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "0", rec.Header().Get(RemainingHeader))
+	require.Equal(t, "10", rec.Header().Get(RetryAfterHeader))
+
+	// authenticated clients are limited by their subject
+	r := request("10.0.0.1:5003")
+	r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Subject: "billing"}))
+	require.Equal(t, http.StatusOK, serve(handler, r).Code)
+}
+
+func TestMiddlewareLetsRequestsThroughWhenTheStoreFails(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(failingStore{}, BudgetWrite, Limit{Rate: 1, Burst: 1})(ok)
+
+	rec := serve(handler, httptest.NewRequest(http.MethodPost, "/changeBalance", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(LimitHeader))
+}
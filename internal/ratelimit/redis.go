@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes a token from the bucket at KEYS[1] the same
+// way the memory store does, so that concurrent instances never race on it.
+// It reads the clock of the server, the clocks of the instances may disagree.
+// The bucket expires once it would have refilled.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = burst
+	updated = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - updated) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+
+return {allowed, tostring(tokens)}
+`)
+
+// redisStore keeps the buckets in Redis, it limits the clients across every
+// instance of the service sharing the server.
+type redisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+func NewRedisStore(client redis.Scripter) *redisStore {
+	return &redisStore{client: client, prefix: "ratelimit:"}
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, limit.Rate, limit.Burst).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reply) != 2 {
+		return nil, fmt.Errorf("unexpected reply of the rate limit script: %v", reply)
+	}
+
+	allowed, ok := reply[0].(int64)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply of the rate limit script: %v", reply)
+	}
+
+	text, ok := reply[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply of the rate limit script: %v", reply)
+	}
+
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return result(allowed == 1, tokens, limit), nil
+}
@@ -0,0 +1,80 @@
+// Package response lets the middlewares see the response a handler wrote.
+package response
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Writer records the status of the response it passes on and, when made by
+// NewBodyWriter, its body.
+type Writer struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+func NewWriter(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w}
+}
+
+// NewBodyWriter returns a Writer that also keeps the body of the response.
+func NewBodyWriter(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w, body: &bytes.Buffer{}}
+}
+
+func (w *Writer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body != nil {
+		w.body.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps event streams working behind the middlewares.
+func (w *Writer) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Status returns the status of the response, 200 when the handler wrote
+// nothing.
+func (w *Writer) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Body returns the body written so far, nil unless made by NewBodyWriter.
+func (w *Writer) Body() []byte {
+	if w.body == nil {
+		return nil
+	}
+
+	return w.body.Bytes()
+}
+
+// Failed tells whether the response reports a server error.
+func (w *Writer) Failed() bool {
+	return w.Status() >= http.StatusInternalServerError
+}
+
+// Rejected tells whether the response reports an error of the request.
+// Handlers report some business errors with http.Error and status 200, so
+// those are recognized by the nosniff header http.Error sets.
+func (w *Writer) Rejected() bool {
+	return !w.Failed() && (w.Status() >= http.StatusBadRequest || w.Header().Get("X-Content-Type-Options") == "nosniff")
+}
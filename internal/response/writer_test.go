@@ -0,0 +1,51 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterDefaultsToOK(t *testing.T) {
+	w := NewWriter(httptest.NewRecorder())
+	assert.Equal(t, http.StatusOK, w.Status())
+
+	w.Write([]byte("ok"))
+	assert.Equal(t, http.StatusOK, w.Status())
+	assert.Nil(t, w.Body())
+	assert.False(t, w.Failed())
+	assert.False(t, w.Rejected())
+}
+
+func TestWriterKeepsFirstStatus(t *testing.T) {
+	w := NewWriter(httptest.NewRecorder())
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.WriteHeader(http.StatusOK)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Status())
+	assert.True(t, w.Failed())
+	assert.False(t, w.Rejected())
+}
+
+func TestBodyWriterKeepsBody(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := NewBodyWriter(recorder)
+
+	http.Error(w, "not enough money", http.StatusOK)
+
+	assert.Equal(t, "not enough money\n", string(w.Body()))
+	assert.Equal(t, "not enough money\n", recorder.Body.String())
+	assert.True(t, w.Rejected())
+}
+
+func TestWriterFlushes(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := NewWriter(recorder)
+
+	var _ http.Flusher = w
+	w.Flush()
+
+	assert.True(t, recorder.Flushed)
+}
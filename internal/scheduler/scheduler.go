@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/siraj18/balance-service-new/internal/lifecycle"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Executor runs the schedules that are due and returns how many ran.
+type Executor interface {
+	ExecuteDueSchedules(ctx context.Context, limit int) (int, error)
+}
+
+type scheduler struct {
+	executor Executor
+	interval time.Duration
+	batch    int
+	logger   *logrus.Logger
+}
+
+func NewScheduler(executor Executor, interval time.Duration, batch int) *scheduler {
+	return &scheduler{
+		executor: executor,
+		interval: interval,
+		batch:    batch,
+		logger:   logrus.StandardLogger(),
+	}
+}
+
+// Run polls for due schedules every interval until ctx is cancelled. A full
+// batch means more work is probably waiting, so the next poll starts at once.
+// A batch that started runs to the end, its transfers are not cut by ctx.
+func (s *scheduler) Run(ctx context.Context) {
+	s.logger.Info("starting scheduler")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		executed, err := s.executor.ExecuteDueSchedules(lifecycle.Detach(ctx), s.batch)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error(err)
+		}
+
+		if err == nil && executed == s.batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("stopping scheduler...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/db/memorydb"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// start runs a scheduler on rep until the test ends.
+func start(t *testing.T, rep *memorydb.Repository, interval time.Duration, batch int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		scheduler.NewScheduler(rep, interval, batch).Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func account(t *testing.T, rep *memorydb.Repository, balance float64) string {
+	uid := uuid.New().String()
+
+	_, err := rep.ChangeBalance(context.Background(), uid, balance)
+	require.NoError(t, err)
+
+	return uid
+}
+
+func balance(t *testing.T, rep *memorydb.Repository, uid string) float64 {
+	user, err := rep.GetBalance(context.Background(), uid)
+	require.NoError(t, err)
+
+	return user.Balance
+}
+
+func schedule(t *testing.T, rep *memorydb.Repository, query models.CreateScheduleQuery) *models.Schedule {
+	created, err := rep.CreateSchedule(context.Background(), query)
+	require.NoError(t, err)
+
+	return created
+}
+
+// settled waits until the schedule with id has run the given number of
+// times, then returns it.
+func settled(t *testing.T, rep *memorydb.Repository, id string, runs int) *models.Schedule {
+	require.Eventually(t, func() bool {
+		scheduleRuns, err := rep.GetScheduleRuns(context.Background(), id)
+		require.NoError(t, err)
+
+		return len(*scheduleRuns) >= runs
+	}, 5*time.Second, 10*time.Millisecond)
+
+	settled, err := rep.GetSchedule(context.Background(), id)
+	require.NoError(t, err)
+
+	return settled
+}
+
+func TestRunExecutesDueSchedules(t *testing.T) {
+	rep := memorydb.NewRepository()
+	from, to := account(t, rep, 100), account(t, rep, 0)
+
+	past := time.Now().Add(-time.Minute)
+	due := schedule(t, rep, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 30, RunAt: &past})
+
+	start(t, rep, 10*time.Millisecond, 10)
+
+	completed := settled(t, rep, due.Id, 1)
+	assert.Equal(t, models.ScheduleCompleted, completed.Status)
+	assert.Nil(t, completed.NextRunAt)
+	assert.Equal(t, 70.0, balance(t, rep, from))
+	assert.Equal(t, 30.0, balance(t, rep, to))
+}
+
+func TestRunPollsAgainAfterFullBatch(t *testing.T) {
+	rep := memorydb.NewRepository()
+	from, to := account(t, rep, 100), account(t, rep, 0)
+
+	past := time.Now().Add(-time.Minute)
+	for i := 0; i < 3; i++ {
+		schedule(t, rep, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 10, RunAt: &past})
+	}
+
+	// the interval is never reached, a full batch is followed by another poll
+	start(t, rep, time.Hour, 1)
+
+	require.Eventually(t, func() bool {
+		return balance(t, rep, to) == 30
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRunAdvancesRecurringSchedules(t *testing.T) {
+	rep := memorydb.NewRepository()
+	from, to := account(t, rep, 100), account(t, rep, 0)
+
+	recurring := schedule(t, rep, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 10, IntervalSeconds: 1})
+	firstRun := *recurring.NextRunAt
+
+	start(t, rep, 10*time.Millisecond, 10)
+
+	advanced := settled(t, rep, recurring.Id, 1)
+	assert.Equal(t, models.ScheduleActive, advanced.Status)
+	assert.Equal(t, 0, advanced.Attempt)
+	require.NotNil(t, advanced.NextRunAt)
+	assert.True(t, advanced.NextRunAt.After(firstRun))
+
+	runs, err := rep.GetScheduleRuns(context.Background(), recurring.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleRunSuccess, (*runs)[0].Outcome)
+	assert.Equal(t, firstRun, (*runs)[0].ScheduledAt)
+}
+
+func TestRunRetriesFailedSchedules(t *testing.T) {
+	rep := memorydb.NewRepository()
+	from, to := account(t, rep, 100), account(t, rep, 0)
+
+	past := time.Now().Add(-time.Minute)
+	retries, noRetries := 1, 0
+	retried := schedule(t, rep, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 1000, RunAt: &past, MaxRetries: &retries})
+	failed := schedule(t, rep, models.CreateScheduleQuery{FromId: from, ToId: to, Money: 1000, RunAt: &past, MaxRetries: &noRetries})
+
+	start(t, rep, 10*time.Millisecond, 10)
+
+	pending := settled(t, rep, retried.Id, 1)
+	assert.Equal(t, models.ScheduleActive, pending.Status)
+	assert.Equal(t, 1, pending.Attempt)
+	require.NotNil(t, pending.NextRunAt)
+	assert.True(t, pending.NextRunAt.After(time.Now().Add(30*time.Second)))
+
+	done := settled(t, rep, failed.Id, 1)
+	assert.Equal(t, models.ScheduleFailed, done.Status)
+	assert.Nil(t, done.NextRunAt)
+
+	runs, err := rep.GetScheduleRuns(context.Background(), failed.Id)
+	require.NoError(t, err)
+	require.Len(t, *runs, 1)
+	assert.Equal(t, models.ScheduleRunFailed, (*runs)[0].Outcome)
+	require.NotNil(t, (*runs)[0].Error)
+
+	assert.Equal(t, 100.0, balance(t, rep, from))
+	assert.Equal(t, 0.0, balance(t, rep, to))
+}
@@ -3,42 +3,72 @@ package server
 import (
 	"context"
 	"github.com/sirupsen/logrus"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 )
 
 type server struct {
-	server *http.Server
-	logger *logrus.Logger
+	server     *http.Server
+	drain      func()
+	drainDelay time.Duration
+	logger     *logrus.Logger
 }
 
-func NewServer(addr string, handler http.Handler, timeouts time.Duration) *server {
-	return &server{
+type Option func(*server)
+
+// WithOnShutdown calls f when the server starts shutting down, to end long
+// lived requests such as event streams that would otherwise hold it up.
+func WithOnShutdown(f func()) Option {
+	return func(s *server) {
+		s.server.RegisterOnShutdown(f)
+	}
+}
+
+// WithDrain calls drain when the server is shut down and keeps serving for
+// delay before shutting down, so that load balancers seeing the service not
+// ready stop sending it requests first.
+func WithDrain(drain func(), delay time.Duration) Option {
+	return func(s *server) {
+		s.drain = drain
+		s.drainDelay = delay
+	}
+}
+
+// NewServer limits reading the request headers to readHeaderTimeout. There
+// is no server wide ReadTimeout or WriteTimeout, which would also cut off
+// event streams, handler limits its requests itself.
+func NewServer(addr string, handler http.Handler, readHeaderTimeout time.Duration, opts ...Option) *server {
+	s := &server{
 		server: &http.Server{
-			Addr:         addr,
-			Handler:      handler,
-			ReadTimeout:  timeouts,
-			WriteTimeout: timeouts,
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: readHeaderTimeout,
 		},
-		logger: logrus.New(),
+		logger: logrus.StandardLogger(),
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
+// Run serves on the address of the server until it is shut down.
 func (s *server) Run() error {
-	exit := make(chan os.Signal, 1)
-	signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
-
-	go func() {
-		<-exit
-		s.Stop()
+	lis, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
 
-	}()
+	return s.Serve(lis)
+}
 
-	s.logger.Info("starting server on port" + s.server.Addr)
-	err := s.server.ListenAndServe()
+// Serve accepts connections on lis until the server is shut down.
+func (s *server) Serve(lis net.Listener) error {
+	s.logger.Info("starting server on port" + lis.Addr().String())
+	err := s.server.Serve(lis)
 
 	if err == http.ErrServerClosed {
 		return nil
@@ -47,11 +77,19 @@ func (s *server) Run() error {
 	return err
 }
 
-func (s *server) Stop() {
+// Shutdown stops accepting connections and waits for the requests in flight
+// to finish until ctx expires.
+func (s *server) Shutdown(ctx context.Context) error {
 	s.logger.Info("stopping server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	s.server.Shutdown(ctx)
+	if s.drain != nil {
+		s.drain()
+
+		select {
+		case <-time.After(s.drainDelay):
+		case <-ctx.Done():
+		}
+	}
 
+	return s.server.Shutdown(ctx)
 }
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopDrainsFirst(t *testing.T) {
+	var drainedAt time.Time
+	s := NewServer("127.0.0.1:0", http.NotFoundHandler(), time.Second,
+		WithDrain(func() { drainedAt = time.Now() }, 30*time.Millisecond))
+
+	shutdownAt := make(chan time.Time, 1)
+	s.server.RegisterOnShutdown(func() { shutdownAt <- time.Now() })
+
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	select {
+	case at := <-shutdownAt:
+		require.False(t, drainedAt.IsZero(), "shut down without draining")
+		require.GreaterOrEqual(t, at.Sub(drainedAt), 30*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("server was not shut down")
+	}
+}
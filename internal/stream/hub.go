@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v4"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	subscriptionBuffer = 16
+	maxReconnectDelay  = 30 * time.Second
+)
+
+// Hub fans the events received on a Postgres notification channel out to the
+// subscribers of their account.
+type Hub struct {
+	connString string
+	channel    string
+	logger     *logrus.Logger
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan models.Event]struct{}
+}
+
+func NewHub(connString, channel string) *Hub {
+	return &Hub{
+		connString:  connString,
+		channel:     channel,
+		logger:      logrus.StandardLogger(),
+		subscribers: make(map[string]map[chan models.Event]struct{}),
+	}
+}
+
+// Subscribe returns the events of account uid and a function that ends the
+// subscription. The channel is closed when the subscriber falls behind or
+// the hub loses its connection, since updates may have been missed then.
+func (h *Hub) Subscribe(uid string) (<-chan models.Event, func()) {
+	ch := make(chan models.Event, subscriptionBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[uid] == nil {
+		h.subscribers[uid] = make(map[chan models.Event]struct{})
+	}
+	h.subscribers[uid][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.remove(uid, ch)
+	}
+}
+
+// remove expects h.mu to be held and tolerates channels removed before.
+func (h *Hub) remove(uid string, ch chan models.Event) {
+	if _, ok := h.subscribers[uid][ch]; !ok {
+		return
+	}
+
+	delete(h.subscribers[uid], ch)
+	if len(h.subscribers[uid]) == 0 {
+		delete(h.subscribers, uid)
+	}
+
+	close(ch)
+}
+
+// Publish hands event to the subscribers of its account.
+func (h *Hub) Publish(event models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[event.AccountId] {
+		select {
+		case ch <- event:
+		default:
+			h.remove(event.AccountId, ch)
+		}
+	}
+}
+
+// CloseSubscriptions ends every subscription, e.g. so that the event streams
+// end when the service shuts down.
+func (h *Hub) CloseSubscriptions() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for uid, channels := range h.subscribers {
+		for ch := range channels {
+			h.remove(uid, ch)
+		}
+	}
+}
+
+// Run listens on the notification channel until ctx is cancelled and
+// reconnects with a growing delay when the connection fails.
+func (h *Hub) Run(ctx context.Context) {
+	delay := time.Second
+
+	for {
+		connected, err := h.listen(ctx)
+		h.CloseSubscriptions()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			delay = time.Second
+		}
+
+		h.logger.Errorf("balance stream listener failed, reconnecting in %s: %s", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// listen returns whether it got to listen before the connection failed.
+func (h *Hub) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.Connect(ctx, h.connString)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{h.channel}.Sanitize()); err != nil {
+		return false, err
+	}
+
+	h.logger.Info("listening for balance changes")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		var event models.Event
+		if err = json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			h.logger.Error(err)
+			continue
+		}
+
+		h.Publish(event)
+	}
+}
@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type connector struct {
+	driver.Connector
+}
+
+// WrapConnector starts a span for every statement, commit and rollback on
+// the connections of c. Statements are traced only within a traced request,
+// so the background pollers do not start a trace every few seconds.
+func WrapConnector(c driver.Connector) driver.Connector {
+	return &connector{c}
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{conn}, nil
+}
+
+// tracedConn traces the statements of a connection. It passes every optional
+// interface of database/sql/driver on to the connection, doing what
+// database/sql does without it when the connection lacks one.
+type tracedConn struct {
+	driver.Conn
+}
+
+func startStatement(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx, nil
+	}
+
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.DBSystemPostgreSQL)}
+	if query != "" {
+		opts = append(opts, trace.WithAttributes(semconv.DBStatement(strings.TrimSpace(query))))
+	}
+
+	return Tracer().Start(ctx, name, opts...)
+}
+
+func endStatement(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+
+	outcome := OutcomeSuccess
+	if err != nil && err != driver.ErrSkip {
+		outcome = OutcomeFailed
+	}
+
+	End(span, outcome, err)
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startStatement(ctx, "sql exec", query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endStatement(span, err)
+
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startStatement(ctx, "sql query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endStatement(span, err)
+
+	return rows, err
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	spanCtx, span := startStatement(ctx, "sql begin", "")
+	tx, err := c.begin(spanCtx, opts)
+	endStatement(span, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedTx{Tx: tx, ctx: ctx}, nil
+}
+
+func (c *tracedConn) begin(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+// CheckNamedValue returns driver.ErrSkip, which makes database/sql convert
+// the value itself, when the connection does not check values.
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+// tracedTx traces the end of a transaction, which driver.Tx gives no context
+// for, under the span the transaction began in.
+type tracedTx struct {
+	driver.Tx
+	ctx context.Context
+}
+
+func (tx *tracedTx) Commit() error {
+	_, span := startStatement(tx.ctx, "sql commit", "")
+	err := tx.Tx.Commit()
+	endStatement(span, err)
+
+	return err
+}
+
+func (tx *tracedTx) Rollback() error {
+	_, span := startStatement(tx.ctx, "sql rollback", "")
+	err := tx.Tx.Rollback()
+	endStatement(span, err)
+
+	return err
+}
@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry tracing: the exporters, a span per
+// HTTP route and a span per SQL statement.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "github.com/siraj18/balance-service-new"
+	serviceName = "balance-service"
+)
+
+// The attributes set on the spans of the service.
+const (
+	OperationKey    = attribute.Key("balance.operation")
+	AmountBucketKey = attribute.Key("balance.amount_bucket")
+	OutcomeKey      = attribute.Key("balance.outcome")
+)
+
+// The outcomes of a span, matching the outcomes of the audit log.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// NewExporter returns the exporter of kind, otlp or stdout. The otlp exporter
+// is configured with the standard OTEL_EXPORTER_OTLP_* variables.
+func NewExporter(ctx context.Context, kind string) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case "otlp":
+		return otlptracehttp.New(ctx)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected otlp or stdout", kind)
+	}
+}
+
+// Install makes a provider built with opts the global one, together with the
+// W3C trace context propagator, and returns it so that it can be shut down.
+// The service exports with sdktrace.WithBatcher, tests with sdktrace.WithSyncer
+// and a tracetest.InMemoryExporter.
+func Install(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	}, opts...)
+
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider
+}
+
+// AmountBucket groups amount by its order of magnitude, keeping the
+// cardinality of the attribute low.
+func AmountBucket(amount float64) string {
+	amount = math.Abs(amount)
+
+	switch {
+	case amount < 10:
+		return "0-10"
+	case amount < 100:
+		return "10-100"
+	case amount < 1000:
+		return "100-1000"
+	case amount < 10000:
+		return "1000-10000"
+	default:
+		return "10000+"
+	}
+}
+
+// End records the outcome and the error of the span and ends it. Rejections,
+// such as insufficient funds, are not errors of the service and leave the span
+// status unset.
+func End(span trace.Span, outcome string, err error) {
+	span.SetAttributes(OutcomeKey.String(outcome))
+
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	if outcome == OutcomeFailed {
+		span.SetStatus(codes.Error, "")
+	}
+
+	span.End()
+}
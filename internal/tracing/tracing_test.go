@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func install(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := Install(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	return exporter
+}
+
+func attributeValue(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	exporter := install(t)
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Post("/transferBalance", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not enough money", http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/transferBalance", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	require.Equal(t, "POST /transferBalance", span.Name)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	require.Equal(t, OutcomeRejected, attributeValue(span, OutcomeKey).AsString())
+	require.Equal(t, "/transferBalance", attributeValue(span, "http.route").AsString())
+	require.Equal(t, codes.Unset, span.Status.Code)
+}
+
+func TestMiddlewareMarksFailures(t *testing.T) {
+	exporter := install(t)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, OutcomeFailed, attributeValue(spans[0], OutcomeKey).AsString())
+	require.Equal(t, codes.Error, spans[0].Status.Code)
+}
+
+func TestAmountBucket(t *testing.T) {
+	require.Equal(t, "0-10", AmountBucket(9.99))
+	require.Equal(t, "10-100", AmountBucket(-50))
+	require.Equal(t, "1000-10000", AmountBucket(1000))
+	require.Equal(t, "10000+", AmountBucket(1e6))
+}
+
+// fakeConn accepts every statement and returns no rows.
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeConn{}, nil }
+func (fakeConn) Commit() error                             { return nil }
+func (fakeConn) Rollback() error                           { return nil }
+func (fakeConn) Ping(ctx context.Context) error            { return nil }
+func (fakeConn) ResetSession(ctx context.Context) error    { return nil }
+func (fakeConn) CheckNamedValue(*driver.NamedValue) error  { return nil }
+
+func (fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return fakeConn{}, nil
+}
+
+func (fakeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"id"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(ctx context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                            { return nil }
+
+func TestStatementsTracedWithinRequests(t *testing.T) {
+	exporter := install(t)
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{}))
+	defer db.Close()
+
+	_, err := db.ExecContext(context.Background(), "UPDATE users SET balance = 0")
+	require.NoError(t, err)
+	require.Empty(t, exporter.GetSpans())
+
+	ctx, span := Tracer().Start(context.Background(), "request")
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET balance = 0")
+	require.NoError(t, err)
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM users")
+	require.NoError(t, err)
+	rows.Close()
+	require.NoError(t, tx.Commit())
+
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 5)
+
+	names := make([]string, 0, len(spans))
+	for _, s := range spans[:4] {
+		names = append(names, s.Name)
+		require.Equal(t, span.SpanContext().SpanID(), s.Parent.SpanID())
+	}
+
+	require.Equal(t, []string{"sql begin", "sql exec", "sql query", "sql commit"}, names)
+	require.Equal(t, "UPDATE users SET balance = 0", attributeValue(spans[1], "db.statement").AsString())
+}
+
+// basicConn implements none of the optional interfaces of a connection.
+type basicConn struct{}
+
+func (basicConn) Prepare(query string) (driver.Stmt, error) { return basicStmt{}, nil }
+func (basicConn) Close() error                              { return nil }
+func (basicConn) Begin() (driver.Tx, error)                 { return fakeConn{}, nil }
+
+type basicStmt struct{}
+
+func (basicStmt) Close() error                                    { return nil }
+func (basicStmt) NumInput() int                                   { return -1 }
+func (basicStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (basicStmt) Query(args []driver.Value) (driver.Rows, error)  { return fakeRows{}, nil }
+
+type basicConnector struct{}
+
+func (basicConnector) Connect(ctx context.Context) (driver.Conn, error) { return basicConn{}, nil }
+func (basicConnector) Driver() driver.Driver                            { return nil }
+
+func TestBasicConnectionsTraced(t *testing.T) {
+	exporter := install(t)
+
+	db := sql.OpenDB(WrapConnector(basicConnector{}))
+	defer db.Close()
+
+	ctx, span := Tracer().Start(context.Background(), "request")
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	// the statements fall back to prepared ones
+	result, err := tx.ExecContext(ctx, "UPDATE users SET balance = $1", 0)
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+	require.NoError(t, tx.Commit())
+
+	_, err = db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.Error(t, err)
+
+	span.End()
+
+	names := make([]string, 0)
+	for _, s := range exporter.GetSpans() {
+		names = append(names, s.Name)
+	}
+
+	require.Equal(t, []string{"sql begin", "sql commit", "sql begin", "request"}, names)
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/csvtool"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseImport reads user_id;amount;comment lines. A header line is skipped,
+// lines that cannot be parsed are reported instead of returned as rows.
+func ParseImport(r io.Reader) ([]models.ImportRow, []models.ImportLineError, error) {
+	records, err := csvtool.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]models.ImportRow, 0, len(records))
+	lineErrors := make([]models.ImportLineError, 0)
+
+	for i, record := range records {
+		line := i + 1
+
+		if i == 0 && len(record) > 0 && strings.EqualFold(record[0], "user_id") {
+			continue
+		}
+
+		if len(record) < 2 || len(record) > 3 {
+			lineErrors = append(lineErrors, models.ImportLineError{Line: line, Error: "expected user_id;amount;comment"})
+			continue
+		}
+
+		if _, err := uuid.Parse(record[0]); err != nil {
+			lineErrors = append(lineErrors, models.ImportLineError{Line: line, Error: fmt.Sprintf("invalid user id %q", record[0])})
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || amount == 0 {
+			lineErrors = append(lineErrors, models.ImportLineError{Line: line, Error: fmt.Sprintf("invalid amount %q", record[1])})
+			continue
+		}
+
+		row := models.ImportRow{Line: line, UserId: record[0], Amount: amount}
+		if len(record) == 3 {
+			row.Comment = record[2]
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, lineErrors, nil
+}
+
+type BalanceImporter interface {
+	ImportBalances(context.Context, []models.ImportRow, bool) (*models.ImportResult, error)
+}
+
+// ImportCsv parses r and hands the rows to importer. Lines that cannot be
+// parsed turn the import into a dry run, so the remaining lines are still
+// validated and every error is reported at once.
+func ImportCsv(ctx context.Context, importer BalanceImporter, r io.Reader, dryRun bool) (*models.ImportResult, error) {
+	rows, lineErrors, err := ParseImport(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := importer.ImportBalances(ctx, rows, dryRun || len(lineErrors) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DryRun = dryRun
+	result.Rows += len(lineErrors)
+	result.Errors = append(result.Errors, lineErrors...)
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+
+	return result, nil
+}
@@ -6,9 +6,7 @@ import (
 	"github.com/siraj18/balance-service-new/pkg/csvtool"
 )
 
-const folder = "./files/reports/"
-
-func GenerateReportsLink(reserves *[]models.Reserve, host string) (string, error) {
+func GenerateReportsLink(reserves *[]models.Reserve, folder, host string) (string, error) {
 	serviceGain := make(map[string]float64)
 
 	for _, j := range *reserves {
@@ -32,3 +30,26 @@ func GenerateReportsLink(reserves *[]models.Reserve, host string) (string, error
 
 	return host + "/reports/" + fileId, nil
 }
+
+func GenerateCreditReportLink(accounts *[]models.Account, folder, host string) (string, error) {
+	data := make([][]string, 0, len(*accounts)+1)
+	data = append(data, []string{"id", "owner", "balance", "credit_limit", "credit_used"})
+
+	for _, account := range *accounts {
+		data = append(data, []string{
+			account.Id,
+			account.Owner,
+			fmt.Sprintf("%f", account.Balance),
+			fmt.Sprintf("%f", account.CreditLimit),
+			fmt.Sprintf("%f", -account.Balance),
+		})
+	}
+
+	fileId, err := csvtool.CreateFile(data, folder)
+
+	if err != nil {
+		return "", err
+	}
+
+	return host + "/reports/" + fileId, nil
+}
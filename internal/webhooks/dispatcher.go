@@ -0,0 +1,218 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Store is the delivery queue, see postgresdb.BalanceRepository.
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+type Option func(*dispatcher)
+
+// WithClient sends the requests with client instead of a client with a ten
+// second timeout.
+func WithClient(client *http.Client) Option {
+	return func(d *dispatcher) {
+		d.client = client
+	}
+}
+
+// WithBackoff sets the delay after the first failed attempt and its upper
+// bound, the delay doubles with every attempt.
+func WithBackoff(base, max time.Duration) Option {
+	return func(d *dispatcher) {
+		d.backoff = base
+		d.maxBackoff = max
+	}
+}
+
+// WithMaxAttempts sets the number of attempts after which a delivery is
+// moved to the dead letter state.
+func WithMaxAttempts(attempts int) Option {
+	return func(d *dispatcher) {
+		d.maxAttempts = attempts
+	}
+}
+
+type dispatcher struct {
+	store       Store
+	client      *http.Client
+	interval    time.Duration
+	batch       int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+	logger      *logrus.Logger
+}
+
+func NewDispatcher(store Store, interval time.Duration, batch int, opts ...Option) *dispatcher {
+	d := &dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		interval:    interval,
+		batch:       batch,
+		backoff:     30 * time.Second,
+		maxBackoff:  time.Hour,
+		maxAttempts: 10,
+		logger:      logrus.StandardLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Sign returns the signature of a request body sent at timestamp: the hex
+// HMAC-SHA256 of "timestamp.body" keyed with the subscription secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run dispatches due deliveries every interval until ctx is cancelled.
+func (d *dispatcher) Run(ctx context.Context) {
+	d.logger.Info("starting webhook dispatcher")
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error(err)
+		}
+
+		if err == nil && sent == d.batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			d.logger.Info("stopping webhook dispatcher...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch sends one batch of due deliveries and records their outcome. It
+// returns the number of attempted deliveries.
+func (d *dispatcher) Dispatch(ctx context.Context) (int, error) {
+	// the lease outlives a request that runs into the client timeout
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.batch, d.client.Timeout+time.Minute)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		d.attempt(ctx, delivery)
+
+		if err = d.store.FinishWebhookDelivery(ctx, delivery); err != nil {
+			return i, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	delivery.Attempt++
+
+	statusCode, err := d.send(ctx, delivery)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.WebhookDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = nil
+		return
+	}
+
+	message := err.Error()
+	delivery.LastError = &message
+
+	if delivery.Attempt >= d.maxAttempts {
+		delivery.Status = models.WebhookDead
+		delivery.NextAttemptAt = nil
+		return
+	}
+
+	next := time.Now().Add(d.delay(delivery.Attempt))
+	delivery.NextAttemptAt = &next
+}
+
+func (d *dispatcher) delay(attempt int) time.Duration {
+	delay := d.backoff << (attempt - 1)
+	if delay <= 0 || delay > d.maxBackoff {
+		return d.maxBackoff
+	}
+
+	return delay
+}
+
+func (d *dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.Id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
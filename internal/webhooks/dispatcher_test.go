@@ -0,0 +1,151 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/internal/webhooks"
+	"github.com/stretchr/testify/suite"
+)
+
+type memoryStore struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+}
+
+func (s *memoryStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := []models.WebhookDelivery{}
+
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.WebhookPending && len(claimed) < limit {
+			claimed = append(claimed, delivery)
+		}
+	}
+
+	return claimed, nil
+}
+
+func (s *memoryStore) FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.deliveries {
+		if s.deliveries[i].Id == delivery.Id {
+			s.deliveries[i] = *delivery
+		}
+	}
+
+	return nil
+}
+
+type dispatcherSuite struct {
+	suite.Suite
+	store *memoryStore
+}
+
+func TestDispatcherSuite(t *testing.T) {
+	suite.Run(t, new(dispatcherSuite))
+}
+
+func (t *dispatcherSuite) delivery(url string) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		Id:        "34be95d0-9a41-11ec-b909-0242ac120020",
+		EventType: models.EventDeposited,
+		Status:    models.WebhookPending,
+		Url:       url,
+		Secret:    "secret",
+		Event: &models.Event{
+			Id:        "34be95d0-9a41-11ec-b909-0242ac120021",
+			Type:      models.EventDeposited,
+			Version:   models.EventSchemaVersion,
+			AccountId: "f0812ab6-9993-11ec-b909-0242ac120002",
+			Data:      json.RawMessage(`{"amount":10,"balance":10,"actor":"anonymous"}`),
+		},
+	}
+}
+
+func (t *dispatcherSuite) Test_signedDelivery() {
+	received := make(chan *http.Request, 1)
+	var body []byte
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer receiver.Close()
+
+	store := &memoryStore{deliveries: []models.WebhookDelivery{t.delivery(receiver.URL)}}
+
+	sent, err := webhooks.NewDispatcher(store, time.Second, 10).Dispatch(context.Background())
+	t.Require().NoError(err)
+	t.Equal(1, sent)
+
+	r := <-received
+	timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+	t.Require().NoError(err)
+
+	t.Equal(webhooks.Sign("secret", timestamp, body), r.Header.Get(webhooks.HeaderSignature))
+	t.Equal(models.EventDeposited, r.Header.Get(webhooks.HeaderEvent))
+
+	event := models.Event{}
+	t.Require().NoError(json.Unmarshal(body, &event))
+	t.Equal("f0812ab6-9993-11ec-b909-0242ac120002", event.AccountId)
+
+	t.Equal(models.WebhookDelivered, store.deliveries[0].Status)
+	t.Equal(http.StatusOK, *store.deliveries[0].LastStatusCode)
+}
+
+func (t *dispatcherSuite) Test_retryWithBackoff() {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	store := &memoryStore{deliveries: []models.WebhookDelivery{t.delivery(receiver.URL)}}
+	d := webhooks.NewDispatcher(store, time.Second, 10, webhooks.WithBackoff(time.Minute, time.Hour))
+
+	before := time.Now()
+	_, err := d.Dispatch(context.Background())
+	t.Require().NoError(err)
+
+	_, err = d.Dispatch(context.Background())
+	t.Require().NoError(err)
+
+	delivery := store.deliveries[0]
+	t.Equal(models.WebhookPending, delivery.Status)
+	t.Equal(2, delivery.Attempt)
+	t.Equal(http.StatusServiceUnavailable, *delivery.LastStatusCode)
+	t.WithinDuration(before.Add(2*time.Minute), *delivery.NextAttemptAt, 5*time.Second)
+}
+
+func (t *dispatcherSuite) Test_deadLetter() {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	receiver.Close()
+
+	store := &memoryStore{deliveries: []models.WebhookDelivery{t.delivery(receiver.URL)}}
+	d := webhooks.NewDispatcher(store, time.Second, 10, webhooks.WithMaxAttempts(3))
+
+	for i := 0; i < 3; i++ {
+		_, err := d.Dispatch(context.Background())
+		t.Require().NoError(err)
+	}
+
+	delivery := store.deliveries[0]
+	t.Equal(models.WebhookDead, delivery.Status)
+	t.Equal(3, delivery.Attempt)
+	t.Nil(delivery.NextAttemptAt)
+	t.NotNil(delivery.LastError)
+}
@@ -0,0 +1,60 @@
+// Package api holds what the service and its clients agree on beyond the
+// payloads: the errors the service reports and the headers it reads. It
+// depends on nothing but the standard library, so the client can import it
+// without linking the service.
+package api
+
+import "fmt"
+
+const (
+	// IdempotencyKeyHeader carries the key under which the result of a
+	// request is kept, so that a retry with the same key returns it instead
+	// of repeating the change.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader marks a response returned for an earlier
+	// request with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// The errors reported by the service. A response carries the message of one
+// of them, some extend it with details after a colon.
+var (
+	ErrorUserNotFound   = fmt.Errorf("user not found")
+	ErrorNotEnoughMoney = fmt.Errorf("not enough money")
+	ErrorInvalidInput   = fmt.Errorf("invalid type for uid")
+	ErrorNegativeAmount = fmt.Errorf("negative amount")
+
+	ErrorInvalidSortParameters = fmt.Errorf("invalid sort parameters")
+
+	ErrorReserveNotFound          = fmt.Errorf("reserve not found")
+	ErrorReserveAlreadyRecognized = fmt.Errorf("reserve already recognized")
+	ErrorReserveAlreadyDeReserved = fmt.Errorf("reserve already de-reserved")
+
+	ErrorAccountAlreadyExists = fmt.Errorf("account already exists")
+	ErrorAccountFrozen        = fmt.Errorf("account is frozen")
+	ErrorAccountClosed        = fmt.Errorf("account is closed")
+	ErrorAccountNotEmpty      = fmt.Errorf("account balance is not zero")
+	ErrorAccountHasReserves   = fmt.Errorf("account has open reserves")
+	ErrorCreditLimitInUse     = fmt.Errorf("credit limit is lower than the credit in use")
+
+	ErrorLimitExceeded  = fmt.Errorf("limit exceeded")
+	ErrorLimitsNotFound = fmt.Errorf("limits not found")
+	ErrorInvalidLimits  = fmt.Errorf("invalid limits")
+
+	ErrorScheduleNotFound = fmt.Errorf("schedule not found")
+	ErrorInvalidSchedule  = fmt.Errorf("invalid schedule")
+	ErrorScheduleState    = fmt.Errorf("schedule cannot be changed in its current status")
+
+	ErrorWebhookNotFound         = fmt.Errorf("webhook not found")
+	ErrorInvalidWebhook          = fmt.Errorf("invalid webhook")
+	ErrorWebhookDeliveryNotFound = fmt.Errorf("webhook delivery not found")
+	ErrorWebhookDeliveryNotDead  = fmt.Errorf("only dead webhook deliveries can be retried")
+
+	ErrorIdempotencyKeyInProgress = fmt.Errorf("a request with this idempotency key is in progress")
+	ErrorIdempotencyKeyReused     = fmt.Errorf("idempotency key was used for a different request")
+
+	ErrorNoCredentials      = fmt.Errorf("no credentials provided")
+	ErrorInvalidCredentials = fmt.Errorf("invalid credentials")
+	ErrorForbidden          = fmt.Errorf("insufficient scope")
+)
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+)
+
+func (c *Client) account(ctx context.Context, method, path string, in interface{}) (*Account, error) {
+	var account Account
+
+	if err := c.call(ctx, method, path, in, &account); err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// CreateAccount creates an account of owner, the service generates the id
+// when it is empty.
+func (c *Client) CreateAccount(ctx context.Context, id, owner string, metadata Metadata) (*Account, error) {
+	return c.account(ctx, http.MethodPost, "/accounts", models.CreateAccountQuery{Id: id, Owner: owner, Metadata: metadata})
+}
+
+func (c *Client) GetAccount(ctx context.Context, id string) (*Account, error) {
+	return c.account(ctx, http.MethodGet, "/accounts/"+url.PathEscape(id), nil)
+}
+
+func (c *Client) FreezeAccount(ctx context.Context, id string, debits, credits bool) (*Account, error) {
+	return c.account(ctx, http.MethodPost, "/accounts/"+url.PathEscape(id)+"/freeze",
+		models.FreezeAccountQuery{Debits: debits, Credits: credits})
+}
+
+func (c *Client) UnfreezeAccount(ctx context.Context, id string) (*Account, error) {
+	return c.account(ctx, http.MethodPost, "/accounts/"+url.PathEscape(id)+"/unfreeze", nil)
+}
+
+func (c *Client) CloseAccount(ctx context.Context, id string) (*Account, error) {
+	return c.account(ctx, http.MethodPost, "/accounts/"+url.PathEscape(id)+"/close", nil)
+}
+
+func (c *Client) SetCreditLimit(ctx context.Context, id string, creditLimit float64) (*Account, error) {
+	return c.account(ctx, http.MethodPut, "/accounts/"+url.PathEscape(id)+"/creditLimit",
+		models.SetCreditLimitQuery{CreditLimit: creditLimit})
+}
+
+func (c *Client) GetLimits(ctx context.Context, scope string) (*Limits, error) {
+	var limits Limits
+
+	if err := c.call(ctx, http.MethodGet, "/limits/"+url.PathEscape(scope), nil, &limits); err != nil {
+		return nil, err
+	}
+
+	return &limits, nil
+}
+
+func (c *Client) SetLimits(ctx context.Context, scope string, limits Limits) (*Limits, error) {
+	var updated Limits
+
+	if err := c.call(ctx, http.MethodPut, "/limits/"+url.PathEscape(scope), limits, &updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (c *Client) DeleteLimits(ctx context.Context, scope string) error {
+	return c.call(ctx, http.MethodDelete, "/limits/"+url.PathEscape(scope), nil, nil)
+}
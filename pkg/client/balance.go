@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/csvtool"
+)
+
+const defaultPageSize = 100
+
+func (c *Client) GetBalance(ctx context.Context, id string) (*User, error) {
+	var user User
+
+	if err := c.call(ctx, http.MethodGet, "/balance/"+url.PathEscape(id), nil, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ChangeBalance deposits a positive money and withdraws a negative one.
+func (c *Client) ChangeBalance(ctx context.Context, id string, money float64) (*User, error) {
+	var user User
+
+	err := c.call(ctx, http.MethodPost, "/changeBalance", models.UserChangeBalanceQuery{Id: id, Money: money}, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (c *Client) TransferBalance(ctx context.Context, fromId, toId string, money float64) error {
+	return c.call(ctx, http.MethodPost, "/transferBalance", models.UserTransferBalanceQuery{FromId: fromId, ToId: toId, Money: money}, nil)
+}
+
+// GetAllTransactions returns a page of the transactions of id, pages start
+// at 1.
+func (c *Client) GetAllTransactions(ctx context.Context, id, sortType string, limit, page int) (*[]Transaction, error) {
+	transactions := []Transaction{}
+
+	query := models.AllTransactionsGetQuery{Id: id, SortType: sortType, Limit: limit, Page: page}
+	if err := c.call(ctx, http.MethodPost, "/allTransactions", query, &transactions); err != nil {
+		return nil, err
+	}
+
+	return &transactions, nil
+}
+
+// Transactions iterates over all transactions of id, fetching pageSize of
+// them at a time.
+func (c *Client) Transactions(ctx context.Context, id, sortType string, pageSize int) *TransactionIterator {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	return &TransactionIterator{client: c, ctx: ctx, id: id, sortType: sortType, pageSize: pageSize}
+}
+
+func (c *Client) ReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	return c.reserveCall(ctx, "/reserveMoney", userId, serviceId, orderId, amount)
+}
+
+func (c *Client) RecognizedMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	return c.reserveCall(ctx, "/recognizeMoney", userId, serviceId, orderId, amount)
+}
+
+func (c *Client) DeReserveMoney(ctx context.Context, userId, serviceId, orderId string, amount float64) error {
+	return c.reserveCall(ctx, "/deReserveMoney", userId, serviceId, orderId, amount)
+}
+
+func (c *Client) reserveCall(ctx context.Context, path, userId, serviceId, orderId string, amount float64) error {
+	query := models.ReserveMoneyQuery{UserId: userId, ServiceId: serviceId, OrderId: orderId, Amount: amount}
+
+	return c.call(ctx, http.MethodPost, path, query, nil)
+}
+
+// GetReportLink returns the link to the csv report of the revenue per
+// service recognized in month.
+func (c *Client) GetReportLink(ctx context.Context, year, month int) (string, error) {
+	var link string
+
+	err := c.call(ctx, http.MethodPost, "/getReportLink", models.GetReportLinkQuery{Year: year, Month: month}, &link)
+
+	return link, err
+}
+
+// GetCreditReportLink returns the link to the csv report of the accounts
+// using their credit line.
+func (c *Client) GetCreditReportLink(ctx context.Context) (string, error) {
+	var link string
+
+	err := c.call(ctx, http.MethodGet, "/getCreditReportLink", nil, &link)
+
+	return link, err
+}
+
+// ExecuteBatch runs operations in one request. A batch the service rejects
+// before running it returns the invalid operations in the result together
+// with the error.
+func (c *Client) ExecuteBatch(ctx context.Context, atomic bool, operations []BatchOperation) (*BatchResult, error) {
+	body, err := json.Marshal(models.BatchQuery{Atomic: atomic, Operations: operations})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, "/batch", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result BatchResult
+
+	switch {
+	case resp.status == http.StatusOK, resp.status == http.StatusUnprocessableEntity && resp.json():
+		if err = resp.decode(&result); err != nil {
+			return nil, err
+		}
+
+		return &result, nil
+	case resp.status == http.StatusBadRequest && resp.json():
+		if err = resp.decode(&result); err != nil {
+			return nil, err
+		}
+
+		return &result, decodeError(resp.status, []byte("invalid batch operations"))
+	default:
+		return nil, resp.err()
+	}
+}
+
+// ImportBalances uploads rows as an import file. Rejected rows are reported
+// in the result, which is then not applied.
+func (c *Client) ImportBalances(ctx context.Context, rows []ImportRow, dryRun bool) (*ImportResult, error) {
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, []string{row.UserId, strconv.FormatFloat(row.Amount, 'f', -1, 64), row.Comment})
+	}
+
+	var file bytes.Buffer
+	if err := csvtool.WriteAll(&file, records); err != nil {
+		return nil, err
+	}
+
+	path := "/imports?dry_run=" + strconv.FormatBool(dryRun)
+
+	resp, err := c.send(ctx, http.MethodPost, path, "text/csv", file.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.status != http.StatusUnprocessableEntity || !resp.json() {
+		if err = resp.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var result ImportResult
+	if err = resp.decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,251 @@
+// Package client is the Go client of the balance service HTTP API. Its
+// methods mirror the repository behind the handlers and return the same
+// errors, so they can be matched with errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultRetries    = 3
+	defaultBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+	header     http.Header
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout limits every attempt of a call, 10 seconds by default. The
+// context of the call limits all of its attempts together.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries retries a call up to retries times after network errors,
+// timeouts and the responses 429, 502, 503 and 504. The delay starts at
+// backoff and doubles with every attempt. Mutating calls are retried with the
+// same Idempotency-Key, so the service applies them at most once.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+func WithApiKey(key string) Option {
+	return func(c *Client) {
+		c.header.Set("X-API-Key", key)
+	}
+}
+
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// NewClient returns a client of the service at baseUrl, e.g.
+// http://localhost:8080.
+func NewClient(baseUrl string, opts ...Option) *Client {
+	c := &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		httpClient: &http.Client{},
+		header:     http.Header{},
+		timeout:    defaultTimeout,
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// err returns the error the response reports. Some errors are reported with
+// status 200, they are told apart by the nosniff header http.Error sets.
+func (r *response) err() error {
+	if r.status < http.StatusBadRequest && r.header.Get("X-Content-Type-Options") != "nosniff" {
+		return nil
+	}
+
+	return decodeError(r.status, r.body)
+}
+
+func (r *response) json() bool {
+	return r.header.Get("Content-Type") == "application/json"
+}
+
+func (r *response) decode(out interface{}) error {
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *string:
+		*out = strings.TrimSpace(string(r.body))
+		return nil
+	default:
+		return json.Unmarshal(r.body, out)
+	}
+}
+
+func retryable(resp *response) bool {
+	switch resp.status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		// the first attempt is still being served, its response will be replayed
+		return strings.TrimSpace(string(resp.body)) == ErrorIdempotencyKeyInProgress.Error()
+	default:
+		return false
+	}
+}
+
+// send performs the request and retries it as configured.
+func (c *Client) send(ctx context.Context, method, path, contentType string, body []byte) (*response, error) {
+	var key string
+	if method != http.MethodGet {
+		key = uuid.NewString()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.attempt(ctx, method, path, contentType, key, body)
+		if err != nil {
+			// the context of the call is done, only the attempt timed out
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			if attempt < c.retries {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if retryable(resp) && attempt < c.retries {
+			continue
+		}
+
+		return resp, nil
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path, contentType, key string, body []byte) (*response, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for header, values := range c.header {
+		req.Header[header] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if key != "" {
+		req.Header.Set(api.IdempotencyKeyHeader, key)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{status: resp.StatusCode, header: resp.Header, body: data}, nil
+}
+
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	delay := c.backoff << (attempt - 1)
+	if delay <= 0 || delay > c.maxBackoff {
+		delay = c.maxBackoff
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// call sends in as JSON and decodes the response into out.
+func (c *Client) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	var contentType string
+
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	resp, err := c.send(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+
+	if err = resp.err(); err != nil {
+		return err
+	}
+
+	return resp.decode(out)
+}
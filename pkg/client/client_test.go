@@ -0,0 +1,236 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/client"
+	"github.com/stretchr/testify/suite"
+)
+
+const userId = "f0812ab6-9993-11ec-b909-0242ac120002"
+
+// scriptedServer answers the n-th request with the n-th handler and records
+// the requests it received.
+type scriptedServer struct {
+	mu       sync.Mutex
+	handlers []http.HandlerFunc
+	requests []*http.Request
+}
+
+func (s *scriptedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := len(s.requests)
+	s.requests = append(s.requests, r)
+	s.mu.Unlock()
+
+	if n >= len(s.handlers) {
+		w.WriteHeader(http.StatusTeapot)
+		return
+	}
+
+	s.handlers[n](w, r)
+}
+
+func (s *scriptedServer) received() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+func status(code int, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, message, code)
+	}
+}
+
+func jsonBody(v interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+type clientSuite struct {
+	suite.Suite
+	script *scriptedServer
+	server *httptest.Server
+	client *client.Client
+}
+
+func TestClientSuite(t *testing.T) {
+	suite.Run(t, new(clientSuite))
+}
+
+func (t *clientSuite) SetupTest() {
+	t.script = &scriptedServer{}
+	t.server = httptest.NewServer(t.script)
+	t.client = client.NewClient(t.server.URL, client.WithRetries(3, time.Millisecond), client.WithApiKey("key"))
+}
+
+func (t *clientSuite) TearDownTest() {
+	t.server.Close()
+}
+
+func (t *clientSuite) Test_retriesWithSameIdempotencyKey() {
+	t.script.handlers = []http.HandlerFunc{
+		status(http.StatusServiceUnavailable, "request timed out"),
+		status(http.StatusConflict, client.ErrorIdempotencyKeyInProgress.Error()),
+		func(w http.ResponseWriter, r *http.Request) {},
+	}
+
+	err := t.client.TransferBalance(context.Background(), userId, "to", 10)
+	t.Require().NoError(err)
+
+	requests := t.script.received()
+	t.Require().Len(requests, 3)
+
+	key := requests[0].Header.Get("Idempotency-Key")
+	t.NotEmpty(key)
+	for _, r := range requests {
+		t.Equal(key, r.Header.Get("Idempotency-Key"))
+		t.Equal("key", r.Header.Get("X-API-Key"))
+	}
+}
+
+func (t *clientSuite) Test_newKeyPerCall() {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	t.script.handlers = []http.HandlerFunc{ok, ok}
+
+	t.Require().NoError(t.client.TransferBalance(context.Background(), userId, "to", 10))
+	t.Require().NoError(t.client.TransferBalance(context.Background(), userId, "to", 10))
+
+	requests := t.script.received()
+	t.NotEqual(requests[0].Header.Get("Idempotency-Key"), requests[1].Header.Get("Idempotency-Key"))
+}
+
+func (t *clientSuite) Test_getWithoutIdempotencyKey() {
+	t.script.handlers = []http.HandlerFunc{jsonBody(models.User{Id: userId, Balance: 10})}
+
+	user, err := t.client.GetBalance(context.Background(), userId)
+	t.Require().NoError(err)
+
+	t.Equal(10.0, user.Balance)
+	t.Empty(t.script.received()[0].Header.Get("Idempotency-Key"))
+}
+
+func (t *clientSuite) Test_givesUpAfterRetries() {
+	for i := 0; i < 4; i++ {
+		t.script.handlers = append(t.script.handlers, status(http.StatusBadGateway, "bad gateway"))
+	}
+
+	err := t.client.TransferBalance(context.Background(), userId, "to", 10)
+
+	var e *client.Error
+	t.Require().True(errors.As(err, &e))
+	t.Equal(http.StatusBadGateway, e.StatusCode)
+	t.Len(t.script.received(), 4)
+}
+
+func (t *clientSuite) Test_doesNotRetryClientErrors() {
+	t.script.handlers = []http.HandlerFunc{status(http.StatusBadRequest, "invalid post data")}
+
+	err := t.client.TransferBalance(context.Background(), userId, "to", 10)
+
+	var e *client.Error
+	t.Require().True(errors.As(err, &e))
+	t.Equal(http.StatusBadRequest, e.StatusCode)
+	t.Equal("invalid post data", e.Message)
+	t.Nil(errors.Unwrap(err))
+	t.Len(t.script.received(), 1)
+}
+
+func (t *clientSuite) Test_decodesErrors() {
+	t.script.handlers = []http.HandlerFunc{
+		status(http.StatusOK, "not enough money"),
+		status(http.StatusNotFound, "user not found"),
+		status(http.StatusBadRequest, "invalid schedule: expected exactly 5 fields"),
+	}
+
+	_, err := t.client.ChangeBalance(context.Background(), userId, -10)
+	t.ErrorIs(err, client.ErrorNotEnoughMoney)
+
+	_, err = t.client.GetBalance(context.Background(), userId)
+	t.ErrorIs(err, client.ErrorUserNotFound)
+
+	_, err = t.client.CreateSchedule(context.Background(), client.CreateScheduleQuery{})
+	t.ErrorIs(err, client.ErrorInvalidSchedule)
+	t.Contains(err.Error(), "expected exactly 5 fields")
+}
+
+func (t *clientSuite) Test_attemptTimeout() {
+	t.client = client.NewClient(t.server.URL, client.WithRetries(1, time.Millisecond), client.WithTimeout(20*time.Millisecond))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	t.script.handlers = []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		},
+		jsonBody(models.User{Id: userId, Balance: 10}),
+	}
+
+	user, err := t.client.GetBalance(context.Background(), userId)
+	t.Require().NoError(err)
+	t.Equal(10.0, user.Balance)
+}
+
+func (t *clientSuite) Test_contextCancelStopsRetries() {
+	t.client = client.NewClient(t.server.URL, client.WithRetries(10, time.Hour))
+	t.script.handlers = []http.HandlerFunc{status(http.StatusServiceUnavailable, "")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := t.client.TransferBalance(ctx, userId, "to", 10)
+
+	t.ErrorIs(err, context.DeadlineExceeded)
+	t.Len(t.script.received(), 1)
+}
+
+func (t *clientSuite) Test_transactionsIterator() {
+	page := func(ids ...string) http.HandlerFunc {
+		transactions := []models.Transaction{}
+		for _, id := range ids {
+			transactions = append(transactions, models.Transaction{Id: id})
+		}
+
+		return jsonBody(transactions)
+	}
+
+	t.script.handlers = []http.HandlerFunc{page("1", "2"), page("3", "4"), page("5")}
+
+	var ids []string
+	it := t.client.Transactions(context.Background(), userId, "date_asc", 2)
+	for it.Next() {
+		ids = append(ids, it.Transaction().Id)
+	}
+
+	t.Require().NoError(it.Err())
+	t.Equal([]string{"1", "2", "3", "4", "5"}, ids)
+	t.Len(t.script.received(), 3)
+}
+
+func (t *clientSuite) Test_transactionsIteratorError() {
+	t.script.handlers = []http.HandlerFunc{
+		jsonBody([]models.Transaction{{Id: "1"}}),
+		status(http.StatusBadRequest, "invalid sort parameters"),
+	}
+
+	it := t.client.Transactions(context.Background(), userId, "date_asc", 1)
+
+	t.True(it.Next())
+	t.False(it.Next())
+	t.ErrorIs(it.Err(), client.ErrorInvalidSortParameters)
+}
@@ -0,0 +1,203 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/siraj18/balance-service-new/internal/auth"
+	"github.com/siraj18/balance-service-new/internal/handlers"
+	"github.com/siraj18/balance-service-new/internal/handlers/mocks"
+	"github.com/siraj18/balance-service-new/internal/idempotency"
+	"github.com/siraj18/balance-service-new/internal/models"
+	"github.com/siraj18/balance-service-new/pkg/api"
+	"github.com/siraj18/balance-service-new/pkg/client"
+	"github.com/stretchr/testify/suite"
+)
+
+const otherUserId = "f0812ab6-9993-11ec-b909-0242ac120003"
+
+// dropFirstResponse loses the response to the first request after the server
+// has served it, like a connection reset on the way back.
+type dropFirstResponse struct {
+	dropped bool
+}
+
+func (d *dropFirstResponse) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil || d.dropped {
+		return resp, err
+	}
+
+	d.dropped = true
+	resp.Body.Close()
+
+	return nil, errors.New("connection reset by peer")
+}
+
+// contractSuite runs the client against the real handler.
+type contractSuite struct {
+	suite.Suite
+	rep    *mocks.MockRepository
+	server *httptest.Server
+	client *client.Client
+}
+
+func TestContractSuite(t *testing.T) {
+	suite.Run(t, new(contractSuite))
+}
+
+func (t *contractSuite) SetupTest() {
+	t.rep = mocks.NewMockRepository()
+
+	h := handlers.NewHandler(t.rep, handlers.WithIdempotency(idempotency.NewMemoryStore()))
+	t.server = httptest.NewServer(h.InitRoutes())
+	t.client = client.NewClient(t.server.URL, client.WithRetries(1, time.Millisecond))
+}
+
+func (t *contractSuite) TearDownTest() {
+	t.server.Close()
+	t.rep.AssertExpectations(t.T())
+}
+
+func (t *contractSuite) Test_getBalance() {
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId, Balance: 100}, nil)
+	t.rep.On("GetBalance", otherUserId).Return(nil, api.ErrorUserNotFound)
+
+	user, err := t.client.GetBalance(context.Background(), userId)
+	t.Require().NoError(err)
+	t.Equal(100.0, user.Balance)
+
+	_, err = t.client.GetBalance(context.Background(), otherUserId)
+	t.ErrorIs(err, client.ErrorUserNotFound)
+}
+
+func (t *contractSuite) Test_changeBalance() {
+	t.rep.On("ChangeBalance", userId, 50.0).Return(&models.User{Id: userId, Balance: 150}, nil)
+	t.rep.On("ChangeBalance", userId, -500.0).Return(nil, api.ErrorNotEnoughMoney)
+
+	user, err := t.client.ChangeBalance(context.Background(), userId, 50)
+	t.Require().NoError(err)
+	t.Equal(150.0, user.Balance)
+
+	_, err = t.client.ChangeBalance(context.Background(), userId, -500)
+	t.ErrorIs(err, client.ErrorNotEnoughMoney)
+}
+
+func (t *contractSuite) Test_transferBalance() {
+	t.rep.On("TransferBalance", userId, otherUserId, 10.0).Return(nil)
+	t.rep.On("TransferBalance", otherUserId, userId, 10.0).Return(api.ErrorAccountFrozen)
+
+	t.NoError(t.client.TransferBalance(context.Background(), userId, otherUserId, 10))
+	t.ErrorIs(t.client.TransferBalance(context.Background(), otherUserId, userId, 10), client.ErrorAccountFrozen)
+}
+
+func (t *contractSuite) Test_retryIsAppliedOnce() {
+	t.client = client.NewClient(t.server.URL,
+		client.WithRetries(1, time.Millisecond),
+		client.WithHttpClient(&http.Client{Transport: &dropFirstResponse{}}),
+	)
+
+	t.rep.On("ChangeBalance", userId, 50.0).Return(&models.User{Id: userId, Balance: 150}, nil).Once()
+
+	user, err := t.client.ChangeBalance(context.Background(), userId, 50)
+	t.Require().NoError(err)
+	t.Equal(150.0, user.Balance)
+}
+
+func (t *contractSuite) Test_transactions() {
+	t.rep.On("GetAllTransactions", userId, "date_desc", 2, 1).Return(&[]models.Transaction{{Id: "1"}, {Id: "2"}}, nil)
+	t.rep.On("GetAllTransactions", userId, "date_desc", 2, 2).Return(&[]models.Transaction{{Id: "3"}}, nil)
+
+	var ids []string
+	it := t.client.Transactions(context.Background(), userId, "date_desc", 2)
+	for it.Next() {
+		ids = append(ids, it.Transaction().Id)
+	}
+
+	t.Require().NoError(it.Err())
+	t.Equal([]string{"1", "2", "3"}, ids)
+}
+
+func (t *contractSuite) Test_reserve() {
+	t.rep.On("ReserveMoney", userId, "service", "order", 10.0).Return(nil)
+	t.rep.On("RecognizedMoney", userId, "service", "order", 10.0).Return(api.ErrorReserveAlreadyRecognized)
+	t.rep.On("DeReserveMoney", userId, "service", "order", 10.0).Return(api.ErrorReserveNotFound)
+
+	t.NoError(t.client.ReserveMoney(context.Background(), userId, "service", "order", 10))
+	t.ErrorIs(t.client.RecognizedMoney(context.Background(), userId, "service", "order", 10), client.ErrorReserveAlreadyRecognized)
+	t.ErrorIs(t.client.DeReserveMoney(context.Background(), userId, "service", "order", 10), client.ErrorReserveNotFound)
+}
+
+func (t *contractSuite) Test_accounts() {
+	t.rep.On("CreateAccount", userId, "owner", models.Metadata{"plan": "pro"}).Return(&models.Account{Id: userId, Owner: "owner"}, nil)
+	t.rep.On("FreezeAccount", userId, true, false).Return(nil, api.ErrorAccountClosed)
+
+	account, err := t.client.CreateAccount(context.Background(), userId, "owner", client.Metadata{"plan": "pro"})
+	t.Require().NoError(err)
+	t.Equal("owner", account.Owner)
+
+	_, err = t.client.FreezeAccount(context.Background(), userId, true, false)
+	t.ErrorIs(err, client.ErrorAccountClosed)
+}
+
+func (t *contractSuite) Test_batch() {
+	operations := []client.BatchOperation{{Type: client.BatchDeposit, Id: userId, Money: 10}}
+	t.rep.On("ExecuteBatch", true, operations).Return(&models.BatchResult{Atomic: true, Results: []models.BatchItemResult{
+		{Index: 0, Status: models.BatchItemFailed, Error: api.ErrorAccountFrozen.Error()},
+	}}, nil)
+
+	result, err := t.client.ExecuteBatch(context.Background(), true, operations)
+	t.Require().NoError(err)
+	t.False(result.Committed)
+	t.Equal(models.BatchItemFailed, result.Results[0].Status)
+
+	result, err = t.client.ExecuteBatch(context.Background(), true, []client.BatchOperation{{Type: client.BatchDeposit, Id: "bad", Money: 10}})
+	var e *client.Error
+	t.Require().True(errors.As(err, &e))
+	t.Equal(http.StatusBadRequest, e.StatusCode)
+	t.Require().Len(result.Results, 1)
+	t.Equal(models.BatchItemInvalid, result.Results[0].Status)
+}
+
+func (t *contractSuite) Test_importBalances() {
+	rows := []client.ImportRow{
+		{Line: 1, UserId: userId, Amount: 10.5, Comment: "refund"},
+		{Line: 2, UserId: otherUserId, Amount: -5},
+	}
+	t.rep.On("ImportBalances", rows, true).Return(&models.ImportResult{Rows: 2, Errors: []models.ImportLineError{
+		{Line: 2, Error: api.ErrorNotEnoughMoney.Error()},
+	}}, nil)
+
+	result, err := t.client.ImportBalances(context.Background(), rows, true)
+	t.Require().NoError(err)
+	t.True(result.DryRun)
+	t.Equal(2, result.Rows)
+	t.Require().Len(result.Errors, 1)
+	t.Equal(2, result.Errors[0].Line)
+}
+
+func (t *contractSuite) Test_credentials() {
+	authenticator := auth.NewApiKeyAuthenticator([]auth.ApiKey{
+		{Key: "reader-key", Subject: "reader", Scopes: []string{auth.ScopeReadBalance}},
+	})
+	h := handlers.NewHandler(t.rep, handlers.WithAuthenticator(authenticator))
+
+	server := httptest.NewServer(h.InitRoutes())
+	defer server.Close()
+
+	t.rep.On("GetBalance", userId).Return(&models.User{Id: userId}, nil)
+
+	reader := client.NewClient(server.URL, client.WithApiKey("reader-key"))
+
+	_, err := reader.GetBalance(context.Background(), userId)
+	t.NoError(err)
+
+	t.ErrorIs(reader.TransferBalance(context.Background(), userId, otherUserId, 10), client.ErrorForbidden)
+
+	_, err = client.NewClient(server.URL).GetBalance(context.Background(), userId)
+	t.ErrorIs(err, client.ErrorNoCredentials)
+}
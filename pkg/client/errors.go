@@ -0,0 +1,89 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/siraj18/balance-service-new/pkg/api"
+)
+
+// The errors reported by the service. Calls return an *Error wrapping the one
+// the response carries.
+var (
+	ErrorUserNotFound             = api.ErrorUserNotFound
+	ErrorNotEnoughMoney           = api.ErrorNotEnoughMoney
+	ErrorInvalidInput             = api.ErrorInvalidInput
+	ErrorNegativeAmount           = api.ErrorNegativeAmount
+	ErrorInvalidSortParameters    = api.ErrorInvalidSortParameters
+	ErrorReserveNotFound          = api.ErrorReserveNotFound
+	ErrorReserveAlreadyRecognized = api.ErrorReserveAlreadyRecognized
+	ErrorReserveAlreadyDeReserved = api.ErrorReserveAlreadyDeReserved
+	ErrorAccountAlreadyExists     = api.ErrorAccountAlreadyExists
+	ErrorAccountFrozen            = api.ErrorAccountFrozen
+	ErrorAccountClosed            = api.ErrorAccountClosed
+	ErrorAccountNotEmpty          = api.ErrorAccountNotEmpty
+	ErrorAccountHasReserves       = api.ErrorAccountHasReserves
+	ErrorCreditLimitInUse         = api.ErrorCreditLimitInUse
+	ErrorLimitExceeded            = api.ErrorLimitExceeded
+	ErrorLimitsNotFound           = api.ErrorLimitsNotFound
+	ErrorInvalidLimits            = api.ErrorInvalidLimits
+	ErrorScheduleNotFound         = api.ErrorScheduleNotFound
+	ErrorInvalidSchedule          = api.ErrorInvalidSchedule
+	ErrorScheduleState            = api.ErrorScheduleState
+	ErrorWebhookNotFound          = api.ErrorWebhookNotFound
+	ErrorInvalidWebhook           = api.ErrorInvalidWebhook
+	ErrorWebhookDeliveryNotFound  = api.ErrorWebhookDeliveryNotFound
+	ErrorWebhookDeliveryNotDead   = api.ErrorWebhookDeliveryNotDead
+	ErrorIdempotencyKeyInProgress = api.ErrorIdempotencyKeyInProgress
+	ErrorIdempotencyKeyReused     = api.ErrorIdempotencyKeyReused
+	ErrorNoCredentials            = api.ErrorNoCredentials
+	ErrorInvalidCredentials       = api.ErrorInvalidCredentials
+	ErrorForbidden                = api.ErrorForbidden
+)
+
+var knownErrors = []error{
+	ErrorUserNotFound, ErrorNotEnoughMoney, ErrorInvalidInput, ErrorNegativeAmount, ErrorInvalidSortParameters,
+	ErrorReserveNotFound, ErrorReserveAlreadyRecognized, ErrorReserveAlreadyDeReserved,
+	ErrorAccountAlreadyExists, ErrorAccountFrozen, ErrorAccountClosed, ErrorAccountNotEmpty, ErrorAccountHasReserves,
+	ErrorCreditLimitInUse, ErrorLimitExceeded, ErrorLimitsNotFound, ErrorInvalidLimits,
+	ErrorScheduleNotFound, ErrorInvalidSchedule, ErrorScheduleState,
+	ErrorWebhookNotFound, ErrorInvalidWebhook, ErrorWebhookDeliveryNotFound, ErrorWebhookDeliveryNotDead,
+	ErrorIdempotencyKeyInProgress, ErrorIdempotencyKeyReused,
+	ErrorNoCredentials, ErrorInvalidCredentials, ErrorForbidden,
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int
+	Message    string
+	err        error
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("balance service: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return "balance service: " + e.Message
+}
+
+// Unwrap returns the known error the response carries, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// decodeError recognizes the known errors by their message, which some
+// errors extend with details after a colon.
+func decodeError(status int, body []byte) error {
+	e := &Error{StatusCode: status, Message: strings.TrimSpace(string(body))}
+
+	for _, known := range knownErrors {
+		if e.Message == known.Error() || strings.HasPrefix(e.Message, known.Error()+":") {
+			e.err = known
+			break
+		}
+	}
+
+	return e
+}
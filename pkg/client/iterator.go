@@ -0,0 +1,60 @@
+package client
+
+import "context"
+
+// TransactionIterator pages through transactions:
+//
+//	it := c.Transactions(ctx, id, "date_asc", 100)
+//	for it.Next() {
+//		transaction := it.Transaction()
+//	}
+//	if err := it.Err(); err != nil {
+//
+// Pages are fetched by offset, so transactions added while iterating in
+// descending order shift the pages and may be seen twice.
+type TransactionIterator struct {
+	client   *Client
+	ctx      context.Context
+	id       string
+	sortType string
+	pageSize int
+
+	page    int
+	items   []Transaction
+	current Transaction
+	done    bool
+	err     error
+}
+
+// Next advances to the next transaction and reports whether there is one.
+func (it *TransactionIterator) Next() bool {
+	for len(it.items) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		it.page++
+
+		transactions, err := it.client.GetAllTransactions(it.ctx, it.id, it.sortType, it.pageSize, it.page)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.items = *transactions
+		it.done = len(it.items) < it.pageSize
+	}
+
+	it.current, it.items = it.items[0], it.items[1:]
+
+	return true
+}
+
+func (it *TransactionIterator) Transaction() Transaction {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *TransactionIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func (c *Client) schedule(ctx context.Context, method, path string, in interface{}) (*Schedule, error) {
+	var schedule Schedule
+
+	if err := c.call(ctx, method, path, in, &schedule); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+func (c *Client) CreateSchedule(ctx context.Context, query CreateScheduleQuery) (*Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, "/schedules", query)
+}
+
+func (c *Client) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	return c.schedule(ctx, http.MethodGet, "/schedules/"+url.PathEscape(id), nil)
+}
+
+func (c *Client) GetScheduleRuns(ctx context.Context, id string) (*[]ScheduleRun, error) {
+	runs := []ScheduleRun{}
+
+	if err := c.call(ctx, http.MethodGet, "/schedules/"+url.PathEscape(id)+"/runs", nil, &runs); err != nil {
+		return nil, err
+	}
+
+	return &runs, nil
+}
+
+func (c *Client) PauseSchedule(ctx context.Context, id string) (*Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, "/schedules/"+url.PathEscape(id)+"/pause", nil)
+}
+
+func (c *Client) ResumeSchedule(ctx context.Context, id string) (*Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, "/schedules/"+url.PathEscape(id)+"/resume", nil)
+}
+
+func (c *Client) CancelSchedule(ctx context.Context, id string) (*Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, "/schedules/"+url.PathEscape(id)+"/cancel", nil)
+}
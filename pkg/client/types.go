@@ -0,0 +1,36 @@
+package client
+
+import "github.com/siraj18/balance-service-new/internal/models"
+
+// The types exchanged with the service.
+type (
+	User                = models.User
+	Transaction         = models.Transaction
+	Reserve             = models.Reserve
+	Account             = models.Account
+	Metadata            = models.Metadata
+	Limits              = models.Limits
+	Schedule            = models.Schedule
+	ScheduleRun         = models.ScheduleRun
+	CreateScheduleQuery = models.CreateScheduleQuery
+	BatchOperation      = models.BatchOperation
+	BatchItemResult     = models.BatchItemResult
+	BatchResult         = models.BatchResult
+	ImportRow           = models.ImportRow
+	ImportLineError     = models.ImportLineError
+	ImportResult        = models.ImportResult
+	EventTypes          = models.EventTypes
+	WebhookSubscription = models.WebhookSubscription
+	CreatedWebhook      = models.CreatedWebhook
+	CreateWebhookQuery  = models.CreateWebhookQuery
+	WebhookDelivery     = models.WebhookDelivery
+)
+
+const (
+	BatchDeposit  = models.BatchDeposit
+	BatchWithdraw = models.BatchWithdraw
+	BatchTransfer = models.BatchTransfer
+	BatchReserve  = models.BatchReserve
+
+	AllEvents = models.AllEvents
+)
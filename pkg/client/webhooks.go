@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func (c *Client) webhook(ctx context.Context, method, path string, in interface{}) (*WebhookSubscription, error) {
+	var webhook WebhookSubscription
+
+	if err := c.call(ctx, method, path, in, &webhook); err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// CreateWebhook returns the only response carrying the secret of the
+// signatures.
+func (c *Client) CreateWebhook(ctx context.Context, query CreateWebhookQuery) (*CreatedWebhook, error) {
+	var webhook CreatedWebhook
+
+	if err := c.call(ctx, http.MethodPost, "/webhooks", query, &webhook); err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id string) (*WebhookSubscription, error) {
+	return c.webhook(ctx, http.MethodGet, "/webhooks/"+url.PathEscape(id), nil)
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id string) (*WebhookSubscription, error) {
+	return c.webhook(ctx, http.MethodDelete, "/webhooks/"+url.PathEscape(id), nil)
+}
+
+// GetWebhookDeliveries lists the deliveries of a subscription, all of them
+// when status is empty.
+func (c *Client) GetWebhookDeliveries(ctx context.Context, id, status string) (*[]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+
+	path := "/webhooks/" + url.PathEscape(id) + "/deliveries"
+	if status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+
+	if err := c.call(ctx, http.MethodGet, path, nil, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return &deliveries, nil
+}
+
+func (c *Client) RetryWebhookDelivery(ctx context.Context, id, deliveryId string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+
+	path := "/webhooks/" + url.PathEscape(id) + "/deliveries/" + url.PathEscape(deliveryId) + "/retry"
+	if err := c.call(ctx, http.MethodPost, path, nil, &delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
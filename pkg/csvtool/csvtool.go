@@ -3,13 +3,17 @@ package csvtool
 import (
 	"encoding/csv"
 	"github.com/google/uuid"
+	"io"
 	"os"
+	"path/filepath"
 )
 
+const comma = ';'
+
 func CreateFile(data [][]string, folder string) (string, error) {
 	fileId := uuid.New()
 
-	file, err := os.Create(folder + fileId.String() + ".csv")
+	file, err := os.Create(filepath.Join(folder, fileId.String()+".csv"))
 	defer file.Close()
 	if err != nil {
 		return "", err
@@ -17,7 +21,7 @@ func CreateFile(data [][]string, folder string) (string, error) {
 
 	writer := csv.NewWriter(file)
 
-	writer.Comma = ';'
+	writer.Comma = comma
 
 	err = writer.WriteAll(data)
 	if err != nil {
@@ -26,3 +30,24 @@ func CreateFile(data [][]string, folder string) (string, error) {
 
 	return fileId.String(), nil
 }
+
+// ReadAll reads every record of r in the same dialect CreateFile writes.
+// Records may have different numbers of fields.
+func ReadAll(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	return reader.ReadAll()
+}
+
+// WriteAll writes records to w in the dialect ReadAll reads.
+func WriteAll(w io.Writer, records [][]string) error {
+	writer := csv.NewWriter(w)
+
+	writer.Comma = comma
+
+	return writer.WriteAll(records)
+}
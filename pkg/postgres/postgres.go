@@ -1,34 +1,215 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"strconv"
 	"time"
 
-	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 )
 
-func NewDb(conStr string, retries int) (*sqlx.DB, error) {
+// statementCacheSize is how many prepared statements every connection of a
+// pool keeps.
+const statementCacheSize = 512
+
+// The waits between attempts to connect unless WithBackoff is given.
+const (
+	defaultBackoff    = 2 * time.Second
+	defaultMaxBackoff = 30 * time.Second
+)
+
+type options struct {
+	maxOpenConns     int
+	maxIdleConns     int
+	connMaxLifetime  time.Duration
+	statementTimeout time.Duration
+	backoff          time.Duration
+	maxBackoff       time.Duration
+	wrapConnector    func(driver.Connector) driver.Connector
+	onRetry          func(err error)
+}
+
+type Option func(*options)
+
+func newOptions(opts []Option) *options {
+	o := &options{maxIdleConns: 2, backoff: defaultBackoff, maxBackoff: defaultMaxBackoff}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithMaxOpenConns bounds the connections of the pool, zero leaves it
+// unbounded.
+func WithMaxOpenConns(n int) Option {
+	return func(o *options) {
+		o.maxOpenConns = n
+	}
+}
+
+// WithMaxIdleConns keeps up to n connections open between queries instead of
+// the two kept by database/sql.
+func WithMaxIdleConns(n int) Option {
+	return func(o *options) {
+		o.maxIdleConns = n
+	}
+}
+
+// WithConnMaxLifetime reopens connections older than d, so that the pool
+// follows failovers and rebalanced poolers. Zero keeps connections forever.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.connMaxLifetime = d
+	}
+}
+
+// WithStatementTimeout makes the server cancel the statements running longer
+// than d. Zero leaves the timeout of the server.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.statementTimeout = d
+	}
+}
+
+// WithBackoff waits initial after the first failed attempt to connect and
+// doubles the wait after every next one, up to max.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(o *options) {
+		o.backoff = initial
+		o.maxBackoff = max
+	}
+}
+
+// WithConnector makes the db of NewDb open its connections through the
+// connector wrap returns, for example to trace its statements.
+func WithConnector(wrap func(driver.Connector) driver.Connector) Option {
+	return func(o *options) {
+		o.wrapConnector = wrap
+	}
+}
+
+// WithOnRetry calls onRetry with the error of every failed attempt to connect
+// that is followed by another one, for example to count them.
+func WithOnRetry(onRetry func(err error)) Option {
+	return func(o *options) {
+		o.onRetry = onRetry
+	}
+}
+
+// NewDb connects to conStr, trying up to retries times.
+func NewDb(conStr string, retries int, opts ...Option) (*sqlx.DB, error) {
+	o := newOptions(opts)
+
+	config, err := pgx.ParseConfig(conStr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+
+	if o.statementTimeout > 0 {
+		config.RuntimeParams["statement_timeout"] = strconv.FormatInt(o.statementTimeout.Milliseconds(), 10)
+	}
+
+	connector := stdlib.GetConnector(*config)
+	if o.wrapConnector != nil {
+		connector = o.wrapConnector(connector)
+	}
+
+	db := sqlx.NewDb(sql.OpenDB(connector), "pgx")
+	db.SetMaxOpenConns(o.maxOpenConns)
+	db.SetMaxIdleConns(o.maxIdleConns)
+	db.SetConnMaxLifetime(o.connMaxLifetime)
+
+	if err = retry(func() error { return db.Ping() }, retries, o); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+
+	return db, nil
+}
+
+// NewPool connects a native pgx pool to conStr, trying up to retries times.
+// The statements run on its connections are prepared once per connection and
+// cached, so that repeated queries are not parsed and planned again. It
+// ignores WithConnector.
+func NewPool(conStr string, retries int, opts ...Option) (*pgxpool.Pool, error) {
+	o := newOptions(opts)
+
+	config, err := pgxpool.ParseConfig(conStr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+
+	if o.statementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(o.statementTimeout.Milliseconds(), 10)
+	}
+	config.ConnConfig.BuildStatementCache = func(conn *pgconn.PgConn) stmtcache.Cache {
+		return stmtcache.New(conn, stmtcache.ModePrepare, statementCacheSize)
+	}
+	if o.maxOpenConns > 0 {
+		config.MaxConns = int32(o.maxOpenConns)
+	}
+	if o.connMaxLifetime > 0 {
+		config.MaxConnLifetime = o.connMaxLifetime
+	}
+
+	var pool *pgxpool.Pool
+	err = retry(func() error {
+		pool, err = pgxpool.ConnectConfig(context.Background(), config)
+		return err
+	}, retries, o)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+
+	return pool, nil
+}
+
+// retry runs connect up to retries times, backing off between the attempts.
+func retry(connect func() error, retries int, o *options) error {
 	var err error
-	var db *sqlx.DB
 
 	for i := 0; i < retries; i++ {
-		db, err = sqlx.Connect("pgx", conStr)
-		if err != nil {
-			logrus.Error("unable to connect db:%w", err)
-			time.Sleep(time.Second * 2)
-		} else if err = db.Ping(); err != nil {
-			logrus.Error("an error occurred when ping database: %w", err)
-			time.Sleep(time.Second * 2)
-		} else {
-			err = nil
+		if err = connect(); err == nil {
+			return nil
+		}
+
+		if i == retries-1 {
 			break
 		}
+
+		if o.onRetry != nil {
+			o.onRetry(err)
+		}
+
+		wait := backoff(i, o.backoff, o.maxBackoff)
+		logrus.Errorf("an error occurred when ping database, retrying in %s: %s", wait, err)
+		time.Sleep(wait)
 	}
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to the database: %w", err)
+
+	return err
+}
+
+// backoff returns the wait after the failed attempt number attempt, counted
+// from zero.
+func backoff(attempt int, initial, max time.Duration) time.Duration {
+	wait := initial
+	for i := 0; i < attempt && wait < max; i++ {
+		wait *= 2
 	}
 
-	return db, nil
+	if wait > max {
+		return max
+	}
+
+	return wait
 }
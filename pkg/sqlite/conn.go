@@ -0,0 +1,51 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// connector opens the connections of NewDb, which bind times in UTC.
+type connector struct {
+	dsn string
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &utcConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// utcConn converts the times bound to its statements to UTC. The driver
+// stores a time with its own offset, and times of different offsets do not
+// compare as text.
+type utcConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *utcConn) CheckNamedValue(value *driver.NamedValue) error {
+	converted, err := driver.DefaultParameterConverter.ConvertValue(value.Value)
+	if err != nil {
+		return err
+	}
+
+	if t, ok := converted.(time.Time); ok {
+		converted = t.UTC()
+	}
+
+	value.Value = converted
+
+	return nil
+}
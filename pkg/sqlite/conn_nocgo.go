@@ -0,0 +1,24 @@
+//go:build !cgo
+
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// connector opens the stub the driver is built as without cgo, which fails
+// with an error saying so.
+type connector struct {
+	dsn string
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
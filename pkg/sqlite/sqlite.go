@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type options struct {
+	maxOpenConns int
+	busyTimeout  time.Duration
+}
+
+type Option func(*options)
+
+// WithMaxOpenConns bounds the connections of the pool, zero leaves it
+// unbounded. Writers are serialized by the database whatever the bound.
+func WithMaxOpenConns(n int) Option {
+	return func(o *options) {
+		o.maxOpenConns = n
+	}
+}
+
+// WithBusyTimeout makes a transaction wait up to d for the writer before it
+// fails with a busy error, instead of the five seconds waited by default.
+func WithBusyTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.busyTimeout = d
+	}
+}
+
+// NewDb opens the database file at path, creating it when it does not exist.
+//
+// Every transaction takes the write lock when it begins, so that the
+// read-modify-write transactions of the repository cannot interleave, which
+// Postgres achieves with row locks. The journal is kept in WAL mode, so reads
+// outside transactions do not wait for the writer. The times bound to
+// statements are stored in UTC, so that they sort as text.
+//
+// The driver needs cgo, without it NewDb always fails.
+func NewDb(path string, opts ...Option) (*sqlx.DB, error) {
+	o := &options{busyTimeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Set("_foreign_keys", "1")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", strconv.FormatInt(o.busyTimeout.Milliseconds(), 10))
+
+	db := sqlx.NewDb(sql.OpenDB(&connector{dsn: "file:" + path + "?" + params.Encode()}), "sqlite3")
+	db.SetMaxOpenConns(o.maxOpenConns)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening the database: %w", err)
+	}
+
+	return db, nil
+}